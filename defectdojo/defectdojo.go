@@ -0,0 +1,93 @@
+package defectdojo
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ScanType is the DefectDojo parser for reports written by logging.WriteDefectDojo.
+const ScanType = "Generic Findings Import"
+
+// Client talks to a DefectDojo instance's v2 API with an API token.
+type Client struct {
+	BaseURL    string // e.g. https://defectdojo.example.com
+	Token      string
+	HTTPClient *http.Client
+}
+
+// CheckEngagement verifies that the token is accepted and the engagement exists, so
+// credential problems show up before a long scan rather than after it.
+func (c Client) CheckEngagement(ctx context.Context, engagement int) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint(fmt.Sprintf("engagements/%d/", engagement)), nil)
+	if err != nil {
+		return err
+	}
+	return c.do(req)
+}
+
+// ImportScan uploads report as a new test in the engagement.
+func (c Client) ImportScan(ctx context.Context, engagement int, report []byte) error {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fields := map[string]string{
+		"scan_type":          ScanType,
+		"engagement":         strconv.Itoa(engagement),
+		"active":             "true",
+		"verified":           "false",
+		"minimum_severity":   "Info",
+		"close_old_findings": "false",
+	}
+	for k, v := range fields {
+		if err := mw.WriteField(k, v); err != nil {
+			return err
+		}
+	}
+	fw, err := mw.CreateFormFile("file", "aperture-defectdojo.json")
+	if err != nil {
+		return err
+	}
+	if _, err := fw.Write(report); err != nil {
+		return err
+	}
+	if err := mw.Close(); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint("import-scan/"), &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return c.do(req)
+}
+
+func (c Client) endpoint(path string) string {
+	return strings.TrimRight(c.BaseURL, "/") + "/api/v2/" + path
+}
+
+func (c Client) do(req *http.Request) error {
+	req.Header.Set("Authorization", "Token "+c.Token)
+	req.Header.Set("Accept", "application/json")
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("%s %s: token rejected (status %d)", req.Method, req.URL.Path, resp.StatusCode)
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("%s %s: status %d: %s", req.Method, req.URL.Path, resp.StatusCode, strings.TrimSpace(string(b)))
+	}
+	return nil
+}
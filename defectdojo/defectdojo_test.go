@@ -0,0 +1,91 @@
+package defectdojo
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestImportScan(t *testing.T) {
+	var fields map[string]string
+	var file string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost || req.URL.Path != "/api/v2/import-scan/" {
+			t.Errorf("request = %s %s, want POST /api/v2/import-scan/", req.Method, req.URL.Path)
+		}
+		if got := req.Header.Get("Authorization"); got != "Token secret" {
+			t.Errorf("Authorization = %q", got)
+		}
+		if err := req.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("parse form: %v", err)
+			return
+		}
+		fields = map[string]string{}
+		for k, v := range req.MultipartForm.Value {
+			fields[k] = v[0]
+		}
+		if fh := req.MultipartForm.File["file"]; len(fh) == 1 {
+			f, _ := fh[0].Open()
+			b, _ := io.ReadAll(f)
+			f.Close()
+			file = string(b)
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	c := Client{BaseURL: srv.URL + "/", Token: "secret"}
+	if err := c.ImportScan(context.Background(), 7, []byte(`{"findings": []}`)); err != nil {
+		t.Fatalf("ImportScan: %v", err)
+	}
+	want := map[string]string{
+		"scan_type":          ScanType,
+		"engagement":         "7",
+		"active":             "true",
+		"verified":           "false",
+		"minimum_severity":   "Info",
+		"close_old_findings": "false",
+	}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("fields = %v, want %v", fields, want)
+	}
+	if file != `{"findings": []}` {
+		t.Errorf("file = %q", file)
+	}
+}
+
+func TestCheckEngagement(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch {
+		case req.Header.Get("Authorization") != "Token good":
+			http.Error(w, `{"detail": "Invalid token."}`, http.StatusUnauthorized)
+		case req.URL.Path == "/api/v2/engagements/7/":
+			w.Write([]byte(`{"id": 7}`))
+		default:
+			http.Error(w, `{"detail": "Not found."}`, http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	for _, tt := range []struct {
+		token      string
+		engagement int
+		want       string // error substring, "" for none
+	}{
+		{"good", 7, ""},
+		{"bad", 7, "token rejected (status 401)"},
+		{"good", 8, `status 404: {"detail": "Not found."}`},
+	} {
+		err := Client{BaseURL: srv.URL, Token: tt.token}.CheckEngagement(context.Background(), tt.engagement)
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("token %s, engagement %d: %v", tt.token, tt.engagement, err)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("token %s, engagement %d: err = %v, want %q", tt.token, tt.engagement, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package logging
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/yansol0/aperture/runner"
+)
+
+// Baseline holds the findings of an earlier run, so a run can report only the findings
+// introduced since. A finding is matched by method, endpoint, attacker (the test's
+// credentials) and object owner, whatever its verdict or confidence.
+type Baseline map[string]bool
+
+func baselineKey(rl runner.ResultLog) string {
+	return strings.Join([]string{strings.ToUpper(rl.Method), rl.Endpoint, rl.Test.Request.AuthUser, rl.Control.Request.AuthUser}, "\x00")
+}
+
+func isFinding(rl runner.ResultLog) bool {
+	return rl.Result == runner.ResultIDORFound || rl.Result == runner.ResultPotential
+}
+
+// ReadBaseline reads the IDOR FOUND and POTENTIAL results from JSON Lines results, as
+// written with --jsonl or by WriteBaseline. Other results are ignored.
+func ReadBaseline(r io.Reader) (Baseline, error) {
+	b := Baseline{}
+	dec := json.NewDecoder(r)
+	for n := 1; ; n++ {
+		var rl runner.ResultLog
+		err := dec.Decode(&rl)
+		if errors.Is(err, io.EOF) {
+			return b, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("result %d: %w", n, err)
+		}
+		if isFinding(rl) {
+			b[baselineKey(rl)] = true
+		}
+	}
+}
+
+// Mark sets InBaseline on the findings among results that the baseline already has and
+// returns how many IDOR FOUND results are new.
+func (b Baseline) Mark(results []runner.ResultLog) int {
+	fresh := 0
+	for i := range results {
+		if !isFinding(results[i]) {
+			continue
+		}
+		if b[baselineKey(results[i])] {
+			results[i].InBaseline = true
+		} else if results[i].Result == runner.ResultIDORFound {
+			fresh++
+		}
+	}
+	return fresh
+}
+
+// WriteBaseline writes the findings among results as JSON Lines, the next run's baseline.
+// Findings no longer reported are dropped from it.
+func WriteBaseline(w io.Writer, results []runner.ResultLog) error {
+	var findings []runner.ResultLog
+	for _, rl := range results {
+		if isFinding(rl) {
+			rl.InBaseline = false
+			findings = append(findings, rl)
+		}
+	}
+	return WriteJSONL(w, findings)
+}
+
+// countBaselineKnown returns how many finding pairs the baseline already had.
+func countBaselineKnown(results []runner.ResultLog) int {
+	n := 0
+	for _, rl := range results {
+		if rl.InBaseline {
+			n++
+		}
+	}
+	return n
+}
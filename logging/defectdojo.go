@@ -0,0 +1,125 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yansol0/aperture/runner"
+)
+
+// evidenceBodyLimit caps how much of each response body is quoted in a finding description.
+const evidenceBodyLimit = 2000
+
+// cweIDOR is CWE-639, Authorization Bypass Through User-Controlled Key.
+const cweIDOR = 639
+
+type ddReport struct {
+	Findings []ddFinding `json:"findings"`
+}
+
+type ddFinding struct {
+	Title            string       `json:"title"`
+	Severity         string       `json:"severity"`
+	Description      string       `json:"description"`
+	Mitigation       string       `json:"mitigation"`
+	Date             string       `json:"date"`
+	CWE              int          `json:"cwe"`
+	UniqueIDFromTool string       `json:"unique_id_from_tool"`
+	Active           bool         `json:"active"`
+	Verified         bool         `json:"verified"`
+	DynamicFinding   bool         `json:"dynamic_finding"`
+	StaticFinding    bool         `json:"static_finding"`
+	Endpoints        []ddEndpoint `json:"endpoints,omitempty"`
+}
+
+type ddEndpoint struct {
+	Protocol string `json:"protocol"`
+	Host     string `json:"host"`
+	Port     int    `json:"port,omitempty"`
+	Path     string `json:"path,omitempty"`
+	Query    string `json:"query,omitempty"`
+}
+
+// WriteDefectDojo writes IDOR FOUND (High) and POTENTIAL (Medium) results in DefectDojo's
+// Generic Findings Import JSON format, dated date. Each finding carries the control and test
+// exchanges as evidence and the result's stable ID as unique_id_from_tool.
+func WriteDefectDojo(w io.Writer, results []runner.ResultLog, date time.Time) error {
+	report := ddReport{Findings: []ddFinding{}}
+	for _, rl := range results {
+		var severity string
+		switch rl.Result {
+		case runner.ResultIDORFound:
+			severity = "High"
+		case runner.ResultPotential:
+			severity = "Medium"
+		default:
+			continue
+		}
+		f := ddFinding{
+			Title:            fmt.Sprintf("IDOR: %s %s (creds=%s, object=%s)", rl.Method, rl.Endpoint, rl.Test.Request.AuthUser, rl.Control.Request.AuthUser),
+			Severity:         severity,
+			Description:      ddDescription(rl),
+			Mitigation:       "Check that the authenticated user owns or may access the referenced object before acting on it.",
+			Date:             date.Format("2006-01-02"),
+			CWE:              cweIDOR,
+			UniqueIDFromTool: rl.ID(),
+			Active:           true,
+			Verified:         rl.WriteVerified,
+			DynamicFinding:   true,
+		}
+		if ep, ok := ddEndpointFor(rl.Test.Request.URL); ok {
+			f.Endpoints = []ddEndpoint{ep}
+		}
+		report.Findings = append(report.Findings, f)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// ddDescription is the Markdown description of a finding: verdict, notes and evidence.
+func ddDescription(rl runner.ResultLog) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s with confidence %.2f: user **%s** accessed an object of user **%s**.\n\n", rl.Result, rl.Confidence, rl.Test.Request.AuthUser, rl.Control.Request.AuthUser)
+	if op := operationLabel(rl.OperationID, rl.Tags, rl.Summary); op != "" {
+		fmt.Fprintf(&b, "Operation: %s\n\n", op)
+	}
+	for _, n := range rl.Notes {
+		fmt.Fprintf(&b, "- %s\n", n)
+	}
+	for _, part := range []struct {
+		label string
+		ex    runner.Exchange
+	}{{"Control", rl.Control}, {"Test", rl.Test}} {
+		body := part.ex.Response.FullBody()
+		if len(body) > evidenceBodyLimit {
+			body = body[:evidenceBodyLimit] + "\n… (truncated)"
+		}
+		fmt.Fprintf(&b, "\n**%s** (creds=%s)\n\n```\n%s %s\n```\n\nResponse status %d:\n\n```\n%s\n```\n",
+			part.label, part.ex.Request.AuthUser, part.ex.Request.Method, part.ex.Request.URL, part.ex.Response.Status, body)
+	}
+	return b.String()
+}
+
+// ddEndpointFor splits a request URL into DefectDojo's endpoint fields.
+func ddEndpointFor(raw string) (ddEndpoint, bool) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return ddEndpoint{}, false
+	}
+	ep := ddEndpoint{
+		Protocol: u.Scheme,
+		Host:     u.Hostname(),
+		Path:     strings.TrimPrefix(u.Path, "/"),
+		Query:    u.RawQuery,
+	}
+	if p, err := strconv.Atoi(u.Port()); err == nil {
+		ep.Port = p
+	}
+	return ep, true
+}
@@ -0,0 +1,64 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/yansol0/aperture/runner"
+)
+
+func TestWriteDefectDojo(t *testing.T) {
+	exchange := func(user, url string) runner.Exchange {
+		return runner.Exchange{
+			Request:  runner.RequestDetails{Method: "GET", URL: url, AuthUser: user},
+			Response: runner.ResponseDetails{Status: 200, Body: `{"id": 1}`},
+		}
+	}
+	results := []runner.ResultLog{
+		{Endpoint: "/notes/{note_id}", Method: "GET", Result: runner.ResultIDORFound,
+			Control: exchange("alice", "https://api.test:8443/v1/notes/1?full=true"),
+			Test:    exchange("bob", "https://api.test:8443/v1/notes/1?full=true")},
+		{Endpoint: "/notes/{note_id}", Method: "GET", Result: runner.ResultSecure,
+			Control: exchange("alice", "http://api.test/notes/1"),
+			Test:    exchange("carol", "http://api.test/notes/1")},
+		{Endpoint: "/files/{file_id}", Method: "GET", Result: runner.ResultPotential,
+			Control: exchange("bob", "http://api.test/files/2"),
+			Test:    exchange("alice", "http://api.test/files/2")},
+	}
+	var buf bytes.Buffer
+	if err := WriteDefectDojo(&buf, results, time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("WriteDefectDojo: %v", err)
+	}
+	var report ddReport
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("parse report: %v\n%s", err, buf.Bytes())
+	}
+	if len(report.Findings) != 2 {
+		t.Fatalf("%d findings, want 2 (SECURE is left out)", len(report.Findings))
+	}
+	for i, want := range []struct {
+		result   runner.ResultLog
+		severity string
+		endpoint ddEndpoint
+	}{
+		{results[0], "High", ddEndpoint{Protocol: "https", Host: "api.test", Port: 8443, Path: "v1/notes/1", Query: "full=true"}},
+		{results[2], "Medium", ddEndpoint{Protocol: "http", Host: "api.test", Path: "files/2"}},
+	} {
+		f := report.Findings[i]
+		if f.Severity != want.severity {
+			t.Errorf("finding %d severity = %q, want %q", i, f.Severity, want.severity)
+		}
+		if f.UniqueIDFromTool != want.result.ID() {
+			t.Errorf("finding %d unique_id_from_tool = %q, want %q", i, f.UniqueIDFromTool, want.result.ID())
+		}
+		if !reflect.DeepEqual(f.Endpoints, []ddEndpoint{want.endpoint}) {
+			t.Errorf("finding %d endpoints = %+v, want %+v", i, f.Endpoints, want.endpoint)
+		}
+		if f.Date != "2024-05-01" || f.CWE != cweIDOR {
+			t.Errorf("finding %d date = %q, cwe = %d", i, f.Date, f.CWE)
+		}
+	}
+}
@@ -2,75 +2,263 @@ package logging
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"os"
 	"sort"
 	"strings"
+	"time"
 
+	"github.com/charmbracelet/lipgloss"
 	"github.com/yansol0/aperture/runner"
+	"github.com/yansol0/aperture/theme"
 )
 
+// Output formats accepted by WriteResults.
+const (
+	FormatText  = "text"
+	FormatJSONL = "jsonl"
+)
+
+// WriteResults writes results to w in the given format. It is the single entry point for
+// result files so every caller produces identical output for a format.
+func WriteResults(w io.Writer, format string, results []runner.ResultLog, baseURL string) error {
+	switch format {
+	case FormatText:
+		return WriteText(w, results, baseURL)
+	case FormatJSONL:
+		return WriteJSONL(w, results)
+	}
+	return fmt.Errorf("unknown output format %q", format)
+}
+
 // WriteText writes results in a human-readable HTTP exchange format to the provided writer.
+// Results are grouped by endpoint (path, then method) under a header line, and a table of
+// contents with each endpoint's verdict counts and starting line comes first. Endpoints
+// whose results were all skipped are collapsed to a single line.
 func WriteText(w io.Writer, results []runner.ResultLog, baseURL string) error {
-	bw := bufio.NewWriter(w)
-	for _, rl := range results {
-		// Skipped entries: single simplified block
-		if rl.Result == runner.ResultSkipped {
-			if err := writeSeparator(bw); err != nil {
-				return err
-			}
-			if _, err := fmt.Fprintln(bw, "Request:"); err != nil {
-				return err
-			}
-			if _, err := fmt.Fprintln(bw, "--"); err != nil {
-				return err
-			}
-			if _, err := fmt.Fprintln(bw); err != nil {
-				return err
-			}
-			fullURL := strings.TrimRight(baseURL, "/") + rl.Endpoint
-			reason := strings.TrimSpace(rl.SkippedReason)
-			if reason == "" && len(rl.Notes) > 0 {
-				reason = strings.TrimSpace(rl.Notes[0])
-			}
-			if _, err := fmt.Fprintf(bw, "%s - skipped - %s\n\n", fullURL, reason); err != nil {
-				return err
-			}
-			if err := writeSeparator(bw); err != nil {
+	groups := groupByEndpoint(results)
+
+	// The body is streamed to a temp file first so the table of contents can point at
+	// line numbers without holding every exchange in memory
+	tmp, err := os.CreateTemp("", "aperture-log-*.txt")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	lines := &lineCounter{w: tmp}
+	bw := bufio.NewWriter(lines)
+	starts := make([]int, len(groups))
+	for i, g := range groups {
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+		starts[i] = lines.n
+		if g.skippedOnly() {
+			if _, err := fmt.Fprintf(bw, "## %s %s - skipped - %s\n\n", g.method, g.endpoint, skipReason(g.results[0])); err != nil {
 				return err
 			}
 			continue
 		}
-
-		// Write control exchange if present
-		if rl.Control.Request.URL != "" || rl.Control.Request.Method != "" {
-			if err := writeSeparator(bw); err != nil {
-				return err
-			}
-			if err := writeExchange(bw, rl.Control); err != nil {
-				return err
-			}
-			if err := writeSeparator(bw); err != nil {
-				return err
-			}
+		if _, err := fmt.Fprintf(bw, "## %s %s [%s]\n", g.method, g.endpoint, g.counts()); err != nil {
+			return err
 		}
-		// Write test exchange if present
-		if rl.Test.Request.URL != "" || rl.Test.Request.Method != "" {
-			if err := writeSeparator(bw); err != nil {
-				return err
-			}
-			if err := writeExchange(bw, rl.Test); err != nil {
+		first := g.results[0]
+		if op := operationLabel(first.OperationID, first.Tags, first.Summary); op != "" {
+			if _, err := fmt.Fprintf(bw, "Operation: %s\n", op); err != nil {
 				return err
 			}
-			if err := writeSeparator(bw); err != nil {
+		}
+		if err := bw.WriteByte('\n'); err != nil {
+			return err
+		}
+		for _, rl := range g.results {
+			if err := writeResult(bw, rl, baseURL); err != nil {
 				return err
 			}
 		}
 	}
-	return bw.Flush()
+	// Trailer identifying the build that produced the log
+	if _, err := fmt.Fprintf(bw, "Generated by aperture %s\n", runner.Version()); err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+
+	out := bufio.NewWriter(w)
+	// Title, one line per endpoint and a blank line precede the body
+	tocLines := len(groups) + 2
+	if _, err := fmt.Fprintf(out, "Contents (%d endpoints):\n", len(groups)); err != nil {
+		return err
+	}
+	for i, g := range groups {
+		if _, err := fmt.Fprintf(out, "  line %-6d %s %s [%s]\n", tocLines+starts[i]+1, g.method, g.endpoint, g.counts()); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintln(out); err != nil {
+		return err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, tmp); err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+// lineCounter passes writes through to w, counting the newlines written.
+type lineCounter struct {
+	w io.Writer
+	n int
+}
+
+func (c *lineCounter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += bytes.Count(p[:n], []byte("\n"))
+	return n, err
+}
+
+// operationLabel describes a spec operation as "id (tags: a, b) - summary", leaving out
+// missing parts; it is empty when the operation has none of them.
+func operationLabel(id string, tags []string, summary string) string {
+	var parts []string
+	if id != "" {
+		parts = append(parts, id)
+	}
+	if len(tags) > 0 {
+		parts = append(parts, "(tags: "+strings.Join(tags, ", ")+")")
+	}
+	label := strings.Join(parts, " ")
+	if summary != "" {
+		if label != "" {
+			label += " - "
+		}
+		label += summary
+	}
+	return label
+}
+
+// writeResult writes one result: a simplified block for skipped entries, otherwise the
+// control and test exchanges that were sent.
+func writeResult(bw *bufio.Writer, rl runner.ResultLog, baseURL string) error {
+	// Skipped entries: single simplified block
+	if rl.Result == runner.ResultSkipped {
+		if err := writeSeparator(bw); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(bw, "Request:"); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(bw, "--"); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(bw); err != nil {
+			return err
+		}
+		fullURL := strings.TrimRight(baseURL, "/") + rl.Endpoint
+		if _, err := fmt.Fprintf(bw, "%s - skipped - %s\n\n", fullURL, skipReason(rl)); err != nil {
+			return err
+		}
+		return writeSeparator(bw)
+	}
+
+	// Write control exchange if present
+	if rl.Control.Request.URL != "" || rl.Control.Request.Method != "" {
+		if err := writeSeparator(bw); err != nil {
+			return err
+		}
+		if err := writeExchange(bw, rl.Control); err != nil {
+			return err
+		}
+		if err := writeSeparator(bw); err != nil {
+			return err
+		}
+	}
+	// Write test exchange if present
+	if rl.Test.Request.URL != "" || rl.Test.Request.Method != "" {
+		if err := writeSeparator(bw); err != nil {
+			return err
+		}
+		if err := writeExchange(bw, rl.Test); err != nil {
+			return err
+		}
+		if err := writeSeparator(bw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// skipReason returns why a result was skipped, falling back to its first note.
+func skipReason(rl runner.ResultLog) string {
+	reason := strings.TrimSpace(rl.SkippedReason)
+	if reason == "" && len(rl.Notes) > 0 {
+		reason = strings.TrimSpace(rl.Notes[0])
+	}
+	return reason
+}
+
+// endpointGroup is the results of one method and path in the text log.
+type endpointGroup struct {
+	endpoint string
+	method   string
+	results  []runner.ResultLog
+}
+
+// groupByEndpoint groups results by method and path, sorted by path then method, keeping
+// run order within each group.
+func groupByEndpoint(results []runner.ResultLog) []*endpointGroup {
+	index := map[string]*endpointGroup{}
+	var groups []*endpointGroup
+	for _, rl := range results {
+		key := rl.Method + " " + rl.Endpoint
+		g, ok := index[key]
+		if !ok {
+			g = &endpointGroup{endpoint: rl.Endpoint, method: rl.Method}
+			index[key] = g
+			groups = append(groups, g)
+		}
+		g.results = append(g.results, rl)
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groups[i].endpoint != groups[j].endpoint {
+			return groups[i].endpoint < groups[j].endpoint
+		}
+		return groups[i].method < groups[j].method
+	})
+	return groups
+}
+
+func (g *endpointGroup) skippedOnly() bool {
+	for _, rl := range g.results {
+		if rl.Result != runner.ResultSkipped {
+			return false
+		}
+	}
+	return true
+}
+
+// counts formats the group's non-zero verdict counts, e.g. "IDOR FOUND 2, SECURE 1".
+func (g *endpointGroup) counts() string {
+	n := map[string]int{}
+	for _, rl := range g.results {
+		n[rl.Result]++
+	}
+	var parts []string
+	for _, v := range summaryVerdicts {
+		if n[v] > 0 {
+			parts = append(parts, fmt.Sprintf("%s %d", v, n[v]))
+		}
+	}
+	return strings.Join(parts, ", ")
 }
 
 // WriteJSONL writes results as JSON Lines to the provided writer.
@@ -84,17 +272,491 @@ func WriteJSONL(w io.Writer, results []runner.ResultLog) error {
 	return nil
 }
 
-// PrintSummary prints a concise console summary of findings.
-func PrintSummary(results []runner.ResultLog, testedEndpoints int) {
-	var found int
+// RunStats are the run-wide figures reported at the end of the console summary.
+type RunStats struct {
+	TestedEndpoints int
+	Requests        int
+	Elapsed         time.Duration
+	AuthRefreshes   map[string]int // successful auth refreshes per user
+	// Traffic is the bytes exchanged with the target, see runner.Runner.Traffic.
+	Traffic runner.TrafficStats
+	// Concurrency is each host's range of effective concurrency in an adaptive run, see
+	// runner.Runner.EffectiveConcurrency.
+	Concurrency map[string]runner.ConcurrencyRange
+	// FindingsBy is how findings are deduplicated: GroupByEndpoint (default) or GroupByVictim.
+	FindingsBy string
+	// Preflight is the pre-scan check, when one ran.
+	Preflight *runner.PreflightReport
+	// Pacing describes the endpoint and user delays and concurrency caps in effect, see
+	// Runner.PacingOverrides.
+	Pacing []string
+	// SelfTest is the pre-scan baseline comparison, when one ran.
+	SelfTest *runner.SelfTestReport
+}
+
+// Finding grouping keys for RunStats.FindingsBy. Either way a finding is one method, path
+// and verdict; GroupByVictim also splits it per object owner, for endpoints where only
+// some users' objects are exposed.
+const (
+	GroupByEndpoint = "endpoint"
+	GroupByVictim   = "victim"
+)
+
+// summaryVerdicts is the order verdict counts are listed in the console summary.
+var summaryVerdicts = []string{
+	runner.ResultIDORFound,
+	runner.ResultPotential,
+	runner.ResultSecure,
+	runner.ResultControlFailed,
+	runner.ResultError,
+	runner.ResultSkipped,
+}
+
+// maxSkipReasons caps how many skip reasons without a flag hint the console summary lists.
+const maxSkipReasons = 5
+
+// PrintSummary writes a concise console summary to w: counts per verdict, IDOR and
+// potential findings grouped by endpoint with the pairs behind them, the most common
+// skip reasons and run totals. Colors follow the configured theme.
+func PrintSummary(w io.Writer, results []runner.ResultLog, stats RunStats) {
+	th := theme.Current()
+	counts := map[string]int{}
+	for _, rl := range results {
+		counts[rl.Result]++
+	}
+	parts := make([]string, 0, len(summaryVerdicts))
+	for _, v := range summaryVerdicts {
+		parts = append(parts, lipgloss.NewStyle().Foreground(th.Verdict(v)).Render(fmt.Sprintf("%s %d", v, counts[v])))
+	}
+	fmt.Fprintf(w, "Verdicts: %s\n", strings.Join(parts, "  "))
+	findings := groupFindings(results, stats.FindingsBy)
+	printFindings(w, findings)
+	if known := countBaselineKnown(results); known > 0 {
+		fmt.Fprintf(w, "Baseline: %d known finding %s not listed\n", known, plural(known, "pair", "pairs"))
+	}
+	idor, potential := countFindings(findings)
+	printSkipReasons(w, results)
+	printCleanupErrors(w, results)
+	printVersionFamilies(w, results)
+	printMethodInconsistencies(w, results)
+	printAuthRefreshes(w, stats.AuthRefreshes)
+	printTraffic(w, stats.Traffic)
+	printConcurrency(w, stats.Concurrency)
+	printServerErrors(w, runner.ServerErrors(results))
+	printPreflight(w, stats.Preflight)
+	printSelfTest(w, stats.SelfTest)
+	if len(stats.Pacing) > 0 {
+		fmt.Fprintf(w, "Pacing: %s\n", strings.Join(stats.Pacing, "; "))
+	}
+	fmt.Fprintf(w, "Completed in %s: %d endpoints tested, %d requests sent, %d IDOR findings (%d pairs), %d potential (%d pairs).\n",
+		stats.Elapsed.Round(time.Second), stats.TestedEndpoints, stats.Requests,
+		idor, counts[runner.ResultIDORFound], potential, counts[runner.ResultPotential])
+}
+
+// findingGroup is one deduplicated finding: the pairs of one endpoint (and victim, with
+// GroupByVictim) that share a verdict.
+type findingGroup struct {
+	method     string
+	endpoint   string
+	victim     string // set with GroupByVictim only
+	verdict    string
+	confidence float64
+	pairs      []runner.ResultLog
+}
+
+func (g *findingGroup) label() string {
+	if g.victim != "" {
+		return fmt.Sprintf("%s %s object=%s", g.method, g.endpoint, g.victim)
+	}
+	return g.method + " " + g.endpoint
+}
+
+// groupFindings groups IDOR FOUND and POTENTIAL results not in the baseline into one
+// finding per method, path and verdict (and object owner, when by is GroupByVictim), most
+// severe and most confident first, with each finding's pairs most confident first.
+func groupFindings(results []runner.ResultLog, by string) []*findingGroup {
+	groups := map[string]*findingGroup{}
+	var order []*findingGroup
+	for _, rl := range results {
+		if !isFinding(rl) || rl.InBaseline {
+			continue
+		}
+		g := &findingGroup{method: rl.Method, endpoint: rl.Endpoint, verdict: rl.Result}
+		if by == GroupByVictim {
+			g.victim = rl.Control.Request.AuthUser
+		}
+		key := g.label() + "\x00" + rl.Result
+		if existing, ok := groups[key]; ok {
+			g = existing
+		} else {
+			groups[key] = g
+			order = append(order, g)
+		}
+		if rl.Confidence > g.confidence {
+			g.confidence = rl.Confidence
+		}
+		g.pairs = append(g.pairs, rl)
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		if order[i].verdict != order[j].verdict {
+			return verdictRank[order[i].verdict] < verdictRank[order[j].verdict]
+		}
+		return order[i].confidence > order[j].confidence
+	})
+	for _, g := range order {
+		sort.SliceStable(g.pairs, func(i, j int) bool { return g.pairs[i].Confidence > g.pairs[j].Confidence })
+	}
+	return order
+}
+
+// countFindings returns how many of the grouped findings are IDOR FOUND and POTENTIAL.
+func countFindings(groups []*findingGroup) (idor, potential int) {
+	for _, g := range groups {
+		if g.verdict == runner.ResultIDORFound {
+			idor++
+		} else {
+			potential++
+		}
+	}
+	return idor, potential
+}
+
+// printFindings lists the grouped findings with the affected pairs below each.
+func printFindings(w io.Writer, groups []*findingGroup) {
+	if len(groups) == 0 {
+		return
+	}
+	th := theme.Current()
+	fmt.Fprintln(w, "Findings:")
+	for _, g := range groups {
+		tag := lipgloss.NewStyle().Bold(true).Foreground(th.Verdict(g.verdict)).Render("[" + g.verdict + "]")
+		fmt.Fprintf(w, "  %s %s (confidence %.2f, %d %s)\n", tag, g.label(), g.confidence, len(g.pairs), plural(len(g.pairs), "pair", "pairs"))
+		for _, rl := range g.pairs {
+			fmt.Fprintf(w, "    creds=%s, object=%s\n", rl.Test.Request.AuthUser, rl.Control.Request.AuthUser)
+		}
+	}
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
+
+// printPreflight lists the pre-scan target and credential checks.
+func printPreflight(w io.Writer, p *runner.PreflightReport) {
+	if p == nil {
+		return
+	}
+	fmt.Fprintf(w, "Preflight: target answered HEAD with %d in %dms\n", p.Status, p.DurationMs)
+	for _, u := range p.Users {
+		switch {
+		case u.Endpoint == "":
+			fmt.Fprintf(w, "  %s: credentials not checked, no GET operation fits the user's fields\n", u.User)
+		case u.Error != "":
+			fmt.Fprintf(w, "  %s: GET %s failed: %s\n", u.User, u.Endpoint, u.Error)
+		default:
+			fmt.Fprintf(w, "  %s: GET %s returned %d\n", u.User, u.Endpoint, u.Status)
+		}
+	}
+}
+
+// printSelfTest lists the anomalies of the pre-scan baseline comparison.
+func printSelfTest(w io.Writer, s *runner.SelfTestReport) {
+	if s == nil {
+		return
+	}
+	if len(s.Anomalies) == 0 {
+		fmt.Fprintf(w, "Self-test: %d %s compared as expected\n", s.Endpoints, plural(s.Endpoints, "endpoint", "endpoints"))
+		return
+	}
+	fmt.Fprintf(w, "Self-test: %d %s on %d %s; findings there may be unreliable\n",
+		len(s.Anomalies), plural(len(s.Anomalies), "anomaly", "anomalies"), s.Endpoints, plural(s.Endpoints, "endpoint", "endpoints"))
+	for _, a := range s.Anomalies {
+		fmt.Fprintf(w, "  %s\n", a)
+	}
+}
+
+// skipReasonCounts counts skipped results per reason.
+func skipReasonCounts(results []runner.ResultLog) map[string]int {
+	counts := map[string]int{}
+	for _, rl := range results {
+		if rl.Result != runner.ResultSkipped {
+			continue
+		}
+		counts[skipReason(rl)]++
+	}
+	return counts
+}
+
+// skipReasonHints names the flag that tests what a built-in skip reason left out.
+var skipReasonHints = map[string]string{
+	runner.SkipReasonNoSecurity:     "use --include-no-auth to test them",
+	runner.SkipReasonMutations:      "use --allow-mutations to test them",
+	runner.SkipReasonInfrastructure: "use --no-default-skips to test them",
+}
+
+// printSkipReasons prints how many results were skipped per reason, most frequent first.
+// Reasons with a flag hint are always listed; only maxSkipReasons of the others are.
+func printSkipReasons(w io.Writer, results []runner.ResultLog) {
+	counts := skipReasonCounts(results)
+	if len(counts) == 0 {
+		return
+	}
+	reasons := make([]string, 0, len(counts))
+	for r := range counts {
+		reasons = append(reasons, r)
+	}
+	sort.Slice(reasons, func(i, j int) bool {
+		if counts[reasons[i]] != counts[reasons[j]] {
+			return counts[reasons[i]] > counts[reasons[j]]
+		}
+		return reasons[i] < reasons[j]
+	})
+	fmt.Fprintln(w, "Skipped:")
+	listed, more := 0, 0
+	for _, r := range reasons {
+		hint, ok := skipReasonHints[r]
+		if !ok {
+			if listed == maxSkipReasons {
+				more++
+				continue
+			}
+			listed++
+		}
+		line := fmt.Sprintf("  %d x %s", counts[r], r)
+		if hint != "" {
+			line += " (" + hint + ")"
+		}
+		fmt.Fprintln(w, line)
+	}
+	if more > 0 {
+		fmt.Fprintf(w, "  ... and %d more reason(s), see the output log\n", more)
+	}
+}
+
+// printCleanupErrors lists every failed cleanup sequence; later results on the affected
+// objects may be unreliable.
+func printCleanupErrors(w io.Writer, results []runner.ResultLog) {
+	var lines []string
+	for _, rl := range results {
+		for _, e := range rl.CleanupErrors {
+			lines = append(lines, fmt.Sprintf("  %s %s (object=%s): %s", rl.Method, rl.Endpoint, rl.Control.Request.AuthUser, e))
+		}
+	}
+	if len(lines) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "CLEANUP FAILED (%d) - later results for these objects may be unreliable:\n", len(lines))
+	for _, l := range lines {
+		fmt.Fprintln(w, l)
+	}
+}
+
+// verdictRank orders verdicts from most to least severe for version family summaries.
+var verdictRank = map[string]int{
+	runner.ResultIDORFound:     0,
+	runner.ResultPotential:     1,
+	runner.ResultControlFailed: 2,
+	runner.ResultError:         3,
+	runner.ResultSecure:        4,
+	runner.ResultSkipped:       5,
+}
+
+// printVersionFamilies prints, for each method and resource served under more than one
+// versioned path, the most severe verdict of every version so inconsistencies stand out.
+func printVersionFamilies(w io.Writer, results []runner.ResultLog) {
+	families := map[string]map[string]string{}
 	for _, rl := range results {
-		if rl.Result == runner.ResultIDORFound {
-			found++
-			fmt.Printf("[IDOR FOUND] %s %s\n", rl.Method, rl.Endpoint)
-			fmt.Printf("  creds=%s, object=%s\n", rl.Test.Request.AuthUser, rl.Control.Request.AuthUser)
+		if rl.Resource == "" {
+			continue
+		}
+		key := rl.Method + " " + rl.Resource
+		if families[key] == nil {
+			families[key] = map[string]string{}
+		}
+		worst, seen := families[key][rl.Endpoint]
+		if !seen || verdictRank[rl.Result] < verdictRank[worst] {
+			families[key][rl.Endpoint] = rl.Result
+		}
+	}
+	var keys []string
+	for k, eps := range families {
+		if len(eps) > 1 {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return
+	}
+	sort.Strings(keys)
+	fmt.Fprintln(w, "Version families:")
+	for _, k := range keys {
+		fmt.Fprintf(w, "  %s\n", k)
+		eps := make([]string, 0, len(families[k]))
+		for ep := range families[k] {
+			eps = append(eps, ep)
+		}
+		sort.Strings(eps)
+		for _, ep := range eps {
+			fmt.Fprintf(w, "    %s: %s\n", ep, families[k][ep])
 		}
 	}
-	fmt.Printf("Completed. %d endpoints tested, %d potential IDOR findings.\n", testedEndpoints, found)
+}
+
+// printConcurrency lists the lowest and highest effective concurrency per host.
+func printConcurrency(w io.Writer, ranges map[string]runner.ConcurrencyRange) {
+	if len(ranges) == 0 {
+		return
+	}
+	hosts := make([]string, 0, len(ranges))
+	for h := range ranges {
+		hosts = append(hosts, h)
+	}
+	sort.Strings(hosts)
+	parts := make([]string, 0, len(hosts))
+	for _, h := range hosts {
+		cr := ranges[h]
+		parts = append(parts, fmt.Sprintf("%s min %d, max %d (%d %s)", h, cr.Min, cr.Max, cr.Adjustments, plural(cr.Adjustments, "adjustment", "adjustments")))
+	}
+	fmt.Fprintf(w, "Concurrency: %s\n", strings.Join(parts, "; "))
+}
+
+// printAuthRefreshes lists how often each user's credential was refreshed during the run.
+func printAuthRefreshes(w io.Writer, refreshes map[string]int) {
+	if len(refreshes) == 0 {
+		return
+	}
+	users := make([]string, 0, len(refreshes))
+	for u := range refreshes {
+		users = append(users, u)
+	}
+	sort.Strings(users)
+	parts := make([]string, 0, len(users))
+	for _, u := range users {
+		parts = append(parts, fmt.Sprintf("%s %d", u, refreshes[u]))
+	}
+	fmt.Fprintf(w, "Auth refreshes: %s\n", strings.Join(parts, ", "))
+}
+
+// printTraffic prints the bytes exchanged with the target in total and per user.
+func printTraffic(w io.Writer, t runner.TrafficStats) {
+	if t.Total.Requests == 0 {
+		return
+	}
+	fmt.Fprintf(w, "Traffic: %s sent, %s received (average response %s)\n",
+		formatBytes(t.Total.BytesSent), formatBytes(t.Total.BytesReceived), formatBytes(t.Total.AvgResponseBytes))
+	users := make([]string, 0, len(t.Users))
+	for u := range t.Users {
+		users = append(users, u)
+	}
+	sort.Strings(users)
+	for _, u := range users {
+		ut := t.Users[u]
+		fmt.Fprintf(w, "  %s: %s sent, %s received\n", u, formatBytes(ut.BytesSent), formatBytes(ut.BytesReceived))
+	}
+}
+
+// formatBytes renders n in B, KB or MB, with 1 KB = 1024 bytes.
+func formatBytes(n int64) string {
+	switch {
+	case n < 1024:
+		return fmt.Sprintf("%d B", n)
+	case n < 1024*1024:
+		return fmt.Sprintf("%.1f KB", float64(n)/1024)
+	}
+	return fmt.Sprintf("%.1f MB", float64(n)/(1024*1024))
+}
+
+// printServerErrors lists the endpoints whose tests got 5xx responses, most first.
+func printServerErrors(w io.Writer, counts map[string]int) {
+	if len(counts) == 0 {
+		return
+	}
+	endpoints := make([]string, 0, len(counts))
+	for ep := range counts {
+		endpoints = append(endpoints, ep)
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		if counts[endpoints[i]] != counts[endpoints[j]] {
+			return counts[endpoints[i]] > counts[endpoints[j]]
+		}
+		return endpoints[i] < endpoints[j]
+	})
+	fmt.Fprintln(w, "Server errors (check the synthesized requests):")
+	for _, ep := range endpoints {
+		fmt.Fprintf(w, "  %d x %s\n", counts[ep], ep)
+	}
+}
+
+// printMethodInconsistencies lists paths where some methods leaked while others were secure.
+func printMethodInconsistencies(w io.Writer, results []runner.ResultLog) {
+	found := runner.MethodInconsistencies(results)
+	if len(found) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "Inconsistent methods:")
+	for _, mi := range found {
+		fmt.Fprintf(w, "  %s: secure for %s, leaks for %s\n", mi.Endpoint, strings.Join(mi.Secure, ", "), strings.Join(mi.Leaking, ", "))
+	}
+}
+
+// WriteCoverageJSON writes the user/endpoint eligibility report as indented JSON.
+func WriteCoverageJSON(w io.Writer, check runner.ConfigCheck) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(check)
+}
+
+// WriteCoverageText writes the user/endpoint eligibility report in a human-readable form.
+func WriteCoverageText(w io.Writer, check runner.ConfigCheck) error {
+	bw := bufio.NewWriter(w)
+	for _, n := range check.Notes {
+		if _, err := fmt.Fprintf(bw, "note: %s\n", n); err != nil {
+			return err
+		}
+	}
+	for _, ec := range check.Endpoints {
+		if _, err := fmt.Fprintf(bw, "%s %s\n", ec.Method, ec.Endpoint); err != nil {
+			return err
+		}
+		if op := operationLabel(ec.OperationID, ec.Tags, ""); op != "" {
+			if _, err := fmt.Fprintf(bw, "  operation: %s\n", op); err != nil {
+				return err
+			}
+		}
+		if ec.SkipReason != "" {
+			if _, err := fmt.Fprintf(bw, "  skipped: %s\n", ec.SkipReason); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := fmt.Fprintf(bw, "  object users:   %s\n  attacker users: %s\n  pairs: %d\n", listOrNone(ec.ObjectUsers), listOrNone(ec.AttackerUsers), ec.Pairs); err != nil {
+			return err
+		}
+		users := make([]string, 0, len(ec.UserSkips))
+		for u := range ec.UserSkips {
+			users = append(users, u)
+		}
+		sort.Strings(users)
+		for _, u := range users {
+			if _, err := fmt.Fprintf(bw, "  %s cannot own objects here: %s\n", u, ec.UserSkips[u]); err != nil {
+				return err
+			}
+		}
+	}
+	if _, err := fmt.Fprintf(bw, "%d of %d endpoints testable\n", check.Testable, len(check.Endpoints)); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func listOrNone(names []string) string {
+	if len(names) == 0 {
+		return "(none)"
+	}
+	return strings.Join(names, ", ")
 }
 
 func writeSeparator(w *bufio.Writer) error {
@@ -113,6 +775,14 @@ func writeExchange(w *bufio.Writer, x runner.Exchange) error {
 		return err
 	}
 
+	if x.Request.Raw != "" {
+		// Exact bytes as sent, with CRLF line endings normalized
+		if _, err := fmt.Fprintf(w, "%s\n\n", strings.TrimRight(strings.ReplaceAll(x.Request.Raw, "\r\n", "\n"), "\n")); err != nil {
+			return err
+		}
+		return writeResponse(w, x.Response)
+	}
+
 	u, _ := url.Parse(x.Request.URL)
 	pathWithQuery := u.EscapedPath()
 	if u.RawQuery != "" {
@@ -159,36 +829,50 @@ func writeExchange(w *bufio.Writer, x runner.Exchange) error {
 		}
 	}
 
+	return writeResponse(w, x.Response)
+}
+
+func writeResponse(w *bufio.Writer, resp runner.ResponseDetails) error {
 	if _, err := fmt.Fprintln(w, "Response:"); err != nil {
 		return err
 	}
 	if _, err := fmt.Fprintln(w, "--"); err != nil {
 		return err
 	}
-	statusText := http.StatusText(x.Response.Status)
+	statusText := http.StatusText(resp.Status)
 	if statusText == "" {
 		statusText = ""
 	}
-	if _, err := fmt.Fprintf(w, "HTTP/1.1 %d %s\n", x.Response.Status, statusText); err != nil {
+	if _, err := fmt.Fprintf(w, "HTTP/1.1 %d %s\n", resp.Status, statusText); err != nil {
 		return err
 	}
 
-	// Response headers (sorted)
-	rh := make([]string, 0, len(x.Response.Headers))
-	for k := range x.Response.Headers {
+	// Response headers (sorted), one line per value
+	values := resp.HeaderValues
+	if values == nil {
+		values = map[string][]string{}
+		for k, v := range resp.Headers {
+			values[k] = []string{v}
+		}
+	}
+	rh := make([]string, 0, len(values))
+	for k := range values {
 		rh = append(rh, k)
 	}
 	sort.Strings(rh)
 	for _, k := range rh {
-		if _, err := fmt.Fprintf(w, "%s: %s\n", k, x.Response.Headers[k]); err != nil {
-			return err
+		for _, v := range values[k] {
+			if _, err := fmt.Fprintf(w, "%s: %s\n", k, v); err != nil {
+				return err
+			}
 		}
 	}
 	if _, err := fmt.Fprintln(w); err != nil {
 		return err
 	}
-	if strings.TrimSpace(x.Response.Body) != "" {
-		if _, err := fmt.Fprintln(w, strings.TrimSpace(x.Response.Body)); err != nil {
+	// Spilled bodies are read back one at a time, only while they are written out
+	if body := strings.TrimSpace(resp.FullBody()); body != "" {
+		if _, err := fmt.Fprintln(w, body); err != nil {
 			return err
 		}
 	}
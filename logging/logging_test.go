@@ -0,0 +1,204 @@
+package logging
+
+import (
+	"bytes"
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yansol0/aperture/runner"
+	"github.com/yansol0/aperture/theme"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// checkGolden compares got with testdata/name, rewriting the file with -update.
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden file (run with -update to create it): %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s (run with -update to accept it):\n%s", path, got)
+	}
+}
+
+// textLogResults covers the shapes of the text log: a finding with a request body, a
+// secure pair recorded raw, a skipped pair beside run ones and an all-skipped endpoint.
+func textLogResults() []runner.ResultLog {
+	control := runner.Exchange{
+		Request: runner.RequestDetails{
+			Method:   "GET",
+			URL:      "http://api.test/notes/1?owner=alice",
+			Headers:  map[string]string{"X-API-Key": "KEY_ALICE", "Accept": "application/json"},
+			AuthUser: "alice",
+		},
+		Response: runner.ResponseDetails{
+			Status:       200,
+			HeaderValues: map[string][]string{"Content-Type": {"application/json"}, "Set-Cookie": {"a=1", "b=2"}},
+			Body:         `{"id":1,"owner":"alice"}`,
+		},
+	}
+	test := control
+	test.Request.Headers = map[string]string{"X-API-Key": "KEY_BOB", "Accept": "application/json"}
+	test.Request.AuthUser = "bob"
+
+	update := runner.Exchange{
+		Request: runner.RequestDetails{
+			Method:  "PUT",
+			URL:     "http://api.test/notes/2",
+			Headers: map[string]string{"X-API-Key": "KEY_BOB"},
+			Body:    map[string]any{"title": "changed"},
+		},
+		Response: runner.ResponseDetails{Status: 403, Headers: map[string]string{"Content-Type": "application/json"}, Body: `{"error":"forbidden"}`},
+	}
+	raw := update
+	raw.Request.Raw = "PUT /notes/2 HTTP/1.1\r\nHost: api.test\r\nX-API-Key: KEY_ALICE\r\n\r\n"
+
+	return []runner.ResultLog{
+		{Endpoint: "/notes/{note_id}", Method: "PUT", OperationID: "updateNote", Control: update, Test: raw, Result: runner.ResultSecure},
+		{Endpoint: "/notes/{note_id}", Method: "GET", OperationID: "getNote", Tags: []string{"notes"}, Summary: "Get a note", Control: control, Test: test, Result: runner.ResultIDORFound},
+		{Endpoint: "/notes/{note_id}", Method: "GET", OperationID: "getNote", Tags: []string{"notes"}, Summary: "Get a note", Result: runner.ResultSkipped, SkippedReason: "no object identifiers referenced by this operation"},
+		{Endpoint: "/admin", Method: "GET", Result: runner.ResultSkipped, Notes: []string{"no users can reach this operation"}},
+	}
+}
+
+func TestWriteTextGolden(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteText(&buf, textLogResults(), "http://api.test/"); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "text.golden", buf.Bytes())
+}
+
+func TestWriteTextEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteText(&buf, nil, "http://api.test"); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "text_empty.golden", buf.Bytes())
+}
+
+// TestPrintSummaryNoColor checks the console summary with color disabled: plain text
+// with no escape sequences.
+func TestPrintSummaryNoColor(t *testing.T) {
+	if err := theme.Configure(theme.ColorNever, "default"); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	PrintSummary(&buf, textLogResults(), RunStats{
+		TestedEndpoints: 2,
+		Requests:        6,
+		Elapsed:         3 * time.Second,
+		AuthRefreshes:   map[string]int{"alice": 1},
+		Pacing:          []string{"/notes/{note_id}: max 2 concurrent"},
+	})
+	if bytes.Contains(buf.Bytes(), []byte("\x1b[")) {
+		t.Errorf("summary has escape sequences:\n%q", buf.String())
+	}
+	checkGolden(t, "summary_nocolor.golden", buf.Bytes())
+}
+
+// TestWriteTextContentsLines checks each table of contents entry points at its
+// endpoint's header line.
+func TestWriteTextContentsLines(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteText(&buf, textLogResults(), "http://api.test"); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(buf.String(), "\n")
+	entries := 0
+	for _, l := range lines[1:] {
+		if l == "" {
+			break
+		}
+		var n int
+		var method, endpoint string
+		if _, err := fmt.Sscanf(l, "  line %d %s %s", &n, &method, &endpoint); err != nil {
+			t.Fatalf("contents entry %q: %v", l, err)
+		}
+		if want := "## " + method + " " + endpoint + " "; n < 1 || n > len(lines) || !strings.HasPrefix(lines[n-1], want) {
+			t.Errorf("entry %q points at line %d, want a line starting %q", l, n, want)
+		}
+		entries++
+	}
+	if entries != 3 {
+		t.Errorf("contents has %d entries, want 3", entries)
+	}
+}
+
+// TestPrintSkipReasonsKeepsHints checks that reasons with a flag hint are listed however
+// many more frequent free-form reasons there are.
+func TestPrintSkipReasonsKeepsHints(t *testing.T) {
+	var results []runner.ResultLog
+	skip := func(reason string, n int) {
+		for range n {
+			results = append(results, runner.ResultLog{Method: "GET", Endpoint: "/x", Result: runner.ResultSkipped, SkippedReason: reason})
+		}
+	}
+	for i := range maxSkipReasons + 2 {
+		skip(fmt.Sprintf("user lacks field_%d", i), 10+i)
+	}
+	skip(runner.SkipReasonNoSecurity, 2)
+	skip(runner.SkipReasonMutations, 1)
+
+	var buf bytes.Buffer
+	printSkipReasons(&buf, results)
+	got := buf.String()
+	for _, want := range []string{
+		"  2 x no security requirement (use --include-no-auth to test them)\n",
+		"  1 x mutating requests are disabled (use --allow-mutations to test them)\n",
+		"  16 x user lacks field_6\n",
+		"  ... and 2 more reason(s), see the output log\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("skip reasons:\n%s\nwant a line %q", got, want)
+		}
+	}
+	if strings.Contains(got, "field_0") || strings.Contains(got, "field_1\n") {
+		t.Errorf("skip reasons:\n%s\nwant the least frequent free-form reasons cut", got)
+	}
+}
+
+// TestTapFlushesGzip checks that every tap line is readable from a gzip stream that was
+// never closed, as after an interrupted run.
+func TestTapFlushesGzip(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tap, tapErr := NewTap(gz)
+	for _, status := range []int{200, 403} {
+		tap(runner.RequestDetails{Method: "GET", URL: "https://api.example.com/notes/1"}, runner.ResponseDetails{Status: status})
+	}
+	if err := tapErr(); err != nil {
+		t.Fatalf("tap: %v", err)
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The stream has no trailer, so reading ends in io.ErrUnexpectedEOF after the data
+	data, _ := io.ReadAll(r)
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("read %d lines, want 2:\n%s", len(lines), data)
+	}
+	for i, want := range []string{`"status":200`, `"status":403`} {
+		if !strings.Contains(lines[i], want) {
+			t.Errorf("line %d = %s, want %s", i+1, lines[i], want)
+		}
+	}
+}
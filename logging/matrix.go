@@ -0,0 +1,113 @@
+package logging
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/yansol0/aperture/runner"
+)
+
+// Coverage matrix outcomes, from the most to the least significant verdict of an
+// operation's results.
+const (
+	CoverageFound       = "found"
+	CoveragePotential   = "potential"
+	CoverageTested      = "tested"
+	CoverageSkipped     = "skipped"
+	CoverageNotSelected = "not selected"
+)
+
+// coverageVerdicts are the verdict count columns of the coverage matrix.
+var coverageVerdicts = []string{
+	runner.ResultIDORFound,
+	runner.ResultPotential,
+	runner.ResultSecure,
+	runner.ResultControlFailed,
+	runner.ResultError,
+	runner.ResultSkipped,
+}
+
+// WriteCoverageCSV writes one row per operation of doc, sorted by path and method, with
+// its outcome and verdict counts in results. Unlike the results, it lists operations that
+// produced none, such as those left out by --only-operation, as "not selected", so gaps
+// in an assessment are visible.
+func WriteCoverageCSV(w io.Writer, results []runner.ResultLog, doc *openapi3.T) error {
+	type key struct{ method, path string }
+	counts := map[key]map[string]int{}
+	reasons := map[key]map[string]bool{}
+	for _, rl := range results {
+		k := key{rl.Method, rl.Endpoint}
+		if counts[k] == nil {
+			counts[k] = map[string]int{}
+		}
+		counts[k][rl.Result]++
+		if rl.Result == runner.ResultSkipped && rl.SkippedReason != "" {
+			if reasons[k] == nil {
+				reasons[k] = map[string]bool{}
+			}
+			reasons[k][rl.SkippedReason] = true
+		}
+	}
+
+	cw := csv.NewWriter(w)
+	header := []string{"method", "path", "operation_id", "tags", "outcome"}
+	for _, v := range coverageVerdicts {
+		header = append(header, strings.ToLower(strings.ReplaceAll(v, " ", "_")))
+	}
+	header = append(header, "skip_reasons")
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	paths := doc.Paths.Map()
+	names := make([]string, 0, len(paths))
+	for p := range paths {
+		names = append(names, p)
+	}
+	sort.Strings(names)
+	for _, path := range names {
+		ops := paths[path].Operations()
+		methods := make([]string, 0, len(ops))
+		for m := range ops {
+			methods = append(methods, m)
+		}
+		sort.Strings(methods)
+		for _, method := range methods {
+			op := ops[method]
+			k := key{method, path}
+			row := []string{method, path, op.OperationID, strings.Join(op.Tags, ";"), coverageOutcome(counts[k])}
+			for _, v := range coverageVerdicts {
+				row = append(row, strconv.Itoa(counts[k][v]))
+			}
+			skipped := make([]string, 0, len(reasons[k]))
+			for r := range reasons[k] {
+				skipped = append(skipped, r)
+			}
+			sort.Strings(skipped)
+			row = append(row, strings.Join(skipped, "; "))
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// coverageOutcome summarizes an operation's verdict counts.
+func coverageOutcome(counts map[string]int) string {
+	switch {
+	case len(counts) == 0:
+		return CoverageNotSelected
+	case counts[runner.ResultIDORFound] > 0:
+		return CoverageFound
+	case counts[runner.ResultPotential] > 0:
+		return CoveragePotential
+	case len(counts) == 1 && counts[runner.ResultSkipped] > 0:
+		return CoverageSkipped
+	}
+	return CoverageTested
+}
@@ -0,0 +1,62 @@
+package logging
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/yansol0/aperture/runner"
+)
+
+const coverageSpec = `
+openapi: 3.0.3
+info: {title: coverage, version: "1"}
+paths:
+  /notes/{note_id}:
+    get: {operationId: getNote, tags: [notes, read], responses: {"200": {description: OK}}}
+    delete: {operationId: deleteNote, tags: [notes], responses: {"204": {description: Deleted}}}
+  /notes/{note_id}/preview:
+    get: {operationId: previewNote, responses: {"200": {description: OK}}}
+  /users/{user_id}/notes:
+    get: {operationId: listUserNotes, responses: {"200": {description: OK}}}
+  /admin:
+    post: {operationId: adminAction, responses: {"200": {description: OK}}}
+`
+
+func TestWriteCoverageCSVPartialSpec(t *testing.T) {
+	doc, err := openapi3.NewLoader().LoadFromData([]byte(coverageSpec))
+	if err != nil {
+		t.Fatalf("load spec: %v", err)
+	}
+	result := func(method, path, verdict, reason string) runner.ResultLog {
+		return runner.ResultLog{Method: method, Endpoint: path, Result: verdict, SkippedReason: reason}
+	}
+	// DELETE /notes/{note_id} was left out of the run, so it has no results
+	results := []runner.ResultLog{
+		result("GET", "/notes/{note_id}", runner.ResultIDORFound, ""),
+		result("GET", "/notes/{note_id}", runner.ResultSecure, ""),
+		result("GET", "/notes/{note_id}/preview", runner.ResultPotential, ""),
+		result("GET", "/notes/{note_id}/preview", runner.ResultSkipped, "user lacks note_id"),
+		result("GET", "/users/{user_id}/notes", runner.ResultSecure, ""),
+		result("GET", "/users/{user_id}/notes", runner.ResultControlFailed, ""),
+		result("POST", "/admin", runner.ResultSkipped, runner.SkipReasonMutations),
+		result("POST", "/admin", runner.ResultSkipped, runner.SkipReasonMutations),
+		result("GET", "-", runner.ResultSkipped, "config warning"),
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCoverageCSV(&buf, results, doc); err != nil {
+		t.Fatalf("WriteCoverageCSV: %v", err)
+	}
+
+	want := `method,path,operation_id,tags,outcome,idor_found,potential,secure,control_failed,error,skipped,skip_reasons
+POST,/admin,adminAction,,skipped,0,0,0,0,0,2,mutating requests are disabled
+DELETE,/notes/{note_id},deleteNote,notes,not selected,0,0,0,0,0,0,
+GET,/notes/{note_id},getNote,notes;read,found,1,0,1,0,0,0,
+GET,/notes/{note_id}/preview,previewNote,,potential,0,1,0,0,0,1,user lacks note_id
+GET,/users/{user_id}/notes,listUserNotes,,tested,0,0,1,1,0,0,
+`
+	if got := buf.String(); got != want {
+		t.Errorf("coverage matrix:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,238 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/yansol0/aperture/runner"
+	"gopkg.in/yaml.v3"
+)
+
+// NucleiAuthVariable is the template variable the attacker's credential is read from,
+// e.g. nuclei -t dir -u https://api.example.com -var attacker_auth="Bearer ...".
+const NucleiAuthVariable = "attacker_auth"
+
+type nucleiTemplate struct {
+	ID        string            `yaml:"id"`
+	Info      nucleiInfo        `yaml:"info"`
+	Variables map[string]string `yaml:"variables"`
+	HTTP      []nucleiRequest   `yaml:"http"`
+}
+
+type nucleiInfo struct {
+	Name           string            `yaml:"name"`
+	Author         string            `yaml:"author"`
+	Severity       string            `yaml:"severity"`
+	Description    string            `yaml:"description"`
+	Tags           string            `yaml:"tags"`
+	Classification map[string]string `yaml:"classification"`
+}
+
+type nucleiRequest struct {
+	Method            string            `yaml:"method"`
+	Path              []string          `yaml:"path"`
+	Headers           map[string]string `yaml:"headers,omitempty"`
+	Body              string            `yaml:"body,omitempty"`
+	MatchersCondition string            `yaml:"matchers-condition"`
+	Matchers          []nucleiMatcher   `yaml:"matchers"`
+}
+
+type nucleiMatcher struct {
+	Type   string   `yaml:"type"`
+	Part   string   `yaml:"part,omitempty"`
+	Status []int    `yaml:"status,omitempty"`
+	Words  []string `yaml:"words,omitempty"`
+}
+
+// WriteNucleiTemplates writes one nuclei HTTP template per IDOR FOUND result to dir,
+// named after the result's ID, and returns how many were written. Each template replays
+// the test request with the headers in authHeaders replaced by the NucleiAuthVariable
+// placeholder, and matches the test's status plus an identifier of the victim that appeared
+// in the leaked response.
+func WriteNucleiTemplates(dir string, results []runner.ResultLog, authHeaders []string) (int, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return 0, err
+	}
+	auth := map[string]bool{}
+	for _, h := range authHeaders {
+		auth[http.CanonicalHeaderKey(h)] = true
+	}
+	n := 0
+	for _, rl := range results {
+		if rl.Result != runner.ResultIDORFound {
+			continue
+		}
+		tmpl, err := nucleiTemplateFor(rl, auth)
+		if err != nil {
+			return n, fmt.Errorf("%s %s: %w", rl.Method, rl.Endpoint, err)
+		}
+		var b bytes.Buffer
+		enc := yaml.NewEncoder(&b)
+		enc.SetIndent(2)
+		if err := enc.Encode(tmpl); err != nil {
+			return n, err
+		}
+		if err := os.WriteFile(filepath.Join(dir, tmpl.ID+".yaml"), b.Bytes(), 0o644); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+func nucleiTemplateFor(rl runner.ResultLog, auth map[string]bool) (nucleiTemplate, error) {
+	req := rl.Test.Request
+	u, err := url.Parse(req.URL)
+	if err != nil {
+		return nucleiTemplate{}, err
+	}
+	headers := map[string]string{}
+	for k, v := range req.Headers {
+		switch ck := http.CanonicalHeaderKey(k); {
+		case auth[ck]:
+			headers[k] = "{{" + NucleiAuthVariable + "}}"
+		case ck == "Host" || ck == "Content-Length":
+		default:
+			headers[k] = nucleiLiteral(v)
+		}
+	}
+	var body string
+	if req.Body != nil {
+		var b []byte
+		if ct, k := requestContentType(req.Headers); strings.HasPrefix(strings.ToLower(ct), "multipart/form-data") {
+			var err error
+			if b, ct, err = nucleiMultipartBody(req.Body); err != nil {
+				return nucleiTemplate{}, err
+			}
+			headers[k] = ct
+		} else if b, err = json.Marshal(req.Body); err != nil {
+			return nucleiTemplate{}, err
+		}
+		body = nucleiLiteral(string(b))
+	}
+
+	matchers := []nucleiMatcher{{Type: "status", Status: []int{rl.Test.Response.Status}}}
+	if word := leakedIdentifier(rl); word != "" {
+		matchers = append(matchers, nucleiMatcher{Type: "word", Part: "body", Words: []string{word}})
+	}
+	return nucleiTemplate{
+		ID: "aperture-idor-" + rl.ID(),
+		Info: nucleiInfo{
+			Name:     fmt.Sprintf("IDOR in %s %s", rl.Method, rl.Endpoint),
+			Author:   "aperture",
+			Severity: "high",
+			Description: fmt.Sprintf("Credentials of %s returned an object of %s (confidence %.2f). Set -var %s to a credential of a user who must not see the object.",
+				req.AuthUser, rl.Control.Request.AuthUser, rl.Confidence, NucleiAuthVariable),
+			Tags:           "idor,aperture",
+			Classification: map[string]string{"cwe-id": "CWE-639"},
+		},
+		Variables: map[string]string{NucleiAuthVariable: ""},
+		HTTP: []nucleiRequest{{
+			Method:            req.Method,
+			Path:              []string{"{{RootURL}}" + nucleiLiteral(u.RequestURI())},
+			Headers:           headers,
+			Body:              body,
+			MatchersCondition: "and",
+			Matchers:          matchers,
+		}},
+	}, nil
+}
+
+// nucleiBoundary is the multipart boundary of template bodies; the runner's boundary is
+// random, so the logged request does not say which one was sent.
+const nucleiBoundary = "aperture-nuclei-boundary"
+
+// requestContentType returns the Content-Type of headers and the key it is set under.
+func requestContentType(headers map[string]string) (string, string) {
+	for k, v := range headers {
+		if http.CanonicalHeaderKey(k) == "Content-Type" {
+			return v, k
+		}
+	}
+	return "", "Content-Type"
+}
+
+// nucleiMultipartBody rebuilds a multipart/form-data body from the fields it was logged as,
+// in name order with a fixed boundary, and returns it with its Content-Type. Values are
+// encoded the way the runner sends text parts: strings as they are, others as JSON. The
+// log does not record which parts were files, so file parts are sent as text parts.
+func nucleiMultipartBody(fields any) ([]byte, string, error) {
+	obj, ok := fields.(map[string]any)
+	if !ok {
+		return nil, "", fmt.Errorf("multipart body is not an object")
+	}
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	if err := w.SetBoundary(nucleiBoundary); err != nil {
+		return nil, "", err
+	}
+	for _, name := range names {
+		text, ok := obj[name].(string)
+		if !ok {
+			b, err := json.Marshal(obj[name])
+			if err != nil {
+				return nil, "", err
+			}
+			text = string(b)
+		}
+		if err := w.WriteField(name, text); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), w.FormDataContentType(), nil
+}
+
+// nucleiLiteral protects s from nuclei's {{expression}} evaluation by sending it
+// base64-encoded when it contains a marker.
+func nucleiLiteral(s string) string {
+	if !strings.Contains(s, "{{") && !strings.Contains(s, "}}") {
+		return s
+	}
+	return `{{base64_decode("` + base64.StdEncoding.EncodeToString([]byte(s)) + `")}}`
+}
+
+// minLeakedWordLen is the shortest identifier used as a word matcher; shorter values such
+// as "1" would match almost any body.
+const minLeakedWordLen = 3
+
+// leakedIdentifier returns the longest of the victim's path and query values that appears
+// in the test response body, or "" when none is long enough to be distinctive.
+func leakedIdentifier(rl runner.ResultLog) string {
+	testBody := rl.Test.Response.FullBody()
+	var values []string
+	for _, m := range []map[string]string{rl.Test.Request.PathParams, rl.Test.Request.QueryParams} {
+		for _, v := range m {
+			if len(v) >= minLeakedWordLen && strings.Contains(testBody, v) {
+				values = append(values, v)
+			}
+		}
+	}
+	if len(values) == 0 {
+		return ""
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if len(values[i]) != len(values[j]) {
+			return len(values[i]) > len(values[j])
+		}
+		return values[i] < values[j]
+	})
+	return values[0]
+}
@@ -0,0 +1,164 @@
+package logging
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yansol0/aperture/runner"
+	"gopkg.in/yaml.v3"
+)
+
+func TestNucleiLiteral(t *testing.T) {
+	for _, tt := range []struct{ in, want string }{
+		{"/notes/1?q=a: b #c", "/notes/1?q=a: b #c"},
+		{"{", "{"},
+		{"{{x}}", `{{base64_decode("e3t4fX0=")}}`},
+		{"a}}", `{{base64_decode("YX19")}}`},
+	} {
+		if got := nucleiLiteral(tt.in); got != tt.want {
+			t.Errorf("nucleiLiteral(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// unliteral reverses nucleiLiteral.
+func unliteral(t *testing.T, s string) string {
+	t.Helper()
+	enc, ok := strings.CutPrefix(s, `{{base64_decode("`)
+	if !ok {
+		return s
+	}
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSuffix(enc, `")}}`))
+	if err != nil {
+		t.Fatalf("decode %q: %v", s, err)
+	}
+	return string(b)
+}
+
+// readNucleiTemplate writes the template of rl and parses it back.
+func readNucleiTemplate(t *testing.T, rl runner.ResultLog) nucleiRequest {
+	t.Helper()
+	dir := t.TempDir()
+	if n, err := WriteNucleiTemplates(dir, []runner.ResultLog{rl}, []string{"Authorization"}); err != nil || n != 1 {
+		t.Fatalf("WriteNucleiTemplates = %d, %v", n, err)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "aperture-idor-"+rl.ID()+".yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tmpl nucleiTemplate
+	if err := yaml.Unmarshal(b, &tmpl); err != nil {
+		t.Fatalf("parse template: %v\n%s", err, b)
+	}
+	if len(tmpl.HTTP) != 1 {
+		t.Fatalf("template has %d requests, want 1", len(tmpl.HTTP))
+	}
+	return tmpl.HTTP[0]
+}
+
+func nucleiResult(req runner.RequestDetails) runner.ResultLog {
+	req.AuthUser = "bob"
+	return runner.ResultLog{
+		Endpoint: "/notes/{note_id}",
+		Method:   req.Method,
+		Result:   runner.ResultIDORFound,
+		Control:  runner.Exchange{Request: runner.RequestDetails{AuthUser: "alice"}},
+		Test: runner.Exchange{
+			Request:  req,
+			Response: runner.ResponseDetails{Status: 200, Body: `{"id": 1}`},
+		},
+	}
+}
+
+// TestNucleiTemplateRoundTrip checks that values YAML or nuclei would otherwise read as
+// syntax come back from the written template as they were sent.
+func TestNucleiTemplateRoundTrip(t *testing.T) {
+	body := map[string]any{
+		"title":  "{{7*7}} and }}",
+		"text":   "key: value # not a comment\nsecond line",
+		"nested": map[string]any{"tags": []any{"a: b", "#c"}},
+	}
+	req := runner.RequestDetails{
+		Method: "PUT",
+		URL:    "http://api.test/notes/1?q=%7B%7Bx%7D%7D&r=a:%20b",
+		Headers: map[string]string{
+			"Authorization": "Bearer bob",
+			"Content-Type":  "application/json",
+			"X-Trace":       "#1: {{id}}",
+			"Host":          "api.test",
+		},
+		Body: body,
+	}
+	got := readNucleiTemplate(t, nucleiResult(req))
+
+	if len(got.Path) != 1 {
+		t.Fatalf("paths = %q, want one", got.Path)
+	}
+	if path := unliteral(t, strings.TrimPrefix(got.Path[0], "{{RootURL}}")); path != "/notes/1?q=%7B%7Bx%7D%7D&r=a:%20b" {
+		t.Errorf("path = %q", path)
+	}
+	wantHeaders := map[string]string{
+		"Authorization": "{{attacker_auth}}",
+		"Content-Type":  "application/json",
+		"X-Trace":       "#1: {{id}}",
+	}
+	headers := map[string]string{}
+	for k, v := range got.Headers {
+		headers[k] = v
+		if k != "Authorization" {
+			headers[k] = unliteral(t, v)
+		}
+	}
+	if !reflect.DeepEqual(headers, wantHeaders) {
+		t.Errorf("headers = %v, want %v", headers, wantHeaders)
+	}
+	var sent any
+	if err := json.Unmarshal([]byte(unliteral(t, got.Body)), &sent); err != nil {
+		t.Fatalf("body %q: %v", got.Body, err)
+	}
+	if !reflect.DeepEqual(sent, body) {
+		t.Errorf("body = %v, want %v", sent, body)
+	}
+}
+
+func TestNucleiTemplateMultipart(t *testing.T) {
+	req := runner.RequestDetails{
+		Method: "POST",
+		URL:    "http://api.test/notes/1/attachments",
+		Headers: map[string]string{
+			"Authorization": "Bearer bob",
+			"Content-Type":  "multipart/form-data; boundary=4f1c",
+		},
+		Body: map[string]any{"name": "a: {{b}}", "size": 3.0},
+	}
+	got := readNucleiTemplate(t, nucleiResult(req))
+
+	mt, params, err := mime.ParseMediaType(got.Headers["Content-Type"])
+	if err != nil || mt != "multipart/form-data" || params["boundary"] != nucleiBoundary {
+		t.Fatalf("Content-Type = %q, want multipart with boundary %q", got.Headers["Content-Type"], nucleiBoundary)
+	}
+	mr := multipart.NewReader(strings.NewReader(unliteral(t, got.Body)), nucleiBoundary)
+	parts := map[string]string{}
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("read body: %v", err)
+		}
+		b, _ := io.ReadAll(p)
+		parts[p.FormName()] = string(b)
+	}
+	if want := map[string]string{"name": "a: {{b}}", "size": "3"}; !reflect.DeepEqual(parts, want) {
+		t.Errorf("parts = %v, want %v", parts, want)
+	}
+}
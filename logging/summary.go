@@ -0,0 +1,103 @@
+package logging
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/yansol0/aperture/runner"
+)
+
+// Summary is the machine-readable form of the console summary.
+type Summary struct {
+	Version         string           `json:"version"`
+	ElapsedSeconds  float64          `json:"elapsed_seconds"`
+	TestedEndpoints int              `json:"tested_endpoints"`
+	Requests        int              `json:"requests"`
+	Verdicts        map[string]int   `json:"verdicts"`
+	IDORFindings    int              `json:"idor_findings"`
+	Potential       int              `json:"potential_findings"`
+	Findings        []SummaryFinding `json:"findings"`
+	// BaselineKnown counts the finding pairs left out of Findings because the baseline has them.
+	BaselineKnown int            `json:"baseline_known,omitempty"`
+	SkipReasons   map[string]int `json:"skip_reasons"`
+	AuthRefreshes map[string]int `json:"auth_refreshes,omitempty"`
+	// Traffic is the bytes exchanged with the target, when any request was sent.
+	Traffic *runner.TrafficStats `json:"traffic,omitempty"`
+	// Concurrency is each host's range of effective concurrency in an adaptive run.
+	Concurrency map[string]runner.ConcurrencyRange `json:"concurrency,omitempty"`
+	// ServerErrors counts ERROR results per "METHOD path".
+	ServerErrors map[string]int `json:"server_errors"`
+	// Preflight is the pre-scan check, when one ran.
+	Preflight *runner.PreflightReport `json:"preflight,omitempty"`
+	Pacing    []string                `json:"pacing,omitempty"`
+	SelfTest  *runner.SelfTestReport  `json:"self_test,omitempty"`
+}
+
+// SummaryFinding is one deduplicated finding, grouped as in the console summary.
+type SummaryFinding struct {
+	Method      string        `json:"method"`
+	Endpoint    string        `json:"endpoint"`
+	Victim      string        `json:"victim,omitempty"` // with GroupByVictim
+	OperationID string        `json:"operation_id"`
+	Verdict     string        `json:"verdict"`
+	Confidence  float64       `json:"confidence"`
+	Pairs       []SummaryPair `json:"pairs"`
+}
+
+// SummaryPair is one user pair affected by a finding; ID matches runner.ResultLog.ID.
+type SummaryPair struct {
+	ID         string  `json:"id"`
+	Creds      string  `json:"creds"`
+	Object     string  `json:"object"`
+	Confidence float64 `json:"confidence"`
+}
+
+// WriteSummaryJSON writes the run summary as an indented JSON document.
+func WriteSummaryJSON(w io.Writer, results []runner.ResultLog, stats RunStats) error {
+	s := Summary{
+		Version:         runner.Version(),
+		ElapsedSeconds:  stats.Elapsed.Seconds(),
+		TestedEndpoints: stats.TestedEndpoints,
+		Requests:        stats.Requests,
+		Verdicts:        map[string]int{},
+		Findings:        []SummaryFinding{},
+		BaselineKnown:   countBaselineKnown(results),
+		SkipReasons:     skipReasonCounts(results),
+		AuthRefreshes:   stats.AuthRefreshes,
+		Concurrency:     stats.Concurrency,
+		ServerErrors:    runner.ServerErrors(results),
+		Preflight:       stats.Preflight,
+		Pacing:          stats.Pacing,
+		SelfTest:        stats.SelfTest,
+	}
+	if stats.Traffic.Total.Requests > 0 {
+		s.Traffic = &stats.Traffic
+	}
+	for _, rl := range results {
+		s.Verdicts[rl.Result]++
+	}
+	groups := groupFindings(results, stats.FindingsBy)
+	s.IDORFindings, s.Potential = countFindings(groups)
+	for _, g := range groups {
+		f := SummaryFinding{
+			Method:      g.method,
+			Endpoint:    g.endpoint,
+			Victim:      g.victim,
+			OperationID: g.pairs[0].OperationID,
+			Verdict:     g.verdict,
+			Confidence:  g.confidence,
+		}
+		for _, rl := range g.pairs {
+			f.Pairs = append(f.Pairs, SummaryPair{
+				ID:         rl.ID(),
+				Creds:      rl.Test.Request.AuthUser,
+				Object:     rl.Control.Request.AuthUser,
+				Confidence: rl.Confidence,
+			})
+		}
+		s.Findings = append(s.Findings, f)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
@@ -0,0 +1,45 @@
+package logging
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/yansol0/aperture/runner"
+)
+
+// tapEntry is one line of a tap log.
+type tapEntry struct {
+	Time     time.Time              `json:"time"`
+	Request  runner.RequestDetails  `json:"request"`
+	Response runner.ResponseDetails `json:"response"`
+}
+
+// NewTap returns a runner.Runner.Tap callback writing each exchange to w as a JSON line
+// as it happens. A w with a Flush method, such as a gzip writer, is flushed after each
+// line, so an interrupted run leaves every exchange so far readable. Writes are
+// serialized, so the callback is safe for concurrent use; the first write error is
+// returned by the second function, and later exchanges are dropped.
+func NewTap(w io.Writer) (func(runner.RequestDetails, runner.ResponseDetails), func() error) {
+	var (
+		mu  sync.Mutex
+		err error
+	)
+	enc := json.NewEncoder(w)
+	tap := func(req runner.RequestDetails, resp runner.ResponseDetails) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err == nil {
+			err = enc.Encode(tapEntry{Time: time.Now(), Request: req, Response: resp})
+		}
+		if f, ok := w.(interface{ Flush() error }); ok && err == nil {
+			err = f.Flush()
+		}
+	}
+	return tap, func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		return err
+	}
+}
@@ -1,20 +1,30 @@
 package main
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"log"
+	"net/http"
 	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/spf13/pflag"
+	"github.com/yansol0/aperture/defectdojo"
 	"github.com/yansol0/aperture/logging"
 	"github.com/yansol0/aperture/openapiutil"
 	"github.com/yansol0/aperture/runner"
 	"github.com/yansol0/aperture/testconfig"
+	"github.com/yansol0/aperture/theme"
 	"github.com/yansol0/aperture/tui"
+	"golang.org/x/term"
 )
 
 func main() {
@@ -27,7 +37,70 @@ func main() {
 		timeoutSec int
 		jsonl      bool
 		listOnly   bool
+		checkOnly  bool
+		planOut    string
+		planIn     string
+		baseline   string
+		baseOut    string
+		coverage   string
+		matrixPath string
 		skipDelete bool
+		minPatch   bool
+		noAuth     bool
+		requireOK  bool
+		strictSpec bool
+		reclassify bool
+		bodyOpts   runner.BodyOptions
+		onlyOps    []string
+		excludeOps []string
+		deprecated string
+		expandEnum bool
+		enumMax    int
+		expandEx   bool
+		groupBy    string
+		outDir     string
+		spillDir   string
+		spillMin   int
+		keepBodies string
+		tapPath    string
+		noPreflt   bool
+		userAgent  string
+		queryArgs  []string
+		strictVals bool
+		noVerify   bool
+		confirmW   bool
+		condProbe  bool
+		versionPfx string
+		showVer    bool
+		noTUI      bool
+		recordRaw  bool
+		colorMode  string
+		themeName  string
+		quiet      bool
+		allowMut   bool
+		refreshN   int
+		warmupN    int
+		ddPath     string
+		ddURL      string
+		ddToken    string
+		ddEng      int
+		nucleiDir  string
+		authHeader string
+		discover   bool
+		discoverN  int
+		selfTest   bool
+		skipIdent  bool
+		noDefSkips bool
+		matchPct   float64
+		allowHosts []string
+		concurrN   int
+		noAdapt    bool
+		adaptRate  float64
+		adaptWin   int
+
+		allowExternalRefs bool
+		allowedRefs       []string
+		bundlePath        string
 	)
 
 	// Use a custom FlagSet to control help/error behavior
@@ -36,15 +109,79 @@ func main() {
 	fs.SetOutput(io.Discard) // suppress pflag's own error/help lines; we print our own
 
 	// Define flags (short and long forms)
-	fs.StringVarP(&specPath, "spec", "s", "", "Path or URL to OpenAPI spec (JSON or YAML)")
-	fs.StringVarP(&configPath, "config", "c", "", "Path to YAML config file with users and fields")
+	fs.StringVarP(&specPath, "spec", "s", "", "Path or URL to OpenAPI spec (JSON or YAML; .gz is gunzipped)")
+	fs.StringVarP(&configPath, "config", "c", "", "Path to YAML config file with users and fields (.gz is gunzipped, as is a .gz fields_csv)")
 	fs.StringVarP(&baseURL, "base-url", "b", "", "Base URL to target API (overrides OpenAPI servers[0])")
-	fs.StringVarP(&outPath, "out", "o", "aperture_log.txt", "Output log file path")
+	fs.StringSliceVar(&allowHosts, "allow-host", nil, "Host requests may be sent to, with an optional :port (repeatable; default: only the base URL's host)")
+	fs.StringVar(&outDir, "output-dir", "", "Also write <timestamp>-results.txt, -results.jsonl and -summary.json to this directory (created if missing); --out is then only written when given explicitly")
+	fs.StringVarP(&outPath, "out", "o", "aperture_log.txt", "Output log file path (- writes results to stdout; a .gz suffix gzip-compresses it, as it does for the other report paths, trading a little CPU and memory for much smaller files)")
+	fs.StringVar(&authHeader, "auth-header", "", "Header carrying header credentials, overriding default_auth_header_name (a user's header_name still wins)")
+	fs.StringVar(&userAgent, "user-agent", "", "User-Agent header sent with every request (default aperture/<version>)")
+	fs.StringArrayVar(&queryArgs, "query", nil, "Query parameter key=value added to every request unless the request already sets it (repeatable)")
+	fs.BoolVar(&showVer, "version", false, "Print version and build information and exit")
+	fs.BoolVar(&noTUI, "no-tui", false, "Print plain progress lines instead of the interactive UI (automatic when stdout is not a terminal)")
+	fs.StringVar(&colorMode, "color", theme.ColorAuto, "When to use color in the UI and console output: auto, always or never (auto honors NO_COLOR)")
+	fs.StringVar(&themeName, "theme", "default", "Color theme for the UI and console output: default, light or mono")
+	fs.BoolVar(&quiet, "quiet", false, "Print nothing but fatal errors; only the output files are written")
 	fs.BoolVarP(&verbose, "verbose", "v", false, "Verbose logging")
 	fs.IntVarP(&timeoutSec, "timeout", "t", 20, "HTTP request timeout in seconds")
+	fs.IntVar(&concurrN, "concurrency", 1, "Number of operations tested at once (1 tests them one after another)")
+	fs.BoolVar(&noAdapt, "no-adaptive", false, "Keep --concurrency fixed instead of halving the requests in flight to a host while it returns errors")
+	fs.Float64Var(&adaptRate, "adaptive-error-rate", runner.DefaultAdaptiveErrorRate, "Share of a host's recent requests that may fail (transport errors, 5xx, 429) before its concurrency is halved")
+	fs.IntVar(&adaptWin, "adaptive-window", runner.DefaultAdaptiveWindow, "Number of a host's most recent requests the adaptive error rate is measured over")
+	fs.StringVar(&spillDir, "spill-dir", "", "Write response bodies larger than --spill-threshold to this directory instead of keeping them in memory")
+	fs.IntVar(&spillMin, "spill-threshold", 64*1024, "Size in bytes above which --spill-dir stores a response body on disk")
+	fs.StringVar(&keepBodies, "keep-bodies", runner.KeepBodiesAll, "Spilled bodies to keep after the run: none, findings or all")
+	fs.BoolVar(&noPreflt, "skip-preflight", false, "Do not check that the target is reachable and each user's credentials are accepted before the scan")
+	fs.StringVar(&tapPath, "tap", "", "Append every request and response to this file as a JSON line the moment it completes, for debugging a run in progress (.gz compresses it, flushed after every line)")
+	fs.BoolVar(&recordRaw, "raw", false, "Record the exact bytes of every request in the output log")
 	fs.BoolVarP(&jsonl, "jsonl", "j", false, "Write JSON Lines output instead of text")
 	fs.BoolVarP(&listOnly, "list", "l", false, "List unique path parameter names from the provided spec and exit")
+	fs.StringVar(&ddPath, "defectdojo", "", "Write IDOR and potential findings in DefectDojo's Generic Findings Import format to this path")
+	fs.StringVar(&ddURL, "defectdojo-url", "", "Upload findings to this DefectDojo instance's import-scan API after the run")
+	fs.StringVar(&ddToken, "defectdojo-token", os.Getenv("DEFECTDOJO_TOKEN"), "DefectDojo API token (default $DEFECTDOJO_TOKEN)")
+	fs.IntVar(&ddEng, "defectdojo-engagement", 0, "DefectDojo engagement ID findings are imported into")
+	fs.StringVar(&nucleiDir, "nuclei-dir", "", "Write a nuclei template reproducing each IDOR finding to this directory")
+	fs.BoolVar(&checkOnly, "config-check", false, "Validate the config against the spec and report coverage without sending requests")
+	fs.StringVar(&planOut, "plan-out", "", "Write every request the scan would send, with credentials redacted, and the skip list to this JSON file (.gz compresses it), then exit without sending anything")
+	fs.StringVar(&planIn, "plan", "", "Send only the requests in this approved --plan-out file (.gz is gunzipped); pairs needing any other request are skipped")
+	fs.StringVar(&baseline, "baseline", "", "JSON Lines results of an earlier run (--jsonl or --baseline-out; .gz is gunzipped); only findings not in it are listed, and the exit status is 1 when an IDOR FOUND is new")
+	fs.StringVar(&baseOut, "baseline-out", "", "Write this run's IDOR FOUND and POTENTIAL results as JSON Lines to this path, for use as the next --baseline")
+	fs.StringVar(&coverage, "coverage", "", "Write a report of which users can test which endpoints to this path (JSON if it ends in .json, text otherwise; .gz compresses it)")
+	fs.StringVar(&matrixPath, "coverage-matrix", "", "After the scan, write a CSV with every spec operation, including untested ones, and its outcome and verdict counts to this path")
+	fs.BoolVar(&strictVals, "strict-fields", false, "Abort when a user field value does not fit the spec's schema for that name")
+	fs.BoolVar(&allowMut, "allow-mutations", false, "Test POST/PUT/PATCH/DELETE operations; they are skipped otherwise so no data is modified")
+	fs.BoolVar(&confirmW, "confirm-writes", false, "Ask in the TUI before sending each mutating (POST/PUT/PATCH/DELETE) cross-user request; implies --allow-mutations")
+	fs.BoolVar(&discover, "discover", false, "Before the scan, call list endpoints as each user and fill missing user fields with IDs of their own objects")
+	fs.IntVar(&discoverN, "discover-max-endpoints", 20, "Maximum list endpoints --discover calls, once per user each (0 for no limit)")
+	fs.BoolVar(&selfTest, "self-test", false, "Before the scan, compare users' own GET responses against themselves and each other to check the comparison settings are neither too strict nor too loose")
+	fs.IntVar(&refreshN, "refresh-after", 1, "Consecutive 401 responses to a user's requests before running that user's auth.refresh request")
+	fs.BoolVar(&noVerify, "no-verify-writes", false, "Do not re-read objects after successful cross-user writes to confirm they changed")
+	fs.BoolVar(&condProbe, "conditional-probe", false, "Repeat GET/HEAD tests with If-None-Match set to the control's ETag; a 304 to the attacker is reported as IDOR FOUND")
 	fs.BoolVar(&skipDelete, "skip-delete", false, "Skip DELETE requests during testing (default: false)")
+	fs.BoolVar(&minPatch, "minimal-patch", true, "Send PATCH bodies with only the properties the object user has fields for (--minimal-patch=false synthesizes every required property)")
+	fs.BoolVar(&noAuth, "include-no-auth", false, "Also test operations that declare no security requirement in the spec")
+	fs.BoolVar(&requireOK, "require-success-response", false, "Skip operations whose spec declares no 2xx response")
+	fs.BoolVar(&strictSpec, "strict-spec", false, "Downgrade IDOR FOUND to POTENTIAL when the control got a status the spec does not document")
+	fs.BoolVar(&reclassify, "reclassify", false, "Retry every POTENTIAL pair once after the scan and take the new verdict when it is no longer POTENTIAL")
+	fs.BoolVar(&noDefSkips, "no-default-skips", false, "Also test health, metrics and documentation endpoints such as /healthz and /swagger.json")
+	fs.Float64Var(&matchPct, "body-match-pct", 0, "Treat test and control bodies sharing at least this percentage of tokens as equal when detecting IDOR (0 or 100: exact match)")
+	fs.BoolVar(&skipIdent, "skip-identical-errors", false, "Record pairs whose control and test fail identically (same error, or same non-2xx status and body) as skipped")
+	fs.StringSliceVar(&onlyOps, "only-operation", nil, "Only test operations with these operationIds (repeatable or comma-separated)")
+	fs.StringSliceVar(&excludeOps, "exclude-operation", nil, "Never test operations with these operationIds (repeatable or comma-separated)")
+	fs.StringVar(&versionPfx, "version-prefix", "", "Regexp matched at the start of paths and stripped to group results by resource across API versions (e.g. '/v[0-9]+')")
+	fs.StringVar(&groupBy, "group-findings", logging.GroupByEndpoint, "How the console summary deduplicates findings: endpoint (one per method, path and verdict) or victim (also per object owner)")
+	fs.StringVar(&deprecated, "deprecated", runner.DeprecatedInclude, "How to treat deprecated operations: skip, include or only")
+	fs.BoolVar(&expandEnum, "expand-enums", false, "Run each pair once per value of enum-constrained query parameters not set by user fields")
+	fs.IntVar(&enumMax, "expand-enums-max", 5, "Only expand query enums with at most this many values")
+	fs.IntVar(&warmupN, "warmup", 0, "Discarded requests sent to each read-only endpoint before its first pair, so caches are warm for the compared requests")
+	fs.BoolVar(&expandEx, "expand-examples", false, "Run each pair once per named request body example in the spec, sending the example instead of a synthesized body")
+	fs.IntVar(&bodyOpts.MaxDepth, "body-max-depth", 0, "Maximum object nesting when synthesizing request bodies (0 = unlimited)")
+	fs.IntVar(&bodyOpts.ArrayCap, "body-array-cap", 0, "Size synthesized arrays from minItems/maxItems, capped at this many items (0 = always one item)")
+	fs.BoolVar(&bodyOpts.IncludeOptional, "body-include-optional", false, "Synthesize optional body properties even when no user field matches")
+	fs.BoolVar(&allowExternalRefs, "allow-external-refs", false, "Resolve $refs that point outside the spec (files or URLs)")
+	fs.StringSliceVar(&allowedRefs, "allow-ref", nil, "URL prefix or directory external $refs may resolve to (repeatable; requires --allow-external-refs)")
+	fs.StringVar(&bundlePath, "bundle", "", "Write the spec with all external $refs inlined to this path")
 
 	// Custom usage/help
 	fs.Usage = func() {
@@ -59,12 +196,12 @@ func main() {
 		`
 		fmt.Fprintln(w, bannerString)
 		fmt.Fprintf(w, "Aperture IDOR Tester\n\n")
-		fmt.Fprintf(w, "Usage:\n  aperture --spec <path-or-url> --config <config.yaml> [--base-url URL] [--out PATH] [--timeout SECONDS] [--jsonl] [--verbose] [--list] [--skip-delete]\n\n")
+		fmt.Fprintf(w, "Usage:\n  aperture --spec <path-or-url> --config <config.yaml> [--base-url URL] [--out PATH] [--timeout SECONDS] [--jsonl] [--verbose] [--list] [--allow-mutations] [--skip-delete] [--include-no-auth] [--require-success-response] [--allow-external-refs [--allow-ref PREFIX]...] [--bundle PATH]\n\n")
 		fmt.Fprintf(w, "Options:\n")
 		fs.SetOutput(w)
 		fs.PrintDefaults()
 		fs.SetOutput(io.Discard)
-		fmt.Fprintf(w, "\nExamples:\n  aperture -s openapi.json -c config.yml -b https://api.example.com -o out.jsonl -j -v --skip-delete\n  aperture --spec /path/to/openapi.json --list\n")
+		fmt.Fprintf(w, "\nExamples:\n  aperture -s openapi.json -c config.yml -b https://api.example.com -o out.jsonl -j -v --allow-mutations --skip-delete\n  aperture --spec /path/to/openapi.json --list\n  aperture -s openapi.json -c config.yml --config-check\n  aperture --spec openapi.yaml --allow-external-refs --allow-ref ./schemas --bundle bundled.json\n")
 	}
 
 	if err := fs.Parse(os.Args[1:]); err != nil {
@@ -76,25 +213,176 @@ func main() {
 		os.Exit(2)
 	}
 
+	if showVer {
+		fmt.Println(runner.BuildInfo())
+		return
+	}
+
+	if err := theme.Configure(colorMode, themeName); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+
 	// Validate required flags
 	if specPath == "" {
 		fmt.Fprintln(os.Stderr, "missing required flag: --spec")
 		fs.Usage()
 		os.Exit(2)
 	}
-	if !listOnly && configPath == "" {
+	switch deprecated {
+	case runner.DeprecatedInclude, runner.DeprecatedSkip, runner.DeprecatedOnly:
+	default:
+		fmt.Fprintf(os.Stderr, "invalid --deprecated value %q: want skip, include or only\n", deprecated)
+		fs.Usage()
+		os.Exit(2)
+	}
+	if matchPct < 0 || matchPct > 100 {
+		fmt.Fprintf(os.Stderr, "invalid --body-match-pct %g: want 0 to 100\n", matchPct)
+		os.Exit(2)
+	}
+	if outDir != "" {
+		// Fail before the scan rather than after it
+		if err := os.MkdirAll(outDir, 0o755); err != nil {
+			fmt.Fprintf(os.Stderr, "cannot create --output-dir: %v\n", err)
+			os.Exit(2)
+		}
+	}
+	if concurrN < 1 {
+		fmt.Fprintf(os.Stderr, "invalid --concurrency %d: want 1 or more\n", concurrN)
+		fs.Usage()
+		os.Exit(2)
+	}
+	if adaptRate <= 0 || adaptRate >= 1 {
+		fmt.Fprintf(os.Stderr, "invalid --adaptive-error-rate %g: want more than 0 and less than 1\n", adaptRate)
+		fs.Usage()
+		os.Exit(2)
+	}
+	if adaptWin < 1 {
+		fmt.Fprintf(os.Stderr, "invalid --adaptive-window %d: want 1 or more\n", adaptWin)
+		fs.Usage()
+		os.Exit(2)
+	}
+	switch keepBodies {
+	case runner.KeepBodiesNone, runner.KeepBodiesFindings, runner.KeepBodiesAll:
+	default:
+		fmt.Fprintf(os.Stderr, "invalid --keep-bodies value %q: want none, findings or all\n", keepBodies)
+		fs.Usage()
+		os.Exit(2)
+	}
+	if warmupN < 0 {
+		fmt.Fprintf(os.Stderr, "invalid --warmup %d: want 0 or more\n", warmupN)
+		fs.Usage()
+		os.Exit(2)
+	}
+	if groupBy != logging.GroupByEndpoint && groupBy != logging.GroupByVictim {
+		fmt.Fprintf(os.Stderr, "invalid --group-findings value %q: want endpoint or victim\n", groupBy)
+		fs.Usage()
+		os.Exit(2)
+	}
+	extraQuery := map[string]string{}
+	for _, kv := range queryArgs {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || k == "" {
+			fmt.Fprintf(os.Stderr, "invalid --query %q: want key=value\n", kv)
+			fs.Usage()
+			os.Exit(2)
+		}
+		extraQuery[k] = v
+	}
+	var versionRe *regexp.Regexp
+	if versionPfx != "" {
+		re, err := regexp.Compile(versionPfx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid --version-prefix: %v\n", err)
+			os.Exit(2)
+		}
+		versionRe = re
+	}
+	stdoutIsTerminal := term.IsTerminal(int(os.Stdout.Fd()))
+	plain := noTUI || quiet || !stdoutIsTerminal
+	if plain && confirmW {
+		fmt.Fprintln(os.Stderr, "--confirm-writes needs the interactive UI; it cannot be used with --no-tui, --quiet or without a terminal")
+		os.Exit(2)
+	}
+	if ddURL != "" && (ddToken == "" || ddEng <= 0) {
+		fmt.Fprintln(os.Stderr, "--defectdojo-url needs --defectdojo-token (or DEFECTDOJO_TOKEN) and --defectdojo-engagement")
+		os.Exit(2)
+	}
+	if (planOut != "" || planIn != "") && (discover || selfTest) {
+		// Both send requests whose outcome shapes the scan, so they cannot be planned
+		fmt.Fprintln(os.Stderr, "--plan-out and --plan cannot be used with --discover or --self-test")
+		os.Exit(2)
+	}
+	if planOut != "" && planIn != "" {
+		fmt.Fprintln(os.Stderr, "--plan-out and --plan are mutually exclusive")
+		os.Exit(2)
+	}
+	var known logging.Baseline
+	if baseline != "" {
+		// Fail before the scan rather than after it
+		b, err := readBaseline(baseline)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cannot read --baseline: %v\n", err)
+			os.Exit(2)
+		}
+		known = b
+	}
+	if !listOnly && bundlePath == "" && configPath == "" {
 		fmt.Fprintln(os.Stderr, "missing required flag: --config")
 		fs.Usage()
 		os.Exit(2)
 	}
 
+	// Status lines, progress and the summary go to stdout unless results are written there
+	var console io.Writer = os.Stdout
+	if outPath == "-" {
+		console = os.Stderr
+	}
+	if quiet {
+		console = io.Discard
+	}
+
 	ctx := context.Background()
+	// One client for spec loading and scanning so both use the same network settings
+	httpClient := &http.Client{Timeout: time.Duration(timeoutSec) * time.Second}
+
+	// The spec and the config are independent; load them at the same time
+	configCh := make(chan configResult, 1)
+	loadConfig := configPath != "" && !listOnly
+	if loadConfig {
+		go func() {
+			cfg, err := testconfig.Load(configPath)
+			configCh <- configResult{cfg, err}
+		}()
+	}
 
 	// Load OpenAPI
-	fmt.Printf("[*] Loading OpenAPI spec from %s\n", specPath)
-	swagger, inferredBaseURL, err := openapiutil.LoadSpec(ctx, specPath)
+	fmt.Fprintf(console, "[*] Loading OpenAPI spec from %s\n", specPath)
+	swagger, inferredBaseURL, err := openapiutil.LoadSpec(ctx, specPath, openapiutil.LoadOptions{
+		AllowExternalRefs:  allowExternalRefs,
+		AllowedRefPrefixes: allowedRefs,
+		HTTPClient:         httpClient,
+	})
+	var problems startupProblems
 	if err != nil {
-		log.Fatalf("failed to load OpenAPI spec: %v", err)
+		problems.add("spec", specPath, "failed to load OpenAPI spec: %v", err)
+		if loadConfig {
+			// Report a broken config in the same run rather than after the spec is fixed
+			if res := <-configCh; res.err != nil {
+				problems.add("config", configPath, "failed to load config: %v", res.err)
+			}
+		}
+		problems.fatal()
+	}
+
+	if bundlePath != "" {
+		if err := openapiutil.WriteBundle(ctx, swagger, bundlePath); err != nil {
+			log.Fatalf("failed to bundle OpenAPI spec: %v", err)
+		}
+		fmt.Fprintf(console, "[✓] Wrote bundled spec to %s\n", bundlePath)
+		if configPath == "" && !listOnly {
+			return
+		}
 	}
 
 	if listOnly {
@@ -109,74 +397,550 @@ func main() {
 		baseURL = inferredBaseURL
 	}
 	if baseURL == "" {
-		log.Fatalf("base URL not provided and not found in spec servers")
+		problems.add("spec", specPath, "base URL not provided and not found in spec servers")
+	}
+	if unknown := openapiutil.UnknownOperationIDs(swagger, append(append([]string(nil), onlyOps...), excludeOps...)); len(unknown) > 0 {
+		problems.add("spec", specPath, "unknown operationId(s) requested: %s", strings.Join(unknown, ", "))
 	}
-	fmt.Printf("[✓] OpenAPI loaded; base URL: %s; paths: %d\n", baseURL, len(swagger.Paths.Map()))
+	res := <-configCh
+	if res.err != nil {
+		problems.add("config", configPath, "failed to load config: %v", res.err)
+	} else if len(res.cfg.Users) < 2 {
+		problems.add("config", configPath, "config must define at least two users")
+	}
+	problems.fatal()
+	fmt.Fprintf(console, "[✓] OpenAPI loaded; base URL: %s; paths: %d\n", baseURL, len(swagger.Paths.Map()))
 
 	// Load Config
-	fmt.Printf("[*] Loading config from %s\n", configPath)
-	cfg, err := testconfig.Load(configPath)
-	if err != nil {
-		log.Fatalf("failed to load config: %v", err)
+	fmt.Fprintf(console, "[*] Loading config from %s\n", configPath)
+	cfg := res.cfg
+	fmt.Fprintf(console, "[✓] Config loaded; users: %d\n", len(cfg.Users))
+	if authHeader != "" {
+		cfg.DefaultAuthHeaderName = authHeader
+	}
+	if b := cfg.Body; b != nil {
+		// The --body-* flags win over the config's body section
+		if !fs.Changed("body-max-depth") {
+			bodyOpts.MaxDepth = b.MaxDepth
+		}
+		if !fs.Changed("body-array-cap") {
+			bodyOpts.ArrayCap = b.ArrayCap
+		}
+		if !fs.Changed("body-include-optional") {
+			bodyOpts.IncludeOptional = b.IncludeOptional
+		}
 	}
-	fmt.Printf("[✓] Config loaded; users: %d\n", len(cfg.Users))
-	if len(cfg.Users) < 2 {
-		log.Fatalf("config must define at least two users")
+	for _, w := range cfg.JWTWarnings(time.Now()) {
+		fmt.Fprintf(console, "[!] WARNING: %s\n", w)
 	}
 
 	// Prepare runner with events
 	events := make(chan runner.Event, 64)
+	commands := make(chan runner.Command, 8)
 	r := runner.Runner{
-		Spec:        swagger,
-		BaseURL:     baseURL,
-		Config:      cfg,
-		Verbose:     verbose,
-		HTTPTimeout: time.Duration(timeoutSec) * time.Second,
-		Events:      events,
-		SkipDelete:  skipDelete,
-	}
-
-	// Start TUI
-	ui := tui.NewModel(tui.ModelInit{
-		SpecPath:   specPath,
-		ConfigPath: configPath,
-		BaseURL:    baseURL,
-		Events:     events,
-	})
+		Spec:             swagger,
+		BaseURL:          baseURL,
+		Config:           cfg,
+		Verbose:          verbose,
+		QuietStdout:      !plain || console != os.Stdout,
+		HTTPTimeout:      time.Duration(timeoutSec) * time.Second,
+		HTTPClient:       httpClient,
+		UserAgent:        userAgent,
+		ExtraQuery:       extraQuery,
+		RecordRaw:        recordRaw,
+		VerifyWrites:     !noVerify,
+		ConfirmWrites:    confirmW,
+		ConditionalProbe: condProbe,
+		Events:           events,
+		Commands:         commands,
+		SkipDelete:       skipDelete,
+		MinimalPatch:     minPatch,
+		IncludeNoAuth:    noAuth,
+
+		// Confirming each write is explicit consent to mutations
+		AllowMutations:         allowMut || confirmW,
+		RequireSuccessResponse: requireOK,
+		StrictSpec:             strictSpec,
+		Reclassify:             reclassify,
+		SkipIdenticalErrors:    skipIdent,
+		NoDefaultSkips:         noDefSkips,
+		BodyMatchPct:           matchPct,
+		BodyOptions:            bodyOpts,
+
+		OnlyOperations:    onlyOps,
+		ExcludeOperations: excludeOps,
+		Deprecated:        deprecated,
+		VersionPrefix:     versionRe,
+		ExpandEnums:       expandEnum,
+		EnumMax:           enumMax,
+		ExpandExamples:    expandEx,
+		RefreshAfter:      refreshN,
+		WarmupRequests:    warmupN,
+
+		Concurrency:       concurrN,
+		Adaptive:          !noAdapt,
+		AdaptiveErrorRate: adaptRate,
+		AdaptiveWindow:    adaptWin,
+
+		SpillDir:       spillDir,
+		SpillThreshold: spillMin,
+
+		AllowedHosts: allowHosts,
+	}
+	if bad := r.DisallowedHosts(); len(bad) > 0 {
+		log.Fatalf("%s", strings.Join(bad, "; "))
+	}
+	// Redirects must not leave the allowed hosts either
+	httpClient.CheckRedirect = r.CheckRedirect
+
+	for _, w := range r.UnknownExtensions() {
+		fmt.Fprintf(console, "[!] WARNING: %s\n", w)
+	}
+
+	if !checkOnly {
+		for _, w := range r.SharedCredentials() {
+			fmt.Fprintf(console, "[!] WARNING: %s\n", w)
+		}
+	}
+
+	if mismatches := r.FieldValueMismatches(); len(mismatches) > 0 && !checkOnly {
+		for _, m := range mismatches {
+			fmt.Fprintf(console, "[!] %s\n", m)
+		}
+		if strictVals {
+			log.Fatalf("%d field value mismatch(es) with --strict-fields", len(mismatches))
+		}
+	}
+
+	if coverage != "" {
+		if err := writeCoverage(coverage, r.CheckConfig()); err != nil {
+			log.Fatalf("failed to write coverage report: %v", err)
+		}
+		fmt.Fprintf(console, "[✓] Wrote coverage report to %s\n", coverage)
+	}
+
+	var dojo *defectdojo.Client
+	if ddURL != "" {
+		// Check the token before the scan rather than failing the upload after it
+		dojo = &defectdojo.Client{BaseURL: ddURL, Token: ddToken, HTTPClient: httpClient}
+		if err := dojo.CheckEngagement(ctx, ddEng); err != nil {
+			log.Fatalf("DefectDojo check failed: %v", err)
+		}
+		fmt.Fprintf(console, "[✓] DefectDojo engagement %d is reachable\n", ddEng)
+	}
+
+	// closeTap finishes the tap log; it also runs before the exit on new findings, which
+	// skips deferred calls
+	closeTap := func() {}
+	if tapPath != "" && !checkOnly {
+		tapFile, err := openOutput(tapPath, os.O_APPEND)
+		if err != nil {
+			log.Fatalf("failed to open tap log: %v", err)
+		}
+		var tapErr func() error
+		r.Tap, tapErr = logging.NewTap(tapFile)
+		closeTap = func() {
+			err := tapErr()
+			if cerr := tapFile.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				log.Printf("tap log incomplete: %v", err)
+			}
+			closeTap = func() {}
+		}
+	}
+	defer func() { closeTap() }()
+
+	if checkOnly {
+		if printConfigCheck(r.CheckConfig()) == 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if planOut != "" {
+		plan, err := r.BuildPlan(ctx)
+		if err != nil {
+			log.Fatalf("failed to build plan: %v", err)
+		}
+		if err := runner.WritePlan(planOut, plan); err != nil {
+			log.Fatalf("failed to write plan: %v", err)
+		}
+		fmt.Fprintf(console, "[✓] Wrote plan of %d request(s) and %d skip(s) to %s; nothing was sent\n", len(plan.Requests), len(plan.Skipped), planOut)
+		return
+	}
+	if planIn != "" {
+		plan, err := runner.ReadPlan(planIn)
+		if err != nil {
+			log.Fatalf("failed to read plan: %v", err)
+		}
+		if err := r.UsePlan(plan); err != nil {
+			log.Fatalf("cannot use plan: %v", err)
+		}
+		fmt.Fprintf(console, "[*] Sending only the %d request(s) in %s\n", len(plan.Requests), planIn)
+		// The preflight requests are not part of the plan
+		noPreflt = true
+	}
+
+	var preflight *runner.PreflightReport
+	if !noPreflt {
+		report, err := r.Preflight(ctx)
+		if err != nil {
+			log.Fatalf("preflight failed: %v (use --skip-preflight to scan anyway)", err)
+		}
+		fmt.Fprintf(console, "[✓] Target reachable: HEAD %s returned %d\n", baseURL, report.Status)
+		for _, w := range report.Warnings() {
+			fmt.Fprintf(console, "[!] WARNING: %s\n", w)
+		}
+		preflight = &report
+	}
+
+	if discover {
+		fmt.Fprintf(console, "[*] Discovering object IDs from list endpoints\n")
+		found := r.Discover(ctx, discoverN)
+		for _, d := range found {
+			fmt.Fprintf(console, "[✓] Discovered %s\n", d)
+		}
+		fmt.Fprintf(console, "[✓] Discovery filled %d field(s); add them to the config to skip discovery next time\n", len(found))
+	}
+
+	var selfTestReport *runner.SelfTestReport
+	if selfTest {
+		fmt.Fprintf(console, "[*] Self-test: comparing baseline responses\n")
+		report := r.SelfTest(ctx)
+		for _, a := range report.Anomalies {
+			fmt.Fprintf(console, "[!] WARNING: self-test: %s\n", a)
+		}
+		fmt.Fprintf(console, "[✓] Self-test checked %d endpoint(s); anomalies: %d\n", report.Endpoints, len(report.Anomalies))
+		selfTestReport = &report
+	}
+
+	for _, p := range r.PacingOverrides() {
+		fmt.Fprintf(console, "[*] Pacing %s\n", p)
+	}
+
+	// Run execution in a separate goroutine so progress can be rendered meanwhile
+	var (
+		results []runner.ResultLog
+		runErr  error
+	)
+	finished := make(chan struct{})
+	started := time.Now()
 	go func() {
-		// Run execution in a separate goroutine so TUI can render
-		results, err := r.Execute(ctx)
+		results, runErr = r.Execute(ctx)
 		close(events)
-		ui.Done(results, err)
+		close(finished)
 	}()
 
-	if err := ui.Run(); err != nil {
-		log.Fatalf("ui error: %v", err)
+	if plain {
+		tui.RunPlain(console, events, console == os.Stdout && stdoutIsTerminal)
+		<-finished
+	} else {
+		ui := tui.NewModel(tui.ModelInit{
+			SpecPath:   specPath,
+			ConfigPath: configPath,
+			BaseURL:    baseURL,
+			Events:     events,
+			Commands:   commands,
+		})
+		go func() {
+			<-finished
+			ui.Done(results, runErr)
+		}()
+		if err := ui.Run(); err != nil {
+			log.Fatalf("ui error: %v", err)
+		}
+		select {
+		case <-finished:
+		default:
+			log.Fatalf("no results produced: the run was interrupted")
+		}
+	}
+	if runErr != nil {
+		log.Fatalf("run failed: %v", runErr)
 	}
+	newIDOR := 0
+	if known != nil {
+		newIDOR = known.Mark(results)
+	}
+	stats := logging.RunStats{
+		TestedEndpoints: r.TestedEndpoints,
+		Requests:        r.CompletedRequests,
+		Elapsed:         time.Since(started),
+		AuthRefreshes:   r.AuthRefreshes,
+		Traffic:         r.Traffic,
+		Concurrency:     r.EffectiveConcurrency,
+		FindingsBy:      groupBy,
+		Preflight:       preflight,
+		Pacing:          r.PacingOverrides(),
+		SelfTest:        selfTestReport,
+	}
+
+	// Result files list body refs; those of spilled bodies about to be pruned lose their path
+	fileResults := runner.WithoutPrunedBodyRefs(results, keepBodies)
 
-	// After TUI completes, it provides results
-	results := ui.Results()
-	if results == nil {
-		log.Fatalf("no results produced")
+	// With --output-dir the default --out file is not written; an explicit one still is
+	if outDir == "" || fs.Changed("out") {
+		fmt.Fprintf(console, "[*] Writing results to %s\n", outPath)
+		var (
+			f   io.Writer = os.Stdout
+			out io.WriteCloser
+		)
+		if outPath != "-" {
+			out, err = createOutput(outPath)
+			if err != nil {
+				log.Fatalf("failed to open output file: %v", err)
+			}
+			f = out
+		}
+
+		format, written := logging.FormatText, results
+		if jsonl {
+			format, written = logging.FormatJSONL, fileResults
+		}
+		if err := logging.WriteResults(f, format, written, baseURL); err != nil {
+			log.Printf("failed to write %s output: %v", format, err)
+		}
+		if out != nil {
+			if err := out.Close(); err != nil {
+				log.Printf("failed to finish output file: %v", err)
+			}
+		}
+		fmt.Fprintf(console, "[✓] Wrote %d results to %s\n", len(results), outPath)
 	}
-	fmt.Printf("[*] Writing results to %s\n", outPath)
-	f, err := os.Create(outPath)
-	if err != nil {
-		log.Fatalf("failed to open output file: %v", err)
+
+	if outDir != "" {
+		prefix := filepath.Join(outDir, started.Format("20060102-150405")+"-")
+		for _, o := range []struct {
+			name  string
+			write func(io.Writer) error
+		}{
+			{"results.txt", func(w io.Writer) error { return logging.WriteResults(w, logging.FormatText, results, baseURL) }},
+			{"results.jsonl", func(w io.Writer) error { return logging.WriteResults(w, logging.FormatJSONL, fileResults, baseURL) }},
+			{"summary.json", func(w io.Writer) error { return logging.WriteSummaryJSON(w, results, stats) }},
+		} {
+			// Keep going on failure so one bad file does not cost the others
+			if err := writeFile(prefix+o.name, o.write); err != nil {
+				log.Printf("failed to write %s: %v", prefix+o.name, err)
+				continue
+			}
+			fmt.Fprintf(console, "[✓] Wrote %s\n", prefix+o.name)
+		}
 	}
-	defer f.Close()
 
-	if jsonl {
-		if err := logging.WriteJSONL(f, results); err != nil {
-			log.Printf("failed to write JSONL output: %v", err)
+	if ddPath != "" || dojo != nil {
+		if err := exportDefectDojo(ctx, ddPath, dojo, ddEng, results); err != nil {
+			log.Printf("DefectDojo export failed: %v", err)
+		} else {
+			fmt.Fprintf(console, "[✓] Exported findings to DefectDojo format\n")
 		}
-	} else {
-		if err := logging.WriteText(f, results, baseURL); err != nil {
-			log.Printf("failed to write text log: %v", err)
+	}
+
+	if matrixPath != "" {
+		if err := writeFile(matrixPath, func(w io.Writer) error { return logging.WriteCoverageCSV(w, results, swagger) }); err != nil {
+			log.Printf("failed to write coverage matrix: %v", err)
+		} else {
+			fmt.Fprintf(console, "[✓] Wrote coverage matrix to %s\n", matrixPath)
+		}
+	}
+
+	if nucleiDir != "" {
+		n, err := logging.WriteNucleiTemplates(nucleiDir, results, authHeaderNames(cfg))
+		if err != nil {
+			log.Printf("failed to write nuclei templates: %v", err)
+		}
+		fmt.Fprintf(console, "[✓] Wrote %d nuclei templates to %s\n", n, nucleiDir)
+	}
+
+	// Every output is written; drop spilled bodies that are not to be kept
+	if err := runner.PruneSpilledBodies(results, keepBodies); err != nil {
+		log.Printf("failed to remove spilled bodies: %v", err)
+	}
+
+	if baseOut != "" {
+		if err := writeFile(baseOut, func(w io.Writer) error { return logging.WriteBaseline(w, fileResults) }); err != nil {
+			log.Printf("failed to write baseline: %v", err)
+		} else {
+			fmt.Fprintf(console, "[✓] Wrote baseline to %s\n", baseOut)
 		}
 	}
-	fmt.Printf("[✓] Wrote %d results to %s\n", len(results), outPath)
 
 	// Console summary
-	logging.PrintSummary(results, r.TestedEndpoints)
+	logging.PrintSummary(console, results, stats)
+
+	if known != nil && newIDOR > 0 {
+		fmt.Fprintf(console, "[!] IDOR FOUND results not in the baseline: %d\n", newIDOR)
+		closeTap()
+		os.Exit(1)
+	}
+}
+
+// readBaseline reads a --baseline file, gunzipping it when the name ends in .gz.
+func readBaseline(path string) (logging.Baseline, error) {
+	in, err := testconfig.OpenInput(path)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	return logging.ReadBaseline(in)
+}
+
+// configResult is the outcome of loading the config alongside the spec.
+type configResult struct {
+	cfg testconfig.Config
+	err error
+}
+
+// startupProblems collects what went wrong loading the spec and config, so a run with
+// several problems reports them all at once instead of one per attempt.
+type startupProblems []string
+
+func (p *startupProblems) add(source, path, format string, args ...any) {
+	*p = append(*p, fmt.Sprintf("%s %s: %s", source, path, fmt.Sprintf(format, args...)))
+}
+
+// fatal exits with every collected problem when there are any.
+func (p startupProblems) fatal() {
+	switch len(p) {
+	case 0:
+		return
+	case 1:
+		log.Fatal(p[0])
+	}
+	log.Fatalf("startup failed with %d problems:\n  %s", len(p), strings.Join(p, "\n  "))
+}
+
+// writeCoverage writes the eligibility report to path, as JSON when path ends in .json.
+func writeCoverage(path string, check runner.ConfigCheck) error {
+	f, err := createOutput(path)
+	if err != nil {
+		return err
+	}
+	if strings.HasSuffix(strings.TrimSuffix(strings.ToLower(path), ".gz"), ".json") {
+		err = logging.WriteCoverageJSON(f, check)
+	} else {
+		err = logging.WriteCoverageText(f, check)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// exportDefectDojo writes findings in DefectDojo's import format to path, when set, and
+// uploads them to the engagement, when dojo is set.
+func exportDefectDojo(ctx context.Context, path string, dojo *defectdojo.Client, engagement int, results []runner.ResultLog) error {
+	var report bytes.Buffer
+	if err := logging.WriteDefectDojo(&report, results, time.Now()); err != nil {
+		return err
+	}
+	if path != "" {
+		f, err := createOutput(path)
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(report.Bytes())
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if dojo != nil {
+		return dojo.ImportScan(ctx, engagement, report.Bytes())
+	}
+	return nil
+}
+
+// authHeaderNames returns the headers that carry user credentials under cfg.
+func authHeaderNames(cfg testconfig.Config) []string {
+	var names []string
+	for _, u := range cfg.Users {
+		switch {
+		case u.Auth.Type == "cookie":
+			names = append(names, "Cookie")
+		case u.Auth.HeaderName != "":
+			names = append(names, u.Auth.HeaderName)
+		default:
+			names = append(names, cfg.DefaultAuthHeaderName)
+		}
+	}
+	return names
+}
+
+// createOutput creates an output file. Paths ending in .gz are gzip-compressed; closing
+// the returned writer finishes the gzip stream and then closes the file.
+func createOutput(path string) (io.WriteCloser, error) {
+	return openOutput(path, os.O_TRUNC)
+}
+
+// openOutput opens an output file like createOutput, with mode (os.O_TRUNC or
+// os.O_APPEND) deciding what happens to an existing file. Appending to a .gz file adds a
+// gzip stream, which gzip -d and zcat read as one file.
+func openOutput(path string, mode int) (io.WriteCloser, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|mode, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(strings.ToLower(path), ".gz") {
+		return f, nil
+	}
+	return &gzipFile{Writer: gzip.NewWriter(f), file: f}, nil
+}
+
+// writeFile creates path, writes it with write and closes it, reporting the first error.
+func writeFile(path string, write func(io.Writer) error) error {
+	f, err := createOutput(path)
+	if err != nil {
+		return err
+	}
+	err = write(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// gzipFile is a gzip stream written to a file it owns.
+type gzipFile struct {
+	*gzip.Writer
+	file *os.File
+}
+
+func (g *gzipFile) Close() error {
+	err := g.Writer.Close()
+	if cerr := g.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// printConfigCheck prints the dry-run report and returns the number of testable endpoints.
+func printConfigCheck(check runner.ConfigCheck) int {
+	for _, n := range check.Notes {
+		fmt.Printf("[!] %s\n", n)
+	}
+	for _, ec := range check.Endpoints {
+		if ec.SkipReason != "" {
+			fmt.Printf("[~] %s %s: skipped - %s\n", ec.Method, ec.Endpoint, ec.SkipReason)
+			continue
+		}
+		if ec.Pairs == 0 {
+			fmt.Printf("[~] %s %s: skipped - no user can act as object owner\n", ec.Method, ec.Endpoint)
+		} else {
+			fmt.Printf("[✓] %s %s: %d object user(s) [%s], %d pair(s)\n", ec.Method, ec.Endpoint, len(ec.ObjectUsers), strings.Join(ec.ObjectUsers, ", "), ec.Pairs)
+		}
+		users := make([]string, 0, len(ec.UserSkips))
+		for u := range ec.UserSkips {
+			users = append(users, u)
+		}
+		sort.Strings(users)
+		for _, u := range users {
+			fmt.Printf("      %s: %s\n", u, ec.UserSkips[u])
+		}
+	}
+	fmt.Printf("Config check: %d of %d endpoints testable.\n", check.Testable, len(check.Endpoints))
+	return check.Testable
 }
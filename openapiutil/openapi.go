@@ -1,26 +1,52 @@
 package openapiutil
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/getkin/kin-openapi/openapi3"
+	"gopkg.in/yaml.v3"
 )
 
-func LoadSpec(ctx context.Context, pathOrURL string) (*openapi3.T, string, error) {
+// LoadOptions controls how LoadSpec resolves the document and its references.
+type LoadOptions struct {
+	// AllowExternalRefs permits $refs pointing outside the root document.
+	// It is off by default so a spec cannot make the scanner fetch arbitrary URLs or read local files.
+	AllowExternalRefs bool
+	// AllowedRefPrefixes restricts external refs to URLs starting with one of these prefixes
+	// or to files under one of these directories. Empty allows any location.
+	AllowedRefPrefixes []string
+	// HTTPClient fetches remote specs and external refs; nil uses http.DefaultClient.
+	// Pass the scan's client so spec loading honors the same proxy and TLS settings.
+	HTTPClient *http.Client
+}
+
+func LoadSpec(ctx context.Context, pathOrURL string, opts LoadOptions) (*openapi3.T, string, error) {
 	loader := openapi3.NewLoader()
-	loader.IsExternalRefsAllowed = true
+	loader.Context = ctx
+	loader.IsExternalRefsAllowed = opts.AllowExternalRefs
+	guard := newRefGuard(opts.HTTPClient, opts.AllowedRefPrefixes)
+	loader.ReadFromURIFunc = guard.readFromURI
 
 	var (
 		doc *openapi3.T
 		err error
 	)
 	if isHTTPURL(pathOrURL) {
-		u, err := url.Parse(pathOrURL)
-		if err != nil {
+		var u *url.URL
+		if u, err = url.Parse(pathOrURL); err != nil {
 			return nil, "", err
 		}
 		doc, err = loader.LoadFromURI(u)
@@ -28,7 +54,17 @@ func LoadSpec(ctx context.Context, pathOrURL string) (*openapi3.T, string, error
 		doc, err = loader.LoadFromFile(pathOrURL)
 	}
 	if err != nil {
-		return nil, "", err
+		if ref := rejectedRef(err); ref != "" {
+			where := ""
+			if src := guard.refSource(ref); src != "" {
+				where = " at " + src
+			}
+			if !opts.AllowExternalRefs {
+				return nil, "", fmt.Errorf("%s: $ref %q%s: %w (external refs are disabled; pass --allow-external-refs)", pathOrURL, ref, where, err)
+			}
+			return nil, "", fmt.Errorf("%s: $ref %q%s: %w", pathOrURL, ref, where, err)
+		}
+		return nil, "", fmt.Errorf("%s: %w", pathOrURL, err)
 	}
 	if err := doc.Validate(ctx); err != nil {
 		// Proceed even if validation reports issues (e.g., regex patterns incompatible with Go's RE2)
@@ -38,6 +74,159 @@ func LoadSpec(ctx context.Context, pathOrURL string) (*openapi3.T, string, error
 	return doc, firstServerURL(doc), nil
 }
 
+// refGuard reads the root document unconditionally and every later location (i.e.
+// external refs) only when it falls under one of the allowed prefixes. It keeps what it
+// read so a rejected ref can be traced to the document and line it appears on.
+type refGuard struct {
+	read    openapi3.ReadFromURIFunc
+	allowed []string
+	docs    []readDoc // in reading order, root first
+}
+
+type readDoc struct {
+	location string
+	data     []byte
+}
+
+func newRefGuard(client *http.Client, allowed []string) *refGuard {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &refGuard{
+		read:    openapi3.URIMapCache(openapi3.ReadFromURIs(openapi3.ReadFromHTTP(client), openapi3.ReadFromFile)),
+		allowed: allowed,
+	}
+}
+
+func (g *refGuard) readFromURI(loader *openapi3.Loader, location *url.URL) ([]byte, error) {
+	if len(g.docs) > 0 && !refLocationAllowed(location, g.allowed) {
+		return nil, fmt.Errorf("external ref location %s is not in the allowlist", location)
+	}
+	data, err := g.read(loader, location)
+	if err == nil && strings.HasSuffix(strings.ToLower(location.Path), ".gz") {
+		data, err = gunzip(data)
+	}
+	if err == nil {
+		g.docs = append(g.docs, readDoc{location: displayLocation(location), data: data})
+	}
+	return data, err
+}
+
+// gunzip decompresses a document whose name ends in .gz.
+func gunzip(data []byte) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	return io.ReadAll(gz)
+}
+
+// refSource returns "location:line" of the last document read that has a $ref to ref,
+// or "" when none does. The deepest document is read last, and a rejected ref is always
+// in the document that was being resolved when loading stopped.
+func (g *refGuard) refSource(ref string) string {
+	for i := len(g.docs) - 1; i >= 0; i-- {
+		var root yaml.Node
+		if yaml.Unmarshal(g.docs[i].data, &root) != nil {
+			continue
+		}
+		if line := refLine(&root, ref); line > 0 {
+			return fmt.Sprintf("%s:%d", g.docs[i].location, line)
+		}
+	}
+	return ""
+}
+
+// refLine returns the line of the first "$ref: ref" under n, or 0.
+func refLine(n *yaml.Node, ref string) int {
+	if n.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if k, v := n.Content[i], n.Content[i+1]; k.Value == "$ref" && v.Kind == yaml.ScalarNode && v.Value == ref {
+				return k.Line
+			}
+		}
+	}
+	for _, c := range n.Content {
+		if line := refLine(c, ref); line > 0 {
+			return line
+		}
+	}
+	return 0
+}
+
+var rejectedRefRe = regexp.MustCompile(`(?:disallowed external reference: |error resolving reference )("(?:[^"\\]|\\.)*")`)
+
+// rejectedRef returns the $ref a loader error rejected: one external refs are disabled
+// for, or the innermost one resolving to a location outside the allowlist. It returns ""
+// for other errors.
+func rejectedRef(err error) string {
+	msg := err.Error()
+	if !strings.Contains(msg, "disallowed external reference") && !strings.Contains(msg, "is not in the allowlist") {
+		return ""
+	}
+	m := rejectedRefRe.FindAllStringSubmatch(msg, -1)
+	if len(m) == 0 {
+		return ""
+	}
+	ref, uerr := strconv.Unquote(m[len(m)-1][1])
+	if uerr != nil {
+		return ""
+	}
+	return ref
+}
+
+func displayLocation(location *url.URL) string {
+	if location.Scheme == "" || location.Scheme == "file" {
+		return filepath.FromSlash(location.Path)
+	}
+	return location.String()
+}
+
+func refLocationAllowed(location *url.URL, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	remote := location.Scheme == "http" || location.Scheme == "https"
+	for _, prefix := range allowed {
+		if isHTTPURL(prefix) {
+			if remote && strings.HasPrefix(location.String(), prefix) {
+				return true
+			}
+			continue
+		}
+		if remote {
+			continue
+		}
+		dir, err := filepath.Abs(prefix)
+		if err != nil {
+			continue
+		}
+		file, err := filepath.Abs(filepath.FromSlash(location.Path))
+		if err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(dir, file); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteBundle inlines every external reference of doc into its components and
+// writes the result as JSON to path, so later runs need neither network nor side files.
+func WriteBundle(ctx context.Context, doc *openapi3.T, path string) error {
+	doc.InternalizeRefs(ctx, nil)
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal bundle: %w", err)
+	}
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		return fmt.Errorf("write bundle: %w", err)
+	}
+	return nil
+}
+
 func firstServerURL(doc *openapi3.T) string {
 	if doc == nil || len(doc.Servers) == 0 {
 		return ""
@@ -101,3 +290,22 @@ func ListPathParams(doc *openapi3.T) []string {
 	sort.Strings(out)
 	return out
 }
+
+// UnknownOperationIDs returns the ids that no operation in the document declares, in input order.
+func UnknownOperationIDs(doc *openapi3.T, ids []string) []string {
+	known := map[string]struct{}{}
+	for _, item := range doc.Paths.Map() {
+		for _, op := range item.Operations() {
+			if op != nil && op.OperationID != "" {
+				known[op.OperationID] = struct{}{}
+			}
+		}
+	}
+	var unknown []string
+	for _, id := range ids {
+		if _, ok := known[id]; !ok {
+			unknown = append(unknown, id)
+		}
+	}
+	return unknown
+}
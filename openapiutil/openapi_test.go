@@ -0,0 +1,161 @@
+package openapiutil
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const notesSpec = `{
+  "openapi": "3.0.3",
+  "info": {"title": "notes", "version": "1"},
+  "servers": [{"url": "https://api.example.com"}],
+  "paths": {
+    "/notes/{note_id}": {
+      "get": {
+        "parameters": [{"name": "note_id", "in": "path", "required": true, "schema": {"type": "string"}}],
+        "responses": {"200": {"description": "OK"}}
+      }
+    }
+  }
+}`
+
+func TestLoadSpecFromURL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/openapi.json" {
+			http.NotFound(w, req)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(notesSpec))
+	}))
+	defer srv.Close()
+
+	doc, server, err := LoadSpec(context.Background(), srv.URL+"/openapi.json", LoadOptions{HTTPClient: srv.Client()})
+	if err != nil {
+		t.Fatalf("LoadSpec: %v", err)
+	}
+	if doc.Paths.Find("/notes/{note_id}") == nil || server != "https://api.example.com" {
+		t.Errorf("loaded spec with paths %v and server %q", doc.Paths.InMatchingOrder(), server)
+	}
+
+	// A spec that cannot be fetched is an error, not a nil document
+	if _, _, err := LoadSpec(context.Background(), srv.URL+"/missing.json", LoadOptions{HTTPClient: srv.Client()}); err == nil {
+		t.Error("LoadSpec of a 404 succeeded")
+	} else if !strings.Contains(err.Error(), "/missing.json") {
+		t.Errorf("error %q does not name the spec", err)
+	}
+}
+
+func TestLoadSpecUnreachableURL(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL + "/openapi.json"
+	srv.Close()
+
+	if _, _, err := LoadSpec(context.Background(), url, LoadOptions{}); err == nil {
+		t.Error("LoadSpec of an unreachable URL succeeded")
+	}
+}
+
+// refSpec is a spec whose single response schema is a $ref to ref, on line 12.
+func refSpec(ref string) string {
+	return `{
+  "openapi": "3.0.3",
+  "info": {"title": "refs", "version": "1"},
+  "paths": {
+    "/notes/{note_id}": {
+      "get": {
+        "parameters": [{"name": "note_id", "in": "path", "required": true, "schema": {"type": "string"}}],
+        "responses": {
+          "200": {
+            "description": "OK",
+            "content": {"application/json": {
+              "schema": {"$ref": "` + ref + `"}
+            }}
+          }
+        }
+      }
+    }
+  }
+}`
+}
+
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadSpecFileRefs(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "openapi.json")
+	writeFile(t, root, refSpec("schemas/note.json#/Note"))
+	writeFile(t, filepath.Join(dir, "schemas", "note.json"), `{"Note": {"$ref": "../private/owner.json#/Owner"}}`)
+	writeFile(t, filepath.Join(dir, "private", "owner.json"), `{"Owner": {"type": "object"}}`)
+
+	_, _, err := LoadSpec(context.Background(), root, LoadOptions{})
+	if err == nil {
+		t.Fatal("external ref loaded with external refs disabled")
+	}
+	if want := `$ref "schemas/note.json#/Note" at ` + root + `:12`; !strings.Contains(err.Error(), want) {
+		t.Errorf("error %q does not contain %q", err, want)
+	}
+	if !strings.Contains(err.Error(), "--allow-external-refs") {
+		t.Errorf("error %q does not suggest --allow-external-refs", err)
+	}
+
+	// schemas/ is allowed but the ref it makes into private/ is not
+	_, _, err = LoadSpec(context.Background(), root, LoadOptions{AllowExternalRefs: true, AllowedRefPrefixes: []string{filepath.Join(dir, "schemas")}})
+	if err == nil {
+		t.Fatal("ref outside the allowlist loaded")
+	}
+	if want := `$ref "../private/owner.json#/Owner" at ` + filepath.Join(dir, "schemas", "note.json") + `:1`; !strings.Contains(err.Error(), want) {
+		t.Errorf("error %q does not contain %q", err, want)
+	}
+
+	if _, _, err := LoadSpec(context.Background(), root, LoadOptions{AllowExternalRefs: true, AllowedRefPrefixes: []string{dir}}); err != nil {
+		t.Errorf("allowlisted refs: %v", err)
+	}
+}
+
+func TestLoadSpecHTTPRefs(t *testing.T) {
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	mux.HandleFunc("/api/openapi.json", func(w http.ResponseWriter, req *http.Request) {
+		_, _ = w.Write([]byte(refSpec(srv.URL + "/shared/note.json#/Note")))
+	})
+	mux.HandleFunc("/shared/note.json", func(w http.ResponseWriter, req *http.Request) {
+		_, _ = w.Write([]byte(`{"Note": {"type": "object"}}`))
+	})
+	specURL := srv.URL + "/api/openapi.json"
+	ref := srv.URL + "/shared/note.json#/Note"
+
+	_, _, err := LoadSpec(context.Background(), specURL, LoadOptions{HTTPClient: srv.Client()})
+	if err == nil {
+		t.Fatal("external ref of a remote spec loaded with external refs disabled")
+	}
+	if want := `$ref "` + ref + `" at ` + specURL + `:12`; !strings.Contains(err.Error(), want) {
+		t.Errorf("error %q does not contain %q", err, want)
+	}
+
+	_, _, err = LoadSpec(context.Background(), specURL, LoadOptions{HTTPClient: srv.Client(), AllowExternalRefs: true, AllowedRefPrefixes: []string{srv.URL + "/api/"}})
+	if err == nil {
+		t.Fatal("ref outside the allowlist loaded")
+	}
+	if want := `$ref "` + ref + `" at ` + specURL + `:12`; !strings.Contains(err.Error(), want) || !strings.Contains(err.Error(), "not in the allowlist") {
+		t.Errorf("error %q does not contain %q", err, want)
+	}
+
+	if _, _, err := LoadSpec(context.Background(), specURL, LoadOptions{HTTPClient: srv.Client(), AllowExternalRefs: true, AllowedRefPrefixes: []string{srv.URL + "/shared/"}}); err != nil {
+		t.Errorf("allowlisted refs: %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// SkipReasonCallback is recorded for callback and webhook operations. They describe
+// requests the API sends to its clients, at URLs such as {$request.body#/callbackUrl},
+// so there is nothing on the target to test.
+const SkipReasonCallback = "callback definition"
+
+// callbackSkips returns one SKIPPED result per operation of the callbacks of the
+// selected operations and of the OpenAPI 3.1 webhooks. Neither is part of Spec.Paths,
+// so they are never planned; the results only make them visible in the skip summary.
+func (r *Runner) callbackSkips() []ResultLog {
+	var results []ResultLog
+	addItem := func(endpoint string, item *openapi3.PathItem, note string) {
+		ops := operationsFor(item)
+		methods := make([]string, 0, len(ops))
+		for m := range ops {
+			methods = append(methods, m)
+		}
+		sort.Strings(methods)
+		for _, method := range methods {
+			results = append(results, ResultLog{
+				Endpoint:      endpoint,
+				Method:        method,
+				Result:        ResultSkipped,
+				SkippedReason: SkipReasonCallback,
+				Notes:         []string{note},
+			})
+		}
+	}
+
+	paths := r.Spec.Paths.Map()
+	for _, path := range sortedKeys(paths) {
+		ops := operationsFor(paths[path])
+		for _, method := range sortedKeys(ops) {
+			op := ops[method]
+			if !r.operationSelected(op) {
+				continue
+			}
+			for _, name := range sortedKeys(op.Callbacks) {
+				cb := op.Callbacks[name]
+				if cb == nil || cb.Value == nil {
+					continue
+				}
+				exprs := cb.Value.Map()
+				for _, expr := range sortedKeys(exprs) {
+					addItem(expr, exprs[expr], fmt.Sprintf("callback %s of %s %s", name, method, path))
+				}
+			}
+		}
+	}
+
+	// kin-openapi reads OpenAPI 3.0, so a 3.1 webhooks section is left as raw JSON in
+	// the document's extensions
+	webhooks, _ := r.Spec.Extensions["webhooks"].(map[string]any)
+	for _, name := range sortedKeys(webhooks) {
+		raw, err := json.Marshal(webhooks[name])
+		if err != nil {
+			continue
+		}
+		var item openapi3.PathItem
+		if err := json.Unmarshal(raw, &item); err != nil {
+			continue
+		}
+		addItem("webhook "+name, &item, "webhook "+name)
+	}
+	return results
+}
+
+// sortedKeys returns the keys of m in ascending order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
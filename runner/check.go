@@ -0,0 +1,154 @@
+package runner
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/yansol0/aperture/testconfig"
+)
+
+// EndpointCheck describes how a single operation would be handled by Execute.
+type EndpointCheck struct {
+	Endpoint string `json:"endpoint"`
+	Method   string `json:"method"`
+	// OperationID and Tags identify the operation like the same fields on ResultLog.
+	OperationID string   `json:"operation_id"`
+	Tags        []string `json:"tags"`
+	// ObjectUsers are the users able to act as object owner; each is paired with every other user.
+	ObjectUsers []string `json:"object_users"`
+	// AttackerUsers are the users whose credentials would be tried against those objects.
+	AttackerUsers []string `json:"attacker_users"`
+	// Pairs is the number of control/test pairs that would be sent.
+	Pairs int `json:"pairs"`
+	// SkipReason is set when the whole operation would be skipped.
+	SkipReason string `json:"skip_reason,omitempty"`
+	// UserSkips maps eligible users that still cannot act as object owner to the reason.
+	UserSkips map[string]string `json:"user_skips,omitempty"`
+}
+
+// ConfigCheck is the outcome of a dry run over the spec and config.
+type ConfigCheck struct {
+	// Notes carries config warnings, such as user fields unknown to the spec.
+	Notes     []string        `json:"notes,omitempty"`
+	Endpoints []EndpointCheck `json:"endpoints"`
+	// Testable counts endpoints with at least one pair to send.
+	Testable int `json:"testable"`
+}
+
+// CheckConfig plans the run without sending any HTTP request, reporting per endpoint
+// which users can act as object owner and why endpoints or users would be skipped.
+func (r *Runner) CheckConfig() ConfigCheck {
+	var check ConfigCheck
+	if r.Spec == nil {
+		return check
+	}
+
+	var warnings []ResultLog
+	r.validateConfigFields(r.collectAllFieldNames(), &warnings)
+	r.validateFieldValues(&warnings)
+	r.validateSharedCredentials(&warnings)
+	for _, w := range warnings {
+		check.Notes = append(check.Notes, w.Notes...)
+	}
+
+	for path, item := range r.Spec.Paths.Map() {
+		for method, op := range operationsFor(item) {
+			if !r.operationSelected(op) {
+				continue
+			}
+			ec := EndpointCheck{Endpoint: path, Method: method, OperationID: op.OperationID, Tags: operationTags(op)}
+			if reason := r.operationSkipReason(path, method, op, item); reason != "" {
+				ec.SkipReason = reason
+				check.Endpoints = append(check.Endpoints, ec)
+				continue
+			}
+			for _, u := range r.eligibleUsers(r.requiredParams(path, op, item)) {
+				if reason := objectUserSkipReason(path, op, item, u); reason != "" {
+					if ec.UserSkips == nil {
+						ec.UserSkips = map[string]string{}
+					}
+					ec.UserSkips[u.Name] = reason
+					continue
+				}
+				ec.ObjectUsers = append(ec.ObjectUsers, u.Name)
+				ec.Pairs += r.compatiblePairs(op, u)
+			}
+			if ec.Pairs > 0 {
+				check.Testable++
+				ec.AttackerUsers = attackerUsers(ec.ObjectUsers, r.Config.Users)
+			}
+			check.Endpoints = append(check.Endpoints, ec)
+		}
+	}
+
+	sort.Slice(check.Endpoints, func(i, j int) bool {
+		a, b := check.Endpoints[i], check.Endpoints[j]
+		if a.Endpoint != b.Endpoint {
+			return a.Endpoint < b.Endpoint
+		}
+		return strings.Compare(a.Method, b.Method) < 0
+	})
+	return check
+}
+
+// attackerUsers returns, in config order, every user paired as credentials with at least one object user.
+func attackerUsers(objectUsers []string, all []testconfig.User) []string {
+	var out []string
+	for _, u := range all {
+		for _, o := range objectUsers {
+			if o != u.Name {
+				out = append(out, u.Name)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// SharedCredentials reports groups of users whose effective credentials are identical,
+// which makes every test between them meaningless since attacker and owner are the same.
+func (r *Runner) SharedCredentials() []string {
+	byCred := map[string][]string{}
+	var order []string
+	for _, u := range r.Config.Users {
+		credential := u.Auth.Value
+		if u.Auth.Type == testconfig.AuthSignedQuery && u.Auth.Signing != nil {
+			credential = u.Auth.Signing.Credential()
+		}
+		if credential == "" {
+			continue
+		}
+		where := u.Auth.Type
+		if u.Auth.Type == "header" {
+			where = u.Auth.HeaderName
+			if where == "" {
+				where = r.Config.DefaultAuthHeaderName
+			}
+		}
+		key := where + "\x00" + credential
+		if _, ok := byCred[key]; !ok {
+			order = append(order, key)
+		}
+		byCred[key] = append(byCred[key], u.Name)
+	}
+	var out []string
+	for _, key := range order {
+		if names := byCred[key]; len(names) > 1 {
+			where := strings.SplitN(key, "\x00", 2)[0]
+			out = append(out, fmt.Sprintf("users %s share the same %s credentials; tests between them cannot detect IDOR", strings.Join(names, ", "), where))
+		}
+	}
+	return out
+}
+
+func (r *Runner) validateSharedCredentials(results *[]ResultLog) {
+	for _, m := range r.SharedCredentials() {
+		*results = append(*results, ResultLog{
+			Endpoint: "-",
+			Method:   "-",
+			Result:   ResultSkipped,
+			Notes:    []string{m},
+		})
+	}
+}
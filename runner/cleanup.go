@@ -0,0 +1,72 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/yansol0/aperture/testconfig"
+)
+
+// runCleanup sends the endpoint's configured cleanup requests as the object owner and
+// applies any captured values to the owner's fields. The sequence stops at the first
+// failure, which is recorded on the result.
+func (r *Runner) runCleanup(ctx context.Context, client *http.Client, method, path, ownerName string, res *ResultLog) {
+	ov, ok := r.Config.OverrideFor(method, path)
+	if !ok || len(ov.Cleanup) == 0 {
+		return
+	}
+	for _, step := range ov.Cleanup {
+		if err := r.cleanupStep(ctx, client, step, ownerName, res); err != nil {
+			msg := fmt.Sprintf("cleanup %s %s failed: %v", strings.ToUpper(step.Method), step.Path, err)
+			res.CleanupErrors = append(res.CleanupErrors, msg)
+			res.Notes = append(res.Notes, msg)
+			r.logf("[x] %s %s: %s", method, path, msg)
+			return
+		}
+	}
+}
+
+func (r *Runner) cleanupStep(ctx context.Context, client *http.Client, step testconfig.CleanupRequest, ownerName string, res *ResultLog) error {
+	ownerIdx := r.userIndex(ownerName)
+	if ownerIdx < 0 {
+		return fmt.Errorf("unknown user %s", ownerName)
+	}
+	owner := r.Config.Users[ownerIdx]
+
+	item := r.Spec.Paths.Value(step.Path)
+	if item == nil {
+		return fmt.Errorf("path not found in spec")
+	}
+	op := item.GetOperation(strings.ToUpper(step.Method))
+	if op == nil {
+		return fmt.Errorf("operation not found in spec")
+	}
+	ex, resp, err := r.sendOne(ctx, client, step.Method, step.Path, op, item, owner, owner, nil)
+	if err != nil {
+		return err
+	}
+	res.Cleanup = append(res.Cleanup, ex)
+	if resp.Status < 200 || resp.Status >= 300 {
+		return fmt.Errorf("status %d", resp.Status)
+	}
+
+	fields := make([]string, 0, len(step.Capture))
+	for f := range step.Capture {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+	for _, f := range fields {
+		v, err := testconfig.ExtractJSONPointer([]byte(resp.FullBody()), testconfig.DotPathToPointer(step.Capture[f]))
+		if err != nil {
+			return fmt.Errorf("capture %s: %w", f, err)
+		}
+		if r.Config.Users[ownerIdx].Fields == nil {
+			r.Config.Users[ownerIdx].Fields = map[string]string{}
+		}
+		r.Config.Users[ownerIdx].Fields[f] = v
+	}
+	return nil
+}
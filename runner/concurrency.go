@@ -0,0 +1,200 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// Defaults of Runner.AdaptiveErrorRate and Runner.AdaptiveWindow.
+const (
+	DefaultAdaptiveErrorRate = 0.2
+	DefaultAdaptiveWindow    = 20
+)
+
+// pairState is what sendOne needs to know about the pair being run besides its
+// arguments. It belongs to the goroutine holding workers, see unlocked.
+type pairState struct {
+	// example is the request body example the pair sends, see ExpandExamples.
+	example *bodyExample
+	// perturbWrite is set while the test of a verified write is sent, so its body differs
+	// from the control's in one property; see perturbBody.
+	perturbWrite bool
+	// fullPatch is set once the control rejected the minimal PATCH body, see MinimalPatch.
+	fullPatch bool
+	// extraHeaders are set on every request while a conditional probe runs.
+	extraHeaders map[string]string
+}
+
+// forEachOperation calls run for each plan. With Concurrency above 1 it does so from that
+// many goroutines, each holding workers while it runs, so only one of them touches the
+// Runner at a time; sendOne and pace release the lock while they wait, which is when the
+// others go on. Operations then start in order but finish in any order.
+func (r *Runner) forEachOperation(plans []operationPlan, run func(int, operationPlan)) {
+	n := min(r.Concurrency, len(plans))
+	if n < 2 {
+		for i, plan := range plans {
+			run(i, plan)
+		}
+		return
+	}
+	r.workers = &sync.Mutex{}
+	r.slotFree = sync.NewCond(r.workers)
+	r.throttles = map[string]*hostThrottle{}
+	r.inFlight = map[string]int{}
+	next := 0
+	var wg sync.WaitGroup
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.workers.Lock()
+			defer r.workers.Unlock()
+			// Another worker may have left its pair here while it waits on the network
+			r.pair = pairState{}
+			for next < len(plans) {
+				i := next
+				next++
+				run(i, plans[i])
+			}
+		}()
+	}
+	wg.Wait()
+	r.EffectiveConcurrency = nil
+	if r.Adaptive {
+		r.EffectiveConcurrency = map[string]ConcurrencyRange{}
+		for host, t := range r.throttles {
+			r.EffectiveConcurrency[host] = t.seen
+		}
+	}
+	r.workers, r.slotFree, r.throttles, r.inFlight = nil, nil, nil, nil
+}
+
+// unlocked runs f without holding workers while operations run concurrently, so other
+// workers can go on meanwhile. The pair state is theirs in the meantime and is put back
+// once f returns.
+func (r *Runner) unlocked(f func()) {
+	if r.workers == nil {
+		f()
+		return
+	}
+	pair := r.pair
+	r.workers.Unlock()
+	defer func() {
+		r.workers.Lock()
+		r.pair = pair
+	}()
+	f()
+}
+
+// ConcurrencyRange is the lowest and highest request limit a host had during a run and
+// how often the limit changed.
+type ConcurrencyRange struct {
+	Min         int `json:"min"`
+	Max         int `json:"max"`
+	Adjustments int `json:"adjustments"`
+}
+
+// hostThrottle limits the requests in flight to one host during an adaptive run. The
+// outcome of each request goes into a sliding window of the last AdaptiveWindow. When more
+// than AdaptiveErrorRate of a full window failed, the limit halves; when at most half
+// that rate did, it grows by one, up to Concurrency. The window starts over after each
+// change, so a limit is judged on its own requests and ramping up takes a window per step.
+type hostThrottle struct {
+	limit    int
+	inFlight int
+	window   []bool // true for a failed request, oldest first
+	failed   int
+	seen     ConcurrencyRange
+}
+
+func (r *Runner) adaptiveErrorRate() float64 {
+	if r.AdaptiveErrorRate <= 0 {
+		return DefaultAdaptiveErrorRate
+	}
+	return r.AdaptiveErrorRate
+}
+
+func (r *Runner) adaptiveWindow() int {
+	if r.AdaptiveWindow <= 0 {
+		return DefaultAdaptiveWindow
+	}
+	return r.AdaptiveWindow
+}
+
+// acquireSlot waits until host may take another request and counts one in flight. It
+// returns the host's throttle, or nil when requests are not throttled.
+func (r *Runner) acquireSlot(host string) *hostThrottle {
+	if r.workers == nil || !r.Adaptive {
+		return nil
+	}
+	t := r.throttles[host]
+	if t == nil {
+		t = &hostThrottle{limit: r.Concurrency, seen: ConcurrencyRange{Min: r.Concurrency, Max: r.Concurrency}}
+		r.throttles[host] = t
+		r.emitConcurrency(host, t.limit, "")
+	}
+	pair := r.pair
+	for t.inFlight >= t.limit {
+		r.slotFree.Wait()
+	}
+	r.pair = pair
+	t.inFlight++
+	return t
+}
+
+// releaseSlot ends a request acquireSlot admitted to host and records whether it failed:
+// a transport error such as a timeout, a 5xx or a 429. A request cut short by the end of
+// the run says nothing about the host and is not recorded.
+func (r *Runner) releaseSlot(ctx context.Context, host string, t *hostThrottle, status int, err error) {
+	if t == nil {
+		return
+	}
+	t.inFlight--
+	defer r.slotFree.Broadcast()
+	if ctx.Err() != nil {
+		return
+	}
+	failed := err != nil || status >= 500 || status == http.StatusTooManyRequests
+	t.window = append(t.window, failed)
+	if failed {
+		t.failed++
+	}
+	size := r.adaptiveWindow()
+	if len(t.window) > size {
+		if t.window[0] {
+			t.failed--
+		}
+		t.window = t.window[1:]
+	}
+	if len(t.window) < size {
+		return
+	}
+	rate := float64(t.failed) / float64(size)
+	prefix, limit := "[*]", t.limit
+	switch {
+	case rate > r.adaptiveErrorRate() && t.limit > 1:
+		prefix, limit = "[!]", max(1, t.limit/2)
+	case rate <= r.adaptiveErrorRate()/2 && t.limit < r.Concurrency:
+		limit = t.limit + 1
+	default:
+		return
+	}
+	msg := fmt.Sprintf("%s %s: %d of the last %d requests failed; concurrency %d -> %d", prefix, host, t.failed, size, t.limit, limit)
+	t.limit = limit
+	t.window, t.failed = nil, 0
+	t.seen.Min = min(t.seen.Min, limit)
+	t.seen.Max = max(t.seen.Max, limit)
+	t.seen.Adjustments++
+	r.emitConcurrency(host, limit, msg)
+}
+
+// emitConcurrency reports host's request limit as an EventConcurrency. Like logf, a
+// message is also printed with Verbose unless QuietStdout is set.
+func (r *Runner) emitConcurrency(host string, limit int, msg string) {
+	if msg != "" && r.Verbose && !r.QuietStdout {
+		fmt.Println(msg)
+	}
+	r.emitEvent(Event{Kind: EventConcurrency, Host: host, Concurrency: limit, Message: msg})
+}
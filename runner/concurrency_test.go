@@ -0,0 +1,231 @@
+package runner
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yansol0/aperture/testconfig"
+)
+
+// flakyNotesRunner tests n GET operations under /notes/{note_id}/ whose handler fails the
+// way fail does for the requests it returns true for, numbered from 1 in arrival order,
+// and otherwise returns the note.
+func flakyNotesRunner(t *testing.T, n int, fail func(int64) bool) *Runner {
+	t.Helper()
+	api := newTestAPI(t)
+	var count atomic.Int64
+	api.Mux.HandleFunc("GET /notes/{note_id}/{op}", func(w http.ResponseWriter, req *http.Request) {
+		if fail(count.Add(1)) {
+			writeTestJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "overloaded"})
+			return
+		}
+		if note, ok := api.note(w, req); ok {
+			writeTestJSON(w, http.StatusOK, note)
+		}
+	})
+	var paths strings.Builder
+	for i := range n {
+		paths.WriteString(noteOperation(string(rune('a' + i))))
+	}
+	r := newTestRunner(api, parseTestSpec(t, `
+openapi: 3.0.3
+info: {title: flaky, version: "1"}
+security: [{ApiKeyAuth: []}]
+paths:
+`+paths.String()+`
+components:
+  securitySchemes:
+    ApiKeyAuth: {type: apiKey, in: header, name: X-API-Key}
+`))
+	r.Concurrency = 4
+	r.Adaptive = true
+	r.AdaptiveWindow = 4
+	r.Events = make(chan Event, 1000)
+	return r
+}
+
+// concurrencyEvents drains r.Events and returns its EventConcurrency events.
+func concurrencyEvents(r *Runner) []Event {
+	close(r.Events)
+	var out []Event
+	for e := range r.Events {
+		if e.Kind == EventConcurrency {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func TestConcurrentResultsMatchSequential(t *testing.T) {
+	run := func(concurrency int) []ResultLog {
+		r := flakyNotesRunner(t, 8, func(int64) bool { return false })
+		r.Concurrency = concurrency
+		return execute(t, r)
+	}
+	want := verdicts(run(1))
+	results := run(4)
+	if got := verdicts(results); !reflect.DeepEqual(got, want) {
+		t.Errorf("verdicts = %v, want %v", got, want)
+	}
+	// Each operation's results stay together however the operations finished
+	done := map[string]bool{}
+	for i, res := range results {
+		key := res.Method + " " + res.Endpoint
+		if i > 0 && results[i-1].Method+" "+results[i-1].Endpoint != key {
+			if done[key] {
+				t.Errorf("results of %s are interleaved with other operations", key)
+			}
+		}
+		done[key] = true
+	}
+}
+
+func TestAdaptiveConcurrencyHalves(t *testing.T) {
+	r := flakyNotesRunner(t, 6, func(int64) bool { return true })
+	execute(t, r)
+
+	host := strings.TrimPrefix(r.BaseURL, "http://")
+	want := map[string]ConcurrencyRange{host: {Min: 1, Max: 4, Adjustments: 2}}
+	if !reflect.DeepEqual(r.EffectiveConcurrency, want) {
+		t.Errorf("EffectiveConcurrency = %v, want %v", r.EffectiveConcurrency, want)
+	}
+	var limits []int
+	for _, e := range concurrencyEvents(r) {
+		if e.Host != host {
+			t.Errorf("event host = %q, want %q", e.Host, host)
+		}
+		limits = append(limits, e.Concurrency)
+		if e.Concurrency < 4 && !strings.Contains(e.Message, "4 of the last 4 requests failed") {
+			t.Errorf("message = %q, want the failed requests", e.Message)
+		}
+	}
+	if want := []int{4, 2, 1}; !reflect.DeepEqual(limits, want) {
+		t.Errorf("limits = %v, want %v", limits, want)
+	}
+}
+
+func TestAdaptiveConcurrencyRecovers(t *testing.T) {
+	r := flakyNotesRunner(t, 12, func(n int64) bool { return n <= 4 })
+	execute(t, r)
+
+	host := strings.TrimPrefix(r.BaseURL, "http://")
+	got := r.EffectiveConcurrency[host]
+	if got.Min >= 4 || got.Max != 4 {
+		t.Errorf("EffectiveConcurrency = %+v, want a lower min and max 4", got)
+	}
+	events := concurrencyEvents(r)
+	if len(events) == 0 || events[len(events)-1].Concurrency != 4 {
+		t.Fatalf("events = %+v, want the limit back at 4", events)
+	}
+	// Ramping up is one step per clean window
+	for i := 1; i < len(events); i++ {
+		if prev, cur := events[i-1].Concurrency, events[i].Concurrency; cur > prev && cur != prev+1 {
+			t.Errorf("limit went from %d to %d, want one step", prev, cur)
+		}
+	}
+}
+
+func TestNoAdaptive(t *testing.T) {
+	r := flakyNotesRunner(t, 6, func(int64) bool { return true })
+	r.Adaptive = false
+	execute(t, r)
+
+	if r.EffectiveConcurrency != nil {
+		t.Errorf("EffectiveConcurrency = %v, want nil", r.EffectiveConcurrency)
+	}
+	if events := concurrencyEvents(r); len(events) != 0 {
+		t.Errorf("events = %+v, want none", events)
+	}
+}
+
+// TestMaxConcurrent checks that requests to an endpoint or with a user's credentials that
+// carry max_concurrent never overlap beyond it, while operations run concurrently.
+func TestMaxConcurrent(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		setup func(r *Runner)
+		key   func(req *http.Request) string // which requests the cap covers, "" for none
+	}{
+		{
+			name: "endpoint",
+			setup: func(r *Runner) {
+				r.Config.EndpointOverrides = []testconfig.EndpointOverride{{Path: "/reports/{note_id}", MaxConcurrent: 1}}
+			},
+			key: func(req *http.Request) string {
+				if strings.HasPrefix(req.URL.Path, "/reports/") {
+					return "reports"
+				}
+				return ""
+			},
+		},
+		{
+			name:  "user",
+			setup: func(r *Runner) { r.Config.Users[0].MaxConcurrent = 1 },
+			key: func(req *http.Request) string {
+				if req.Header.Get("X-API-Key") == "KEY_ALICE" {
+					return "alice"
+				}
+				return ""
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newTestAPI(t)
+			var mu sync.Mutex
+			inFlight, most := 0, 0
+			api.Mux.HandleFunc("/reports/{note_id}", func(w http.ResponseWriter, req *http.Request) {
+				capped := tt.key(req) != ""
+				if capped {
+					mu.Lock()
+					inFlight++
+					most = max(most, inFlight)
+					mu.Unlock()
+				}
+				time.Sleep(10 * time.Millisecond)
+				if capped {
+					mu.Lock()
+					inFlight--
+					mu.Unlock()
+				}
+				if _, ok := api.user(w, req); !ok {
+					return
+				}
+				if note, ok := api.note(w, req); ok {
+					writeTestJSON(w, http.StatusOK, note)
+				}
+			})
+			r := newTestRunner(api, parseTestSpec(t, `
+openapi: 3.0.3
+info: {title: reports, version: "1"}
+security: [{ApiKeyAuth: []}]
+paths:
+  /reports/{note_id}:
+    parameters:
+      - {name: note_id, in: path, required: true, schema: {type: integer}}
+    get: {operationId: getReport, responses: {"200": {description: OK}}}
+    head: {operationId: headReport, responses: {"200": {description: OK}}}
+    delete: {operationId: deleteReport, responses: {"204": {description: Deleted}}}
+    put: {operationId: putReport, responses: {"200": {description: OK}}}
+components:
+  securitySchemes:
+    ApiKeyAuth: {type: apiKey, in: header, name: X-API-Key}
+`))
+			r.AllowMutations = true
+			r.Concurrency = 4
+			tt.setup(r)
+			execute(t, r)
+
+			if sent := len(api.Requests()); sent < 16 {
+				t.Fatalf("sent %d requests, want at least 16", sent)
+			}
+			if most != 1 {
+				t.Errorf("at most %d capped requests were in flight at once, want 1", most)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package runner
+
+import "github.com/getkin/kin-openapi/openapi3"
+
+// Confidence weights. Each signal contributes its weight when present on a 2xx test
+// response, so a finding that matches on every signal scores 1.0:
+//
+//   - status match (0.20): the test returned the same status as the control
+//   - body similarity (0.35): the test body is equal to the control body, ignoring JSON
+//     formatting when both responses are JSON
+//   - identifier leak (0.30): the test body or headers contain the object user's field values or sensitive keys
+//   - schema validation (0.15): the test body validates against the operation's declared response schema
+//
+// Non-2xx tests score 0. A write confirmed by re-reading the object adds 0.30 (capped
+// at 1.0); a write that verifiably did not change the object has its score halved.
+const (
+	weightStatusMatch    = 0.20
+	weightBodySimilarity = 0.35
+	weightIdentifierLeak = 0.30
+	weightSchemaValid    = 0.15
+	weightWriteVerified  = 0.30
+)
+
+// confidence scores how likely a test response reflects real cross-user access.
+func confidence(op *openapi3.Operation, ctrl, test ResponseDetails, identifiers map[string]string, sensitiveKeys, ignore []string) float64 {
+	if test.Status < 200 || test.Status >= 300 {
+		return 0
+	}
+	var score float64
+	if test.Status == ctrl.Status {
+		score += weightStatusMatch
+	}
+	if bodiesLikelyEqual(ctrl, test, ignore) {
+		score += weightBodySimilarity
+	}
+	if bodySuggestsLeakedData(test, identifiers) || len(headersWithIdentifiers(test.HeaderValues, identifiers)) > 0 || len(sensitiveKeys) > 0 {
+		score += weightIdentifierLeak
+	}
+	if bodyMatchesResponseSchema(op, test) {
+		score += weightSchemaValid
+	}
+	return score
+}
+
+// bodyMatchesResponseSchema reports whether resp's body is JSON that validates against the
+// schema declared for its status code. Operations without a JSON schema never match.
+func bodyMatchesResponseSchema(op *openapi3.Operation, resp ResponseDetails) bool {
+	if op == nil || op.Responses == nil {
+		return false
+	}
+	ref := op.Responses.Status(resp.Status)
+	if ref == nil {
+		ref = op.Responses.Default()
+	}
+	if ref == nil || ref.Value == nil {
+		return false
+	}
+	var schema *openapi3.Schema
+	for ct, mt := range ref.Value.Content {
+		if mt == nil || mt.Schema == nil || mt.Schema.Value == nil {
+			continue
+		}
+		if isJSONMediaType(ct) {
+			schema = mt.Schema.Value
+			break
+		}
+	}
+	if schema == nil {
+		return false
+	}
+	v, ok := decodeBodyJSON(resp)
+	if !ok {
+		return false
+	}
+	return schema.VisitJSON(v, openapi3.VisitAsResponse()) == nil
+}
@@ -0,0 +1,53 @@
+package runner
+
+import (
+	"context"
+	"errors"
+)
+
+// ConfirmDecision is the operator's reply to an EventConfirmWrite prompt.
+type ConfirmDecision int
+
+const (
+	ConfirmApprove ConfirmDecision = iota
+	ConfirmSkip
+	// ConfirmApproveAll approves this request and every later one without asking.
+	ConfirmApproveAll
+)
+
+// SkipReasonDeclined is the skipped reason of results whose test request the operator declined.
+const SkipReasonDeclined = "declined by operator"
+
+var errDeclined = errors.New(SkipReasonDeclined)
+
+// confirmWrite asks the operator, through an EventConfirmWrite event, whether a mutating
+// cross-user request may be sent, and blocks until they reply. It returns errDeclined when
+// the request must not be sent, including when no one is listening for events.
+func (r *Runner) confirmWrite(ctx context.Context, method, path string, req RequestDetails) error {
+	if !r.ConfirmWrites || r.writesApproved || !isWriteMethod(method) {
+		return nil
+	}
+	if r.Events == nil {
+		return errDeclined
+	}
+	reply := make(chan ConfirmDecision, 1)
+	select {
+	case r.Events <- Event{Kind: EventConfirmWrite, Method: method, Endpoint: path, Request: req, Reply: reply}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case d := <-reply:
+		switch d {
+		case ConfirmApprove:
+			return nil
+		case ConfirmApproveAll:
+			r.writesApproved = true
+			return nil
+		default:
+			return errDeclined
+		}
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,50 @@
+package runner
+
+import "context"
+
+// Command is an operator instruction delivered to a running Runner on Runner.Commands.
+type Command int
+
+const (
+	// CommandPause blocks the runner before its next request until CommandResume.
+	CommandPause Command = iota
+	CommandResume
+	// CommandSkipEndpoint records the remaining pairs of the current operation as skipped,
+	// or of every operation in progress when several run concurrently.
+	CommandSkipEndpoint
+)
+
+// SkipReasonOperator is the skipped reason of pairs dropped by CommandSkipEndpoint.
+const SkipReasonOperator = "skipped by operator"
+
+// checkpoint applies pending commands and, while paused, blocks until resumed or ctx ends.
+// It is called between requests, so an in-flight request always completes first.
+func (r *Runner) checkpoint(ctx context.Context) error {
+	if r.Commands == nil {
+		return ctx.Err()
+	}
+	for {
+		var cmd Command
+		if r.paused {
+			select {
+			case cmd = <-r.Commands:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		} else {
+			select {
+			case cmd = <-r.Commands:
+			default:
+				return ctx.Err()
+			}
+		}
+		switch cmd {
+		case CommandPause:
+			r.paused = true
+		case CommandResume:
+			r.paused = false
+		case CommandSkipEndpoint:
+			r.skipGen++
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package runner
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/yansol0/aperture/testconfig"
+)
+
+// Detector decides the verdict of a pair whose control succeeded, from the control and
+// test responses. It sets res.Result and may add notes, sensitive keys and a confidence.
+// What happens around it (control failures, write verification, conditional probes and
+// spec extensions) is the same for every Detector.
+type Detector interface {
+	Detect(p Pair, res *ResultLog)
+}
+
+// Pair is what a Detector sees of one control/test pair.
+type Pair struct {
+	Method, Path string
+	Op           *openapi3.Operation
+	Item         *openapi3.PathItem
+	// ObjectUser owns the object and sent the control; CredUser's credentials sent the test.
+	ObjectUser testconfig.User
+	CredUser   testconfig.User
+	Control    ResponseDetails
+	Test       ResponseDetails
+	// Reference is the response a HEAD test's headers are compared with, and
+	// ReferenceName what it is; see headReference. Both are empty for other methods.
+	Reference     ResponseDetails
+	ReferenceName string
+}
+
+// detector returns Runner.Detector, or the built-in heuristics when it is nil.
+func (r *Runner) detector() Detector {
+	if r.Detector != nil {
+		return r.Detector
+	}
+	return heuristicDetector{r}
+}
+
+// heuristicDetector is the built-in Detector. A 2xx test is an IDOR when its body or headers
+// carry the object user's identifiers or sensitive keys, or its body matches the control's; 401 and
+// 403 are SECURE, 5xx is ERROR and any other status POTENTIAL. HEAD pairs are compared by
+// headers (see classifyHead) and 2xx error envelopes count as denials.
+type heuristicDetector struct {
+	r *Runner
+}
+
+func (d heuristicDetector) Detect(p Pair, res *ResultLog) {
+	r := d.r
+	method, path := p.Method, p.Path
+	ctrlResp, testResp := p.Control, p.Test
+	test2xx := testResp.Status >= 200 && testResp.Status < 300
+	testEnvelope := ""
+	if test2xx {
+		testEnvelope = r.errorEnvelope(testResp)
+		test2xx = testEnvelope == ""
+	}
+
+	if strings.EqualFold(method, http.MethodHead) {
+		classifyHead(res, p.Op, ctrlResp, testResp, p.Reference, p.ReferenceName, r.Config.IgnoreFields)
+		if leaking := headersWithIdentifiers(testResp.HeaderValues, objectIdentifiers(p.Op, p.Item, p.ObjectUser.Fields)); test2xx && len(leaking) > 0 {
+			// Headers naming the owner's object outweigh the validators that differ
+			res.Result = ResultIDORFound
+			res.Notes = append(res.Notes, fmt.Sprintf("object identifiers in response headers: %s", strings.Join(leaking, ", ")))
+		}
+		r.logf("[*] %s: %s %s (HEAD status=%d)", res.Result, method, path, testResp.Status)
+	} else if testEnvelope != "" {
+		res.Result = ResultSecure
+		res.Notes = append(res.Notes, fmt.Sprintf("test response is an error envelope (%s)", testEnvelope))
+		r.logf("[✓] SECURE: %s %s (status=%d with error envelope %s)", method, path, testResp.Status, testEnvelope)
+	} else if test2xx {
+		res.SensitiveKeys = sensitiveKeysInBody(testResp, r.Config.SensitiveKeys)
+		res.SensitiveKeys = append(res.SensitiveKeys, sensitiveHeaderKeys(testResp.HeaderValues, r.Config.SensitiveKeys)...)
+		if len(res.SensitiveKeys) > 0 {
+			res.Notes = append(res.Notes, fmt.Sprintf("sensitive keys exposed: %s", strings.Join(res.SensitiveKeys, ", ")))
+		}
+		identifiers := objectIdentifiers(p.Op, p.Item, p.ObjectUser.Fields)
+		leakingHeaders := headersWithIdentifiers(testResp.HeaderValues, identifiers)
+		if len(leakingHeaders) > 0 {
+			res.Notes = append(res.Notes, fmt.Sprintf("object identifiers in response headers: %s", strings.Join(leakingHeaders, ", ")))
+		}
+		res.Confidence = confidence(p.Op, ctrlResp, testResp, identifiers, res.SensitiveKeys, r.Config.IgnoreFields)
+		bodiesMatch, similarity := r.bodiesMatch(ctrlResp, testResp)
+		if similarity > 0 && bodiesMatch {
+			res.Notes = append(res.Notes, fmt.Sprintf("bodies %.1f%% similar (threshold %g%%)", similarity, r.BodyMatchPct))
+		}
+		if bodySuggestsLeakedData(testResp, identifiers) || len(leakingHeaders) > 0 || bodiesMatch || len(res.SensitiveKeys) > 0 {
+			res.Result = ResultIDORFound
+			r.logf("[!] IDOR FOUND: %s %s (creds=%s object=%s)", method, path, p.CredUser.Name, p.ObjectUser.Name)
+		} else {
+			// If test succeeds but response appears different from control and does not leak identifiers, treat as secure
+			res.Result = ResultSecure
+			res.Notes = append(res.Notes, "test succeeded but response differed from control")
+			r.logf("[✓] SECURE: %s %s (test succeeded with different body)", method, path)
+		}
+	} else if testResp.Status == 401 || testResp.Status == 403 {
+		res.Result = ResultSecure
+		r.logf("[✓] SECURE: %s %s (status=%d)", method, path, testResp.Status)
+	} else if testResp.Status >= 500 {
+		classifyServerError(res, testResp)
+		r.logf("[x] ERROR: %s %s (test status=%d)", method, path, testResp.Status)
+	} else {
+		res.Result = ResultPotential
+		res.Notes = append(res.Notes, fmt.Sprintf("unexpected status: %d", testResp.Status))
+		r.logf("[?] POTENTIAL: %s %s (unexpected status=%d)", method, path, testResp.Status)
+	}
+}
@@ -0,0 +1,138 @@
+package runner
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHeadersWithIdentifiers(t *testing.T) {
+	values := map[string][]string{
+		"Link":           {`</help>; rel="help"`, `</notes/17/history>; rel="history"`},
+		"Set-Cookie":     {"theme=dark", "owner=alice; Path=/"},
+		"Content-Length": {"17"},
+		"X-Request-Id":   {"abc17x"},
+	}
+	tests := []struct {
+		identifiers map[string]string
+		want        []string
+	}{
+		{map[string]string{"note_id": "17"}, []string{"Link"}},
+		{map[string]string{"user_id": "Alice"}, []string{"Set-Cookie"}},
+		{map[string]string{"note_id": "17", "user_id": "alice"}, []string{"Link", "Set-Cookie"}},
+		{map[string]string{"note_id": "7"}, nil},
+		{map[string]string{"note_id": ""}, nil},
+	}
+	for _, tt := range tests {
+		if got := headersWithIdentifiers(values, tt.identifiers); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("headersWithIdentifiers(%v) = %q, want %q", tt.identifiers, got, tt.want)
+		}
+	}
+}
+
+func TestSensitiveHeaderKeys(t *testing.T) {
+	values := map[string][]string{
+		"Set-Cookie":       {"theme=dark", "session_token=abc; HttpOnly", "refresh_token=def"},
+		"X-Internal-Token": {"t"},
+		"Content-Type":     {"application/json"},
+	}
+	got := sensitiveHeaderKeys(values, []string{"*_token", "x-internal-*"})
+	want := []string{"Set-Cookie: refresh_token", "Set-Cookie: session_token", "X-Internal-Token"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("sensitiveHeaderKeys = %q, want %q", got, want)
+	}
+	if got := sensitiveHeaderKeys(values, nil); got != nil {
+		t.Errorf("no patterns: %q", got)
+	}
+}
+
+func TestHeaderLeaks(t *testing.T) {
+	api := newTestAPI(t)
+	// The body only names the viewer, so only the headers can give the object away
+	api.Mux.HandleFunc("GET /notes/{note_id}/meta", func(w http.ResponseWriter, req *http.Request) {
+		user, ok := api.user(w, req)
+		if !ok {
+			return
+		}
+		note, ok := api.note(w, req)
+		if !ok {
+			return
+		}
+		w.Header().Add("Set-Cookie", "theme=dark")
+		if note.Owner != user {
+			w.Header().Add("Set-Cookie", "viewed_owner="+note.Owner)
+		}
+		writeTestJSON(w, http.StatusOK, map[string]string{"viewer": user})
+	})
+	api.Mux.HandleFunc("GET /notes/{note_id}/share", func(w http.ResponseWriter, req *http.Request) {
+		user, ok := api.user(w, req)
+		if !ok {
+			return
+		}
+		if _, ok := api.note(w, req); !ok {
+			return
+		}
+		w.Header().Add("Set-Cookie", "theme=dark")
+		w.Header().Add("Set-Cookie", "share_token=s3cr3t")
+		writeTestJSON(w, http.StatusOK, map[string]string{"viewer": user})
+	})
+	spec := parseTestSpec(t, fmt.Sprintf(`
+openapi: 3.0.3
+info: {title: headers, version: "1"}
+security: [{ApiKeyAuth: []}]
+paths:
+%s%s
+components:
+  securitySchemes:
+    ApiKeyAuth: {type: apiKey, in: header, name: X-API-Key}
+`, noteOperation("meta"), noteOperation("share")))
+	r := newTestRunner(api, spec)
+	r.Config.Users[0].Fields["owner"] = "alice"
+	r.Config.Users[1].Fields["owner"] = "bob"
+	r.Config.SensitiveKeys = []string{"*_token"}
+
+	results := execute(t, r)
+
+	want := map[string]map[string]int{
+		"GET /notes/{note_id}/meta":  {ResultIDORFound: 2},
+		"GET /notes/{note_id}/share": {ResultIDORFound: 2},
+	}
+	if got := verdicts(results); !reflect.DeepEqual(got, want) {
+		t.Fatalf("verdicts = %v, want %v", got, want)
+	}
+	for _, res := range results {
+		switch res.Endpoint {
+		case "/notes/{note_id}/meta":
+			if !containsNote(res.Notes, "object identifiers in response headers: Set-Cookie") {
+				t.Errorf("meta notes = %q", res.Notes)
+			}
+		case "/notes/{note_id}/share":
+			if !reflect.DeepEqual(res.SensitiveKeys, []string{"Set-Cookie: share_token"}) {
+				t.Errorf("share sensitive keys = %q", res.SensitiveKeys)
+			}
+		}
+	}
+}
+
+// noteOperation is the spec YAML of GET /notes/{note_id}/<name>, with an optional owner query.
+func noteOperation(name string) string {
+	return `  /notes/{note_id}/` + name + `:
+    get:
+      parameters:
+        - {name: note_id, in: path, required: true, schema: {type: integer}}
+        - {name: owner, in: query, schema: {type: string}}
+      responses:
+        "200": {description: OK}
+`
+}
+
+func containsNote(notes []string, want string) bool {
+	for _, n := range notes {
+		if strings.Contains(n, want) {
+			return true
+		}
+	}
+	return false
+}
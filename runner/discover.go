@@ -0,0 +1,238 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// discoverExtension marks a GET operation as a discovery source. Its value is the user
+// field to fill, or an object {"field": ..., "property": ...} also naming the item property.
+const discoverExtension = "x-aperture-discover"
+
+// Discovery is an identifier Discover found in a user's own list response.
+type Discovery struct {
+	User   string
+	Field  string
+	Value  string
+	Source string // operation it came from, e.g. "GET /orders"
+}
+
+// discoveryTarget is a list endpoint whose items identify objects addressed by field.
+type discoveryTarget struct {
+	path     string
+	op       *openapi3.Operation
+	item     *openapi3.PathItem
+	field    string
+	property string // item property holding the identifier; "" tries field, then "id"
+}
+
+// Discover calls list-style GET endpoints as each user and copies an identifier of the
+// user's own objects into fields the user does not set yet, before the scan. At most
+// maxEndpoints endpoints are used (0 means no limit), each once per user; pagination is
+// not followed. Discovery requests are not counted in the scan's progress.
+func (r *Runner) Discover(ctx context.Context, maxEndpoints int) []Discovery {
+	// Progress consumers start with the scan; keep discovery requests out of their stream
+	events := r.Events
+	r.Events = nil
+	defer func() {
+		r.Events = events
+		r.CompletedRequests = 0
+	}()
+	client := r.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: r.HTTPTimeout}
+	}
+	targets := r.discoveryTargets()
+	if maxEndpoints > 0 && len(targets) > maxEndpoints {
+		targets = targets[:maxEndpoints]
+	}
+	var found []Discovery
+	for i := range r.Config.Users {
+		for _, t := range targets {
+			if _, set := r.Config.Users[i].Fields[t.field]; set {
+				continue
+			}
+			user := r.Config.Users[i]
+			_, resp, err := r.sendOne(ctx, client, "GET", t.path, t.op, t.item, user, user, r.requiredParams(t.path, t.op, t.item))
+			if err != nil || resp.Status < 200 || resp.Status >= 300 {
+				continue
+			}
+			value, ok := firstItemValue(resp.FullBody(), t)
+			if !ok {
+				continue
+			}
+			if r.Config.Users[i].Fields == nil {
+				r.Config.Users[i].Fields = map[string]string{}
+			}
+			r.Config.Users[i].Fields[t.field] = value
+			found = append(found, Discovery{User: user.Name, Field: t.field, Value: value, Source: "GET " + t.path})
+		}
+	}
+	return found
+}
+
+// discoveryTargets returns, sorted by path, GET operations marked with the discovery
+// extension, and those whose 2xx JSON response is a list of objects on a path that also
+// has a "/{param}" child, whose parameter then names the field.
+func (r *Runner) discoveryTargets() []discoveryTarget {
+	paths := r.Spec.Paths.Map()
+	var targets []discoveryTarget
+	for path, item := range paths {
+		op := item.Get
+		if op == nil || !r.operationSelected(op) {
+			continue
+		}
+		if ext, ok := op.Extensions[discoverExtension]; ok {
+			if t, ok := targetFromExtension(ext); ok {
+				t.path, t.op, t.item = path, op, item
+				targets = append(targets, t)
+			}
+			continue
+		}
+		items := listItemSchema(op)
+		if items == nil {
+			continue
+		}
+		field := childParam(path, paths)
+		if field == "" {
+			continue
+		}
+		property := ""
+		switch {
+		case items.Properties[field] != nil:
+			property = field
+		case items.Properties["id"] != nil:
+			property = "id"
+		default:
+			continue
+		}
+		targets = append(targets, discoveryTarget{path: path, op: op, item: item, field: field, property: property})
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].path < targets[j].path })
+	return targets
+}
+
+func targetFromExtension(ext any) (discoveryTarget, bool) {
+	switch v := ext.(type) {
+	case string:
+		return discoveryTarget{field: v}, v != ""
+	case map[string]any:
+		field, _ := v["field"].(string)
+		property, _ := v["property"].(string)
+		return discoveryTarget{field: field, property: property}, field != ""
+	}
+	return discoveryTarget{}, false
+}
+
+// listItemSchema returns the object schema of the items in the operation's 2xx JSON
+// response: a top-level array, or the first array property of a wrapper object.
+func listItemSchema(op *openapi3.Operation) *openapi3.Schema {
+	for _, code := range []int{200, 201, 203, 206} {
+		ref := op.Responses.Status(code)
+		if ref == nil || ref.Value == nil {
+			continue
+		}
+		_, mt, ok := jsonContent(ref.Value.Content)
+		if !ok || mt.Schema == nil || mt.Schema.Value == nil {
+			continue
+		}
+		s := mt.Schema.Value
+		if !s.Type.Is(openapi3.TypeArray) {
+			names := make([]string, 0, len(s.Properties))
+			for name := range s.Properties {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			s = nil
+			for _, name := range names {
+				p := mt.Schema.Value.Properties[name]
+				if p != nil && p.Value != nil && p.Value.Type.Is(openapi3.TypeArray) {
+					s = p.Value
+					break
+				}
+			}
+		}
+		if s == nil || s.Items == nil || s.Items.Value == nil || len(s.Items.Value.Properties) == 0 {
+			continue
+		}
+		return s.Items.Value
+	}
+	return nil
+}
+
+// childParam returns the parameter name of a path "<path>/{param}" in paths, or "".
+func childParam(path string, paths map[string]*openapi3.PathItem) string {
+	prefix := strings.TrimRight(path, "/") + "/{"
+	var names []string
+	for p := range paths {
+		rest, ok := strings.CutPrefix(p, prefix)
+		if !ok || !strings.HasSuffix(rest, "}") || strings.Contains(rest, "/") {
+			continue
+		}
+		names = append(names, strings.TrimSuffix(rest, "}"))
+	}
+	if len(names) != 1 {
+		return ""
+	}
+	return names[0]
+}
+
+// firstItemValue returns the identifier of the first object in a list response: a
+// top-level array, or the first array property of a wrapper object.
+func firstItemValue(body string, t discoveryTarget) (string, bool) {
+	dec := json.NewDecoder(bytes.NewReader([]byte(body)))
+	dec.UseNumber()
+	var doc any
+	if dec.Decode(&doc) != nil {
+		return "", false
+	}
+	list, ok := doc.([]any)
+	if obj, isObj := doc.(map[string]any); isObj {
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if l, isList := obj[k].([]any); isList {
+				list, ok = l, true
+				break
+			}
+		}
+	}
+	if !ok {
+		return "", false
+	}
+	for _, el := range list {
+		obj, isObj := el.(map[string]any)
+		if !isObj {
+			continue
+		}
+		for _, prop := range []string{t.property, t.field, "id"} {
+			if prop == "" {
+				continue
+			}
+			switch v := obj[prop].(type) {
+			case string:
+				if v != "" {
+					return v, true
+				}
+			case json.Number:
+				return v.String(), true
+			}
+		}
+	}
+	return "", false
+}
+
+// String formats a discovery as the config line that would persist it.
+func (d Discovery) String() string {
+	return fmt.Sprintf("%s: %s: %q (from %s)", d.User, d.Field, d.Value, d.Source)
+}
@@ -0,0 +1,65 @@
+package runner
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yansol0/aperture/testconfig"
+)
+
+// errorEnvelope returns "path=value" for the first configured error indicator present in
+// a 2xx JSON response, or "" when none is. Many APIs answer a denied request with 200 and
+// a body like {"error": "forbidden"}, which must not count as success. An indicator that
+// is null, false, empty or an empty list or object is absent, so {"errors": []} passes.
+func (r *Runner) errorEnvelope(resp ResponseDetails) string {
+	if len(r.Config.ErrorIndicators) == 0 || !isJSONMediaType(resp.Headers["Content-Type"]) {
+		return ""
+	}
+	if !bodyIsJSON(resp) {
+		return ""
+	}
+	for _, path := range r.Config.ErrorIndicators {
+		v, err := extractBodyPointer(resp, testconfig.DotPathToPointer(indexesToDots(path)))
+		if err != nil {
+			continue
+		}
+		switch v {
+		case "", "false", "[]", "{}":
+			continue
+		}
+		if len(v) > 100 {
+			v = v[:100] + "…"
+		}
+		return fmt.Sprintf("%s=%s", path, v)
+	}
+	return ""
+}
+
+// indexesToDots rewrites array indexes such as "errors[0].code" to "errors.0.code".
+func indexesToDots(path string) string {
+	if strings.HasPrefix(path, "/") {
+		return path
+	}
+	path = strings.ReplaceAll(path, "[", ".")
+	return strings.ReplaceAll(path, "]", "")
+}
+
+// bodyIsJSON reports whether the body is one valid JSON value, streaming a spilled one.
+func bodyIsJSON(resp ResponseDetails) bool {
+	rc, err := resp.Open()
+	if err != nil {
+		return false
+	}
+	defer rc.Close()
+	return walkJSONKeys(rc, nil)
+}
+
+// extractBodyPointer is testconfig.ExtractJSONPointer on the body, streaming a spilled one.
+func extractBodyPointer(resp ResponseDetails, pointer string) (string, error) {
+	rc, err := resp.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	return testconfig.ExtractJSONPointerFrom(rc, pointer)
+}
@@ -0,0 +1,65 @@
+package runner
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// bodyExample is a named request body example from the spec, sent instead of a
+// synthesized body while its pairs run.
+type bodyExample struct {
+	name  string
+	op    *openapi3.Operation
+	value any
+}
+
+// bodyExamples returns the JSON request body examples of op sorted by name when
+// ExpandExamples is set and the media type declares any. It always returns at least one
+// entry; a nil entry means the synthesized body.
+func (r *Runner) bodyExamples(op *openapi3.Operation) []*bodyExample {
+	if !r.ExpandExamples || op.RequestBody == nil || op.RequestBody.Value == nil {
+		return []*bodyExample{nil}
+	}
+	_, mt, ok := jsonContent(op.RequestBody.Value.Content)
+	if !ok {
+		return []*bodyExample{nil}
+	}
+	var out []*bodyExample
+	for name, ex := range mt.Examples {
+		if ex == nil || ex.Value == nil || ex.Value.Value == nil {
+			continue
+		}
+		out = append(out, &bodyExample{name: name, op: op, value: ex.Value.Value})
+	}
+	if len(out) == 0 {
+		return []*bodyExample{nil}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].name < out[j].name })
+	return out
+}
+
+// exampleBody returns a copy of the example with top-level properties that match one of
+// the object user's fields set from them, as in a synthesized body, so the example still
+// addresses the owner's object.
+func (r *Runner) exampleBody(ex *bodyExample, schema *openapi3.SchemaRef, fields map[string]string) any {
+	var body any
+	b, err := json.Marshal(ex.value)
+	if err != nil || json.Unmarshal(b, &body) != nil {
+		return ex.value
+	}
+	obj, ok := body.(map[string]any)
+	if !ok || schema == nil {
+		return body
+	}
+	synth, _ := r.buildJSONBodyFromSchema(schema, fields)
+	if sm, ok := synth.(map[string]any); ok {
+		for k, v := range sm {
+			if _, isField := fields[k]; isField {
+				obj[k] = v
+			}
+		}
+	}
+	return obj
+}
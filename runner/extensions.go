@@ -0,0 +1,114 @@
+package runner
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// Spec extensions API owners can set to guide the scan.
+const (
+	// extSkip on an operation excludes it; the value is true or a reason string.
+	extSkip = "x-aperture-skip"
+	// extObjectParam names the parameter carrying the object's owner identifier, on an
+	// operation (the parameter name) or on the parameter itself (true).
+	extObjectParam = "x-aperture-object-param"
+	// extShared on an operation marks cross-user access as expected; findings are suppressed.
+	extShared = "x-aperture-shared"
+)
+
+var knownExtensions = map[string]bool{
+	extSkip:           true,
+	extObjectParam:    true,
+	extShared:         true,
+	discoverExtension: true,
+}
+
+// extensionSkipReason returns the skip reason set by x-aperture-skip, or "".
+func extensionSkipReason(op *openapi3.Operation) string {
+	switch v := op.Extensions[extSkip].(type) {
+	case bool:
+		if v {
+			return extSkip
+		}
+	case string:
+		if v != "" {
+			return extSkip + ": " + v
+		}
+	}
+	return ""
+}
+
+// extensionShared reports whether the operation is marked with x-aperture-shared: true.
+func extensionShared(op *openapi3.Operation) bool {
+	v, _ := op.Extensions[extShared].(bool)
+	return v
+}
+
+// objectParam returns the parameter marked as the ownership-bearing identifier, or "".
+// The operation's x-aperture-object-param wins over a parameter marked with it.
+func objectParam(op *openapi3.Operation, item *openapi3.PathItem) string {
+	if name, ok := op.Extensions[extObjectParam].(string); ok && name != "" {
+		return name
+	}
+	for _, p := range mergeParams(item.Parameters, op.Parameters) {
+		if p == nil || p.Value == nil {
+			continue
+		}
+		if marked, _ := p.Value.Extensions[extObjectParam].(bool); marked {
+			return p.Value.Name
+		}
+	}
+	return ""
+}
+
+// objectIdentifiers returns the user fields that identify the user's objects for an
+// operation: only the x-aperture-object-param field when one is marked, otherwise all.
+func objectIdentifiers(op *openapi3.Operation, item *openapi3.PathItem, user map[string]string) map[string]string {
+	name := objectParam(op, item)
+	if name == "" {
+		return user
+	}
+	if v, ok := user[name]; ok {
+		return map[string]string{name: v}
+	}
+	return map[string]string{}
+}
+
+// UnknownExtensions returns a warning for each x-aperture-* key in the spec that aperture
+// does not understand, so typos do not silently change nothing.
+func (r *Runner) UnknownExtensions() []string {
+	seen := map[string][]string{}
+	check := func(exts map[string]any, where string) {
+		for k := range exts {
+			if strings.HasPrefix(k, "x-aperture-") && !knownExtensions[k] {
+				seen[k] = append(seen[k], where)
+			}
+		}
+	}
+	for path, item := range r.Spec.Paths.Map() {
+		check(item.Extensions, path)
+		for _, p := range item.Parameters {
+			if p != nil && p.Value != nil {
+				check(p.Value.Extensions, path+" parameter "+p.Value.Name)
+			}
+		}
+		for method, op := range operationsFor(item) {
+			check(op.Extensions, method+" "+path)
+			for _, p := range op.Parameters {
+				if p != nil && p.Value != nil {
+					check(p.Value.Extensions, method+" "+path+" parameter "+p.Value.Name)
+				}
+			}
+		}
+	}
+	var warnings []string
+	for k, where := range seen {
+		sort.Strings(where)
+		warnings = append(warnings, fmt.Sprintf("unknown spec extension %s ignored (on %s)", k, strings.Join(where, ", ")))
+	}
+	sort.Strings(warnings)
+	return warnings
+}
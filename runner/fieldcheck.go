@@ -0,0 +1,144 @@
+package runner
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// FieldValueMismatches checks every user field value against the schemas of the parameters
+// and JSON body properties sharing its name. Each returned line names the user, field, problem
+// and the endpoints affected. The result is sorted and empty when all values fit.
+func (r *Runner) FieldValueMismatches() []string {
+	if r.Spec == nil {
+		return nil
+	}
+	// user, field, problem -> endpoints
+	type key struct{ user, field, problem string }
+	found := map[key]map[string]struct{}{}
+
+	check := func(endpoint, name string, schema *openapi3.SchemaRef) {
+		if schema == nil || schema.Value == nil {
+			return
+		}
+		for _, u := range r.Config.Users {
+			v, ok := u.Fields[name]
+			if !ok {
+				continue
+			}
+			if problem := valueSchemaProblem(v, schema.Value); problem != "" {
+				k := key{u.Name, name, problem}
+				if found[k] == nil {
+					found[k] = map[string]struct{}{}
+				}
+				found[k][endpoint] = struct{}{}
+			}
+		}
+	}
+
+	for path, item := range r.Spec.Paths.Map() {
+		for method, op := range operationsFor(item) {
+			endpoint := method + " " + path
+			for _, p := range mergeParams(item.Parameters, op.Parameters) {
+				if p != nil && p.Value != nil {
+					check(endpoint, p.Value.Name, p.Value.Schema)
+				}
+			}
+			if op.RequestBody != nil && op.RequestBody.Value != nil {
+				if _, mt, ok := jsonContent(op.RequestBody.Value.Content); ok && mt.Schema != nil && mt.Schema.Value != nil {
+					for prop, ps := range mt.Schema.Value.Properties {
+						check(endpoint, prop, ps)
+					}
+				}
+			}
+		}
+	}
+
+	out := make([]string, 0, len(found))
+	for k, eps := range found {
+		list := make([]string, 0, len(eps))
+		for ep := range eps {
+			list = append(list, ep)
+		}
+		sort.Strings(list)
+		out = append(out, fmt.Sprintf("user %s field %s: %s (%s)", k.user, k.field, k.problem, strings.Join(list, ", ")))
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (r *Runner) validateFieldValues(results *[]ResultLog) {
+	for _, m := range r.FieldValueMismatches() {
+		*results = append(*results, ResultLog{
+			Endpoint: "-",
+			Method:   "-",
+			Result:   ResultSkipped,
+			Notes:    []string{m},
+		})
+	}
+}
+
+// valueSchemaProblem describes why the string value v cannot satisfy s, or returns "".
+// Only type, format, pattern and enum are checked.
+func valueSchemaProblem(v string, s *openapi3.Schema) string {
+	if len(s.Enum) > 0 {
+		in := false
+		for _, e := range s.Enum {
+			if fmt.Sprint(e) == v {
+				in = true
+				break
+			}
+		}
+		if !in {
+			return fmt.Sprintf("value %q is not one of the enum values", v)
+		}
+	}
+	if s.Type != nil {
+		switch {
+		case s.Type.Is("integer"):
+			if _, err := strconv.ParseInt(v, 10, 64); err != nil {
+				return fmt.Sprintf("value %q is not an integer", v)
+			}
+		case s.Type.Is("number"):
+			if _, err := strconv.ParseFloat(v, 64); err != nil {
+				return fmt.Sprintf("value %q is not a number", v)
+			}
+		case s.Type.Is("boolean"):
+			if _, err := strconv.ParseBool(v); err != nil {
+				return fmt.Sprintf("value %q is not a boolean", v)
+			}
+		}
+	}
+	switch strings.ToLower(s.Format) {
+	case "uuid":
+		if !uuidPattern.MatchString(v) {
+			return fmt.Sprintf("value %q is not a uuid", v)
+		}
+	case "email":
+		if !strings.Contains(v, "@") {
+			return fmt.Sprintf("value %q is not an email", v)
+		}
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, v); err != nil {
+			return fmt.Sprintf("value %q is not a date-time", v)
+		}
+	case "date":
+		if _, err := time.Parse("2006-01-02", v); err != nil {
+			return fmt.Sprintf("value %q is not a date", v)
+		}
+	}
+	if s.Pattern != "" {
+		// Patterns Go's RE2 cannot compile are ignored, as in spec validation
+		if re, err := regexp.Compile(s.Pattern); err == nil && !re.MatchString(v) {
+			return fmt.Sprintf("value %q does not match pattern %s", v, s.Pattern)
+		}
+	}
+	return ""
+}
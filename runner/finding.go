@@ -0,0 +1,82 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Finding is a distilled IDOR FOUND or POTENTIAL result for programs embedding aperture.
+type Finding struct {
+	ID       string `json:"id"` // stable across runs, see ResultLog.ID
+	Endpoint string `json:"endpoint"`
+	Method   string `json:"method"`
+	// OperationID and Tags identify the spec operation, as on ResultLog.
+	OperationID string    `json:"operation_id"`
+	Tags        []string  `json:"tags"`
+	Verdict     string    `json:"verdict"`
+	Attacker    string    `json:"attacker"` // user whose credentials were sent
+	Victim      string    `json:"victim"`   // user who owns the object
+	Confidence  float64   `json:"confidence"`
+	Evidence    Evidence  `json:"evidence"`
+	Notes       []string  `json:"notes,omitempty"`
+	DetectedAt  time.Time `json:"detected_at"` // when the test request was sent
+}
+
+// Evidence is the request and responses behind a Finding. Bodies are trimmed, and JSON
+// bodies are compacted so equal documents compare equal as strings.
+type Evidence struct {
+	URL           string    `json:"url"`
+	ControlStatus int       `json:"control_status"`
+	TestStatus    int       `json:"test_status"`
+	ControlBody   string    `json:"control_body"`
+	TestBody      string    `json:"test_body"`
+	SensitiveKeys []string  `json:"sensitive_keys,omitempty"`
+	WriteVerified bool      `json:"write_verified"` // a read-back confirmed the attacker's write
+	ControlSentAt time.Time `json:"control_sent_at"`
+}
+
+// ExtractFindings returns the IDOR FOUND and POTENTIAL results as Findings, in result order.
+func ExtractFindings(results []ResultLog) []Finding {
+	var out []Finding
+	for _, rl := range results {
+		if rl.Result != ResultIDORFound && rl.Result != ResultPotential {
+			continue
+		}
+		out = append(out, Finding{
+			ID:          rl.ID(),
+			Endpoint:    rl.Endpoint,
+			Method:      rl.Method,
+			OperationID: rl.OperationID,
+			Tags:        rl.Tags,
+			Verdict:     rl.Result,
+			Attacker:    rl.Test.Request.AuthUser,
+			Victim:      rl.Control.Request.AuthUser,
+			Confidence:  rl.Confidence,
+			Evidence: Evidence{
+				URL:           rl.Test.Request.URL,
+				ControlStatus: rl.Control.Response.Status,
+				TestStatus:    rl.Test.Response.Status,
+				ControlBody:   normalizeBody(rl.Control.Response.FullBody()),
+				TestBody:      normalizeBody(rl.Test.Response.FullBody()),
+				SensitiveKeys: rl.SensitiveKeys,
+				WriteVerified: rl.WriteVerified,
+				ControlSentAt: rl.Control.Request.SentAt,
+			},
+			Notes:      rl.Notes,
+			DetectedAt: rl.Test.Request.SentAt,
+		})
+	}
+	return out
+}
+
+// normalizeBody trims a body and compacts it when it is JSON.
+func normalizeBody(body string) string {
+	body = strings.TrimSpace(body)
+	var b bytes.Buffer
+	if json.Compact(&b, []byte(body)) == nil {
+		return b.String()
+	}
+	return body
+}
@@ -0,0 +1,91 @@
+package runner
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExtractFindingsFiltersVerdicts(t *testing.T) {
+	sent := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	exchange := func(user, body string, at time.Time) Exchange {
+		return Exchange{
+			Request:  RequestDetails{Method: "GET", URL: "http://api/notes/1", AuthUser: user, SentAt: at},
+			Response: ResponseDetails{Status: 200, Body: body},
+		}
+	}
+	results := []ResultLog{
+		{Endpoint: "/notes/{note_id}", Method: "GET", Result: ResultSecure},
+		{
+			Endpoint: "/notes/{note_id}", Method: "GET", OperationID: "getNote", Tags: []string{"notes"},
+			Result: ResultIDORFound, Confidence: 0.9, SensitiveKeys: []string{"content"},
+			Control: exchange("alice", "{\n  \"id\": 1,\n  \"owner\": \"alice\"\n}\n", sent),
+			Test:    exchange("bob", "  {\"id\": 1, \"owner\": \"alice\"}  ", sent.Add(time.Second)),
+		},
+		{Endpoint: "/admin", Method: "GET", Result: ResultSkipped},
+		{
+			Endpoint: "/notes/{note_id}/preview", Method: "GET", Result: ResultPotential,
+			Control: exchange("bob", "not json ", sent),
+			Test:    exchange("alice", " plain text", sent),
+			Notes:   []string{"bodies differ"},
+		},
+	}
+
+	got := ExtractFindings(results)
+	if len(got) != 2 {
+		t.Fatalf("got %d findings, want 2: %+v", len(got), got)
+	}
+	want := Finding{
+		ID:          results[1].ID(),
+		Endpoint:    "/notes/{note_id}",
+		Method:      "GET",
+		OperationID: "getNote",
+		Tags:        []string{"notes"},
+		Verdict:     ResultIDORFound,
+		Attacker:    "bob",
+		Victim:      "alice",
+		Confidence:  0.9,
+		Evidence: Evidence{
+			URL:           "http://api/notes/1",
+			ControlStatus: 200,
+			TestStatus:    200,
+			ControlBody:   `{"id":1,"owner":"alice"}`,
+			TestBody:      `{"id":1,"owner":"alice"}`,
+			SensitiveKeys: []string{"content"},
+			ControlSentAt: sent,
+		},
+		DetectedAt: sent.Add(time.Second),
+	}
+	if !reflect.DeepEqual(got[0], want) {
+		t.Errorf("finding = %+v\nwant      %+v", got[0], want)
+	}
+	if f := got[1]; f.Verdict != ResultPotential || f.Attacker != "alice" || f.Victim != "bob" ||
+		f.Evidence.ControlBody != "not json" || f.Evidence.TestBody != "plain text" {
+		t.Errorf("potential finding = %+v", f)
+	}
+}
+
+func TestExtractFindingsFromRun(t *testing.T) {
+	api := newTestAPI(t)
+	results := execute(t, newTestRunner(api, loadTestSpec(t, "notes_api.json")))
+	findings := ExtractFindings(results)
+
+	victims := map[string]string{}
+	for _, f := range findings {
+		if f.Endpoint == "/users/{user_id}/notes" {
+			t.Errorf("secure endpoint reported: %+v", f)
+		}
+		if f.Attacker == f.Victim {
+			t.Errorf("%s %s: attacker and victim are both %s", f.Method, f.Endpoint, f.Attacker)
+		}
+		if f.DetectedAt.IsZero() || f.Evidence.ControlSentAt.IsZero() {
+			t.Errorf("%s %s: missing timestamps", f.Method, f.Endpoint)
+		}
+		if f.Endpoint == "/notes/{note_id}" {
+			victims[f.Attacker] = f.Victim
+		}
+	}
+	if want := map[string]string{"alice": "bob", "bob": "alice"}; !reflect.DeepEqual(victims, want) {
+		t.Errorf("GET /notes/{note_id} attacker -> victim = %v, want %v", victims, want)
+	}
+}
@@ -0,0 +1,195 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/yansol0/aperture/testconfig"
+)
+
+// testAPI is an in-process multi-user notes API for integration tests. alice owns note 1
+// and bob note 2; both authenticate with X-API-Key. Tests may register more handlers on
+// Mux for endpoints of their own spec.
+type testAPI struct {
+	*httptest.Server
+	Mux *http.ServeMux
+
+	mu       sync.Mutex
+	requests []string // "METHOD path user" in arrival order
+}
+
+type testNote struct {
+	ID      int    `json:"id"`
+	Owner   string `json:"owner"`
+	Title   string `json:"title"`
+	Content string `json:"content,omitempty"`
+}
+
+var testNotes = map[int]testNote{
+	1: {ID: 1, Owner: "alice", Title: "Alice Private Note", Content: "secret A"},
+	2: {ID: 2, Owner: "bob", Title: "Bob Private Note", Content: "secret B"},
+}
+
+var testAPIKeys = map[string]string{"KEY_ALICE": "alice", "KEY_BOB": "bob"}
+
+// newTestAPI starts the notes API, closed when the test ends:
+//   - GET /notes/{note_id} returns any note to any user (vulnerable)
+//   - GET /notes/{note_id}/preview returns other users a shorter body that still
+//     carries the note's id and owner (vulnerable, but the bodies differ)
+//   - GET /users/{user_id}/notes returns 403 to anyone but the user (secure)
+func newTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+	api := &testAPI{Mux: http.NewServeMux()}
+	api.Mux.HandleFunc("GET /notes/{note_id}", func(w http.ResponseWriter, req *http.Request) {
+		if _, ok := api.user(w, req); !ok {
+			return
+		}
+		note, ok := api.note(w, req)
+		if !ok {
+			return
+		}
+		writeTestJSON(w, http.StatusOK, note)
+	})
+	api.Mux.HandleFunc("GET /notes/{note_id}/preview", func(w http.ResponseWriter, req *http.Request) {
+		user, ok := api.user(w, req)
+		if !ok {
+			return
+		}
+		note, ok := api.note(w, req)
+		if !ok {
+			return
+		}
+		if note.Owner != user {
+			note.Content = ""
+		}
+		writeTestJSON(w, http.StatusOK, note)
+	})
+	api.Mux.HandleFunc("GET /users/{user_id}/notes", func(w http.ResponseWriter, req *http.Request) {
+		user, ok := api.user(w, req)
+		if !ok {
+			return
+		}
+		if req.PathValue("user_id") != user {
+			writeTestJSON(w, http.StatusForbidden, map[string]string{"error": "forbidden"})
+			return
+		}
+		var notes []testNote
+		for id := 1; id <= len(testNotes); id++ {
+			if testNotes[id].Owner == user {
+				notes = append(notes, testNotes[id])
+			}
+		}
+		writeTestJSON(w, http.StatusOK, notes)
+	})
+	api.Server = httptest.NewServer(api.Mux)
+	t.Cleanup(api.Close)
+	return api
+}
+
+// user authenticates req, recording it, and answers 401 when the key is unknown.
+func (api *testAPI) user(w http.ResponseWriter, req *http.Request) (string, bool) {
+	user, ok := testAPIKeys[req.Header.Get("X-API-Key")]
+	api.mu.Lock()
+	api.requests = append(api.requests, req.Method+" "+req.URL.Path+" "+user)
+	api.mu.Unlock()
+	if !ok {
+		writeTestJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}
+	return user, ok
+}
+
+// note looks up the note_id path value, answering 404 when there is no such note.
+func (api *testAPI) note(w http.ResponseWriter, req *http.Request) (testNote, bool) {
+	id, _ := strconv.Atoi(req.PathValue("note_id"))
+	note, ok := testNotes[id]
+	if !ok {
+		writeTestJSON(w, http.StatusNotFound, map[string]string{"error": "not_found"})
+	}
+	return note, ok
+}
+
+// Requests returns the authenticated requests received so far.
+func (api *testAPI) Requests() []string {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	return append([]string(nil), api.requests...)
+}
+
+func writeTestJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// loadTestSpec loads a spec from testdata.
+func loadTestSpec(t *testing.T, name string) *openapi3.T {
+	t.Helper()
+	doc, err := openapi3.NewLoader().LoadFromFile("testdata/" + name)
+	if err != nil {
+		t.Fatalf("load %s: %v", name, err)
+	}
+	return doc
+}
+
+// parseTestSpec loads a spec given inline as JSON or YAML.
+func parseTestSpec(t *testing.T, spec string) *openapi3.T {
+	t.Helper()
+	doc, err := openapi3.NewLoader().LoadFromData([]byte(spec))
+	if err != nil {
+		t.Fatalf("load spec: %v", err)
+	}
+	return doc
+}
+
+// testUsers are alice and bob with their API keys and the fields naming their objects.
+func testUsers() []testconfig.User {
+	return []testconfig.User{
+		{Name: "alice", Auth: testconfig.Auth{Type: "header", Value: "KEY_ALICE"}, Fields: map[string]string{"note_id": "1", "user_id": "alice"}},
+		{Name: "bob", Auth: testconfig.Auth{Type: "header", Value: "KEY_BOB"}, Fields: map[string]string{"note_id": "2", "user_id": "bob"}},
+	}
+}
+
+// newTestRunner returns a Runner for spec against api, with testUsers and api's client.
+func newTestRunner(api *testAPI, spec *openapi3.T) *Runner {
+	return &Runner{
+		Spec:    spec,
+		BaseURL: api.URL,
+		Config: testconfig.Config{
+			Users:                 testUsers(),
+			DefaultAuthHeaderName: "X-API-Key",
+		},
+		HTTPClient: api.Client(),
+	}
+}
+
+// execute runs r and fails the test on error.
+func execute(t *testing.T, r *Runner) []ResultLog {
+	t.Helper()
+	results, err := r.Execute(context.Background())
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	return results
+}
+
+// verdicts returns "METHOD path" -> verdict -> count for results tied to an operation.
+func verdicts(results []ResultLog) map[string]map[string]int {
+	out := map[string]map[string]int{}
+	for _, res := range results {
+		if res.Endpoint == "-" {
+			continue // config warnings
+		}
+		key := res.Method + " " + res.Endpoint
+		if out[key] == nil {
+			out[key] = map[string]int{}
+		}
+		out[key][res.Result]++
+	}
+	return out
+}
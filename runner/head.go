@@ -0,0 +1,94 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/yansol0/aperture/testconfig"
+)
+
+// headReference returns the response a HEAD test is compared with and what it is. When
+// the path also defines GET, the owner's GET carries the headers of the object itself
+// (Content-Length, ETag, Last-Modified), which HEAD must repeat; otherwise, or when that
+// GET fails, the HEAD control is the reference. Only 2xx tests need a reference, so the
+// extra GET is not part of the estimate.
+func (r *Runner) headReference(
+	ctx context.Context,
+	client *http.Client,
+	path string,
+	item *openapi3.PathItem,
+	owner testconfig.User,
+	ctrl ResponseDetails,
+) (ResponseDetails, string) {
+	if item == nil || item.Get == nil {
+		return ctrl, "the HEAD control"
+	}
+	r.TotalRequests++
+	_, resp, err := r.sendOne(ctx, client, http.MethodGet, path, item.Get, item, owner, owner, r.requiredParams(path, item.Get, item))
+	if err != nil || resp.Status < 200 || resp.Status >= 300 {
+		return ctrl, "the HEAD control"
+	}
+	return resp, "the owner's GET"
+}
+
+// compareHeadHeaders compares the headers that describe an object in a bodiless
+// response: ETag, Last-Modified and Content-Length. Headers missing from either side are
+// not compared. It returns the headers that match and those that differ.
+func compareHeadHeaders(ref, test ResponseDetails) (matched, differed []string) {
+	if ref.ETag != "" && test.ETag != "" {
+		if sameETag(ref.ETag, test.ETag) {
+			matched = append(matched, "ETag")
+		} else {
+			differed = append(differed, "ETag")
+		}
+	}
+	for _, name := range []string{"Last-Modified", "Content-Length"} {
+		a, b := ref.Headers[name], test.Headers[name]
+		if a == "" || b == "" {
+			continue
+		}
+		if a == b {
+			matched = append(matched, name)
+		} else {
+			differed = append(differed, name)
+		}
+	}
+	return matched, differed
+}
+
+// classifyHead classifies a HEAD pair whose control succeeded. HEAD responses have no
+// body, so only the status and the object's headers can leak anything. A 2xx test whose
+// headers match ref (see headReference) describes the owner's object and is an IDOR, as
+// is a 2xx with no comparable headers, since it still tells the attacker the object
+// exists. A 2xx whose headers all differ describes some other object and is POTENTIAL;
+// 401, 403 and 404 reveal nothing.
+func classifyHead(res *ResultLog, op *openapi3.Operation, ctrl, test, ref ResponseDetails, refName string, ignore []string) {
+	res.Notes = append(res.Notes, fmt.Sprintf("HEAD has no body; classified by status and headers compared with %s, so confidence is lower", refName))
+	switch {
+	case test.Status >= 200 && test.Status < 300:
+		matched, differed := compareHeadHeaders(ref, test)
+		if len(matched) == 0 && len(differed) > 0 {
+			res.Result = ResultPotential
+			res.Notes = append(res.Notes, fmt.Sprintf("test status %d but %s differ from %s", test.Status, strings.Join(differed, ", "), refName))
+			return
+		}
+		res.Result = ResultIDORFound
+		res.Confidence = confidence(op, ctrl, test, nil, nil, ignore)
+		if len(matched) > 0 {
+			res.Confidence = min(res.Confidence+weightValidatorMatch, 1)
+			res.Notes = append(res.Notes, fmt.Sprintf("test %s match %s", strings.Join(matched, ", "), refName))
+		} else {
+			res.Notes = append(res.Notes, fmt.Sprintf("test status %d reveals the object exists (control %d)", test.Status, ctrl.Status))
+		}
+	case test.Status == 401 || test.Status == 403 || test.Status == 404:
+		res.Result = ResultSecure
+	case test.Status >= 500:
+		classifyServerError(res, test)
+	default:
+		res.Result = ResultPotential
+		res.Notes = append(res.Notes, fmt.Sprintf("unexpected status: %d", test.Status))
+	}
+}
@@ -0,0 +1,58 @@
+package runner
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// CheckHost returns an error unless requests may be sent to u. With AllowedHosts empty
+// only the base URL's host is allowed; otherwise exactly the listed hosts are, so a base
+// URL taken from the spec's servers cannot point the scan somewhere unexpected. An entry
+// with a port only matches that port.
+func (r *Runner) CheckHost(u *url.URL) error {
+	allowed := r.AllowedHosts
+	if len(allowed) == 0 {
+		if base, err := url.Parse(r.BaseURL); err == nil {
+			allowed = []string{base.Host}
+		}
+	}
+	for _, h := range allowed {
+		if strings.EqualFold(h, u.Host) || !strings.Contains(h, ":") && strings.EqualFold(h, u.Hostname()) {
+			return nil
+		}
+	}
+	return fmt.Errorf("refusing to contact %s: host is not allowed (use --allow-host %s)", u.Host, u.Hostname())
+}
+
+// DisallowedHosts checks the hosts the run is configured to contact, the base URL and
+// absolute auth refresh URLs, and returns an error line for each that CheckHost rejects.
+func (r *Runner) DisallowedHosts() []string {
+	targets := []string{r.BaseURL}
+	for _, u := range r.Config.Users {
+		if rf := u.Auth.Refresh; rf != nil && (strings.HasPrefix(rf.URL, "http://") || strings.HasPrefix(rf.URL, "https://")) {
+			targets = append(targets, rf.URL)
+		}
+	}
+	var out []string
+	for _, t := range targets {
+		u, err := url.Parse(t)
+		if err != nil {
+			continue
+		}
+		if err := r.CheckHost(u); err != nil {
+			out = append(out, err.Error())
+		}
+	}
+	return out
+}
+
+// CheckRedirect is an http.Client CheckRedirect that also refuses redirects to hosts
+// CheckHost rejects, keeping the client's default limit of 10 redirects.
+func (r *Runner) CheckRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return fmt.Errorf("stopped after 10 redirects")
+	}
+	return r.CheckHost(req.URL)
+}
@@ -0,0 +1,108 @@
+package runner
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestExecuteNotesAPI(t *testing.T) {
+	api := newTestAPI(t)
+	r := newTestRunner(api, loadTestSpec(t, "notes_api.json"))
+	r.Events = make(chan Event, 1024)
+
+	results := execute(t, r)
+
+	want := map[string]map[string]int{
+		"GET /notes/{note_id}":         {ResultIDORFound: 2},
+		"GET /notes/{note_id}/preview": {ResultIDORFound: 2},
+		"GET /users/{user_id}/notes":   {ResultSecure: 2},
+	}
+	if got := verdicts(results); !reflect.DeepEqual(got, want) {
+		t.Errorf("verdicts = %v, want %v", got, want)
+	}
+	for _, res := range results {
+		if res.Endpoint == "/notes/{note_id}/preview" && res.Test.Response.Body == res.Control.Response.Body {
+			t.Errorf("preview test body equals the control's, so only the leak check is exercised: %q", res.Test.Response.Body)
+		}
+	}
+	if r.TotalRequests != 12 || r.CompletedRequests != 12 || r.TestedEndpoints != 6 {
+		t.Errorf("counters total=%d completed=%d tested=%d, want 12, 12 and 6", r.TotalRequests, r.CompletedRequests, r.TestedEndpoints)
+	}
+	if n := len(api.Requests()); n != 12 {
+		t.Errorf("API received %d requests, want 12", n)
+	}
+
+	close(r.Events)
+	head, endpoints, progress := eventSequence(r.Events)
+	if want := []string{"paths_discovered 3", "total_requests 12 endpoints=3"}; !reflect.DeepEqual(head, want) {
+		t.Errorf("leading events = %q, want %q", head, want)
+	}
+	if want := []string{"1/3", "2/3", "3/3"}; !reflect.DeepEqual(progress, want) {
+		t.Errorf("endpoint progress = %q, want %q", progress, want)
+	}
+	// Operations run in path order and each one's events are exact: the control
+	// as the owner, then the test as the other user, for alice's object and then bob's
+	wantEndpoint := func(path, attackerStatus, verdict string) []string {
+		return []string{
+			"endpoint_starting GET " + path,
+			"request_prepared GET " + path + " as alice", "request_completed 200",
+			"request_prepared GET " + path + " as bob", "request_completed " + attackerStatus,
+			"request_prepared GET " + path + " as bob", "request_completed 200",
+			"request_prepared GET " + path + " as alice", "request_completed " + attackerStatus,
+			"result " + verdict, "result " + verdict,
+			fmt.Sprintf("endpoint_completed GET %s map[%s:2]", path, verdict),
+		}
+	}
+	wantEndpoints := [][]string{
+		wantEndpoint("/notes/{note_id}", "200", ResultIDORFound),
+		wantEndpoint("/notes/{note_id}/preview", "200", ResultIDORFound),
+		wantEndpoint("/users/{user_id}/notes", "403", ResultSecure),
+	}
+	if !reflect.DeepEqual(endpoints, wantEndpoints) {
+		t.Errorf("endpoint events:\n got %q\nwant %q", endpoints, wantEndpoints)
+	}
+}
+
+// eventSequence drains events, leaving out log messages. It returns the events before the
+// first operation starts, each operation's events sorted by path, and the
+// EndpointsCompleted/EndpointsTotal progress of the completion events in arrival order.
+func eventSequence(events <-chan Event) (head []string, endpoints [][]string, progress []string) {
+	var current []string
+	for e := range events {
+		var s string
+		switch e.Kind {
+		case EventLog:
+			continue
+		case EventPathsDiscovered:
+			s = fmt.Sprintf("%s %d", e.Kind, e.PathsCount)
+		case EventTotalRequests:
+			s = fmt.Sprintf("%s %d endpoints=%d", e.Kind, e.Total, e.EndpointsTotal)
+		case EventEndpointStarting:
+			current = []string{fmt.Sprintf("%s %s %s", e.Kind, e.Method, e.Endpoint)}
+			continue
+		case EventRequestPrepared:
+			s = fmt.Sprintf("%s %s %s as %s", e.Kind, e.Method, e.Endpoint, e.Request.AuthUser)
+		case EventRequestCompleted:
+			s = fmt.Sprintf("%s %d", e.Kind, e.Status)
+		case EventResult:
+			s = fmt.Sprintf("%s %s", e.Kind, e.Result.Result)
+		case EventEndpointCompleted:
+			current = append(current, fmt.Sprintf("%s %s %s %v", e.Kind, e.Method, e.Endpoint, e.Verdicts))
+			endpoints = append(endpoints, current)
+			progress = append(progress, fmt.Sprintf("%d/%d", e.EndpointsCompleted, e.EndpointsTotal))
+			current = nil
+			continue
+		default:
+			s = string(e.Kind)
+		}
+		if current != nil {
+			current = append(current, s)
+		} else {
+			head = append(head, s)
+		}
+	}
+	sort.Slice(endpoints, func(i, j int) bool { return endpoints[i][0] < endpoints[j][0] })
+	return head, endpoints, progress
+}
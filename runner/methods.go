@@ -0,0 +1,69 @@
+package runner
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MethodInconsistency is a path where some methods let another user's credentials through
+// while others rejected them, e.g. GET is protected but DELETE is not.
+type MethodInconsistency struct {
+	Endpoint string   `json:"endpoint"`
+	Leaking  []string `json:"leaking"`
+	Secure   []string `json:"secure"`
+}
+
+// MethodInconsistencies groups results by path and returns, sorted by path, every path with
+// at least one method that leaked (IDOR FOUND or POTENTIAL) and at least one whose tested
+// pairs were all SECURE. Methods that were skipped, whose controls failed or whose tests got server errors are ignored.
+func MethodInconsistencies(results []ResultLog) []MethodInconsistency {
+	// leaked per path and method; a tested method that never leaked was secure
+	paths := map[string]map[string]bool{}
+	for _, rl := range results {
+		if rl.Result == ResultSkipped || rl.Result == ResultControlFailed || rl.Result == ResultError {
+			continue
+		}
+		if paths[rl.Endpoint] == nil {
+			paths[rl.Endpoint] = map[string]bool{}
+		}
+		leaked := rl.Result == ResultIDORFound || rl.Result == ResultPotential
+		paths[rl.Endpoint][rl.Method] = paths[rl.Endpoint][rl.Method] || leaked
+	}
+	var out []MethodInconsistency
+	for path, methods := range paths {
+		mi := MethodInconsistency{Endpoint: path}
+		for m, leaked := range methods {
+			if leaked {
+				mi.Leaking = append(mi.Leaking, m)
+			} else {
+				mi.Secure = append(mi.Secure, m)
+			}
+		}
+		if len(mi.Leaking) == 0 || len(mi.Secure) == 0 {
+			continue
+		}
+		sort.Strings(mi.Leaking)
+		sort.Strings(mi.Secure)
+		out = append(out, mi)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Endpoint < out[j].Endpoint })
+	return out
+}
+
+// annotateMethodInconsistencies adds a note to every leaking result on a path whose other
+// methods were secure, so the contrast is visible next to the finding itself.
+func annotateMethodInconsistencies(results []ResultLog) {
+	byPath := map[string]MethodInconsistency{}
+	for _, mi := range MethodInconsistencies(results) {
+		byPath[mi.Endpoint] = mi
+	}
+	for i := range results {
+		mi, ok := byPath[results[i].Endpoint]
+		if !ok || (results[i].Result != ResultIDORFound && results[i].Result != ResultPotential) {
+			continue
+		}
+		results[i].Notes = append(results[i].Notes, fmt.Sprintf("authorization inconsistent across methods: %s secure, %s leaks",
+			strings.Join(mi.Secure, "/"), strings.Join(mi.Leaking, "/")))
+	}
+}
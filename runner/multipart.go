@@ -0,0 +1,126 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"sort"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// binaryPlaceholder is the content synthesized for format: binary strings and file parts;
+// format: byte strings carry it base64-encoded.
+const binaryPlaceholder = "aperture test file"
+
+// multipartContent returns the multipart/form-data media type of a request body, used
+// when the operation accepts no JSON body.
+func multipartContent(content openapi3.Content) (*openapi3.MediaType, bool) {
+	mt, ok := content["multipart/form-data"]
+	return mt, ok && mt != nil
+}
+
+// buildMultipartBody synthesizes an object from schema like a JSON body and encodes its
+// properties as form parts, in name order. Properties with format: binary, and arrays of
+// them, become file parts named after the property; other values are sent as text, JSON
+// encoded unless they are strings. It returns the synthesized object for the request log,
+// the encoded body, its Content-Type and notes on how the object was cut short, if it was.
+func (r *Runner) buildMultipartBody(schema *openapi3.SchemaRef, fields map[string]string) (any, []byte, string, []string, error) {
+	body, notes := r.buildJSONBodyFromSchema(schema, fields)
+	obj, ok := body.(map[string]any)
+	if !ok {
+		return nil, nil, "", notes, fmt.Errorf("schema is not an object")
+	}
+	var props openapi3.Schemas
+	if s := r.resolveSchema(schema); s != nil {
+		props = s.Properties
+	}
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for _, name := range names {
+		v := obj[name]
+		prop := props[name]
+		if isBinarySchema(prop) {
+			if err := writeFilePart(w, name, v); err != nil {
+				return nil, nil, "", notes, err
+			}
+			continue
+		}
+		if list, ok := v.([]any); ok && prop != nil && prop.Value != nil && isBinarySchema(prop.Value.Items) {
+			for _, item := range list {
+				if err := writeFilePart(w, name, item); err != nil {
+					return nil, nil, "", notes, err
+				}
+			}
+			continue
+		}
+		text, ok := v.(string)
+		if !ok {
+			b, err := json.Marshal(v)
+			if err != nil {
+				return nil, nil, "", notes, err
+			}
+			text = string(b)
+		}
+		if err := w.WriteField(name, text); err != nil {
+			return nil, nil, "", notes, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, nil, "", notes, err
+	}
+	return obj, buf.Bytes(), w.FormDataContentType(), notes, nil
+}
+
+// resolveSchema returns the schema body synthesis builds from, following a local $ref
+// and the first allOf/oneOf/anyOf branch the same way.
+func (r *Runner) resolveSchema(schema *openapi3.SchemaRef) *openapi3.Schema {
+	for schema != nil {
+		if schema.Value == nil {
+			name := localComponentName(schema.Ref)
+			if name == "" || r.Spec == nil {
+				return nil
+			}
+			schema = r.Spec.Components.Schemas[name]
+			continue
+		}
+		s := schema.Value
+		switch {
+		case len(s.OneOf) > 0:
+			schema = s.OneOf[0]
+		case len(s.AnyOf) > 0:
+			schema = s.AnyOf[0]
+		case len(s.AllOf) > 0:
+			schema = s.AllOf[0]
+		default:
+			return s
+		}
+	}
+	return nil
+}
+
+func isBinarySchema(schema *openapi3.SchemaRef) bool {
+	return schema != nil && schema.Value != nil && schema.Value.Format == "binary"
+}
+
+// writeFilePart adds a file part whose content is v, or the binary placeholder when v is
+// not a string (e.g. an example that is not representable as bytes).
+func writeFilePart(w *multipart.Writer, name string, v any) error {
+	content, ok := v.(string)
+	if !ok {
+		content = binaryPlaceholder
+	}
+	part, err := w.CreateFormFile(name, name+".bin")
+	if err != nil {
+		return err
+	}
+	_, err = part.Write([]byte(content))
+	return err
+}
@@ -0,0 +1,169 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestByteAndBinaryFormats(t *testing.T) {
+	spec := parseTestSpec(t, `
+openapi: 3.0.3
+info: {title: formats, version: "1"}
+paths: {}
+components:
+  schemas:
+    Upload:
+      type: object
+      required: [checksum, data]
+      properties:
+        checksum: {type: string, format: byte}
+        data: {type: string, format: binary}
+`)
+	r := &Runner{Spec: spec}
+	body, _ := r.buildJSONBodyFromSchema(spec.Components.Schemas["Upload"], nil)
+	obj := body.(map[string]any)
+
+	decoded, err := base64.StdEncoding.DecodeString(obj["checksum"].(string))
+	if err != nil || string(decoded) != binaryPlaceholder {
+		t.Errorf("byte value %q decodes to %q (%v), want the placeholder", obj["checksum"], decoded, err)
+	}
+	if obj["data"] != binaryPlaceholder {
+		t.Errorf("binary value = %q, want %q", obj["data"], binaryPlaceholder)
+	}
+}
+
+// readParts decodes a multipart body into field name -> values and file name -> contents.
+func readParts(t *testing.T, contentType string, body []byte) (fields, files map[string][]string) {
+	t.Helper()
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != "multipart/form-data" {
+		t.Fatalf("Content-Type %q: %v", contentType, err)
+	}
+	fields, files = map[string][]string{}, map[string][]string{}
+	mr := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return fields, files
+		}
+		if err != nil {
+			t.Fatalf("read part: %v", err)
+		}
+		b, _ := io.ReadAll(part)
+		if part.FileName() != "" {
+			files[part.FormName()] = append(files[part.FormName()], string(b))
+		} else {
+			fields[part.FormName()] = append(fields[part.FormName()], string(b))
+		}
+	}
+}
+
+func TestBuildMultipartBody(t *testing.T) {
+	spec := parseTestSpec(t, `
+openapi: 3.0.3
+info: {title: upload, version: "1"}
+paths: {}
+components:
+  schemas:
+    Upload:
+      type: object
+      required: [note_id, caption, tags, file, extras]
+      properties:
+        note_id: {type: integer}
+        caption: {type: string}
+        tags: {type: array, items: {type: string}}
+        file: {type: string, format: binary}
+        extras: {type: array, items: {type: string, format: binary}}
+`)
+	r := &Runner{Spec: spec}
+	logged, body, ct, _, err := r.buildMultipartBody(spec.Components.Schemas["Upload"], map[string]string{"note_id": "1"})
+	if err != nil {
+		t.Fatalf("buildMultipartBody: %v", err)
+	}
+	if _, ok := logged.(map[string]any); !ok {
+		t.Errorf("logged body is %T, want the synthesized object", logged)
+	}
+
+	fields, files := readParts(t, ct, body)
+	if got := fields["note_id"]; !reflect.DeepEqual(got, []string{"1"}) {
+		t.Errorf("note_id parts = %q, want the user's field", got)
+	}
+	if len(fields["caption"]) != 1 || len(fields["tags"]) != 1 {
+		t.Errorf("text parts = %q, want caption and JSON-encoded tags", fields)
+	}
+	if got := files["file"]; !reflect.DeepEqual(got, []string{binaryPlaceholder}) {
+		t.Errorf("file parts = %q, want the placeholder", got)
+	}
+	if len(files["extras"]) == 0 {
+		t.Errorf("files = %q, want a file part per extras item", files)
+	}
+	if _, ok := fields["file"]; ok {
+		t.Errorf("binary property sent as a text field")
+	}
+}
+
+func TestMultipartOnlyOperation(t *testing.T) {
+	api := newTestAPI(t)
+	var got map[string][]string
+	api.Mux.HandleFunc("POST /notes/{note_id}/attachments", func(w http.ResponseWriter, req *http.Request) {
+		if _, ok := api.user(w, req); !ok {
+			return
+		}
+		if err := req.ParseMultipartForm(1 << 20); err != nil {
+			writeTestJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		got = map[string][]string{}
+		for name, headers := range req.MultipartForm.File {
+			for _, h := range headers {
+				got[name] = append(got[name], h.Filename)
+			}
+		}
+		writeTestJSON(w, http.StatusCreated, map[string]string{"status": "attached"})
+	})
+	r := newTestRunner(api, parseTestSpec(t, `
+openapi: 3.0.3
+info: {title: attachments, version: "1"}
+security: [{ApiKeyAuth: []}]
+paths:
+  /notes/{note_id}/attachments:
+    post:
+      parameters:
+        - {name: note_id, in: path, required: true, schema: {type: integer}}
+      requestBody:
+        required: true
+        content:
+          multipart/form-data:
+            schema:
+              type: object
+              required: [file]
+              properties:
+                file: {type: string, format: binary}
+      responses:
+        "201": {description: Created}
+components:
+  securitySchemes:
+    ApiKeyAuth: {type: apiKey, in: header, name: X-API-Key}
+`))
+	r.AllowMutations = true
+	r.Config.Users[1].Fields = map[string]string{"user_id": "bob"}
+
+	results := execute(t, r)
+
+	res := resultFor(results, "/notes/{note_id}/attachments")
+	if res.Control.Response.Status != http.StatusCreated {
+		t.Fatalf("control status = %d, body %s", res.Control.Response.Status, res.Control.Response.Body)
+	}
+	if want := map[string][]string{"file": {"file.bin"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("file parts received = %v, want %v", got, want)
+	}
+	if !containsNote(res.Control.Request.Notes, "multipart/form-data body") {
+		t.Errorf("request notes = %q, want the multipart body noted", res.Control.Request.Notes)
+	}
+}
@@ -0,0 +1,143 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yansol0/aperture/testconfig"
+)
+
+// pace blocks until sending method path with credUser's credentials keeps the configured
+// endpoint and user delays, then records the send. Every request goes through it right
+// before it is sent, so the spacing holds for scan, verification, cleanup and discovery
+// requests alike. Other workers go on while it waits, so the wait is checked again after.
+func (r *Runner) pace(ctx context.Context, method, path string, credUser testconfig.User) error {
+	delays := map[string]time.Duration{}
+	if ov, ok := r.Config.OverrideFor(method, path); ok && ov.Delay > 0 {
+		delays["endpoint "+strings.ToUpper(ov.Method)+" "+ov.Path] = ov.Delay
+	}
+	if credUser.Delay > 0 {
+		delays["user "+credUser.Name] = credUser.Delay
+	}
+	if len(delays) == 0 {
+		return nil
+	}
+	for {
+		var wait time.Duration
+		now := time.Now()
+		for key, delay := range delays {
+			if next := r.lastSent[key].Add(delay); next.After(now) && next.Sub(now) > wait {
+				wait = next.Sub(now)
+			}
+		}
+		if wait <= 0 {
+			break
+		}
+		t := time.NewTimer(wait)
+		var err error
+		r.unlocked(func() {
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				t.Stop()
+				err = ctx.Err()
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if r.lastSent == nil {
+		r.lastSent = map[string]time.Time{}
+	}
+	sent := time.Now()
+	for k := range delays {
+		r.lastSent[k] = sent
+	}
+	return nil
+}
+
+// concurrencyLimits returns the caps on requests in flight that apply to sending method
+// path with credUser's credentials, keyed like pace's delays.
+func (r *Runner) concurrencyLimits(method, path string, credUser testconfig.User) map[string]int {
+	limits := map[string]int{}
+	if ov, ok := r.Config.OverrideFor(method, path); ok && ov.MaxConcurrent > 0 {
+		limits["endpoint "+strings.ToUpper(ov.Method)+" "+ov.Path] = ov.MaxConcurrent
+	}
+	if credUser.MaxConcurrent > 0 {
+		limits["user "+credUser.Name] = credUser.MaxConcurrent
+	}
+	return limits
+}
+
+// acquireLimits waits until every key in limits is below its cap of requests in flight,
+// then counts one more in flight for each. Like acquireSlot it only waits while
+// operations run concurrently; a sequential run never has two requests in flight.
+func (r *Runner) acquireLimits(limits map[string]int) {
+	if r.workers == nil || len(limits) == 0 {
+		return
+	}
+	pair := r.pair
+	for r.atLimit(limits) {
+		r.slotFree.Wait()
+	}
+	r.pair = pair
+	for key := range limits {
+		r.inFlight[key]++
+	}
+}
+
+func (r *Runner) atLimit(limits map[string]int) bool {
+	for key, limit := range limits {
+		if r.inFlight[key] >= limit {
+			return true
+		}
+	}
+	return false
+}
+
+// releaseLimits ends a request acquireLimits admitted.
+func (r *Runner) releaseLimits(limits map[string]int) {
+	if r.workers == nil || len(limits) == 0 {
+		return
+	}
+	for key := range limits {
+		r.inFlight[key]--
+	}
+	r.slotFree.Broadcast()
+}
+
+// PacingOverrides describes the configured endpoint and user delays and concurrency
+// caps, for the summary.
+func (r *Runner) PacingOverrides() []string {
+	var out []string
+	for _, o := range r.Config.EndpointOverrides {
+		if parts := pacingParts(o.Delay, o.MaxConcurrent); parts != "" {
+			method := strings.ToUpper(o.Method)
+			if method == "" {
+				method = "*"
+			}
+			out = append(out, fmt.Sprintf("%s %s: %s", method, o.Path, parts))
+		}
+	}
+	for _, u := range r.Config.Users {
+		if parts := pacingParts(u.Delay, u.MaxConcurrent); parts != "" {
+			out = append(out, fmt.Sprintf("user %s: %s", u.Name, parts))
+		}
+	}
+	return out
+}
+
+// pacingParts describes a delay and a concurrency cap, or returns "" when neither is set.
+func pacingParts(delay time.Duration, maxConcurrent int) string {
+	var parts []string
+	if delay > 0 {
+		parts = append(parts, fmt.Sprintf("one request per %s", delay))
+	}
+	if maxConcurrent > 0 {
+		parts = append(parts, fmt.Sprintf("at most %d in flight", maxConcurrent))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,86 @@
+package runner
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// usesMinimalPatch reports whether sendOne sends a minimal body for method path: a PATCH
+// with MinimalPatch set whose body would otherwise be synthesized from a JSON schema, and
+// whose minimal body was not rejected earlier in the pair (r.pair.fullPatch).
+func (r *Runner) usesMinimalPatch(method, path string, op *openapi3.Operation) bool {
+	if !r.MinimalPatch || r.pair.fullPatch || !strings.EqualFold(method, http.MethodPatch) {
+		return false
+	}
+	if ov, ok := r.Config.OverrideFor(method, path); ok && ov.BodyTemplate != "" {
+		return false
+	}
+	if r.pair.example != nil && r.pair.example.op == op {
+		return false
+	}
+	if op.RequestBody == nil || op.RequestBody.Value == nil {
+		return false
+	}
+	_, mt, ok := jsonContent(op.RequestBody.Value.Content)
+	return ok && mt.Schema != nil
+}
+
+// minimalPatchBody builds a PATCH body with only the top-level properties the object
+// user has fields for, plus those include_properties and null_properties force, so a
+// successful cross-user PATCH overwrites as little of the victim's data as possible. With no matching property it sends the first optional
+// property that is not read-only (or, failing that, the first property), synthesized.
+// The first note lists what was omitted; the rest say where a recursive schema was cut.
+func (r *Runner) minimalPatchBody(schema *openapi3.SchemaRef, fields map[string]string) (map[string]any, []string) {
+	s := r.resolveSchema(schema)
+	if s == nil {
+		return map[string]any{}, []string{"minimal PATCH body: schema has no properties"}
+	}
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	body := map[string]any{}
+	b := r.newBodyBuilder()
+	b.enter(schema, s) // a property of the body's own type is synthesized once, not forever
+	for _, name := range names {
+		if v, ok := fields[name]; ok {
+			body[name] = v
+		} else if v, ok := b.forcedProperty(name, s.Properties[name], fields, 0); ok {
+			// The server requires it, so even a minimal body carries it
+			body[name] = v
+		}
+	}
+	if len(body) == 0 && len(names) > 0 {
+		pick := names[0]
+		for _, name := range names {
+			prop := s.Properties[name]
+			if !contains(s.Required, name) && (prop == nil || prop.Value == nil || !prop.Value.ReadOnly) {
+				pick = name
+				break
+			}
+		}
+		body[pick] = b.build(s.Properties[pick], fields, 1)
+	}
+	var omitted []string
+	for _, name := range names {
+		if _, sent := body[name]; !sent {
+			omitted = append(omitted, name)
+		}
+	}
+	note := "minimal PATCH body: no properties omitted"
+	if len(omitted) > 0 {
+		note = fmt.Sprintf("minimal PATCH body: omitted %s", strings.Join(omitted, ", "))
+	}
+	return body, append([]string{note}, b.notes()...)
+}
+
+// minimalPatchRejected reports whether a control response means the server refused the
+// minimal PATCH body itself rather than the request.
+func minimalPatchRejected(status int) bool {
+	return status == http.StatusBadRequest || status == http.StatusUnprocessableEntity
+}
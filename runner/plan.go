@@ -0,0 +1,259 @@
+package runner
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yansol0/aperture/testconfig"
+)
+
+// SkipReasonNotPlanned is the skipped reason of pairs whose control or test request is
+// missing from the approved plan.
+const SkipReasonNotPlanned = "not in the approved plan"
+
+var errNotPlanned = errors.New(SkipReasonNotPlanned)
+
+// redactedCredential replaces credentials in planned request headers.
+const redactedCredential = "<redacted>"
+
+// Plan is every request a scan intends to send, built by BuildPlan without contacting
+// the target, for review before the scan. An approved plan passed to UsePlan limits the
+// scan to exactly these requests.
+type Plan struct {
+	Version   string           `json:"aperture_version"`
+	BaseURL   string           `json:"base_url"`
+	CreatedAt time.Time        `json:"created_at"`
+	Requests  []PlannedRequest `json:"requests"`
+	// AuthRefresh lists the configured auth refresh requests, sent only when a user's
+	// credential expires during the scan. Their headers and body are redacted.
+	AuthRefresh []PlannedRequest `json:"auth_refresh"`
+	Skipped     []PlannedSkip    `json:"skipped"`
+}
+
+// PlannedRequest is one request of a Plan. Headers carry "<redacted>" in place of the
+// user's credential.
+type PlannedRequest struct {
+	Endpoint   string            `json:"endpoint"`
+	Method     string            `json:"method"`
+	URL        string            `json:"url"`
+	AuthUser   string            `json:"auth_user"`
+	ObjectUser string            `json:"object_user,omitempty"`
+	Headers    map[string]string `json:"headers"`
+	Body       any               `json:"body,omitempty"`
+}
+
+// PlannedSkip is an operation or pair the scan will not test, and why.
+type PlannedSkip struct {
+	Endpoint string `json:"endpoint"`
+	Method   string `json:"method"`
+	Reason   string `json:"reason"`
+}
+
+// key identifies a request for matching against an approved plan: method, URL, the
+// user whose credentials are sent, the headers and the body. The credential is matched
+// in its redacted form, so a refreshed token still matches, and User-Agent and the
+// multipart boundary, which differ between the plan and the scan, are left out.
+func (p PlannedRequest) key() string {
+	body, _ := json.Marshal(p.Body)
+	var headers []string
+	for k, v := range p.Headers {
+		switch {
+		case strings.EqualFold(k, "User-Agent"):
+			continue
+		case strings.EqualFold(k, "Content-Type"):
+			if mt, _, err := mime.ParseMediaType(v); err == nil && strings.HasPrefix(mt, "multipart/") {
+				v = mt
+			}
+		}
+		headers = append(headers, http.CanonicalHeaderKey(k)+": "+v)
+	}
+	sort.Strings(headers)
+	return strings.Join([]string{p.Method, p.URL, p.AuthUser, strings.Join(headers, "\n"), string(body)}, "\x00")
+}
+
+// redactHeaders copies headers with the credential replaced by "<redacted>".
+func redactHeaders(headers map[string]string, credential string) map[string]string {
+	out := make(map[string]string, len(headers))
+	for k, v := range headers {
+		if credential != "" {
+			v = strings.ReplaceAll(v, credential, redactedCredential)
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// refreshKey identifies an auth refresh request of an approved plan. Its headers and
+// body are redacted in the plan, so only the method and URL are matched.
+func refreshKey(method, url string) string {
+	return "refresh\x00" + strings.ToUpper(method) + "\x00" + url
+}
+
+// BuildPlan runs the scan's discovery, eligibility and pairing without sending anything:
+// every request sendOne would send is recorded instead and answered with an empty 200,
+// so the plan follows the path of a scan whose controls all succeed. Requests that
+// depend on real responses, such as retries, conditional probes and requests using
+// values captured by cleanup steps, cannot be planned.
+func (r *Runner) BuildPlan(ctx context.Context) (*Plan, error) {
+	plan := &Plan{
+		Version:     Version(),
+		BaseURL:     r.BaseURL,
+		CreatedAt:   time.Now().UTC().Truncate(time.Second),
+		Requests:    []PlannedRequest{},
+		AuthRefresh: []PlannedRequest{},
+		Skipped:     []PlannedSkip{},
+	}
+	events, confirm, users := r.Events, r.ConfirmWrites, r.Config.Users
+	r.Events, r.ConfirmWrites = nil, false
+	// Cleanup captures must not leak into the config
+	r.Config.Users = append([]testconfig.User(nil), users...)
+	for i := range r.Config.Users {
+		fields := map[string]string{}
+		for k, v := range users[i].Fields {
+			fields[k] = v
+		}
+		r.Config.Users[i].Fields = fields
+	}
+	r.plan, r.bodyTime = plan, plan.CreatedAt
+	defer func() {
+		r.Events, r.ConfirmWrites, r.Config.Users = events, confirm, users
+		r.plan, r.bodyTime = nil, time.Time{}
+		r.CompletedRequests, r.TestedEndpoints = 0, 0
+	}()
+
+	results, err := r.Execute(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, res := range results {
+		if res.Result == ResultSkipped && res.Endpoint != "" {
+			plan.Skipped = append(plan.Skipped, PlannedSkip{Endpoint: res.Endpoint, Method: res.Method, Reason: res.SkippedReason})
+		}
+	}
+	for _, u := range users {
+		rf := u.Auth.Refresh
+		if rf == nil {
+			continue
+		}
+		// Refresh requests carry secrets of their own, so only their shape is shown
+		pr := PlannedRequest{
+			Endpoint: rf.URL,
+			Method:   strings.ToUpper(rf.Method),
+			URL:      r.refreshURL(*rf),
+			AuthUser: u.Name,
+			Headers:  map[string]string{},
+		}
+		for k := range rf.Headers {
+			pr.Headers[k] = redactedCredential
+		}
+		if rf.Body != "" {
+			pr.Body = redactedCredential
+		}
+		plan.AuthRefresh = append(plan.AuthRefresh, pr)
+	}
+	return plan, ctx.Err()
+}
+
+// recordPlanned adds a request to the plan being built, with the credential redacted.
+func (r *Runner) recordPlanned(path string, req RequestDetails, objectUser, credUser testconfig.User) {
+	pr := PlannedRequest{
+		Endpoint: path,
+		Method:   req.Method,
+		URL:      req.URL,
+		AuthUser: credUser.Name,
+		Headers:  redactHeaders(req.Headers, credUser.Auth.Value),
+		Body:     req.Body,
+	}
+	if objectUser.Name != credUser.Name {
+		pr.ObjectUser = objectUser.Name
+	}
+	r.plan.Requests = append(r.plan.Requests, pr)
+}
+
+// plannedResponse answers a request recorded while building a plan.
+func plannedResponse() ResponseDetails {
+	return ResponseDetails{Status: http.StatusOK, Headers: map[string]string{}, Notes: []string{"planned, not sent"}}
+}
+
+// UsePlan restricts the scan to the requests of an approved plan: any other request
+// fails with SkipReasonNotPlanned and its pair is skipped. Synthesized date and time
+// values are taken from the plan's creation time so request bodies match it.
+func (r *Runner) UsePlan(p *Plan) error {
+	if strings.TrimRight(p.BaseURL, "/") != strings.TrimRight(r.BaseURL, "/") {
+		return fmt.Errorf("plan targets %s, not %s", p.BaseURL, r.BaseURL)
+	}
+	r.approved = map[string]bool{}
+	for _, pr := range p.Requests {
+		r.approved[pr.key()] = true
+	}
+	for _, pr := range p.AuthRefresh {
+		r.approved[refreshKey(pr.Method, pr.URL)] = true
+	}
+	r.bodyTime = p.CreatedAt
+	return nil
+}
+
+// checkPlanned returns an error wrapping errNotPlanned when an approved plan is in use
+// and does not contain the request sent with credUser's credential.
+func (r *Runner) checkPlanned(req RequestDetails, credUser testconfig.User) error {
+	if r.approved == nil {
+		return nil
+	}
+	pr := PlannedRequest{Method: req.Method, URL: req.URL, AuthUser: req.AuthUser, Headers: redactHeaders(req.Headers, credUser.Auth.Value), Body: req.Body}
+	if !r.approved[pr.key()] {
+		return fmt.Errorf("%w: %s %s as %s", errNotPlanned, req.Method, req.URL, req.AuthUser)
+	}
+	return nil
+}
+
+// now is the time synthesized date and time values are based on.
+func (r *Runner) now() time.Time {
+	if !r.bodyTime.IsZero() {
+		return r.bodyTime
+	}
+	return time.Now()
+}
+
+// ReadPlan loads a plan written by WritePlan, gunzipping it when the name ends in .gz.
+func ReadPlan(path string) (*Plan, error) {
+	b, err := testconfig.ReadInput(path)
+	if err != nil {
+		return nil, err
+	}
+	var p Plan
+	if err := json.Unmarshal(b, &p); err != nil {
+		return nil, fmt.Errorf("parse plan %s: %w", path, err)
+	}
+	return &p, nil
+}
+
+// WritePlan writes p as indented JSON, gzip-compressed when the name ends in .gz.
+func WritePlan(path string, p *Plan) error {
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	if strings.HasSuffix(strings.ToLower(path), ".gz") {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		if _, err := gz.Write(b); err != nil {
+			return err
+		}
+		if err := gz.Close(); err != nil {
+			return err
+		}
+		b = buf.Bytes()
+	}
+	return os.WriteFile(path, b, 0o644)
+}
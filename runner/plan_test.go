@@ -0,0 +1,151 @@
+package runner
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPlanMatchesHeaders(t *testing.T) {
+	api := newTestAPI(t)
+	r := newTestRunner(api, loadTestSpec(t, "notes_api.json"))
+	r.OnlyOperations = []string{"getNote"}
+	plan, err := r.BuildPlan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent := len(api.Requests()); sent != 0 {
+		t.Fatalf("building the plan sent %d requests", sent)
+	}
+	if len(plan.Requests) != 4 {
+		t.Fatalf("plan has %d requests, want 4", len(plan.Requests))
+	}
+	for _, pr := range plan.Requests {
+		if key := pr.Headers["X-API-Key"]; key != redactedCredential {
+			t.Errorf("planned X-API-Key = %q, want it redacted", key)
+		}
+	}
+	// Approving the plan with bob's test of alice's note sent with another Accept header
+	// leaves that pair unplanned
+	for i, pr := range plan.Requests {
+		if pr.AuthUser == "bob" && pr.ObjectUser == "alice" {
+			plan.Requests[i].Headers["Accept"] = "text/html"
+		}
+	}
+	r.UserAgent = "a different agent"
+	if err := r.UsePlan(plan); err != nil {
+		t.Fatal(err)
+	}
+
+	results := execute(t, r)
+
+	want := map[string]map[string]int{"GET /notes/{note_id}": {ResultIDORFound: 1, ResultSkipped: 1}}
+	if got := verdicts(results); !reflect.DeepEqual(got, want) {
+		t.Errorf("verdicts = %v, want %v", got, want)
+	}
+	for _, res := range results {
+		if res.Result == ResultSkipped && res.SkippedReason != SkipReasonNotPlanned {
+			t.Errorf("skipped reason = %q, want %q", res.SkippedReason, SkipReasonNotPlanned)
+		}
+	}
+}
+
+const writePlanSpec = `
+openapi: 3.0.3
+info: {title: notes, version: "1"}
+security: [{ApiKeyAuth: []}]
+paths:
+  /notes:
+    post:
+      operationId: createNote
+      parameters:
+        - {name: owner_id, in: query, required: true, schema: {type: integer, format: int64}}
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              required: [parent_id, title, tags]
+              properties:
+                parent_id: {type: integer, format: int64, example: 9007199254740993}
+                title: {type: string}
+                tags: {type: array, items: {type: string}}
+      responses:
+        "201": {description: Created}
+  /notes/{note_id}:
+    patch:
+      operationId: updateNote
+      parameters:
+        - {name: note_id, in: path, required: true, schema: {type: integer, format: int64}}
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              required: [version, rating, title]
+              properties:
+                version: {type: integer, example: 12345678901234567890}
+                rating: {type: number, example: 0.1}
+                title: {type: string}
+      responses:
+        "200": {description: OK}
+components:
+  securitySchemes:
+    ApiKeyAuth: {type: apiKey, in: header, name: X-API-Key}
+`
+
+// TestPlanRoundTripWithBodies checks that a plan written to disk and read back still
+// approves its POST and PATCH requests, bodies holding an ID beyond float64 precision
+// included.
+func TestPlanRoundTripWithBodies(t *testing.T) {
+	api := newTestAPI(t)
+	api.Mux.HandleFunc("POST /notes", func(w http.ResponseWriter, req *http.Request) {
+		if _, ok := api.user(w, req); ok {
+			writeTestJSON(w, http.StatusCreated, map[string]string{"status": "created"})
+		}
+	})
+	api.Mux.HandleFunc("PATCH /notes/{note_id}", func(w http.ResponseWriter, req *http.Request) {
+		if _, ok := api.user(w, req); ok {
+			writeTestJSON(w, http.StatusOK, map[string]string{"status": "updated"})
+		}
+	})
+	r := newTestRunner(api, parseTestSpec(t, writePlanSpec))
+	r.AllowMutations = true
+	r.Config.Users[0].Fields = map[string]string{"note_id": "9007199254740993", "owner_id": "1", "title": "a: {{b}} # c"}
+	r.Config.Users[1].Fields = map[string]string{"note_id": "2", "owner_id": "2"}
+	plan, err := r.BuildPlan(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Requests) != 8 {
+		t.Fatalf("plan has %d requests, want 8; skipped %v", len(plan.Requests), plan.Skipped)
+	}
+	path := filepath.Join(t.TempDir(), "plan.json")
+	if err := WritePlan(path, plan); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadPlan(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, pr := range read.Requests {
+		if got, want := pr.key(), plan.Requests[i].key(); got != want {
+			t.Errorf("request %d key after the round trip:\n%q\nwant\n%q", i, got, want)
+		}
+	}
+	if err := r.UsePlan(read); err != nil {
+		t.Fatal(err)
+	}
+
+	results := execute(t, r)
+	for _, res := range results {
+		if res.Result == ResultSkipped {
+			t.Errorf("%s %s skipped: %s %q", res.Method, res.Endpoint, res.SkippedReason, res.Notes)
+		}
+	}
+	if sent := len(api.Requests()); sent != 8 {
+		t.Errorf("sent %d requests, want 8", sent)
+	}
+}
@@ -0,0 +1,146 @@
+package runner
+
+import (
+	"fmt"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/yansol0/aperture/testconfig"
+)
+
+// operationPlan is one selected operation of the scan and the test cases planned for it.
+// skip is set, and cases empty, when the operation is not tested at all.
+type operationPlan struct {
+	method, path string
+	op           *openapi3.Operation
+	item         *openapi3.PathItem
+	skip         string
+	cases        []testCase
+}
+
+// testCase is one control/test pair of an operation, with the enum variant and body
+// example it is sent with. skip is set for pairs the plan already knows cannot run; such
+// cases send nothing. index is the position of the case's result in Execute's results,
+// set when the case is kept for reclassification.
+type testCase struct {
+	method, path string
+	op           *openapi3.Operation
+	item         *openapi3.PathItem
+	objectUser   testconfig.User
+	credUser     testconfig.User // empty for a skip that concerns only the object user
+	required     map[string]paramSpec
+	variant      map[string]string
+	example      *bodyExample
+	notes        []string
+	skip         string
+	index        int
+}
+
+// planOperations decides what the scan does without sending anything: which operations
+// are selected and tested, which user pairs each one runs, and with which enum variants
+// and body examples. Operations are planned in path and method order.
+// EstimateTotalRequests counts this plan. Execute starts from it but plans each operation
+// again when it runs, since cleanup steps may have captured field values since, and
+// corrects its total when the pairs changed.
+func (r *Runner) planOperations() []operationPlan {
+	var plans []operationPlan
+	paths := r.Spec.Paths.Map()
+	for _, path := range sortedKeys(paths) {
+		item := paths[path]
+		ops := operationsFor(item)
+		for _, method := range sortedKeys(ops) {
+			op := ops[method]
+			if !r.operationSelected(op) {
+				continue
+			}
+			plans = append(plans, r.planOperation(path, method, op, item))
+		}
+	}
+	return plans
+}
+
+// planOperation plans the pairs of one selected operation.
+func (r *Runner) planOperation(path, method string, op *openapi3.Operation, item *openapi3.PathItem) operationPlan {
+	plan := operationPlan{method: method, path: path, op: op, item: item}
+	if plan.skip = r.operationSkipReason(path, method, op, item); plan.skip != "" {
+		return plan
+	}
+	resultNotes := []string{}
+	if !operationRequiresAuth(r.Spec, op) {
+		resultNotes = append(resultNotes, "spec declares no security requirement for this operation")
+	}
+	if name := objectParam(op, item); name != "" {
+		resultNotes = append(resultNotes, fmt.Sprintf("%s: only %s identifies the object owner", extObjectParam, name))
+	}
+
+	required := r.requiredParams(path, op, item)
+	for _, pair := range userPairsForEligibleObjectUsers(r.eligibleUsers(required), r.Config.Users) {
+		base := testCase{method: method, path: path, op: op, item: item, objectUser: pair[0], required: required, notes: resultNotes}
+		// Skip pairs for which the operation does not reference any object identifier from the user's fields
+		if !operationReferencesUserFields(path, op, item, pair[0]) {
+			base.skip = "no object identifiers referenced by this operation"
+			plan.cases = append(plan.cases, base)
+			continue
+		}
+		base.credUser = pair[1]
+		pathVariants := pathEnumVariants(required, pair[0])
+		reason, schemeNote := r.pairSecurity(op, pair[0], pair[1])
+		if reason == "" {
+			reason = objectUserSkipReason(path, op, item, withFields(pair[0], pathVariants[0]))
+		}
+		if reason != "" {
+			base.skip = reason
+			plan.cases = append(plan.cases, base)
+			continue
+		}
+		if schemeNote != "" {
+			base.notes = append(append([]string(nil), resultNotes...), schemeNote)
+		}
+		for _, pathVariant := range pathVariants {
+			for _, variant := range r.enumVariants(op, item, pair[0]) {
+				for k, v := range pathVariant {
+					variant = withValue(variant, k, v)
+				}
+				for _, example := range r.bodyExamples(op) {
+					tc := base
+					tc.variant, tc.example = variant, example
+					plan.cases = append(plan.cases, tc)
+				}
+			}
+		}
+	}
+	return plan
+}
+
+// runnable reports whether the operation has a case that sends requests.
+func (p operationPlan) runnable() bool {
+	for _, tc := range p.cases {
+		if tc.skip == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// estimateRequests counts the requests plans will send: each runnable case's pair
+// requests, plus the warm-up once per runnable endpoint. Requests that depend on
+// responses are not counted; sendOne's callers add them to TotalRequests as they are
+// sent: auth refresh retries, the full PATCH after a rejected minimal one, the owner's GET
+// a HEAD test is compared with, conditional probes and reclassification retries.
+func (r *Runner) estimateRequests(plans []operationPlan) int {
+	total := 0
+	for _, p := range plans {
+		if !p.runnable() {
+			continue
+		}
+		if !isWriteMethod(p.method) {
+			total += r.WarmupRequests
+		}
+		perPair := r.requestsPerPair(p.method, p.path, p.item)
+		for _, tc := range p.cases {
+			if tc.skip == "" {
+				total += perPair
+			}
+		}
+	}
+	return total
+}
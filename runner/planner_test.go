@@ -0,0 +1,170 @@
+package runner
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/yansol0/aperture/testconfig"
+)
+
+func TestEstimateMatchesRequestsSent(t *testing.T) {
+	api := newTestAPI(t)
+	r := newTestRunner(api, loadTestSpec(t, "notes_api.json"))
+	// carol has no note, so she is never an object owner of a note but still attacks them
+	r.Config.Users = append(r.Config.Users, testconfig.User{
+		Name:   "carol",
+		Auth:   testconfig.Auth{Type: "header", Value: "KEY_CAROL"},
+		Fields: map[string]string{"user_id": "carol"},
+	})
+	r.WarmupRequests = 1
+
+	estimate := r.EstimateTotalRequests()
+	results := execute(t, r)
+
+	// 3 endpoints: the note endpoints have 2 owners x 2 attackers, the user endpoint
+	// 3 owners x 2 attackers; every pair sends 2 requests and every endpoint 1 warm-up
+	if want := (4+4+6)*2 + 3; estimate != want {
+		t.Errorf("EstimateTotalRequests = %d, want %d", estimate, want)
+	}
+	if sent := len(api.Requests()); sent != estimate || r.TotalRequests != estimate {
+		t.Errorf("sent %d requests and TotalRequests = %d, estimate %d", sent, r.TotalRequests, estimate)
+	}
+	want := map[string]map[string]int{
+		"GET /notes/{note_id}":         {ResultIDORFound: 2, ResultSecure: 2},
+		"GET /notes/{note_id}/preview": {ResultIDORFound: 2, ResultSecure: 2},
+		"GET /users/{user_id}/notes":   {ResultSecure: 4, ResultControlFailed: 2},
+	}
+	if got := verdicts(results); !reflect.DeepEqual(got, want) {
+		t.Errorf("verdicts = %v, want %v", got, want)
+	}
+}
+
+func TestEstimateSkipsUntestedOperations(t *testing.T) {
+	api := newTestAPI(t)
+	spec := loadTestSpec(t, "notes_api.json")
+	r := newTestRunner(api, spec)
+	r.OnlyOperations = []string{"getNote", "listUserNotes"}
+	r.Config.Users[1].Fields = map[string]string{"note_id": "2"} // bob cannot own a user's notes
+
+	estimate := r.EstimateTotalRequests()
+	results := execute(t, r)
+
+	if want := 2*2 + 1*2; estimate != want || r.TotalRequests != want {
+		t.Errorf("estimate %d, TotalRequests %d, want %d", estimate, r.TotalRequests, want)
+	}
+	if sent := len(api.Requests()); sent != estimate {
+		t.Errorf("sent %d requests, estimate %d", sent, estimate)
+	}
+	for _, res := range results {
+		if res.Endpoint == "/notes/{note_id}/preview" {
+			t.Errorf("deselected operation has a result: %+v", res)
+		}
+	}
+}
+
+const headNotesSpec = `
+openapi: 3.0.3
+info: {title: notes, version: "1"}
+security: [{ApiKeyAuth: []}]
+paths:
+  /users/{user_id}/notes:
+    get:
+      parameters:
+        - {name: user_id, in: path, required: true, schema: {type: string}}
+      responses:
+        "200": {description: OK}
+  /notes/{note_id}:
+    parameters:
+      - {name: note_id, in: path, required: true, schema: {type: integer}}
+    head:
+      responses:
+        "200": {description: OK}
+    get:
+      responses:
+        "200": {description: OK}
+components:
+  securitySchemes:
+    ApiKeyAuth: {type: apiKey, in: header, name: X-API-Key}
+`
+
+// TestExecuteOrderAndExtraRequests checks that operations run in path and method order,
+// and that requests the estimate leaves out, here the owner's GET each 2xx HEAD test is
+// compared with, are added to TotalRequests as they are sent.
+func TestExecuteOrderAndExtraRequests(t *testing.T) {
+	api := newTestAPI(t)
+	r := newTestRunner(api, parseTestSpec(t, headNotesSpec))
+
+	estimate := r.EstimateTotalRequests()
+	results := execute(t, r)
+
+	var order []string
+	for _, res := range results {
+		if key := res.Method + " " + res.Endpoint; len(order) == 0 || order[len(order)-1] != key {
+			order = append(order, key)
+		}
+	}
+	if want := []string{"GET /notes/{note_id}", "HEAD /notes/{note_id}", "GET /users/{user_id}/notes"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("operations ran in order %q, want %q", order, want)
+	}
+	// 3 operations x 2 pairs x 2 requests, and the owner's GET for both HEAD tests
+	if want := 3 * 2 * 2; estimate != want {
+		t.Errorf("EstimateTotalRequests = %d, want %d", estimate, want)
+	}
+	if sent := len(api.Requests()); sent != estimate+2 || r.TotalRequests != sent {
+		t.Errorf("sent %d requests and TotalRequests = %d, want %d", sent, r.TotalRequests, estimate+2)
+	}
+}
+
+// stubDetector reports every pair as POTENTIAL and remembers what it was given.
+type stubDetector struct {
+	pairs []Pair
+}
+
+func (d *stubDetector) Detect(p Pair, res *ResultLog) {
+	d.pairs = append(d.pairs, p)
+	res.Result = ResultPotential
+	res.Notes = append(res.Notes, "stub")
+}
+
+func TestCustomDetector(t *testing.T) {
+	api := newTestAPI(t)
+	r := newTestRunner(api, loadTestSpec(t, "notes_api.json"))
+	r.OnlyOperations = []string{"listUserNotes"}
+	d := &stubDetector{}
+	r.Detector = d
+
+	results := execute(t, r)
+
+	if got := verdicts(results); !reflect.DeepEqual(got, map[string]map[string]int{"GET /users/{user_id}/notes": {ResultPotential: 2}}) {
+		t.Fatalf("verdicts = %v", got)
+	}
+	if len(d.pairs) != 2 {
+		t.Fatalf("detector saw %d pairs, want 2", len(d.pairs))
+	}
+	p := d.pairs[0]
+	if p.ObjectUser.Name != "alice" || p.CredUser.Name != "bob" || p.Control.Status != 200 || p.Test.Status != 403 {
+		t.Errorf("first pair = %s/%s control %d test %d, want alice/bob 200/403", p.ObjectUser.Name, p.CredUser.Name, p.Control.Status, p.Test.Status)
+	}
+	if p.Method != "GET" || p.Path != "/users/{user_id}/notes" || p.Op == nil || p.Item == nil {
+		t.Errorf("pair operation = %s %s op=%v item=%v", p.Method, p.Path, p.Op != nil, p.Item != nil)
+	}
+}
+
+func TestCustomDetectorSkipsFailedControls(t *testing.T) {
+	api := newTestAPI(t)
+	r := newTestRunner(api, loadTestSpec(t, "notes_api.json"))
+	r.OnlyOperations = []string{"getNote"}
+	r.Config.Users[0].Auth.Value = "KEY_UNKNOWN" // alice's own control gets 401
+	d := &stubDetector{}
+	r.Detector = d
+
+	results := execute(t, r)
+
+	want := map[string]map[string]int{"GET /notes/{note_id}": {ResultControlFailed: 1, ResultPotential: 1}}
+	if got := verdicts(results); !reflect.DeepEqual(got, want) {
+		t.Errorf("verdicts = %v, want %v", got, want)
+	}
+	if len(d.pairs) != 1 || d.pairs[0].ObjectUser.Name != "bob" {
+		t.Errorf("detector saw %d pairs, want only bob's object", len(d.pairs))
+	}
+}
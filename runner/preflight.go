@@ -0,0 +1,152 @@
+package runner
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/yansol0/aperture/testconfig"
+)
+
+// preflightCandidates is how many GET operations are tried per user to find one that
+// can be sent with the user's own fields.
+const preflightCandidates = 5
+
+// PreflightReport is the outcome of Preflight.
+type PreflightReport struct {
+	// Status and DurationMs are from the unauthenticated HEAD request to the base URL.
+	Status     int             `json:"status"`
+	DurationMs int64           `json:"duration_ms"`
+	Users      []PreflightUser `json:"users"`
+}
+
+// PreflightUser is one user's credential check. Endpoint is empty when no GET operation
+// could be sent with the user's fields, so the credential was not checked.
+type PreflightUser struct {
+	User     string `json:"user"`
+	Endpoint string `json:"endpoint,omitempty"`
+	Status   int    `json:"status,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Warnings returns a line for each user whose credential was rejected with 401.
+func (p PreflightReport) Warnings() []string {
+	var out []string
+	for _, u := range p.Users {
+		if u.Status == http.StatusUnauthorized {
+			out = append(out, fmt.Sprintf("credentials of %s were rejected: GET %s returned 401", u.User, u.Endpoint))
+		}
+	}
+	return out
+}
+
+// Preflight checks the target before a scan: a HEAD request to the base URL must get any
+// HTTP response, otherwise the returned error explains why the connection failed. Each
+// user then sends one authenticated GET with their own fields so rejected credentials
+// show up before the run. These requests are not counted in the scan's progress.
+func (r *Runner) Preflight(ctx context.Context) (PreflightReport, error) {
+	var report PreflightReport
+	client := r.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: r.HTTPTimeout}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, r.BaseURL, nil)
+	if err != nil {
+		return report, fmt.Errorf("invalid base URL %q: %w", r.BaseURL, err)
+	}
+	ua := r.UserAgent
+	if ua == "" {
+		ua = DefaultUserAgent()
+	}
+	req.Header.Set("User-Agent", ua)
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return report, fmt.Errorf("%s is unreachable: %s", r.BaseURL, diagnoseConnError(err))
+	}
+	resp.Body.Close()
+	r.recordTraffic(http.MethodHead+" /", "", req, 0, resp, 0)
+	report.Status = resp.StatusCode
+	report.DurationMs = time.Since(start).Milliseconds()
+
+	events := r.Events
+	r.Events = nil
+	defer func() {
+		r.Events = events
+		r.CompletedRequests = 0
+	}()
+	for _, u := range r.Config.Users {
+		report.Users = append(report.Users, r.checkCredentials(ctx, client, u))
+	}
+	return report, nil
+}
+
+// checkCredentials sends the first authenticated GET operation, by path, that u's
+// credential satisfies and u's fields can fill.
+func (r *Runner) checkCredentials(ctx context.Context, client *http.Client, u testconfig.User) PreflightUser {
+	check := PreflightUser{User: u.Name}
+	paths := r.Spec.Paths.Map()
+	names := make([]string, 0, len(paths))
+	for p := range paths {
+		names = append(names, p)
+	}
+	sort.Strings(names)
+	tried := 0
+	for _, path := range names {
+		item := paths[path]
+		op := item.Get
+		if op == nil || !r.operationSelected(op) || !operationRequiresAuth(r.Spec, op) || r.infrastructurePath(path) {
+			continue
+		}
+		if reason, _ := r.pairSecurity(op, u, u); reason != "" {
+			continue
+		}
+		if tried == preflightCandidates {
+			break
+		}
+		tried++
+		_, resp, err := r.sendOne(ctx, client, "GET", path, op, item, u, u, r.requiredParams(path, op, item))
+		if err != nil {
+			var missing *missingPathParamsError
+			if errors.As(err, &missing) || strings.HasPrefix(err.Error(), "missing required") {
+				continue
+			}
+			check.Endpoint, check.Error = path, err.Error()
+			return check
+		}
+		check.Endpoint, check.Status = path, resp.Status
+		return check
+	}
+	return check
+}
+
+// diagnoseConnError names the likely cause of a failed connection.
+func diagnoseConnError(err error) string {
+	var dnsErr *net.DNSError
+	var certErr *tls.CertificateVerificationError
+	var unknownAuth x509.UnknownAuthorityError
+	var hostErr x509.HostnameError
+	var recordErr tls.RecordHeaderError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &dnsErr):
+		return fmt.Sprintf("DNS lookup of %s failed; check the host name in --base-url or your DNS/VPN (%v)", dnsErr.Name, err)
+	case errors.As(err, &certErr), errors.As(err, &unknownAuth), errors.As(err, &hostErr):
+		return fmt.Sprintf("TLS certificate verification failed (%v)", err)
+	case errors.As(err, &recordErr):
+		return fmt.Sprintf("TLS handshake failed; the port may not speak HTTPS, try http:// (%v)", err)
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return fmt.Sprintf("connection refused; check the port and that the service is running (%v)", err)
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return fmt.Sprintf("timed out; the host may be down, firewalled or only reachable over a VPN (%v)", err)
+	}
+	return err.Error()
+}
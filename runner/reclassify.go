@@ -0,0 +1,70 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// reclassify runs every POTENTIAL pair once more. A pair that now comes out SECURE or
+// IDOR FOUND was only ambiguous once and takes the new result. Any other outcome, such as
+// POTENTIAL again or a failed control, says nothing more about authorization, so the pair
+// keeps its first result. Either way the result notes the retry. Replaced results are
+// emitted again with Event.Replaces so live counts stay in step.
+func (r *Runner) reclassify(ctx context.Context, client *http.Client, results []ResultLog) {
+	runs := r.potentialRuns
+	r.potentialRuns = nil
+	if len(runs) == 0 || ctx.Err() != nil {
+		return
+	}
+	for _, run := range runs {
+		r.TotalRequests += r.requestsPerPair(run.method, run.path, run.item)
+	}
+	r.emitEvent(Event{Kind: EventTotalRequests, Total: r.TotalRequests})
+	r.logf("[*] Retrying %d POTENTIAL pairs to reclassify them", len(runs))
+	for _, run := range runs {
+		if ctx.Err() != nil {
+			return
+		}
+		old := &results[run.index]
+		r.logf("[*] %s %s creds=%s object=%s (retry)", run.method, run.path, run.credUser.Name, run.objectUser.Name)
+		tested := r.TestedEndpoints
+		res := r.runPair(ctx, client, run)
+		r.TestedEndpoints = tested // counted on the first run
+		switch res.Result {
+		case ResultSecure, ResultIDORFound:
+		case ResultPotential:
+			old.Notes = append(old.Notes, "still POTENTIAL after a retry")
+			continue
+		default:
+			old.Notes = append(old.Notes, fmt.Sprintf("retry came out %s; kept POTENTIAL", retryOutcome(res)))
+			continue
+		}
+		res.OperationID = old.OperationID
+		res.Tags = old.Tags
+		res.Summary = old.Summary
+		res.Deprecated = old.Deprecated
+		res.Resource = old.Resource
+		res.Notes = append(res.Notes, fmt.Sprintf("reclassified from %s after a retry", ResultPotential))
+		*old = res
+		if r.Events != nil {
+			select {
+			case r.Events <- Event{Kind: EventResult, Endpoint: res.Endpoint, Method: res.Method, Result: old, Replaces: ResultPotential}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// retryOutcome describes a retry's verdict with the reason it was reached, when known.
+func retryOutcome(res ResultLog) string {
+	if reason := strings.TrimSpace(res.SkippedReason); reason != "" {
+		return fmt.Sprintf("%s (%s)", res.Result, reason)
+	}
+	if status := res.Control.Response.Status; res.Result == ResultControlFailed && status != 0 {
+		return fmt.Sprintf("%s (control status %d)", res.Result, status)
+	}
+	return res.Result
+}
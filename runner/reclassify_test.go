@@ -0,0 +1,98 @@
+package runner
+
+import (
+	"net/http"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+// flakyNoteRunner tests GET /notes/{note_id}/flaky for alice's note only, with every pair
+// reported POTENTIAL by a stub detector. alice's reads after the first answer 503.
+func flakyNoteRunner(t *testing.T) *Runner {
+	t.Helper()
+	api := newTestAPI(t)
+	var reads atomic.Int32
+	api.Mux.HandleFunc("GET /notes/{note_id}/flaky", func(w http.ResponseWriter, req *http.Request) {
+		user, ok := api.user(w, req)
+		if !ok {
+			return
+		}
+		if user == "alice" && reads.Add(1) > 1 {
+			writeTestJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "unavailable"})
+			return
+		}
+		writeTestJSON(w, http.StatusOK, testNotes[1])
+	})
+	r := newTestRunner(api, parseTestSpec(t, `
+openapi: 3.0.3
+info: {title: flaky, version: "1"}
+security: [{ApiKeyAuth: []}]
+paths:
+`+noteOperation("flaky")+`
+components:
+  securitySchemes:
+    ApiKeyAuth: {type: apiKey, in: header, name: X-API-Key}
+`))
+	r.Config.Users[1].Fields = map[string]string{"user_id": "bob"}
+	r.Detector = &stubDetector{}
+	r.Reclassify = true
+	return r
+}
+
+func TestReclassifyKeepsPotentialWhenRetryInconclusive(t *testing.T) {
+	results := execute(t, flakyNoteRunner(t))
+	res := resultFor(results, "/notes/{note_id}/flaky")
+
+	want := map[string]map[string]int{"GET /notes/{note_id}/flaky": {ResultPotential: 1}}
+	if got := verdicts(results); !reflect.DeepEqual(got, want) {
+		t.Fatalf("verdicts = %v, want %v", got, want)
+	}
+	if !containsNote(res.Notes, "retry came out CONTROL_FAILED (control status 503); kept POTENTIAL") {
+		t.Errorf("notes = %q, want the retry's outcome", res.Notes)
+	}
+}
+
+// secondRunSecure reports the first pair it sees POTENTIAL and every later one SECURE.
+type secondRunSecure struct{ seen int }
+
+func (d *secondRunSecure) Detect(p Pair, res *ResultLog) {
+	d.seen++
+	res.Result = ResultSecure
+	if d.seen == 1 {
+		res.Result = ResultPotential
+	}
+}
+
+func TestReclassifyReplacesConclusiveRetry(t *testing.T) {
+	api := newTestAPI(t)
+	r := newTestRunner(api, loadTestSpec(t, "notes_api.json"))
+	r.OnlyOperations = []string{"getNote"}
+	r.Config.Users[1].Fields = map[string]string{"user_id": "bob"}
+	r.Detector = &secondRunSecure{}
+	r.Reclassify = true
+
+	results := execute(t, r)
+	res := resultFor(results, "/notes/{note_id}")
+
+	want := map[string]map[string]int{"GET /notes/{note_id}": {ResultSecure: 1}}
+	if got := verdicts(results); !reflect.DeepEqual(got, want) {
+		t.Fatalf("verdicts = %v, want %v", got, want)
+	}
+	if !containsNote(res.Notes, "reclassified from POTENTIAL after a retry") {
+		t.Errorf("notes = %q, want the reclassification noted", res.Notes)
+	}
+	if res.OperationID != "getNote" {
+		t.Errorf("OperationID = %q, want it kept from the first result", res.OperationID)
+	}
+}
+
+// resultFor returns the first result for path.
+func resultFor(results []ResultLog, path string) ResultLog {
+	for _, res := range results {
+		if res.Endpoint == path {
+			return res
+		}
+	}
+	return ResultLog{}
+}
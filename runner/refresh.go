@@ -0,0 +1,124 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/yansol0/aperture/testconfig"
+)
+
+// refreshAfterUnauthorized records a 401 on one of the user's requests. Once
+// RefreshAfter consecutive 401s are reached and the user has auth.refresh configured, it
+// fetches a new credential and stores it in the config. It reports whether the credential
+// was replaced, in which case the caller should retry the request.
+func (r *Runner) refreshAfterUnauthorized(ctx context.Context, client *http.Client, name string) bool {
+	idx := r.userIndex(name)
+	if idx < 0 || r.Config.Users[idx].Auth.Refresh == nil {
+		return false
+	}
+	if r.unauthorized == nil {
+		r.unauthorized = map[string]int{}
+	}
+	r.unauthorized[name]++
+	if r.unauthorized[name] < max(1, r.RefreshAfter) {
+		return false
+	}
+	r.unauthorized[name] = 0
+
+	value, err := r.fetchAuth(ctx, client, name, *r.Config.Users[idx].Auth.Refresh)
+	if err != nil {
+		r.logf("[x] Auth refresh for %s failed: %v", name, err)
+		return false
+	}
+	r.Config.Users[idx].Auth.Value = value
+	if r.AuthRefreshes == nil {
+		r.AuthRefreshes = map[string]int{}
+	}
+	r.AuthRefreshes[name]++
+	r.logf("[*] Refreshed auth for %s (%d so far)", name, r.AuthRefreshes[name])
+	return true
+}
+
+// resetUnauthorized clears the user's run of 401 responses after an authorized request.
+func (r *Runner) resetUnauthorized(name string) {
+	if r.unauthorized != nil {
+		r.unauthorized[name] = 0
+	}
+}
+
+// withLatestAuth returns u with the credential currently stored for it in the config,
+// which may have been refreshed since u was copied.
+func (r *Runner) withLatestAuth(u testconfig.User) testconfig.User {
+	if idx := r.userIndex(u.Name); idx >= 0 {
+		u.Auth = r.Config.Users[idx].Auth
+	}
+	return u
+}
+
+func (r *Runner) userIndex(name string) int {
+	for i, u := range r.Config.Users {
+		if u.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// fetchAuth sends user's refresh request and returns Prefix followed by the token it extracts
+// from the JSON response.
+func (r *Runner) fetchAuth(ctx context.Context, client *http.Client, user string, rf testconfig.AuthRefresh) (string, error) {
+	target := r.refreshURL(rf)
+	if r.approved != nil && !r.approved[refreshKey(rf.Method, target)] {
+		return "", fmt.Errorf("%w: %s %s", errNotPlanned, strings.ToUpper(rf.Method), target)
+	}
+	var body io.Reader
+	if rf.Body != "" {
+		body = strings.NewReader(rf.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, strings.ToUpper(rf.Method), target, body)
+	if err != nil {
+		return "", err
+	}
+	if err := r.CheckHost(req.URL); err != nil {
+		return "", err
+	}
+	if rf.Body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("User-Agent", r.UserAgent)
+	if r.UserAgent == "" {
+		req.Header.Set("User-Agent", DefaultUserAgent())
+	}
+	for k, v := range rf.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	r.recordTraffic(req.Method+" "+rf.URL, user, req, len(rf.Body), resp, len(b))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("status %d", resp.StatusCode)
+	}
+	token, err := testconfig.ExtractJSONPointer(b, testconfig.DotPathToPointer(rf.Token))
+	if err != nil {
+		return "", fmt.Errorf("token %s: %w", rf.Token, err)
+	}
+	return rf.Prefix + token, nil
+}
+
+// refreshURL resolves a refresh request's URL against the base URL unless it is absolute.
+func (r *Runner) refreshURL(rf testconfig.AuthRefresh) string {
+	if strings.HasPrefix(rf.URL, "http://") || strings.HasPrefix(rf.URL, "https://") {
+		return rf.URL
+	}
+	return strings.TrimRight(r.BaseURL, "/") + "/" + strings.TrimLeft(rf.URL, "/")
+}
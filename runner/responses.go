@@ -0,0 +1,60 @@
+package runner
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// documentedStatuses returns the response codes op declares, sorted: exact codes, ranges
+// such as "4XX", and "default".
+func documentedStatuses(op *openapi3.Operation) []string {
+	if op == nil || op.Responses == nil {
+		return nil
+	}
+	var out []string
+	for code := range op.Responses.Map() {
+		out = append(out, code)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// statusDocumented reports whether op declares status, exactly, through its range, or
+// through a default response. Operations that declare no responses document everything.
+func statusDocumented(op *openapi3.Operation, status int) bool {
+	codes := documentedStatuses(op)
+	if len(codes) == 0 {
+		return true
+	}
+	exact := strconv.Itoa(status)
+	for _, c := range codes {
+		if c == exact || c == "default" || strings.EqualFold(c, exact[:1]+"XX") {
+			return true
+		}
+	}
+	return false
+}
+
+// noteUndocumentedStatuses records op's declared responses on res and notes control and
+// test statuses the spec does not declare. With StrictSpec an IDOR FOUND whose control
+// hit an undocumented status becomes POTENTIAL, since the endpoint does not behave as
+// modeled.
+func (r *Runner) noteUndocumentedStatuses(res *ResultLog, op *openapi3.Operation, ctrl, test ResponseDetails) {
+	res.DocumentedStatuses = documentedStatuses(op)
+	declared := strings.Join(res.DocumentedStatuses, ", ")
+	ctrlUndocumented := !statusDocumented(op, ctrl.Status)
+	if ctrlUndocumented {
+		res.Notes = append(res.Notes, fmt.Sprintf("control status %d is not documented (spec declares %s)", ctrl.Status, declared))
+	}
+	if test.Status != 0 && test.Status != ctrl.Status && !statusDocumented(op, test.Status) {
+		res.Notes = append(res.Notes, fmt.Sprintf("test status %d is not documented (spec declares %s)", test.Status, declared))
+	}
+	if r.StrictSpec && ctrlUndocumented && res.Result == ResultIDORFound {
+		res.Result = ResultPotential
+		res.Notes = append(res.Notes, "strict spec: IDOR FOUND downgraded to POTENTIAL because the control status is undocumented")
+	}
+}
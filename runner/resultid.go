@@ -0,0 +1,29 @@
+package runner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ID returns a stable identifier for the result, derived from the operation, the user
+// pair and the enum values and body example it was produced with, so the same test gets
+// the same ID on every run.
+func (rl ResultLog) ID() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\x00%s\x00%s", strings.ToUpper(rl.Method), rl.Endpoint, rl.Test.Request.AuthUser, rl.Control.Request.AuthUser)
+	keys := make([]string, 0, len(rl.EnumValues))
+	for k := range rl.EnumValues {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(h, "\x00%s=%s", k, rl.EnumValues[k])
+	}
+	if rl.BodyExample != "" {
+		fmt.Fprintf(h, "\x00example=%s", rl.BodyExample)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
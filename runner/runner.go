@@ -2,13 +2,25 @@ package runner
 
 import (
 	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"net/http/httputil"
 	"net/url"
+	pathpkg "path"
+	"regexp"
+	"runtime/debug"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/getkin/kin-openapi/openapi3"
@@ -23,13 +35,152 @@ type Runner struct {
 	Config      testconfig.Config
 	Verbose     bool
 	HTTPTimeout time.Duration
+	// HTTPClient sends every request when set; otherwise a client with HTTPTimeout is used.
+	HTTPClient *http.Client
+	// QuietStdout suppresses verbose stdout output, e.g. while a TUI owns the terminal.
+	QuietStdout bool
 
 	SkipDelete bool
+	// AllowMutations tests POST, PUT, PATCH and DELETE operations; without it they are
+	// skipped with SkipReasonMutations so a careless run cannot modify data.
+	AllowMutations bool
+	// IncludeNoAuth tests operations that declare no security requirement instead of skipping them.
+	IncludeNoAuth bool
+	// RequireSuccessResponse skips operations whose spec declares no 2xx response.
+	RequireSuccessResponse bool
+	// BodyMatchPct, when between 0 and 100, lets a test body that shares at least this
+	// percentage of tokens with the control body count as equal in the IDOR check, for
+	// responses with minor per-user variation. 0 or 100 keeps exact comparison.
+	BodyMatchPct float64
+	// NoDefaultSkips tests well-known infrastructure paths such as /healthz; the config's
+	// skip_paths still apply.
+	NoDefaultSkips bool
+	// SkipIdenticalErrors records pairs whose control and test fail the same way (same
+	// transport error, or same non-2xx status and body) as SKIPPED with
+	// SkipReasonIdenticalErrors instead of CONTROL_FAILED or POTENTIAL. After a control
+	// transport error the test request is still sent to compare.
+	SkipIdenticalErrors bool
+	// BodyOptions tunes how request bodies are synthesized from schemas.
+	BodyOptions BodyOptions
+	// Detector classifies pairs whose control succeeded; the built-in heuristics are
+	// used when nil, see heuristicDetector.
+	Detector Detector
+
+	// OnlyOperations, when non-empty, restricts the run to these operationIds.
+	OnlyOperations []string
+	// ExcludeOperations lists operationIds that are never tested.
+	ExcludeOperations []string
+	// ExpandEnums runs each pair once per value of enum-constrained query parameters
+	// that have at most EnumMax values and are not pinned by the object user's fields.
+	// Enum-constrained path parameters a user has no value for are always tried with
+	// each value, within the same EnumMax limit.
+	ExpandEnums bool
+	EnumMax     int
+	// ExpandExamples runs each pair once per named example of the JSON request body,
+	// sending the example (with the object user's matching fields) instead of a
+	// synthesized body. r.pair.example is the example in use while a pair runs.
+	ExpandExamples bool
+	pair           pairState
+	// VersionPrefix, when set, is matched at the start of each path and stripped to
+	// compute ResultLog.Resource, grouping e.g. /v1/users/{id} and /v2/users/{id}.
+	VersionPrefix *regexp.Regexp
+	// ConfirmWrites pauses before each mutating cross-user request until the operator
+	// approves or declines it through an EventConfirmWrite event.
+	ConfirmWrites  bool
+	writesApproved bool
+	// VerifyWrites re-reads the object as its owner after a successful cross-user write
+	// to confirm whether the data actually changed.
+	VerifyWrites bool
+	// Reclassify runs every POTENTIAL pair once more after the scan, see reclassify.
+	// potentialRuns holds those pairs until then.
+	Reclassify    bool
+	potentialRuns []testCase
+	// WarmupRequests is how many discarded requests each read-only endpoint gets before
+	// its first pair, see warmUp.
+	WarmupRequests int
+	// StrictSpec downgrades IDOR FOUND to POTENTIAL when the control got a status the spec
+	// does not document for the operation.
+	StrictSpec bool
+	// MinimalPatch sends PATCH bodies with only the properties the object user has fields
+	// for, see minimalPatchBody. r.pair.fullPatch is set for the rest of a pair once the
+	// control rejected the minimal body.
+	MinimalPatch bool
+	// ConditionalProbe repeats GET and HEAD tests as conditional requests with the
+	// control's ETag, see probeConditional. r.pair.extraHeaders are set on requests while
+	// it runs.
+	ConditionalProbe bool
+	// RecordRaw stores each request's exact serialized bytes in RequestDetails.Raw.
+	RecordRaw bool
+	// SpillDir, when set, receives response bodies longer than SpillThreshold bytes as they
+	// arrive, so large runs do not hold every body in memory; checks stream them back.
+	SpillDir       string
+	SpillThreshold int
+	// AllowedHosts are the hosts requests may be sent to, see CheckHost. Empty allows only
+	// the base URL's host.
+	AllowedHosts []string
+	// plan collects requests instead of sending them while BuildPlan runs; approved holds
+	// the keys of an approved plan's requests, see UsePlan. bodyTime, when set, replaces
+	// the clock for synthesized date and time values.
+	plan     *Plan
+	approved map[string]bool
+	bodyTime time.Time
+	// UserAgent is sent on every request; DefaultUserAgent() is used when empty.
+	UserAgent string
+	// ExtraQuery is added to the query string of every request, such as an api-version
+	// the spec leaves out. Parameters the request already sets keep their value.
+	ExtraQuery map[string]string
+	// Deprecated controls operations marked deprecated: DeprecatedInclude (default), DeprecatedSkip or DeprecatedOnly.
+	Deprecated string
 
 	TestedEndpoints   int
 	CompletedRequests int
 	TotalRequests     int
 
+	// RefreshAfter is how many consecutive 401 responses to a user's requests, as object
+	// owner or as attacker, trigger that user's auth refresh request; values below 1 mean 1.
+	RefreshAfter int
+	// AuthRefreshes counts successful auth refreshes per user.
+	AuthRefreshes map[string]int
+	unauthorized  map[string]int
+	// Traffic counts the bytes exchanged with the target, see recordTraffic.
+	Traffic   TrafficStats
+	trafficMu sync.Mutex
+	// lastSent is when the last request was sent per paced endpoint and user, see pace.
+	lastSent map[string]time.Time
+
+	// Concurrency is how many operations are tested at once; below 2 they are tested one
+	// after another. The pairs of one operation always run in order, see forEachOperation.
+	Concurrency int
+	// Adaptive, with Concurrency above 1, limits the requests in flight to each host and
+	// halves the limit when more than AdaptiveErrorRate (default 0.2) of the host's last
+	// AdaptiveWindow (default 20) responses failed, then raises it again by one per window
+	// with few failures; see hostThrottle.
+	Adaptive          bool
+	AdaptiveErrorRate float64
+	AdaptiveWindow    int
+	// EffectiveConcurrency is the range each host's limit moved in during the last
+	// adaptive run, by host.
+	EffectiveConcurrency map[string]ConcurrencyRange
+	// workers is held by the goroutine testing an operation while operations run
+	// concurrently; it is released while a request is on the wire or waits for pacing.
+	// slotFree is signalled when a host's throttle or a max_concurrent cap admits another
+	// request; inFlight counts the requests in flight per capped endpoint and user.
+	workers   *sync.Mutex
+	slotFree  *sync.Cond
+	throttles map[string]*hostThrottle
+	inFlight  map[string]int
+
+	// Commands optionally receives operator instructions such as pause and resume.
+	// skipGen counts CommandSkipEndpoint; an operation is skipped once it changes.
+	Commands <-chan Command
+	paused   bool
+	skipGen  int
+
+	// Tap, when set, is called with every request as soon as its response arrives, or
+	// with a response carrying only an error note when the request failed. Calls may come
+	// from any goroutine the runner sends from, so Tap must be safe for concurrent use.
+	Tap func(RequestDetails, ResponseDetails)
+
 	// Events is an optional channel used to emit progress updates for a TUI.
 	// If nil, events are not emitted.
 	Events chan Event
@@ -43,13 +194,27 @@ type RequestDetails struct {
 	QueryParams map[string]string `json:"query_params"`
 	Body        any               `json:"body"`
 	AuthUser    string            `json:"auth_user"`
+	Notes       []string          `json:"notes,omitempty"`
+	// Raw is the serialized request as written to the wire, recorded with Runner.RecordRaw.
+	Raw string `json:"raw,omitempty"`
+	// SentAt is when the request was sent; zero when it never was.
+	SentAt time.Time `json:"sent_at"`
 }
 
 type ResponseDetails struct {
-	Status     int               `json:"status"`
-	Headers    map[string]string `json:"headers"`
-	Body       string            `json:"body"`
-	DurationMs int64             `json:"duration_ms"`
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers"` // first value per header, kept for existing consumers
+	// HeaderValues holds every value per header, e.g. multiple Set-Cookie lines.
+	HeaderValues map[string][]string `json:"header_values,omitempty"`
+	Body         string              `json:"body"`
+	// BodyRef replaces Body when the body was spilled to disk; read it with FullBody.
+	BodyRef *BodyRef `json:"body_ref,omitempty"`
+	// ETag and LastModified are the response's caching validators, compared between
+	// control and test since they identify an object's version even without its body.
+	ETag         string   `json:"etag,omitempty"`
+	LastModified string   `json:"last_modified,omitempty"`
+	DurationMs   int64    `json:"duration_ms"`
+	Notes        []string `json:"notes,omitempty"`
 }
 
 type Exchange struct {
@@ -58,13 +223,43 @@ type Exchange struct {
 }
 
 type ResultLog struct {
-	Endpoint      string   `json:"endpoint"`
-	Method        string   `json:"method"`
+	Endpoint string `json:"endpoint"`
+	Method   string `json:"method"`
+	// OperationID, Tags and Summary come from the spec operation. They are always
+	// serialized, empty for results not tied to an operation such as config warnings.
+	OperationID   string   `json:"operation_id"`
+	Tags          []string `json:"tags"`
+	Summary       string   `json:"summary"`
 	Control       Exchange `json:"control"`
 	Test          Exchange `json:"test"`
 	Result        string   `json:"result"`
 	SkippedReason string   `json:"skipped_reason,omitempty"`
-	Notes         []string `json:"notes,omitempty"`
+	Deprecated    bool     `json:"deprecated,omitempty"`
+	// Resource is Endpoint with the version prefix stripped, when Runner.VersionPrefix is set.
+	Resource string `json:"resource,omitempty"`
+	// EnumValues records the expanded enum query and path values this result was produced with.
+	EnumValues map[string]string `json:"enum_values,omitempty"`
+	// BodyExample names the spec request body example sent, with Runner.ExpandExamples.
+	BodyExample   string   `json:"body_example,omitempty"`
+	SensitiveKeys []string `json:"sensitive_keys,omitempty"`
+	// Confidence is a 0-1 score combining the detection signals; see the weights in confidence.go.
+	Confidence float64 `json:"confidence"`
+	// Verification holds the owner's reads before and after the attacker's write, and
+	// WriteVerified is set when they show the write took effect.
+	Verification  []Exchange `json:"verification,omitempty"`
+	WriteVerified bool       `json:"write_verified,omitempty"`
+	// DocumentedStatuses are the response codes the spec declares for the operation.
+	DocumentedStatuses []string `json:"documented_statuses,omitempty"`
+	// ConditionalProbe is the attacker's conditional request, with Runner.ConditionalProbe.
+	ConditionalProbe *Exchange `json:"conditional_probe,omitempty"`
+	// InBaseline is set on IDOR FOUND and POTENTIAL results an earlier run's baseline
+	// already reported; they are left out of the summary's findings.
+	InBaseline bool `json:"in_baseline,omitempty"`
+	// Cleanup holds the endpoint's cleanup requests sent after this pair, and CleanupErrors
+	// why the sequence failed, if it did.
+	Cleanup       []Exchange `json:"cleanup,omitempty"`
+	CleanupErrors []string   `json:"cleanup_errors,omitempty"`
+	Notes         []string   `json:"notes,omitempty"`
 }
 
 const (
@@ -72,18 +267,45 @@ const (
 	ResultSecure        = "SECURE"
 	ResultPotential     = "POTENTIAL"
 	ResultControlFailed = "CONTROL_FAILED"
+	ResultError         = "ERROR" // the test got a 5xx after a successful control
 	ResultSkipped       = "SKIPPED"
 )
 
+// Modes for Runner.Deprecated.
+const (
+	DeprecatedInclude = "include"
+	DeprecatedSkip    = "skip"
+	DeprecatedOnly    = "only"
+)
+
+// SkipReasonNoSecurity is recorded for operations skipped because the spec declares no security requirement.
+const SkipReasonNoSecurity = "no security requirement"
+
+// SkipReasonMutations is recorded for mutating operations skipped because AllowMutations is not set.
+const SkipReasonMutations = "mutating requests are disabled"
+
+// SkipReasonIdenticalErrors is recorded with SkipIdenticalErrors for pairs whose control and test failed the same way.
+const SkipReasonIdenticalErrors = "control and test failed identically"
+
 // EventKind describes the type of progress event emitted by the runner.
 type EventKind string
 
 const (
-	EventPathsDiscovered  EventKind = "paths_discovered"
-	EventTotalRequests    EventKind = "total_requests"
-	EventEndpointStarting EventKind = "endpoint_starting"
-	EventRequestPrepared  EventKind = "request_prepared"
-	EventRequestCompleted EventKind = "request_completed"
+	EventPathsDiscovered   EventKind = "paths_discovered"
+	EventTotalRequests     EventKind = "total_requests"
+	EventEndpointStarting  EventKind = "endpoint_starting"
+	EventRequestPrepared   EventKind = "request_prepared"
+	EventRequestCompleted  EventKind = "request_completed"
+	EventEndpointCompleted EventKind = "endpoint_completed"
+	// EventLog carries an informational message such as a skip or a finding.
+	EventLog EventKind = "log"
+	// EventResult carries one finished result; it is never dropped.
+	EventResult EventKind = "result"
+	// EventConfirmWrite asks the operator to approve a mutating test request; reply on Reply.
+	EventConfirmWrite EventKind = "confirm_write"
+	// EventConcurrency carries a host's request limit in Concurrency: the configured one
+	// when the run starts and each adaptive change, described in Message, after that.
+	EventConcurrency EventKind = "concurrency"
 )
 
 // Event carries progress information for UI consumers.
@@ -95,6 +317,63 @@ type Event struct {
 	Request    RequestDetails
 	Completed  int
 	Total      int
+
+	// Verdicts counts results per verdict for EventEndpointCompleted.
+	Verdicts           map[string]int
+	EndpointsCompleted int
+	EndpointsTotal     int
+
+	// Message is the text of an EventLog.
+	Message string
+
+	// Status and DurationMs describe the response for EventRequestCompleted.
+	Status     int
+	DurationMs int64
+
+	// Result is the finished result for EventResult.
+	Result *ResultLog
+	// Replaces is the verdict of an earlier EventResult that Result supersedes, set when
+	// a POTENTIAL result is reclassified; listeners should drop that earlier count.
+	Replaces string
+
+	// Reply receives the operator's decision for EventConfirmWrite.
+	Reply chan<- ConfirmDecision
+
+	// Host and Concurrency are the host and its request limit for EventConcurrency.
+	Host        string
+	Concurrency int
+}
+
+// DefaultUserAgent returns "aperture/<version>".
+func DefaultUserAgent() string {
+	return "aperture/" + Version()
+}
+
+// Version returns the module version from the build info, or "dev" for local builds.
+func Version() string {
+	if bi, ok := debug.ReadBuildInfo(); ok && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+		return bi.Main.Version
+	}
+	return "dev"
+}
+
+// BuildInfo describes the running binary: version, Go toolchain and, when available,
+// the VCS revision it was built from.
+func BuildInfo() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "aperture %s", Version())
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return b.String()
+	}
+	fmt.Fprintf(&b, "\ngo: %s", bi.GoVersion)
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision", "vcs.time", "vcs.modified", "GOOS", "GOARCH":
+			fmt.Fprintf(&b, "\n%s: %s", s.Key, s.Value)
+		}
+	}
+	return b.String()
 }
 
 func (r *Runner) emitEvent(e Event) {
@@ -109,221 +388,559 @@ func (r *Runner) emitEvent(e Event) {
 	}
 }
 
+// logf records an informational message. It is emitted as an EventLog for the TUI's log
+// pane and, with Verbose, printed to stdout unless QuietStdout is set.
+func (r *Runner) logf(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	if r.Verbose && !r.QuietStdout {
+		fmt.Println(msg)
+	}
+	r.emitEvent(Event{Kind: EventLog, Message: msg})
+}
+
+// emitResults sends an EventResult per result. Unlike progress events these are never
+// dropped, so listeners can keep counts that match the final results exactly.
+func (r *Runner) emitResults(ctx context.Context, results []ResultLog) {
+	if r.Events == nil {
+		return
+	}
+	for i := range results {
+		select {
+		case r.Events <- Event{Kind: EventResult, Endpoint: results[i].Endpoint, Method: results[i].Method, Result: &results[i]}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
 func (r *Runner) Execute(ctx context.Context) ([]ResultLog, error) {
-	client := &http.Client{Timeout: r.HTTPTimeout}
+	client := r.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: r.HTTPTimeout}
+	}
 	var results []ResultLog
+	r.potentialRuns = nil
 
 	allFields := r.collectAllFieldNames()
 	r.validateConfigFields(allFields, &results)
+	r.validateFieldValues(&results)
+	r.validateSharedCredentials(&results)
+	results = append(results, r.callbackSkips()...)
+	r.emitResults(ctx, results)
 
-	if r.Verbose {
-		fmt.Printf("[*] Discovered %d paths in spec\n", len(r.Spec.Paths.Map()))
-	}
+	r.logf("[*] Discovered %d paths in spec", len(r.Spec.Paths.Map()))
 	// Emit paths discovered
 	r.emitEvent(Event{Kind: EventPathsDiscovered, PathsCount: len(r.Spec.Paths.Map())})
 
-	// Estimate total requests and emit
-	r.TotalRequests = r.EstimateTotalRequests()
-	r.emitEvent(Event{Kind: EventTotalRequests, Total: r.TotalRequests})
+	// Plan the scan, then estimate total requests from the plan and emit
+	plans := r.planOperations()
+	r.TotalRequests = r.estimateRequests(plans)
+	endpointsTotal := len(plans)
+	r.emitEvent(Event{Kind: EventTotalRequests, Total: r.TotalRequests, EndpointsTotal: endpointsTotal})
+
+	opResults := make([][]ResultLog, len(plans))
+	opPotential := make([][]testCase, len(plans))
+	completed := 0
+	r.forEachOperation(plans, func(i int, plan operationPlan) {
+		// Plan the operation again with the users as they are now, since cleanup steps of
+		// earlier operations may have captured field values that change its pairs
+		estimated := r.estimateRequests([]operationPlan{plan})
+		plan = r.planOperation(plan.path, plan.method, plan.op, plan.item)
+		if n := r.estimateRequests([]operationPlan{plan}); n != estimated {
+			r.TotalRequests += n - estimated
+			r.emitEvent(Event{Kind: EventTotalRequests, Total: r.TotalRequests, EndpointsTotal: endpointsTotal})
+		}
+		res, potential := r.executeOperation(ctx, client, plan)
+		resource := r.resourceFor(plan.path)
+		for j := range res {
+			res[j].OperationID = plan.op.OperationID
+			res[j].Tags = operationTags(plan.op)
+			res[j].Summary = plan.op.Summary
+			res[j].Deprecated = plan.op.Deprecated
+			res[j].Resource = resource
+		}
+		opResults[i], opPotential[i] = res, potential
+		completed++
+		r.emitResults(ctx, res)
+		r.emitEvent(Event{
+			Kind:               EventEndpointCompleted,
+			Endpoint:           plan.path,
+			Method:             plan.method,
+			Verdicts:           countVerdicts(res),
+			EndpointsCompleted: completed,
+			EndpointsTotal:     endpointsTotal,
+		})
+	})
+	// Results keep the plan's order however the operations finished
+	for i := range plans {
+		for _, tc := range opPotential[i] {
+			tc.index += len(results)
+			r.potentialRuns = append(r.potentialRuns, tc)
+		}
+		results = append(results, opResults[i]...)
+	}
 
-	for path, item := range r.Spec.Paths.Map() {
-		ops := operationsFor(item)
-		for method, op := range ops {
-			resultNotes := []string{}
+	r.reclassify(ctx, client, results)
+	annotateMethodInconsistencies(results)
+	for i := range results {
+		if results[i].Tags == nil {
+			results[i].Tags = []string{}
+		}
+	}
+	return results, nil
+}
 
-			if r.Verbose {
-				fmt.Printf("[*] Testing %s %s\n", method, path)
-			}
-			r.emitEvent(Event{Kind: EventEndpointStarting, Endpoint: path, Method: method})
+// operationTags returns a copy of the operation's tags, never nil so it serializes as [].
+func operationTags(op *openapi3.Operation) []string {
+	return append([]string{}, op.Tags...)
+}
 
-			// Skip DELETE requests when configured
-			if r.SkipDelete && strings.EqualFold(method, "DELETE") {
-				if r.Verbose {
-					fmt.Printf("[~] Skipping %s %s: delete requests are skipped\n", method, path)
-				}
-				results = append(results, ResultLog{
-					Endpoint:      path,
-					Method:        method,
-					Result:        ResultSkipped,
-					SkippedReason: "delete requests are skipped",
-					Notes:         resultNotes,
-				})
-				continue
-			}
+// countVerdicts tallies results by their Result label.
+func countVerdicts(results []ResultLog) map[string]int {
+	counts := map[string]int{}
+	for _, rl := range results {
+		counts[rl.Result]++
+	}
+	return counts
+}
 
-			// Skip endpoints that do not declare any security requirement per OpenAPI
-			if !operationRequiresAuth(r.Spec, op) {
-				if r.Verbose {
-					fmt.Printf("[~] Skipping %s %s: no security requirement\n", method, path)
-				}
-				results = append(results, ResultLog{
-					Endpoint:      path,
-					Method:        method,
-					Result:        ResultSkipped,
-					SkippedReason: "no security requirement",
-					Notes:         resultNotes,
-				})
-				continue
+// resourceFor strips the configured version prefix from path, or returns "" when no
+// prefix is configured or path does not start with it.
+func (r *Runner) resourceFor(path string) string {
+	if r.VersionPrefix == nil {
+		return ""
+	}
+	loc := r.VersionPrefix.FindStringIndex(path)
+	if loc == nil || loc[0] != 0 {
+		return ""
+	}
+	rest := path[loc[1]:]
+	if !strings.HasPrefix(rest, "/") {
+		rest = "/" + rest
+	}
+	return rest
+}
+
+// operationSkipReason returns why an operation is not tested at all, or "" when it is.
+// Execute, EstimateTotalRequests and CheckConfig share it so their filtering stays in sync.
+func (r *Runner) operationSkipReason(path, method string, op *openapi3.Operation, item *openapi3.PathItem) string {
+	if reason := extensionSkipReason(op); reason != "" {
+		return reason
+	}
+	if r.infrastructurePath(path) {
+		return SkipReasonInfrastructure
+	}
+	if r.Deprecated == DeprecatedSkip && op.Deprecated {
+		return "deprecated operation excluded"
+	}
+	if r.SkipDelete && strings.EqualFold(method, "DELETE") {
+		return "delete requests are skipped"
+	}
+	if !r.AllowMutations && isWriteMethod(method) {
+		return SkipReasonMutations
+	}
+	if r.RequireSuccessResponse && !hasSuccessResponse(op) {
+		return "no 2xx response declared"
+	}
+	if !r.IncludeNoAuth && !operationRequiresAuth(r.Spec, op) {
+		return SkipReasonNoSecurity
+	}
+	if len(r.Config.Users) < 2 {
+		return "need >=2 users in config"
+	}
+	if required := r.requiredParams(path, op, item); len(r.eligibleUsers(required)) < 1 {
+		return "need >=1 user with required endpoint fields (path/query): missing " + strings.Join(r.missingFields(required), ", ")
+	}
+	return ""
+}
+
+// objectUserSkipReason returns why objectUser cannot act as the object owner for an
+// otherwise testable operation, or "" when it can.
+func objectUserSkipReason(path string, op *openapi3.Operation, item *openapi3.PathItem, objectUser testconfig.User) string {
+	if !operationReferencesUserFields(path, op, item, objectUser) {
+		return "no object identifiers referenced by this operation"
+	}
+	if _, _, missing := substitutePathParams(path, objectUser.Fields, nil); len(missing) > 0 {
+		return (&missingPathParamsError{names: missing}).Error()
+	}
+	return ""
+}
+
+// executeOperation runs the planned test cases of one operation and returns its results
+// and, with Reclassify, the cases of its POTENTIAL results with their index in them.
+func (r *Runner) executeOperation(ctx context.Context, client *http.Client, plan operationPlan) ([]ResultLog, []testCase) {
+	var results []ResultLog
+	var potential []testCase
+	method, path := plan.method, plan.path
+
+	r.logf("[*] Testing %s %s", method, path)
+	r.emitEvent(Event{Kind: EventEndpointStarting, Endpoint: path, Method: method})
+
+	if plan.skip != "" {
+		r.logf("[~] Skipping %s %s: %s", method, path, plan.skip)
+		results = append(results, ResultLog{
+			Endpoint:      path,
+			Method:        method,
+			Result:        ResultSkipped,
+			SkippedReason: plan.skip,
+			Notes:         []string{},
+		})
+		return results, nil
+	}
+
+	skipGen := r.skipGen
+	warmed := false
+	for _, tc := range plan.cases {
+		if tc.skip != "" {
+			if tc.credUser.Name == "" {
+				r.logf("[~] Skipping %s %s for object=%s: %s", method, path, tc.objectUser.Name, tc.skip)
+			} else {
+				r.logf("[~] Skipping %s %s creds=%s object=%s: %s", method, path, tc.credUser.Name, tc.objectUser.Name, tc.skip)
 			}
+			results = append(results, ResultLog{
+				Endpoint:      path,
+				Method:        method,
+				Result:        ResultSkipped,
+				SkippedReason: tc.skip,
+				Notes:         tc.notes,
+			})
+			continue
+		}
+		if !warmed {
+			warmed = true
+			r.warmUp(ctx, client, method, path, tc.op, tc.item, tc.objectUser, tc.required)
+		}
+		// Cancellation is reported by the next sendOne; here only pause and skip matter
+		_ = r.checkpoint(ctx)
+		if r.skipGen != skipGen {
+			exampleName := ""
+			if tc.example != nil {
+				exampleName = tc.example.name
+			}
+			results = append(results, ResultLog{
+				Endpoint:      path,
+				Method:        method,
+				Result:        ResultSkipped,
+				SkippedReason: SkipReasonOperator,
+				EnumValues:    tc.variant,
+				BodyExample:   exampleName,
+				Notes:         append(append([]string(nil), tc.notes...), fmt.Sprintf("creds=%s object=%s", tc.credUser.Name, tc.objectUser.Name)),
+			})
+			continue
+		}
+		r.logf("[*] %s %s creds=%s object=%s", method, path, tc.credUser.Name, tc.objectUser.Name)
+		res := r.runPair(ctx, client, tc)
+		if r.Reclassify && res.Result == ResultPotential {
+			tc.index = len(results)
+			potential = append(potential, tc)
+		}
+		results = append(results, res)
+	}
+	return results, potential
+}
 
-			required := r.requiredParams(op, item)
+// runPair runs one test case with its body example, records the variant and example on
+// the result and cleans up after a successful control.
+func (r *Runner) runPair(ctx context.Context, client *http.Client, run testCase) ResultLog {
+	r.pair = pairState{example: run.example}
+	res := r.testPair(ctx, client, run.method, run.path, run.op, run.item, run.objectUser, run.credUser, run.required, run.variant, run.notes)
+	r.pair = pairState{}
+	res.EnumValues = run.variant
+	if run.example != nil {
+		res.BodyExample = run.example.name
+		res.Notes = append(res.Notes, fmt.Sprintf("request body example %q", run.example.name))
+	}
+	if res.Control.Response.Status >= 200 && res.Control.Response.Status < 300 {
+		r.runCleanup(ctx, client, run.method, run.path, run.objectUser.Name, &res)
+	}
+	return res
+}
 
-			// For each user, ensure they have required fields for acting as the object owner
-			eligible := r.eligibleUsers(required)
-			if len(r.Config.Users) < 2 {
-				if r.Verbose {
-					fmt.Printf("[~] Skipping %s %s: need >=2 users in config\n", method, path)
-				}
-				results = append(results, ResultLog{
+// testPair sends the control (objectUser's credentials) and test (credUser's credentials)
+// requests for one pair and classifies the outcome. variant holds extra field values,
+// such as an expanded enum, that are sent but not treated as object identifiers.
+func (r *Runner) testPair(
+	ctx context.Context,
+	client *http.Client,
+	method, path string,
+	op *openapi3.Operation,
+	item *openapi3.PathItem,
+	objectUser testconfig.User,
+	credUser testconfig.User,
+	required map[string]paramSpec,
+	variant map[string]string,
+	resultNotes []string,
+) ResultLog {
+	// Either user's credential may have been refreshed since the pairs were built
+	objectUser, credUser = r.withLatestAuth(objectUser), r.withLatestAuth(credUser)
+	sendUser := withFields(objectUser, variant)
+
+	control, ctrlResp, ctrlErr := r.sendOne(ctx, client, method, path, op, item, sendUser, sendUser, required)
+	if ctrlErr == nil && ctrlResp.Status == http.StatusUnauthorized {
+		if r.refreshAfterUnauthorized(ctx, client, objectUser.Name) {
+			objectUser = r.withLatestAuth(objectUser)
+			sendUser = withFields(objectUser, variant)
+			r.TotalRequests++
+			control, ctrlResp, ctrlErr = r.sendOne(ctx, client, method, path, op, item, sendUser, sendUser, required)
+			resultNotes = append(append([]string(nil), resultNotes...), fmt.Sprintf("control retried after refreshing auth for %s", objectUser.Name))
+		}
+	} else if ctrlErr == nil {
+		r.resetUnauthorized(objectUser.Name)
+	}
+	if ctrlErr == nil && minimalPatchRejected(ctrlResp.Status) && r.usesMinimalPatch(method, path, op) {
+		// The server may require the full representation; prove the point with it instead
+		r.pair.fullPatch = true
+		r.TotalRequests++
+		resultNotes = append(append([]string(nil), resultNotes...), fmt.Sprintf("minimal PATCH body rejected with %d; sent the full synthesized body", ctrlResp.Status))
+		control, ctrlResp, ctrlErr = r.sendOne(ctx, client, method, path, op, item, sendUser, sendUser, required)
+	}
+	if errors.Is(ctrlErr, errNotPlanned) {
+		r.logf("[~] Skipping %s %s (creds=%s object=%s): %v", method, path, credUser.Name, objectUser.Name, ctrlErr)
+		return ResultLog{
+			Endpoint:      path,
+			Method:        method,
+			Result:        ResultSkipped,
+			SkippedReason: SkipReasonNotPlanned,
+			Notes:         append(append([]string(nil), resultNotes...), ctrlErr.Error()),
+		}
+	}
+	var missingErr *missingPathParamsError
+	if errors.As(ctrlErr, &missingErr) {
+		r.logf("[~] Skipping %s %s for object=%s: %v", method, path, objectUser.Name, missingErr)
+		return ResultLog{
+			Endpoint:      path,
+			Method:        method,
+			Result:        ResultSkipped,
+			SkippedReason: missingErr.Error(),
+			Notes:         resultNotes,
+		}
+	}
+	if ctrlErr != nil {
+		if r.SkipIdenticalErrors {
+			// An endpoint that is down fails for everyone; that says nothing about authorization
+			test, _, testErr := r.sendOne(ctx, client, method, path, op, item, sendUser, credUser, required)
+			if testErr != nil && testErr.Error() == ctrlErr.Error() {
+				r.logf("[~] Skipping %s %s (creds=%s object=%s): %s: %v", method, path, credUser.Name, objectUser.Name, SkipReasonIdenticalErrors, ctrlErr)
+				return ResultLog{
 					Endpoint:      path,
 					Method:        method,
+					Control:       control,
+					Test:          test,
 					Result:        ResultSkipped,
-					SkippedReason: "need >=2 users in config",
-					Notes:         resultNotes,
-				})
-				continue
-			}
-			if len(eligible) < 1 {
-				if r.Verbose {
-					fmt.Printf("[~] Skipping %s %s: need >=1 user with required endpoint fields (path/query) to act as object owner\n", method, path)
+					SkippedReason: SkipReasonIdenticalErrors,
+					Notes:         append(append([]string(nil), resultNotes...), fmt.Sprintf("control and test error: %v", ctrlErr)),
 				}
-				results = append(results, ResultLog{
-					Endpoint:      path,
-					Method:        method,
-					Result:        ResultSkipped,
-					SkippedReason: "need >=1 user with required endpoint fields (path/query)",
-					Notes:         resultNotes,
-				})
-				continue
 			}
+		}
+		r.logf("[x] Control error for %s %s (user=%s): %v", method, path, objectUser.Name, ctrlErr)
+		return ResultLog{
+			Endpoint: path,
+			Method:   method,
+			Control:  control,
+			Result:   ResultControlFailed,
+			Notes:    append(append([]string(nil), resultNotes...), fmt.Sprintf("control error: %v", ctrlErr)),
+		}
+	}
 
-			pairs := userPairsForEligibleObjectUsers(eligible, r.Config.Users)
-			for _, pair := range pairs {
-				userA := pair[0]
-				userB := pair[1]
-
-				// Skip pairs for which the operation does not reference any object identifier from the user's fields
-				if !operationReferencesUserFields(path, op, item, userA) {
-					if r.Verbose {
-						fmt.Printf("[~] Skipping %s %s for object=%s: no object identifiers referenced by this operation\n", method, path, userA.Name)
-					}
-					results = append(results, ResultLog{
-						Endpoint:      path,
-						Method:        method,
-						Result:        ResultSkipped,
-						SkippedReason: "no object identifiers referenced by this operation",
-						Notes:         resultNotes,
-					})
-					continue
-				}
-
-				if r.Verbose {
-					fmt.Printf("[*] %s %s creds=%s object=%s\n", method, path, userB.Name, userA.Name)
-				}
+	// Snapshot the object as its owner right before the attacker's write so its effect can be verified
+	var snapshot *Exchange
+	verifyPath, verifyOp, verifyItem := r.verificationTarget(method, path, item)
+	if verifyOp != nil && ctrlResp.Status >= 200 && ctrlResp.Status < 300 {
+		if ex, _, err := r.sendOne(ctx, client, "GET", verifyPath, verifyOp, verifyItem, sendUser, sendUser, nil); err == nil {
+			snapshot = &ex
+		}
+	}
 
-				control, ctrlResp, ctrlErr := r.sendOne(ctx, client, method, path, op, item, userA, userA, required)
-				if ctrlErr != nil {
-					if r.Verbose {
-						fmt.Printf("[x] Control error for %s %s (user=%s): %v\n", method, path, userA.Name, ctrlErr)
-					}
-					results = append(results, ResultLog{
-						Endpoint: path,
-						Method:   method,
-						Control:  control,
-						Result:   ResultControlFailed,
-						Notes:    append(resultNotes, fmt.Sprintf("control error: %v", ctrlErr)),
-					})
-					continue
-				}
+	r.pair.perturbWrite = snapshot != nil
+	test, testResp, testErr := r.sendOne(ctx, client, method, path, op, item, sendUser, credUser, required)
+	r.pair.perturbWrite = false
+	// A 401 may come from credUser's own token expiring rather than from the object check
+	staleTest := false
+	if testErr == nil && testResp.Status == http.StatusUnauthorized {
+		if r.refreshAfterUnauthorized(ctx, client, credUser.Name) {
+			credUser = r.withLatestAuth(credUser)
+			r.TotalRequests++
+			r.pair.perturbWrite = snapshot != nil
+			test, testResp, testErr = r.sendOne(ctx, client, method, path, op, item, sendUser, credUser, required)
+			r.pair.perturbWrite = false
+			resultNotes = append(append([]string(nil), resultNotes...), fmt.Sprintf("test retried after refreshing auth for %s", credUser.Name))
+		} else {
+			staleTest = credUser.Auth.Refresh != nil
+		}
+	} else if testErr == nil {
+		r.resetUnauthorized(credUser.Name)
+	}
+	res := ResultLog{
+		Endpoint: path,
+		Method:   method,
+		Control:  control,
+		Test:     test,
+		Notes:    append([]string(nil), resultNotes...),
+	}
+	res.Notes = append(res.Notes, prefixNotes("control", control.Request.Notes)...)
+	res.Notes = append(res.Notes, prefixNotes("control", ctrlResp.Notes)...)
+	res.Notes = append(res.Notes, prefixNotes("test", testResp.Notes)...)
+	if errors.Is(testErr, errDeclined) {
+		res.Result = ResultSkipped
+		res.SkippedReason = SkipReasonDeclined
+		return res
+	}
+	if errors.Is(testErr, errNotPlanned) {
+		res.Result = ResultSkipped
+		res.SkippedReason = SkipReasonNotPlanned
+		res.Notes = append(res.Notes, testErr.Error())
+		r.logf("[~] Skipping %s %s (creds=%s object=%s): %v", method, path, credUser.Name, objectUser.Name, testErr)
+		return res
+	}
+	if testErr != nil {
+		r.logf("[?] Test error for %s %s (creds=%s object=%s): %v", method, path, credUser.Name, objectUser.Name, testErr)
+		res.Result = ResultPotential
+		res.Notes = append(res.Notes, fmt.Sprintf("test error: %v", testErr))
+		return res
+	}
+
+	// Only a successful control says anything about the test; the Detector classifies the rest
+	ctrl2xx := ctrlResp.Status >= 200 && ctrlResp.Status < 300
+	test2xx := testResp.Status >= 200 && testResp.Status < 300
+	// A 2xx carrying an error envelope is a denial, not a success
+	if envelope := r.errorEnvelope(ctrlResp); ctrl2xx && envelope != "" {
+		ctrl2xx = false
+		res.Notes = append(res.Notes, fmt.Sprintf("control response is an error envelope (%s)", envelope))
+	}
+	if test2xx {
+		test2xx = r.errorEnvelope(testResp) == ""
+	}
+
+	if !ctrl2xx && r.SkipIdenticalErrors && testResp.Status == ctrlResp.Status && bodiesLikelyEqual(ctrlResp, testResp, r.Config.IgnoreFields) {
+		res.Result = ResultSkipped
+		res.SkippedReason = SkipReasonIdenticalErrors
+		res.Notes = append(res.Notes, fmt.Sprintf("control and test both returned status %d", ctrlResp.Status))
+		r.logf("[~] Skipping %s %s (creds=%s object=%s): %s (status=%d)", method, path, credUser.Name, objectUser.Name, SkipReasonIdenticalErrors, ctrlResp.Status)
+		return res
+	}
+	if !ctrl2xx {
+		res.Result = ResultControlFailed
+		r.noteUndocumentedStatuses(&res, op, ctrlResp, testResp)
+		r.logf("[x] Control failed for %s %s (status=%d)", method, path, ctrlResp.Status)
+		return res
+	}
+
+	pair := Pair{
+		Method: method, Path: path, Op: op, Item: item,
+		ObjectUser: objectUser, CredUser: credUser,
+		Control: ctrlResp, Test: testResp,
+	}
+	if strings.EqualFold(method, http.MethodHead) {
+		pair.Reference, pair.ReferenceName = ctrlResp, "the HEAD control"
+		if test2xx {
+			pair.Reference, pair.ReferenceName = r.headReference(ctx, client, path, item, sendUser, ctrlResp)
+		}
+	}
+	r.detector().Detect(pair, &res)
+	if staleTest && res.Result == ResultSecure {
+		res.Result = ResultPotential
+		res.Notes = append(res.Notes, fmt.Sprintf("test got 401 before %s's auth refresh was due; the credential may have expired", credUser.Name))
+	}
 
-				test, testResp, testErr := r.sendOne(ctx, client, method, path, op, item, userA, userB, required)
-				res := ResultLog{
-					Endpoint: path,
-					Method:   method,
-					Control:  control,
-					Test:     test,
-				}
-				if testErr != nil {
-					if r.Verbose {
-						fmt.Printf("[?] Test error for %s %s (creds=%s object=%s): %v\n", method, path, userB.Name, userA.Name, testErr)
-					}
-					res.Result = ResultPotential
-					res.Notes = append(resultNotes, fmt.Sprintf("test error: %v", testErr))
-					results = append(results, res)
-					continue
-				}
+	r.compareValidators(&res, ctrlResp, testResp)
+	if r.ConditionalProbe && ctrlResp.ETag != "" && (strings.EqualFold(method, http.MethodGet) || strings.EqualFold(method, http.MethodHead)) {
+		r.probeConditional(ctx, client, &res, method, path, op, item, sendUser, credUser, required, ctrlResp.ETag)
+	}
 
-				// Detection heuristics
-				ctrl2xx := ctrlResp.Status >= 200 && ctrlResp.Status < 300
-				test2xx := testResp.Status >= 200 && testResp.Status < 300
+	if snapshot != nil && test2xx {
+		r.verifyWrite(ctx, client, &res, *snapshot, verifyPath, verifyOp, verifyItem, sendUser)
+	}
 
-				if !ctrl2xx {
-					res.Result = ResultControlFailed
-					if r.Verbose {
-						fmt.Printf("[x] Control failed for %s %s (status=%d)\n", method, path, ctrlResp.Status)
-					}
-					results = append(results, res)
-					continue
-				}
+	r.noteUndocumentedStatuses(&res, op, ctrlResp, testResp)
+	if extensionShared(op) && (res.Result == ResultIDORFound || res.Result == ResultPotential) {
+		res.Notes = append(res.Notes, fmt.Sprintf("%s: cross-user access is expected; %s suppressed", extShared, res.Result))
+		res.Result = ResultSecure
+		r.logf("[✓] SECURE: %s %s (shared by spec extension)", method, path)
+	}
 
-				if test2xx {
-					if bodySuggestsLeakedData(testResp.Body, userA.Fields) || bodiesLikelyEqual(ctrlResp.Body, testResp.Body) {
-						res.Result = ResultIDORFound
-						if r.Verbose {
-							fmt.Printf("[!] IDOR FOUND: %s %s (creds=%s object=%s)\n", method, path, userB.Name, userA.Name)
-						}
-					} else {
-						// If test succeeds but response appears different from control and does not leak identifiers, treat as secure
-						res.Result = ResultSecure
-						res.Notes = append(res.Notes, "test succeeded but response differed from control")
-						if r.Verbose {
-							fmt.Printf("[✓] SECURE: %s %s (test succeeded with different body)\n", method, path)
-						}
-					}
-				} else if testResp.Status == 401 || testResp.Status == 403 {
-					res.Result = ResultSecure
-					if r.Verbose {
-						fmt.Printf("[✓] SECURE: %s %s (status=%d)\n", method, path, testResp.Status)
-					}
-				} else {
-					res.Result = ResultPotential
-					res.Notes = append(res.Notes, fmt.Sprintf("unexpected status: %d", testResp.Status))
-					if r.Verbose {
-						fmt.Printf("[?] POTENTIAL: %s %s (unexpected status=%d)\n", method, path, testResp.Status)
-					}
-				}
+	r.TestedEndpoints++
+	return res
+}
 
-				results = append(results, res)
-				r.TestedEndpoints++
+// enumVariants returns the combinations of enum values to send for query parameters
+// eligible for expansion. It always returns at least one (possibly nil) variant.
+func (r *Runner) enumVariants(op *openapi3.Operation, item *openapi3.PathItem, objectUser testconfig.User) []map[string]string {
+	variants := []map[string]string{nil}
+	if !r.ExpandEnums {
+		return variants
+	}
+	for _, p := range mergeParams(item.Parameters, op.Parameters) {
+		if p == nil || p.Value == nil || p.Value.In != "query" {
+			continue
+		}
+		if _, pinned := objectUser.Fields[p.Value.Name]; pinned {
+			continue
+		}
+		if p.Value.Schema == nil || p.Value.Schema.Value == nil {
+			continue
+		}
+		enum := p.Value.Schema.Value.Enum
+		if len(enum) == 0 || (r.EnumMax > 0 && len(enum) > r.EnumMax) {
+			continue
+		}
+		var next []map[string]string
+		for _, v := range variants {
+			for _, e := range enum {
+				next = append(next, withValue(v, p.Value.Name, fmt.Sprint(e)))
 			}
 		}
+		variants = next
 	}
+	return variants
+}
 
-	return results, nil
+// withFields returns a copy of u whose fields are extended with extra.
+func withFields(u testconfig.User, extra map[string]string) testconfig.User {
+	if len(extra) == 0 {
+		return u
+	}
+	fields := make(map[string]string, len(u.Fields)+len(extra))
+	for k, v := range u.Fields {
+		fields[k] = v
+	}
+	for k, v := range extra {
+		fields[k] = v
+	}
+	u.Fields = fields
+	return u
 }
 
-func (r *Runner) requiredParams(op *openapi3.Operation, item *openapi3.PathItem) map[string]paramSpec {
+// requiredParams returns the operation's required parameters and JSON body properties.
+// Placeholders in the path template are required path parameters even when the spec
+// declares no parameter object for them, as some generated specs do.
+func (r *Runner) requiredParams(path string, op *openapi3.Operation, item *openapi3.PathItem) map[string]paramSpec {
 	req := map[string]paramSpec{}
 	add := func(p *openapi3.ParameterRef) {
 		if p == nil || p.Value == nil {
 			return
 		}
 		if p.Value.Required {
-			req[p.Value.Name] = paramSpec{In: p.Value.In}
+			ps := paramSpec{In: p.Value.In}
+			if ps.In == "path" {
+				ps.enum = r.pathEnum(p.Value)
+			}
+			req[p.Value.Name] = ps
 		}
 	}
-	for _, p := range item.Parameters {
+	for _, p := range mergeParams(item.Parameters, op.Parameters) {
 		add(p)
 	}
-	for _, p := range op.Parameters {
-		add(p)
+	for _, name := range extractPathParamNames(path) {
+		if req[name].In != "path" {
+			req[name] = paramSpec{In: "path"}
+		}
 	}
 
-	// Request body required fields (application/json)
+	// Request body required fields (application/json or a +json type)
 	if op.RequestBody != nil {
 		rb := op.RequestBody.Value
 		if rb != nil && rb.Required {
-			if mt, ok := rb.Content["application/json"]; ok {
+			if _, mt, ok := jsonContent(rb.Content); ok {
 				if mt.Schema != nil && mt.Schema.Value != nil {
 					reqBody := mt.Schema.Value
 					for _, name := range reqBody.Required {
@@ -337,8 +954,81 @@ func (r *Runner) requiredParams(op *openapi3.Operation, item *openapi3.PathItem)
 	return req
 }
 
+// missingFields returns, sorted, the required fields that eligibleUsers checks and at
+// least one user lacks.
+func (r *Runner) missingFields(required map[string]paramSpec) []string {
+	var out []string
+	for name, ps := range required {
+		if ps.In == "header" || ps.In == "body" || len(ps.enum) > 0 {
+			continue
+		}
+		for _, u := range r.Config.Users {
+			if _, ok := u.Fields[name]; !ok {
+				out = append(out, name)
+				break
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
 type paramSpec struct {
 	In string // path, query, header, body
+	// enum holds the values of an enum-constrained path parameter, tried in turn for
+	// users without a value (see pathEnumVariants), so it does not need a user field.
+	enum []string
+}
+
+// pathEnum returns the values a path parameter is constrained to, or nil when it has no
+// enum or one larger than EnumMax.
+func (r *Runner) pathEnum(p *openapi3.Parameter) []string {
+	if p.Schema == nil || p.Schema.Value == nil {
+		return nil
+	}
+	enum := p.Schema.Value.Enum
+	if len(enum) == 0 || (r.EnumMax > 0 && len(enum) > r.EnumMax) {
+		return nil
+	}
+	values := make([]string, len(enum))
+	for i, e := range enum {
+		values[i] = fmt.Sprint(e)
+	}
+	return values
+}
+
+// pathEnumVariants returns the combinations of enum values to send for the required
+// enum path parameters objectUser has no value for, in name order. It always returns at
+// least one (possibly nil) variant.
+func pathEnumVariants(required map[string]paramSpec, objectUser testconfig.User) []map[string]string {
+	variants := []map[string]string{nil}
+	names := make([]string, 0, len(required))
+	for name, ps := range required {
+		if _, ok := objectUser.Fields[name]; !ok && len(ps.enum) > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var next []map[string]string
+		for _, v := range variants {
+			for _, e := range required[name].enum {
+				next = append(next, withValue(v, name, e))
+			}
+		}
+		variants = next
+	}
+	return variants
+}
+
+// withValue returns a copy of m with key set to value.
+func withValue(m map[string]string, key, value string) map[string]string {
+	out := make(map[string]string, len(m)+1)
+	for k, v := range m {
+		out[k] = v
+	}
+	out[key] = value
+	return out
 }
 
 func (r *Runner) eligibleUsers(required map[string]paramSpec) []testconfig.User {
@@ -349,8 +1039,9 @@ func (r *Runner) eligibleUsers(required map[string]paramSpec) []testconfig.User
 			if ps.In == "header" {
 				continue
 			}
-			// Body properties will be synthesized; do not require them to exist in the user's fields
-			if ps.In == "body" {
+			// Body properties will be synthesized and enum path values tried in turn; do not
+			// require them to exist in the user's fields
+			if ps.In == "body" || len(ps.enum) > 0 {
 				continue
 			}
 			if _, exists := u.Fields[name]; !exists {
@@ -376,10 +1067,13 @@ func (r *Runner) sendOne(
 	required map[string]paramSpec,
 ) (Exchange, ResponseDetails, error) {
 	var ex Exchange
+	if err := r.checkpoint(ctx); err != nil {
+		return ex, ResponseDetails{}, err
+	}
 	// Build URL
-	resolvedPath, pathParams := substitutePathParams(path, objectUser.Fields)
-	if strings.Contains(resolvedPath, "{") {
-		return ex, ResponseDetails{}, fmt.Errorf("missing required path params for %s", path)
+	resolvedPath, pathParams, missing := substitutePathParams(path, objectUser.Fields, r.reservedPathParams(op, item))
+	if len(missing) > 0 {
+		return ex, ResponseDetails{}, &missingPathParamsError{names: missing}
 	}
 
 	u, err := url.Parse(strings.TrimRight(r.BaseURL, "/") + resolvedPath)
@@ -402,6 +1096,11 @@ func (r *Runner) sendOne(
 			}
 		}
 	}
+	for k, v := range r.ExtraQuery {
+		if !q.Has(k) {
+			q.Set(k, v)
+		}
+	}
 	u.RawQuery = q.Encode()
 
 	// Headers
@@ -415,7 +1114,38 @@ func (r *Runner) sendOne(
 	} else if credUser.Auth.Type == "cookie" {
 		headers["Cookie"] = credUser.Auth.Value
 	}
-	headers["Accept"] = "application/json"
+	headers["User-Agent"] = r.UserAgent
+	if headers["User-Agent"] == "" {
+		headers["User-Agent"] = DefaultUserAgent()
+	}
+	headers["Accept"] = acceptHeaderFor(op)
+	// Set explicitly so the transport leaves decoding to decodeBody, which also handles deflate
+	headers["Accept-Encoding"] = "gzip, deflate"
+	if ov, ok := r.Config.OverrideFor(method, path); ok && ov.Accept != "" {
+		headers["Accept"] = ov.Accept
+	}
+	for k, v := range r.pair.extraHeaders {
+		headers[k] = v
+	}
+
+	// Cookie params from objectUser fields, merged with any auth cookie
+	var cookies []string
+	for _, p := range allParams {
+		if p == nil || p.Value == nil || p.Value.In != "cookie" {
+			continue
+		}
+		if v, ok := objectUser.Fields[p.Value.Name]; ok {
+			cookies = append(cookies, (&http.Cookie{Name: p.Value.Name, Value: v}).String())
+		} else if p.Value.Required {
+			return ex, ResponseDetails{}, fmt.Errorf("missing required cookie param %s", p.Value.Name)
+		}
+	}
+	if len(cookies) > 0 {
+		if existing := headers["Cookie"]; existing != "" {
+			cookies = append([]string{existing}, cookies...)
+		}
+		headers["Cookie"] = strings.Join(cookies, "; ")
+	}
 
 	// Set required header params from objectUser fields if not already set
 	for _, p := range allParams {
@@ -434,22 +1164,82 @@ func (r *Runner) sendOne(
 	// Body
 	var bodyBytes []byte
 	var body any
-	if op.RequestBody != nil {
-		if mt, ok := op.RequestBody.Value.Content["application/json"]; ok {
+	var reqNotes []string
+	var jsonSchema *openapi3.SchemaRef // of a JSON body built from the spec, which may be perturbed
+	if ov, ok := r.Config.OverrideFor(method, path); ok && ov.BodyTemplate != "" {
+		raw, rendered, err := ov.RenderBody(testconfig.TemplateData{
+			Object:     objectUser.Fields,
+			Creds:      credUser.Fields,
+			ObjectUser: objectUser.Name,
+			CredUser:   credUser.Name,
+		})
+		if err != nil {
+			return ex, ResponseDetails{}, fmt.Errorf("render body_template: %w", err)
+		}
+		// The template's bytes go out verbatim, keeping its key order, number formatting and
+		// duplicate keys; the parsed value is only logged
+		body = rendered
+		bodyBytes = raw
+		headers["Content-Type"] = "application/json"
+		if op.RequestBody != nil && op.RequestBody.Value != nil {
+			if ct, _, ok := jsonContent(op.RequestBody.Value.Content); ok {
+				headers["Content-Type"] = ct
+			}
+		}
+	} else if op.RequestBody != nil {
+		if ct, mt, ok := jsonContent(op.RequestBody.Value.Content); ok && r.pair.example != nil && r.pair.example.op == op {
+			jsonSchema = mt.Schema
+			body = r.exampleBody(r.pair.example, mt.Schema, objectUser.Fields)
+			var err error
+			if bodyBytes, err = json.Marshal(body); err == nil {
+				headers["Content-Type"] = ct
+			}
+		} else if form, isForm := multipartContent(op.RequestBody.Value.Content); !ok && isForm && form.Schema != nil {
+			var notes []string
+			var ct string
+			body, bodyBytes, ct, notes, err = r.buildMultipartBody(form.Schema, objectUser.Fields)
+			if err != nil {
+				return ex, ResponseDetails{}, fmt.Errorf("build multipart body: %w", err)
+			}
+			reqNotes = append(reqNotes, notes...)
+			headers["Content-Type"] = ct
+			reqNotes = append(reqNotes, "multipart/form-data body; logged as its fields")
+		} else if ok && r.usesMinimalPatch(method, path, op) {
+			jsonSchema = mt.Schema
+			var notes []string
+			body, notes = r.minimalPatchBody(mt.Schema, objectUser.Fields)
+			reqNotes = append(reqNotes, notes...)
+			var err error
+			if bodyBytes, err = json.Marshal(body); err == nil {
+				headers["Content-Type"] = ct
+			}
+		} else if ok {
+			jsonSchema = mt.Schema
 			if mt.Schema != nil {
 				// Build a dummy JSON body following the schema, with user field overrides when available
-				body = r.buildJSONBodyFromSchema(mt.Schema, objectUser.Fields)
+				var notes []string
+				body, notes = r.buildJSONBodyFromSchema(mt.Schema, objectUser.Fields)
+				reqNotes = append(reqNotes, notes...)
 				if body != nil {
 					var err error
 					bodyBytes, err = json.Marshal(body)
 					if err == nil {
-						headers["Content-Type"] = "application/json"
+						headers["Content-Type"] = ct
 					}
 				}
 			}
 		}
 	}
 
+	if r.pair.perturbWrite && jsonSchema != nil && bodyBytes != nil {
+		if perturbed, name := r.perturbBody(body, jsonSchema, objectUser.Fields, allParams); name != "" {
+			if b, err := json.Marshal(perturbed); err == nil {
+				body, bodyBytes = perturbed, b
+				reqNotes = append(reqNotes, fmt.Sprintf("%s differs from the control's so the write can be told apart", name))
+			}
+		}
+	}
+
 	// Emit request prepared event before sending
 	preparedReqDetails := RequestDetails{
 		Method:      strings.ToUpper(method),
@@ -459,6 +1249,19 @@ func (r *Runner) sendOne(
 		QueryParams: queryToMap(u.Query()),
 		Body:        body,
 		AuthUser:    credUser.Name,
+		Notes:       reqNotes,
+	}
+	if err := r.checkPlanned(preparedReqDetails, credUser); err != nil {
+		return ex, ResponseDetails{}, err
+	}
+	// A request that may not be sent is not worth the operator's confirmation
+	if err := r.CheckHost(u); err != nil {
+		return ex, ResponseDetails{}, err
+	}
+	if credUser.Name != objectUser.Name {
+		if err := r.confirmWrite(ctx, strings.ToUpper(method), path, preparedReqDetails); err != nil {
+			return ex, ResponseDetails{}, err
+		}
 	}
 	r.emitEvent(Event{Kind: EventRequestPrepared, Method: strings.ToUpper(method), Endpoint: path, Request: preparedReqDetails, Completed: r.CompletedRequests, Total: r.TotalRequests})
 
@@ -470,19 +1273,78 @@ func (r *Runner) sendOne(
 		req.Header.Set(k, v)
 	}
 
+	if r.plan != nil {
+		r.recordPlanned(path, preparedReqDetails, objectUser, credUser)
+		respDet := plannedResponse()
+		return Exchange{Request: preparedReqDetails, Response: respDet}, respDet, nil
+	}
+	limits := r.concurrencyLimits(method, path, credUser)
+	r.acquireLimits(limits)
+	defer r.releaseLimits(limits)
+	if err := r.pace(ctx, method, path, credUser); err != nil {
+		return ex, ResponseDetails{}, err
+	}
+	// Signed query parameters are made last so they are fresh when the request leaves;
+	// plans match the request without them
+	if s := credUser.Auth.Signing; credUser.Auth.Type == testconfig.AuthSignedQuery && s != nil {
+		if err := s.Sign(ctx, req.Method, req.URL, time.Now()); err != nil {
+			return ex, ResponseDetails{}, fmt.Errorf("sign query for %s: %w", credUser.Name, err)
+		}
+		preparedReqDetails.URL = req.URL.String()
+		preparedReqDetails.QueryParams = queryToMap(req.URL.Query())
+	}
+	if r.RecordRaw {
+		if dump, err := httputil.DumpRequestOut(req, true); err == nil {
+			preparedReqDetails.Raw = string(dump)
+		}
+	}
+	throttle := r.acquireSlot(req.URL.Host)
 	start := time.Now()
-	resp, err := client.Do(req)
+	preparedReqDetails.SentAt = start
+	var (
+		resp    *http.Response
+		b       []byte
+		elapsed time.Duration
+	)
+	r.unlocked(func() {
+		if resp, err = client.Do(req); err == nil {
+			b, _ = io.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+		elapsed = time.Since(start)
+	})
 	var respDet ResponseDetails
 	if err != nil {
+		r.releaseSlot(ctx, req.URL.Host, throttle, 0, err)
+		if r.Tap != nil {
+			r.Tap(preparedReqDetails, ResponseDetails{Notes: []string{fmt.Sprintf("request failed: %v", err)}})
+		}
 		return ex, respDet, err
 	}
-	defer resp.Body.Close()
-	b, _ := io.ReadAll(resp.Body)
+	r.releaseSlot(ctx, req.URL.Host, throttle, resp.StatusCode, nil)
+	r.recordTraffic(strings.ToUpper(method)+" "+path, credUser.Name, req, len(bodyBytes), resp, len(b))
+	var respNotes []string
+	respHeader := resp.Header.Clone()
+	if enc := resp.Header.Get("Content-Encoding"); enc != "" && len(b) > 0 {
+		decoded, err := decodeBody(enc, b)
+		if err != nil {
+			respNotes = append(respNotes, fmt.Sprintf("could not decode %s response body, kept raw bytes: %v", enc, err))
+		} else {
+			b = decoded
+			// The logged headers describe the decoded body, as net/http's own decompression does
+			respHeader.Del("Content-Encoding")
+			respHeader.Del("Content-Length")
+		}
+	}
 	respDet = ResponseDetails{
-		Status:     resp.StatusCode,
-		Headers:    simplifyHeaders(resp.Header),
-		Body:       string(b),
-		DurationMs: time.Since(start).Milliseconds(),
+		Status:       resp.StatusCode,
+		Headers:      simplifyHeaders(respHeader),
+		HeaderValues: map[string][]string(respHeader),
+		Body:         string(b),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		DurationMs:   elapsed.Milliseconds(),
+		Notes:        respNotes,
 	}
 
 	ex = Exchange{
@@ -490,9 +1352,16 @@ func (r *Runner) sendOne(
 		Response: respDet,
 	}
 
+	if r.Tap != nil {
+		r.Tap(preparedReqDetails, respDet)
+	}
+	// The tap has seen the body; from here on a large one lives on disk
+	r.spillBody(&respDet)
+	ex.Response = respDet
+
 	// Update completed requests and emit progress
 	r.CompletedRequests++
-	r.emitEvent(Event{Kind: EventRequestCompleted, Completed: r.CompletedRequests, Total: r.TotalRequests})
+	r.emitEvent(Event{Kind: EventRequestCompleted, Completed: r.CompletedRequests, Total: r.TotalRequests, Status: respDet.Status, DurationMs: respDet.DurationMs})
 
 	return ex, respDet, nil
 }
@@ -526,35 +1395,175 @@ func operationsFor(item *openapi3.PathItem) map[string]*openapi3.Operation {
 	return m
 }
 
+// acceptHeaderFor builds an Accept header from the content types declared in the
+// operation's responses. A JSON type is preferred when present: application/json itself,
+// then application/json with parameters such as a charset, then a +json type, the first in
+// sorted order within each. Otherwise all declared types are listed in sorted order, and
+// */* is used when nothing is declared.
+func acceptHeaderFor(op *openapi3.Operation) string {
+	if op == nil || op.Responses == nil {
+		return "*/*"
+	}
+	seen := map[string]struct{}{}
+	var types []string
+	for _, resp := range op.Responses.Map() {
+		if resp == nil || resp.Value == nil {
+			continue
+		}
+		for ct := range resp.Value.Content {
+			if _, ok := seen[ct]; ok {
+				continue
+			}
+			seen[ct] = struct{}{}
+			types = append(types, ct)
+		}
+	}
+	if len(types) == 0 {
+		return "*/*"
+	}
+	sort.Strings(types)
+	best, bestRank := "", 3
+	for _, ct := range types {
+		if rank := jsonAcceptRank(ct); rank < bestRank {
+			best, bestRank = ct, rank
+		}
+	}
+	if best != "" {
+		return best
+	}
+	return strings.Join(types, ", ")
+}
+
+// jsonAcceptRank orders JSON media types for acceptHeaderFor: 0 for application/json, 1
+// for application/json with parameters, 2 for a +json type and 3 for anything else.
+func jsonAcceptRank(ct string) int {
+	mt, _, err := mime.ParseMediaType(ct)
+	switch {
+	case err != nil:
+		return 3
+	case ct == "application/json":
+		return 0
+	case mt == "application/json":
+		return 1
+	case strings.HasSuffix(mt, "+json"):
+		return 2
+	}
+	return 3
+}
+
+// mergeParams returns an operation's parameters: the path item's (a) followed by the
+// operation's own (b). An operation parameter with the same name and location as a path
+// item parameter overrides it, as OpenAPI specifies, so the path item's is left out.
+// Every reader of an operation's parameters goes through here.
 func mergeParams(a, b openapi3.Parameters) openapi3.Parameters {
+	type key struct{ in, name string }
+	overridden := map[key]bool{}
+	for _, p := range b {
+		if p != nil && p.Value != nil {
+			overridden[key{p.Value.In, p.Value.Name}] = true
+		}
+	}
 	var out openapi3.Parameters
-	out = append(out, a...)
-	out = append(out, b...)
-	return out
+	for _, p := range a {
+		if p != nil && p.Value != nil && overridden[key{p.Value.In, p.Value.Name}] {
+			continue
+		}
+		out = append(out, p)
+	}
+	return append(out, b...)
 }
 
-func substitutePathParams(path string, fields map[string]string) (string, map[string]string) {
-	out := path
+// substitutePathParams replaces every {placeholder} in path that has a value in fields.
+// Names in allowReserved keep reserved characters such as "/" unencoded (see encodePathValue).
+// It returns the resolved path, the values used, and the names left unresolved, in order of appearance.
+// The template is scanned rather than the output, so values containing braces cannot corrupt it.
+func substitutePathParams(path string, fields map[string]string, allowReserved map[string]bool) (string, map[string]string, []string) {
+	var out strings.Builder
 	used := map[string]string{}
+	var missing []string
+	remaining := path
 	for {
-		start := strings.Index(out, "{")
+		start := strings.Index(remaining, "{")
 		if start == -1 {
 			break
 		}
-		end := strings.Index(out[start:], "}")
+		end := strings.Index(remaining[start:], "}")
 		if end == -1 {
 			break
 		}
 		end = start + end
-		name := out[start+1 : end]
+		name := remaining[start+1 : end]
+		out.WriteString(remaining[:start])
 		if v, ok := fields[name]; ok {
 			used[name] = v
-			out = out[:start] + url.PathEscape(v) + out[end+1:]
+			out.WriteString(encodePathValue(v, allowReserved[name]))
 		} else {
-			break
+			missing = append(missing, name)
+			out.WriteString(remaining[start : end+1])
+		}
+		remaining = remaining[end+1:]
+	}
+	out.WriteString(remaining)
+	return out.String(), used, missing
+}
+
+// encodePathValue percent-encodes a path parameter value like url.PathEscape does, except
+// that valid %XX sequences are kept as they are, so pre-encoded input is not double-encoded;
+// a "%" not starting one becomes "%25". When allowReserved is set, reserved characters
+// (RFC 3986 gen-delims and sub-delims other than "?" and "#") are kept too, as OpenAPI's
+// allowReserved specifies.
+func encodePathValue(v string, allowReserved bool) string {
+	var b strings.Builder
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		switch {
+		case c == '%' && i+2 < len(v) && isHex(v[i+1]) && isHex(v[i+2]):
+			b.WriteString(v[i : i+3])
+			i += 2
+		case isUnreserved(c):
+			b.WriteByte(c)
+		case allowReserved && strings.IndexByte(":/[]@!$&'()*+,;=", c) >= 0:
+			b.WriteByte(c)
+		case !allowReserved && strings.IndexByte(":@$&+=", c) >= 0:
+			// url.PathEscape leaves these alone within a segment, but escapes !'()*
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+// reservedPathParams returns the path parameters whose values may keep reserved characters,
+// either because the spec sets allowReserved or the config lists them in allow_reserved_fields.
+func (r *Runner) reservedPathParams(op *openapi3.Operation, item *openapi3.PathItem) map[string]bool {
+	out := map[string]bool{}
+	for _, name := range r.Config.AllowReservedFields {
+		out[name] = true
+	}
+	for _, p := range mergeParams(item.Parameters, op.Parameters) {
+		if p != nil && p.Value != nil && p.Value.In == "path" && p.Value.AllowReserved {
+			out[p.Value.Name] = true
 		}
 	}
-	return out, used
+	return out
+}
+
+// missingPathParamsError reports path placeholders the object user has no value for.
+type missingPathParamsError struct {
+	names []string
+}
+
+func (e *missingPathParamsError) Error() string {
+	return "missing path params: " + strings.Join(e.names, ", ")
 }
 
 func queryToMap(v url.Values) map[string]string {
@@ -565,6 +1574,34 @@ func queryToMap(v url.Values) map[string]string {
 	return m
 }
 
+// decodeBody reverses a gzip or deflate Content-Encoding; identity is returned unchanged
+// and any other encoding is an error.
+func decodeBody(encoding string, raw []byte) ([]byte, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		return io.ReadAll(zr)
+	case "deflate":
+		// Most servers send zlib-wrapped deflate; fall back to raw DEFLATE for the rest.
+		if zr, err := zlib.NewReader(bytes.NewReader(raw)); err == nil {
+			defer zr.Close()
+			if out, err := io.ReadAll(zr); err == nil {
+				return out, nil
+			}
+		}
+		fr := flate.NewReader(bytes.NewReader(raw))
+		defer fr.Close()
+		return io.ReadAll(fr)
+	case "identity":
+		return raw, nil
+	}
+	return nil, fmt.Errorf("unsupported encoding")
+}
+
 func simplifyHeaders(h http.Header) map[string]string {
 	m := map[string]string{}
 	for k, vs := range h {
@@ -575,28 +1612,177 @@ func simplifyHeaders(h http.Header) map[string]string {
 	return m
 }
 
-func bodiesLikelyEqual(a, b string) bool {
-	as := strings.TrimSpace(a)
-	bs := strings.TrimSpace(b)
-	if as == bs {
+func prefixNotes(prefix string, notes []string) []string {
+	out := make([]string, 0, len(notes))
+	for _, n := range notes {
+		out = append(out, prefix+": "+n)
+	}
+	return out
+}
+
+// bodiesLikelyEqual compares two response bodies. When both responses declare a JSON
+// Content-Type the bodies are compared after JSON normalization with the ignore fields
+// removed (see stripFields); anything else, such as an HTML login page, is compared as
+// trimmed text. Spilled bodies are streamed from disk.
+func bodiesLikelyEqual(a, b ResponseDetails, ignore []string) bool {
+	if sameTrimmedBody(a, b) {
 		return true
 	}
-	var aj, bj any
-	if json.Unmarshal([]byte(as), &aj) == nil && json.Unmarshal([]byte(bs), &bj) == nil {
-		ajb, _ := json.Marshal(aj)
-		bjb, _ := json.Marshal(bj)
+	if !isJSONMediaType(a.Headers["Content-Type"]) || !isJSONMediaType(b.Headers["Content-Type"]) {
+		return false
+	}
+	aj, aok := decodeBodyJSON(a)
+	bj, bok := decodeBodyJSON(b)
+	if aok && bok {
+		ajb, _ := json.Marshal(stripFields(aj, ignore))
+		bjb, _ := json.Marshal(stripFields(bj, ignore))
 		return bytes.Equal(ajb, bjb)
 	}
 	return false
 }
 
-func bodySuggestsLeakedData(body string, identifiers map[string]string) bool {
-	lower := strings.ToLower(body)
-	for _, v := range identifiers {
-		if v == "" {
+// isJSONMediaType reports whether a Content-Type value is application/json or a +json type.
+func isJSONMediaType(contentType string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mt == "application/json" || strings.HasSuffix(mt, "+json")
+}
+
+// jsonContent returns the first JSON media type declared in content (application/json with
+// or without parameters, or a +json vendor type) and its content type, preferring a plain
+// application/json entry.
+func jsonContent(content openapi3.Content) (string, *openapi3.MediaType, bool) {
+	if mt, ok := content["application/json"]; ok && mt != nil {
+		return "application/json", mt, true
+	}
+	types := make([]string, 0, len(content))
+	for ct, mt := range content {
+		if mt != nil && isJSONMediaType(ct) {
+			types = append(types, ct)
+		}
+	}
+	if len(types) == 0 {
+		return "", nil, false
+	}
+	sort.Strings(types)
+	return types[0], content[types[0]], true
+}
+
+// bodySuggestsLeakedData reports whether the body mentions one of the object's
+// identifiers, ignoring case.
+func bodySuggestsLeakedData(resp ResponseDetails, identifiers map[string]string) bool {
+	return bodyContainsAny(resp, identifiers)
+}
+
+// transportHeaders describe the message rather than the object, so they are not scanned
+// for leaked identifiers; a numeric id would match a Content-Length or a Date by chance.
+var transportHeaders = map[string]bool{
+	"Accept-Ranges": true, "Age": true, "Cache-Control": true, "Connection": true,
+	"Content-Length": true, "Content-Type": true, "Date": true, "Expires": true,
+	"Keep-Alive": true, "Server": true, "Transfer-Encoding": true, "Vary": true,
+}
+
+// headersWithIdentifiers returns the sorted names of the headers with a value containing
+// one of identifiers as a whole token, e.g. the owner's id in a Link or Set-Cookie value.
+// Every value of a repeated header is scanned.
+func headersWithIdentifiers(values map[string][]string, identifiers map[string]string) []string {
+	var out []string
+	for name, vs := range values {
+		if transportHeaders[http.CanonicalHeaderKey(name)] {
+			continue
+		}
+	scan:
+		for _, v := range vs {
+			for _, id := range identifiers {
+				if id != "" && containsToken(strings.ToLower(v), strings.ToLower(id)) {
+					out = append(out, name)
+					break scan
+				}
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// containsToken reports whether s contains tok not directly preceded or followed by a
+// letter or digit, so "1" is found in "/notes/1" but not in "abc12".
+func containsToken(s, tok string) bool {
+	alnum := func(c byte) bool { return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' }
+	for i := 0; i+len(tok) <= len(s); {
+		j := strings.Index(s[i:], tok)
+		if j < 0 {
+			return false
+		}
+		j += i
+		end := j + len(tok)
+		if (j == 0 || !alnum(s[j-1])) && (end == len(s) || !alnum(s[end])) {
+			return true
+		}
+		i = j + 1
+	}
+	return false
+}
+
+// sensitiveHeaderKeys returns the header names, and the names of the cookies set by
+// Set-Cookie, that match patterns, as "Header" or "Set-Cookie: name", sorted.
+func sensitiveHeaderKeys(values map[string][]string, patterns []string) []string {
+	if len(patterns) == 0 {
+		return nil
+	}
+	var out []string
+	for name, vs := range values {
+		if keyMatchesAny(name, patterns) {
+			out = append(out, name)
+		}
+		if !strings.EqualFold(name, "Set-Cookie") {
 			continue
 		}
-		if strings.Contains(lower, strings.ToLower(v)) {
+		for _, v := range vs {
+			if cookie, _, ok := strings.Cut(v, "="); ok && keyMatchesAny(strings.TrimSpace(cookie), patterns) {
+				out = append(out, "Set-Cookie: "+strings.TrimSpace(cookie))
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// sensitiveKeysInBody returns the sorted, de-duplicated JSON object keys in the body that
+// match any of the given patterns. Matching is case-insensitive and supports "*"
+// wildcards. The body is streamed, so a spilled one is never read back whole.
+func sensitiveKeysInBody(resp ResponseDetails, patterns []string) []string {
+	if len(patterns) == 0 {
+		return nil
+	}
+	rc, err := resp.Open()
+	if err != nil {
+		return nil
+	}
+	defer rc.Close()
+	found := map[string]struct{}{}
+	valid := walkJSONKeys(rc, func(k string) {
+		if keyMatchesAny(k, patterns) {
+			found[k] = struct{}{}
+		}
+	})
+	if !valid {
+		return nil
+	}
+	out := make([]string, 0, len(found))
+	for k := range found {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func keyMatchesAny(key string, patterns []string) bool {
+	lower := strings.ToLower(key)
+	for _, p := range patterns {
+		if ok, err := pathpkg.Match(strings.ToLower(p), lower); err == nil && ok {
 			return true
 		}
 	}
@@ -605,13 +1791,12 @@ func bodySuggestsLeakedData(body string, identifiers map[string]string) bool {
 
 func (r *Runner) collectAllFieldNames() map[string]struct{} {
 	names := map[string]struct{}{}
-	for path, item := range r.Spec.Paths.Map() {
-		_ = path
+	for _, item := range r.Spec.Paths.Map() {
 		params := item.Parameters
 		for _, op := range operationsFor(item) {
-			params = append(params, op.Parameters...)
+			params = mergeParams(params, op.Parameters)
 			if op.RequestBody != nil {
-				if mt, ok := op.RequestBody.Value.Content["application/json"]; ok {
+				if _, mt, ok := jsonContent(op.RequestBody.Value.Content); ok {
 					if mt.Schema != nil && mt.Schema.Value != nil {
 						for prop := range mt.Schema.Value.Properties {
 							names[prop] = struct{}{}
@@ -680,18 +1865,96 @@ func userPairsForEligibleObjectUsers(eligible []testconfig.User, all []testconfi
 	return pairs
 }
 
+// BodyOptions tunes request body synthesis. The zero value keeps bodies minimal:
+// unlimited depth, single-item arrays and optional properties only when a user field matches.
+type BodyOptions struct {
+	// MaxDepth limits object nesting; deeper objects are emitted empty. 0 means unlimited.
+	MaxDepth int
+	// ArrayCap, when > 0, sizes arrays to minItems (at least 1, at most maxItems) capped at ArrayCap.
+	ArrayCap int
+	// IncludeOptional synthesizes optional properties even when no user field matches.
+	IncludeOptional bool
+}
+
+// bodyBuilder carries the options and bookkeeping for one body synthesis.
+type bodyBuilder struct {
+	spec *openapi3.T
+	opts BodyOptions
+	now  time.Time // base of synthesized date and time values
+	// include and null are the config's include_properties and null_properties.
+	include   []string
+	null      []string
+	truncated bool
+	// expanding holds the object and array schemas being synthesized, innermost last, so
+	// a schema that contains itself is emitted empty where it repeats; recursive names them.
+	expanding map[*openapi3.Schema]bool
+	recursive []string
+}
+
+// newBodyBuilder returns a bodyBuilder for one body synthesis with the run's options.
+func (r *Runner) newBodyBuilder() *bodyBuilder {
+	return &bodyBuilder{
+		spec:      r.Spec,
+		opts:      r.BodyOptions,
+		now:       r.now(),
+		include:   r.Config.IncludeProperties,
+		null:      r.Config.NullProperties,
+		expanding: map[*openapi3.Schema]bool{},
+	}
+}
+
 // buildJSONBodyFromSchema constructs a JSON value that satisfies the provided schema.
 // It prioritizes values in fields for matching property names and synthesizes the rest as needed.
-func (r *Runner) buildJSONBodyFromSchema(schema *openapi3.SchemaRef, fields map[string]string) any {
+// The notes explain where the depth limit or a recursive schema cut the body short.
+func (r *Runner) buildJSONBodyFromSchema(schema *openapi3.SchemaRef, fields map[string]string) (any, []string) {
+	b := r.newBodyBuilder()
+	v := b.build(schema, fields, 0)
+	return v, b.notes()
+}
+
+// notes describes how the synthesized body was cut short, if it was.
+func (b *bodyBuilder) notes() []string {
+	var notes []string
+	if b.truncated {
+		notes = append(notes, fmt.Sprintf("request body truncated at depth %d", b.opts.MaxDepth))
+	}
+	for _, name := range b.recursive {
+		notes = append(notes, fmt.Sprintf("recursive schema %s sent empty where it contains itself", name))
+	}
+	return notes
+}
+
+// enter marks s as being synthesized and reports true, or reports false when it already
+// is: schema refers to itself and the caller emits an empty value instead of recursing.
+func (b *bodyBuilder) enter(schema *openapi3.SchemaRef, s *openapi3.Schema) bool {
+	if b.expanding[s] {
+		name := schema.Ref
+		if name == "" {
+			name = "(inline)"
+		}
+		if !contains(b.recursive, name) {
+			b.recursive = append(b.recursive, name)
+		}
+		return false
+	}
+	b.expanding[s] = true
+	return true
+}
+
+func (b *bodyBuilder) leave(s *openapi3.Schema) {
+	delete(b.expanding, s)
+}
+
+func (b *bodyBuilder) build(schema *openapi3.SchemaRef, fields map[string]string, depth int) any {
 	if schema == nil {
 		return nil
 	}
 
 	// Resolve local component $ref like #/components/schemas/Type
 	if schema.Value == nil && schema.Ref != "" {
-		if name := localComponentName(schema.Ref); name != "" && r.Spec != nil {
-			if comp, ok := r.Spec.Components.Schemas[name]; ok {
-				return r.buildJSONBodyFromSchema(comp, fields)
+		if name := localComponentName(schema.Ref); name != "" && b.spec != nil {
+			if comp, ok := b.spec.Components.Schemas[name]; ok {
+				return b.build(comp, fields, depth)
 			}
 		}
 	}
@@ -705,13 +1968,13 @@ func (r *Runner) buildJSONBodyFromSchema(schema *openapi3.SchemaRef, fields map[
 
 	// Composition keywords: pick first schema as a heuristic
 	if len(s.OneOf) > 0 {
-		return r.buildJSONBodyFromSchema(s.OneOf[0], fields)
+		return b.build(s.OneOf[0], fields, depth)
 	}
 	if len(s.AnyOf) > 0 {
-		return r.buildJSONBodyFromSchema(s.AnyOf[0], fields)
+		return b.build(s.AnyOf[0], fields, depth)
 	}
 	if len(s.AllOf) > 0 {
-		return r.buildJSONBodyFromSchema(s.AllOf[0], fields)
+		return b.build(s.AllOf[0], fields, depth)
 	}
 
 	// Prefer explicit example/default/enum on non-object schemas
@@ -722,12 +1985,20 @@ func (r *Runner) buildJSONBodyFromSchema(schema *openapi3.SchemaRef, fields map[
 		if len(s.Enum) > 0 {
 			return s.Enum[0]
 		}
-		return r.generateDummyForSimple(schema)
+		return b.generateDummyForSimple(schema, depth)
 	}
 
 	// Object schema
 	if s.Type != nil && s.Type.Is("object") {
 		obj := map[string]any{}
+		if b.opts.MaxDepth > 0 && depth >= b.opts.MaxDepth {
+			b.truncated = true
+			return obj
+		}
+		if !b.enter(schema, s) {
+			return obj
+		}
+		defer b.leave(s)
 
 		// Add required properties
 		for _, reqName := range s.Required {
@@ -737,20 +2008,24 @@ func (r *Runner) buildJSONBodyFromSchema(schema *openapi3.SchemaRef, fields map[
 			}
 			propSchema, ok := s.Properties[reqName]
 			if ok {
-				obj[reqName] = r.buildJSONBodyFromSchema(propSchema, fields)
+				obj[reqName] = b.build(propSchema, fields, depth+1)
 			} else {
 				// Missing schema for required property: fallback to a string
 				obj[reqName] = "example"
 			}
 		}
 
-		// Add optional properties only if provided via fields
-		for name := range s.Properties {
+		// Add optional properties if provided via fields, or synthesized or null when configured
+		for name, propSchema := range s.Properties {
 			if contains(s.Required, name) {
 				continue
 			}
 			if v, ok := fields[name]; ok {
 				obj[name] = v
+			} else if v, ok := b.forcedProperty(name, propSchema, fields, depth); ok {
+				obj[name] = v
+			} else if b.opts.IncludeOptional {
+				obj[name] = b.build(propSchema, fields, depth+1)
 			}
 		}
 
@@ -767,18 +2042,40 @@ func (r *Runner) buildJSONBodyFromSchema(schema *openapi3.SchemaRef, fields map[
 	return "example"
 }
 
+// forcedProperty returns the value of an optional property named in include_properties
+// or null_properties: null when listed in null_properties and the schema permits it,
+// otherwise a synthesized value. ok is false for properties in neither list.
+func (b *bodyBuilder) forcedProperty(name string, schema *openapi3.SchemaRef, fields map[string]string, depth int) (any, bool) {
+	if contains(b.null, name) && schema != nil && schema.Value != nil && schema.Value.PermitsNull() {
+		return nil, true
+	}
+	if contains(b.null, name) || contains(b.include, name) {
+		return b.build(schema, fields, depth+1), true
+	}
+	return nil, false
+}
+
 // generateDummyForSimple produces a simple dummy value for non-object schemas (string/number/integer/boolean/array).
-func (r *Runner) generateDummyForSimple(schema *openapi3.SchemaRef) any {
+func (b *bodyBuilder) generateDummyForSimple(schema *openapi3.SchemaRef, depth int) any {
 	if schema == nil || schema.Value == nil || schema.Value.Type == nil {
 		return "example"
 	}
 	s := schema.Value
-	// Arrays: produce a single-item array
+	// Arrays: a single item unless sized by ArrayCap
 	if s.Type.Is("array") {
-		if s.Items != nil {
-			return []any{r.buildJSONBodyFromSchema(s.Items, map[string]string{})}
+		if !b.enter(schema, s) {
+			return []any{}
+		}
+		defer b.leave(s)
+		items := make([]any, b.arrayLen(s))
+		for i := range items {
+			if s.Items != nil {
+				items[i] = b.build(s.Items, map[string]string{}, depth+1)
+			} else {
+				items[i] = "example"
+			}
 		}
-		return []any{"example"}
+		return items
 	}
 	if s.Type.Is("boolean") {
 		return true
@@ -790,7 +2087,25 @@ func (r *Runner) generateDummyForSimple(schema *openapi3.SchemaRef) any {
 		return 1.0
 	}
 	// string and others
-	return generateStringForFormat(s.Format, s.MinLength)
+	return generateStringForFormat(s.Format, s.MinLength, b.now)
+}
+
+// arrayLen returns how many items to synthesize for an array schema.
+func (b *bodyBuilder) arrayLen(s *openapi3.Schema) int {
+	if b.opts.ArrayCap <= 0 {
+		return 1
+	}
+	n := uint64(1)
+	if s.MinItems > n {
+		n = s.MinItems
+	}
+	if s.MaxItems != nil && n > *s.MaxItems {
+		n = *s.MaxItems
+	}
+	if n > uint64(b.opts.ArrayCap) {
+		n = uint64(b.opts.ArrayCap)
+	}
+	return int(n)
 }
 
 func firstNonNil(values ...any) any {
@@ -811,18 +2126,18 @@ func contains(list []string, v string) bool {
 	return false
 }
 
-func generateStringForFormat(format string, minLen uint64) string {
+func generateStringForFormat(format string, minLen uint64, now time.Time) string {
 	switch strings.ToLower(format) {
 	case "email":
 		return "user@example.com"
 	case "uuid":
 		return "123e4567-e89b-12d3-a456-426614174000"
 	case "date-time":
-		return time.Now().UTC().Format(time.RFC3339)
+		return now.UTC().Format(time.RFC3339)
 	case "date":
-		return time.Now().UTC().Format("2006-01-02")
+		return now.UTC().Format("2006-01-02")
 	case "time":
-		return time.Now().UTC().Format("15:04:05Z07:00")
+		return now.UTC().Format("15:04:05Z07:00")
 	case "uri":
 		return "https://example.com/resource"
 	case "hostname":
@@ -831,6 +2146,11 @@ func generateStringForFormat(format string, minLen uint64) string {
 		return "203.0.113.10"
 	case "ipv6":
 		return "2001:db8::1"
+	case "byte":
+		return base64.StdEncoding.EncodeToString([]byte(binaryPlaceholder))
+	case "binary":
+		// Raw bytes; in multipart bodies the property becomes a file part instead
+		return binaryPlaceholder
 	}
 	// default string; meet minimum length if specified
 	if minLen > 0 {
@@ -864,9 +2184,39 @@ func operationRequiresAuth(doc *openapi3.T, op *openapi3.Operation) bool {
 	return len(doc.Security) > 0
 }
 
+// operationSelected applies the OnlyOperations and ExcludeOperations filters to op's operationId,
+// and in DeprecatedOnly mode drops operations that are not deprecated.
+func (r *Runner) operationSelected(op *openapi3.Operation) bool {
+	if r.Deprecated == DeprecatedOnly && !op.Deprecated {
+		return false
+	}
+	if len(r.OnlyOperations) > 0 && !contains(r.OnlyOperations, op.OperationID) {
+		return false
+	}
+	return !contains(r.ExcludeOperations, op.OperationID)
+}
+
+// hasSuccessResponse reports whether the operation documents any 2xx (or 2XX) response.
+func hasSuccessResponse(op *openapi3.Operation) bool {
+	if op == nil || op.Responses == nil {
+		return false
+	}
+	for code := range op.Responses.Map() {
+		if len(code) == 3 && code[0] == '2' {
+			return true
+		}
+	}
+	return false
+}
+
 // operationReferencesUserFields returns true if the path placeholders, query/header parameters, or request body properties
 // reference any field keys present in the provided user's fields.
 func operationReferencesUserFields(path string, op *openapi3.Operation, item *openapi3.PathItem, user testconfig.User) bool {
+	// A marked ownership parameter is the only identifier that counts
+	if name := objectParam(op, item); name != "" {
+		_, ok := user.Fields[name]
+		return ok
+	}
 	// Path placeholders
 	for _, name := range extractPathParamNames(path) {
 		if _, ok := user.Fields[name]; ok {
@@ -884,7 +2234,7 @@ func operationReferencesUserFields(path string, op *openapi3.Operation, item *op
 	}
 	// Request body JSON properties
 	if op.RequestBody != nil {
-		if mt, ok := op.RequestBody.Value.Content["application/json"]; ok {
+		if _, mt, ok := jsonContent(op.RequestBody.Value.Content); ok {
 			if mt.Schema != nil && mt.Schema.Value != nil {
 				for prop := range mt.Schema.Value.Properties {
 					if _, ok := user.Fields[prop]; ok {
@@ -925,38 +2275,24 @@ func extractPathParamNames(path string) []string {
 	return names
 }
 
-// EstimateTotalRequests returns the number of HTTP requests that will be attempted
-// (control + test) across all eligible endpoint/user pairs.
+// EstimateTotalRequests returns the number of HTTP requests the scan plans to send,
+// excluding retries and other requests that depend on responses.
 func (r *Runner) EstimateTotalRequests() int {
 	if r.Spec == nil {
 		return 0
 	}
-	total := 0
-	for path, item := range r.Spec.Paths.Map() {
-		ops := operationsFor(item)
-		for method, op := range ops {
-			if r.SkipDelete && strings.EqualFold(method, "DELETE") {
-				continue
-			}
-			if !operationRequiresAuth(r.Spec, op) {
-				continue
-			}
-			required := r.requiredParams(op, item)
-			eligible := r.eligibleUsers(required)
-			if len(r.Config.Users) < 2 || len(eligible) < 1 {
-				continue
-			}
-			for _, objectUser := range eligible {
-				if !operationReferencesUserFields(path, op, item, objectUser) {
-					continue
-				}
-				// For each eligible object user, pair with every other user as creds (control + test)
-				numCreds := len(r.Config.Users) - 1
-				if numCreds > 0 {
-					total += numCreds * 2
-				}
-			}
-		}
+	return r.estimateRequests(r.planOperations())
+}
+
+// requestsPerPair is how many requests one pair of an operation sends: control and
+// test, the owner's reads around a verified write, and the cleanup steps.
+func (r *Runner) requestsPerPair(method, path string, item *openapi3.PathItem) int {
+	n := 2
+	if _, verifyOp, _ := r.verificationTarget(method, path, item); verifyOp != nil {
+		n += 2 // owner reads before and after the attacker's write
+	}
+	if ov, ok := r.Config.OverrideFor(method, path); ok {
+		n += len(ov.Cleanup)
 	}
-	return total
+	return n
 }
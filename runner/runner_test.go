@@ -0,0 +1,1108 @@
+package runner
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/yansol0/aperture/testconfig"
+)
+
+func TestAcceptHeaderFor(t *testing.T) {
+	op := func(types ...string) *openapi3.Operation {
+		content := openapi3.Content{}
+		for _, ct := range types {
+			content[ct] = openapi3.NewMediaType()
+		}
+		resp := openapi3.NewResponse().WithDescription("OK").WithContent(content)
+		return &openapi3.Operation{Responses: openapi3.NewResponses(openapi3.WithStatus(200, &openapi3.ResponseRef{Value: resp}))}
+	}
+	tests := []struct {
+		name string
+		op   *openapi3.Operation
+		want string
+	}{
+		{"xml only", op("application/xml"), "application/xml"},
+		{"json preferred", op("application/xml", "application/json"), "application/json"},
+		{"several non-json", op("text/csv", "application/xml"), "application/xml, text/csv"},
+		{"json over variants", op("application/vnd.api+json", "application/json; charset=utf-8", "application/json"), "application/json"},
+		{"charset variant over +json", op("application/hal+json", "application/json; charset=utf-8", "text/html"), "application/json; charset=utf-8"},
+		{"first +json in order", op("application/vnd.b+json", "application/problem+json", "application/xml"), "application/problem+json"},
+		{"nothing declared", op(), "*/*"},
+		{"no responses", &openapi3.Operation{}, "*/*"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := acceptHeaderFor(tt.op); got != tt.want {
+				t.Errorf("acceptHeaderFor = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+const xmlExportSpec = `
+openapi: 3.0.3
+info: {title: export, version: "1"}
+security: [{ApiKeyAuth: []}]
+paths:
+  /notes/{note_id}/export:
+    parameters:
+      - {name: note_id, in: path, required: true, schema: {type: integer}}
+    get:
+      responses:
+        "200":
+          description: OK
+          content:
+            application/xml: {schema: {type: string}}
+components:
+  securitySchemes:
+    ApiKeyAuth: {type: apiKey, in: header, name: X-API-Key}
+`
+
+func TestXMLOnlyOperation(t *testing.T) {
+	api := newTestAPI(t)
+	var mu sync.Mutex
+	var accepts []string
+	api.Mux.HandleFunc("GET /notes/{note_id}/export", func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		accepts = append(accepts, req.Header.Get("Accept"))
+		mu.Unlock()
+		user, ok := api.user(w, req)
+		if !ok {
+			return
+		}
+		note, ok := api.note(w, req)
+		if !ok {
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		// The viewer element makes the attacker's body differ from the owner's
+		fmt.Fprintf(w, "<note><id>%d</id><owner>%s</owner><viewer>%s</viewer></note>", note.ID, note.Owner, user)
+	})
+	r := newTestRunner(api, parseTestSpec(t, xmlExportSpec))
+
+	results := execute(t, r)
+
+	if want := []string{"application/xml", "application/xml", "application/xml", "application/xml"}; !reflect.DeepEqual(accepts, want) {
+		t.Errorf("Accept headers = %q, want %q", accepts, want)
+	}
+	want := map[string]map[string]int{"GET /notes/{note_id}/export": {ResultIDORFound: 2}}
+	if got := verdicts(results); !reflect.DeepEqual(got, want) {
+		t.Errorf("verdicts = %v, want %v", got, want)
+	}
+}
+
+const encodedNoteSpec = `
+openapi: 3.0.3
+info: {title: encoded, version: "1"}
+security: [{ApiKeyAuth: []}]
+paths:
+  /notes/{note_id}/encoded:
+    parameters:
+      - {name: note_id, in: path, required: true, schema: {type: integer}}
+    get:
+      responses:
+        "200": {description: OK, content: {application/json: {schema: {type: object}}}}
+components:
+  securitySchemes:
+    ApiKeyAuth: {type: apiKey, in: header, name: X-API-Key}
+`
+
+// encodedNoteAPI serves GET /notes/{note_id}/encoded with the note compressed by encode
+// under the given Content-Encoding, recording the Accept-Encoding of each request.
+func encodedNoteAPI(t *testing.T, encoding string, encode func([]byte) []byte) (*testAPI, *[]string) {
+	api := newTestAPI(t)
+	var mu sync.Mutex
+	var accepted []string
+	api.Mux.HandleFunc("GET /notes/{note_id}/encoded", func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		accepted = append(accepted, req.Header.Get("Accept-Encoding"))
+		mu.Unlock()
+		if _, ok := api.user(w, req); !ok {
+			return
+		}
+		note, ok := api.note(w, req)
+		if !ok {
+			return
+		}
+		body, _ := json.Marshal(note)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Encoding", encoding)
+		_, _ = w.Write(encode(body))
+	})
+	return api, &accepted
+}
+
+func gzipBytes(b []byte) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, _ = zw.Write(b)
+	_ = zw.Close()
+	return buf.Bytes()
+}
+
+func TestGzipResponseDecoded(t *testing.T) {
+	api, accepted := encodedNoteAPI(t, "gzip", gzipBytes)
+	r := newTestRunner(api, parseTestSpec(t, encodedNoteSpec))
+
+	results := execute(t, r)
+
+	want := map[string]map[string]int{"GET /notes/{note_id}/encoded": {ResultIDORFound: 2}}
+	if got := verdicts(results); !reflect.DeepEqual(got, want) {
+		t.Errorf("verdicts = %v, want %v", got, want)
+	}
+	for _, ae := range *accepted {
+		if ae != "gzip, deflate" {
+			t.Errorf("Accept-Encoding = %q, want %q", ae, "gzip, deflate")
+		}
+	}
+	for _, res := range results {
+		if res.Endpoint == "-" {
+			continue
+		}
+		resp := res.Test.Response
+		if !strings.HasPrefix(resp.Body, `{"id":`) {
+			t.Errorf("test body not decoded: %q", resp.Body)
+		}
+		if resp.Headers["Content-Encoding"] != "" || resp.HeaderValues["Content-Encoding"] != nil {
+			t.Errorf("Content-Encoding kept after decoding: %v", resp.HeaderValues)
+		}
+		if len(resp.Notes) != 0 {
+			t.Errorf("unexpected response notes %q", resp.Notes)
+		}
+	}
+}
+
+func TestUndecodableResponseKeptRaw(t *testing.T) {
+	api, _ := encodedNoteAPI(t, "gzip", func(b []byte) []byte { return b })
+	r := newTestRunner(api, parseTestSpec(t, encodedNoteSpec))
+
+	results := execute(t, r)
+
+	for _, res := range results {
+		if res.Endpoint == "-" {
+			continue
+		}
+		resp := res.Control.Response
+		if !strings.HasPrefix(resp.Body, `{"id":`) || resp.Headers["Content-Encoding"] != "gzip" {
+			t.Errorf("raw body or header not kept: %q %v", resp.Body, resp.Headers)
+		}
+		if len(resp.Notes) != 1 || !strings.HasPrefix(resp.Notes[0], "could not decode gzip response body, kept raw bytes") {
+			t.Errorf("response notes = %q", resp.Notes)
+		}
+	}
+}
+
+func TestDecodeBody(t *testing.T) {
+	plain := []byte(`{"id":1}`)
+	var zlibBuf, flateBuf bytes.Buffer
+	zw := zlib.NewWriter(&zlibBuf)
+	_, _ = zw.Write(plain)
+	_ = zw.Close()
+	fw, _ := flate.NewWriter(&flateBuf, flate.DefaultCompression)
+	_, _ = fw.Write(plain)
+	_ = fw.Close()
+
+	tests := []struct {
+		encoding string
+		raw      []byte
+		wantErr  bool
+	}{
+		{"gzip", gzipBytes(plain), false},
+		{"X-Gzip", gzipBytes(plain), false},
+		{"deflate", zlibBuf.Bytes(), false},
+		{"deflate", flateBuf.Bytes(), false},
+		{"identity", plain, false},
+		{"br", plain, true},
+		{"gzip", plain, true},
+	}
+	for _, tt := range tests {
+		got, err := decodeBody(tt.encoding, tt.raw)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("decodeBody(%s) succeeded, want an error", tt.encoding)
+			}
+			continue
+		}
+		if err != nil || !bytes.Equal(got, plain) {
+			t.Errorf("decodeBody(%s) = %q, %v", tt.encoding, got, err)
+		}
+	}
+}
+
+const titleSpec = `
+openapi: 3.0.3
+info: {title: titles, version: "1"}
+security: [{ApiKeyAuth: []}]
+paths:
+  /notes/{note_id}/title:
+    parameters:
+      - {name: note_id, in: path, required: true, schema: {type: integer}}
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema: {type: object, properties: {title: {type: string}}}
+      responses:
+        "200": {description: OK}
+components:
+  securitySchemes:
+    ApiKeyAuth: {type: apiKey, in: header, name: X-API-Key}
+`
+
+func TestBodyTemplateSentVerbatim(t *testing.T) {
+	api := newTestAPI(t)
+	var mu sync.Mutex
+	var bodies []string
+	api.Mux.HandleFunc("POST /notes/{note_id}/title", func(w http.ResponseWriter, req *http.Request) {
+		b, _ := io.ReadAll(req.Body)
+		mu.Lock()
+		bodies = append(bodies, string(b))
+		mu.Unlock()
+		user, ok := api.user(w, req)
+		if !ok {
+			return
+		}
+		if note, ok := api.note(w, req); ok && note.Owner != user {
+			writeTestJSON(w, http.StatusForbidden, map[string]string{"error": "forbidden"})
+			return
+		}
+		writeTestJSON(w, http.StatusOK, map[string]bool{"ok": true})
+	})
+	r := newTestRunner(api, parseTestSpec(t, titleSpec))
+	r.AllowMutations = true
+	r.Config.EndpointOverrides = []testconfig.EndpointOverride{{
+		Method:       "POST",
+		Path:         "/notes/{note_id}/title",
+		BodyTemplate: `{"title": "x", "rank": 1.50, "id": {{.Object.note_id}}}`,
+	}}
+
+	results := execute(t, r)
+
+	if len(bodies) != 4 {
+		t.Fatalf("server received %d bodies, want 4", len(bodies))
+	}
+	for _, b := range bodies {
+		if b != `{"title": "x", "rank": 1.50, "id": 1}` && b != `{"title": "x", "rank": 1.50, "id": 2}` {
+			t.Errorf("body sent = %s, want the rendered template", b)
+		}
+	}
+	for _, res := range results {
+		if res.Endpoint == "-" {
+			continue
+		}
+		if body, ok := res.Control.Request.Body.(map[string]any); !ok || body["rank"] != 1.5 {
+			t.Errorf("logged body = %#v, want the parsed template", res.Control.Request.Body)
+		}
+	}
+}
+
+// TestVendorJSONBodySynthesized checks that a body declared only as a +json vendor type
+// is synthesized from its schema and sent with that Content-Type.
+func TestVendorJSONBodySynthesized(t *testing.T) {
+	api := newTestAPI(t)
+	var mu sync.Mutex
+	var sent []string
+	api.Mux.HandleFunc("POST /notes/{note_id}/title", func(w http.ResponseWriter, req *http.Request) {
+		b, _ := io.ReadAll(req.Body)
+		mu.Lock()
+		sent = append(sent, req.Header.Get("Content-Type")+" "+string(b))
+		mu.Unlock()
+		if _, ok := api.user(w, req); ok {
+			writeTestJSON(w, http.StatusOK, map[string]bool{"ok": true})
+		}
+	})
+	spec := strings.Replace(titleSpec, "application/json:", "application/vnd.notes.v2+json:", 1)
+	spec = strings.Replace(spec, "{type: object, properties: {title: {type: string}}}", "{type: object, required: [title], properties: {title: {type: string}}}", 1)
+	r := newTestRunner(api, parseTestSpec(t, spec))
+	r.AllowMutations = true
+
+	results := execute(t, r)
+
+	if len(sent) != 4 {
+		t.Fatalf("server received %d requests, want 4", len(sent))
+	}
+	for _, got := range sent {
+		if want := `application/vnd.notes.v2+json {"title":"example"}`; got != want {
+			t.Errorf("request sent = %s, want %s", got, want)
+		}
+	}
+	for _, res := range results {
+		if res.Endpoint == "-" {
+			continue
+		}
+		if body, ok := res.Control.Request.Body.(map[string]any); !ok || body["title"] != "example" {
+			t.Errorf("logged body = %#v, want the synthesized body", res.Control.Request.Body)
+		}
+	}
+}
+
+const bodySchemasSpec = `
+openapi: 3.0.3
+info: {title: bodies, version: "1"}
+paths: {}
+components:
+  schemas:
+    Node:
+      type: object
+      required: [name]
+      properties:
+        name: {type: string}
+        parent: {$ref: "#/components/schemas/Node"}
+        children: {type: array, items: {$ref: "#/components/schemas/Node"}}
+    Chain:
+      type: object
+      required: [next]
+      properties:
+        next: {$ref: "#/components/schemas/Link"}
+    Link:
+      type: object
+      required: [chain]
+      properties:
+        chain: {$ref: "#/components/schemas/Chain"}
+    Nested:
+      type: array
+      items: {$ref: "#/components/schemas/Nested"}
+    Order:
+      type: object
+      required: [lines, customer]
+      properties:
+        lines: {type: array, minItems: 3, maxItems: 5, items: {type: integer}}
+        customer:
+          type: object
+          required: [address]
+          properties:
+            address: {type: object, required: [city], properties: {city: {type: string}}}
+        note: {type: string}
+`
+
+// buildBody synthesizes the named component schema of bodySchemasSpec with opts.
+func buildBody(t *testing.T, name string, opts BodyOptions) (any, []string) {
+	t.Helper()
+	spec := parseTestSpec(t, bodySchemasSpec)
+	r := &Runner{Spec: spec, BodyOptions: opts}
+	return r.buildJSONBodyFromSchema(spec.Components.Schemas[name], map[string]string{})
+}
+
+func TestBuildBodyRecursiveSchemas(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  BodyOptions
+		want  any
+		notes []string
+	}{
+		{"Node", BodyOptions{}, map[string]any{"name": "example"}, nil},
+		{"Node", BodyOptions{IncludeOptional: true},
+			map[string]any{"name": "example", "parent": map[string]any{}, "children": []any{map[string]any{}}},
+			[]string{"recursive schema #/components/schemas/Node sent empty where it contains itself"}},
+		{"Chain", BodyOptions{},
+			map[string]any{"next": map[string]any{"chain": map[string]any{}}},
+			[]string{"recursive schema #/components/schemas/Chain sent empty where it contains itself"}},
+		{"Nested", BodyOptions{}, []any{[]any{}},
+			[]string{"recursive schema #/components/schemas/Nested sent empty where it contains itself"}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %+v", tt.name, tt.opts), func(t *testing.T) {
+			got, notes := buildBody(t, tt.name, tt.opts)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("body = %#v, want %#v", got, tt.want)
+			}
+			if !reflect.DeepEqual(notes, tt.notes) {
+				t.Errorf("notes = %q, want %q", notes, tt.notes)
+			}
+		})
+	}
+}
+
+func TestBuildBodyOptions(t *testing.T) {
+	got, notes := buildBody(t, "Order", BodyOptions{})
+	want := map[string]any{
+		"lines":    []any{1},
+		"customer": map[string]any{"address": map[string]any{"city": "example"}},
+	}
+	if !reflect.DeepEqual(got, want) || notes != nil {
+		t.Errorf("defaults: body = %#v, notes %q", got, notes)
+	}
+
+	got, notes = buildBody(t, "Order", BodyOptions{MaxDepth: 2, ArrayCap: 4, IncludeOptional: true})
+	want = map[string]any{
+		"lines":    []any{1, 1, 1},
+		"customer": map[string]any{"address": map[string]any{}},
+		"note":     "example",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("options: body = %#v, want %#v", got, want)
+	}
+	if wantNotes := []string{"request body truncated at depth 2"}; !reflect.DeepEqual(notes, wantNotes) {
+		t.Errorf("options: notes = %q, want %q", notes, wantNotes)
+	}
+
+	got, _ = buildBody(t, "Order", BodyOptions{ArrayCap: 2})
+	if lines := got.(map[string]any)["lines"]; !reflect.DeepEqual(lines, []any{1, 1}) {
+		t.Errorf("array capped at 2: lines = %#v", lines)
+	}
+}
+
+const profileSchemaSpec = `
+openapi: 3.0.3
+info: {title: profiles, version: "1"}
+paths: {}
+components:
+  schemas:
+    Profile:
+      type: object
+      required: [name]
+      properties:
+        name: {type: string}
+        locale: {type: string}
+        middle_name: {type: string, nullable: true}
+        archived_at: {type: string, format: date-time}
+        bio: {type: string}
+        manager: {$ref: "#/components/schemas/Profile"}
+`
+
+func TestForcedProperties(t *testing.T) {
+	spec := parseTestSpec(t, profileSchemaSpec)
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	r := &Runner{Spec: spec, bodyTime: now, Config: testconfig.Config{
+		IncludeProperties: []string{"locale", "manager"},
+		// archived_at is not nullable, so it is synthesized rather than sent as null
+		NullProperties: []string{"middle_name", "archived_at"},
+	}}
+	schema := spec.Components.Schemas["Profile"]
+	archived := now.Format(time.RFC3339)
+
+	got, notes := r.buildJSONBodyFromSchema(schema, map[string]string{"name": "Alice"})
+	// manager is a Profile inside the Profile being built, so it is cut there
+	want := map[string]any{"name": "Alice", "locale": "example", "middle_name": nil, "archived_at": archived, "manager": map[string]any{}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("body = %#v, want %#v", got, want)
+	}
+	if wantNotes := []string{"recursive schema #/components/schemas/Profile sent empty where it contains itself"}; !reflect.DeepEqual(notes, wantNotes) {
+		t.Errorf("notes = %q, want %q", notes, wantNotes)
+	}
+
+	patch, notes := r.minimalPatchBody(schema, map[string]string{"name": "Alice"})
+	if !reflect.DeepEqual(patch, want) {
+		t.Errorf("minimal PATCH body = %#v, want %#v", patch, want)
+	}
+	if wantNotes := []string{"minimal PATCH body: omitted bio", "recursive schema #/components/schemas/Profile sent empty where it contains itself"}; !reflect.DeepEqual(notes, wantNotes) {
+		t.Errorf("minimal PATCH notes = %q, want %q", notes, wantNotes)
+	}
+}
+
+func TestEncodePathValue(t *testing.T) {
+	tests := []struct {
+		v             string
+		allowReserved bool
+		want          string
+	}{
+		{"42", false, "42"},
+		{"a b", false, "a%20b"},
+		{"folders/2024", false, "folders%2F2024"},
+		{"100%", false, "100%25"},
+		{"a%2Fb", false, "a%2Fb"},
+		{"50%off", false, "50%25off"},
+		{"user:1@x", false, "user:1@x"},
+		{"it's(1)*!", false, "it%27s%281%29%2A%21"},
+		{"café", false, "caf%C3%A9"},
+		{"caf%C3%A9", false, "caf%C3%A9"},
+		{"a/b c", false, "a%2Fb%20c"},
+		{"a;b,c?d#e", false, "a%3Bb%2Cc%3Fd%23e"},
+		{"%zz%4", false, "%25zz%254"},
+		{"folders/2024/reports", true, "folders/2024/reports"},
+		{"a%2Fb", true, "a%2Fb"},
+		{"100%", true, "100%25"},
+		{"q?x#y", true, "q%3Fx%23y"},
+		{"it's(1)*!;b,c", true, "it's(1)*!;b,c"},
+		{"café/résumé", true, "caf%C3%A9/r%C3%A9sum%C3%A9"},
+		{"a b/c", true, "a%20b/c"},
+	}
+	for _, tt := range tests {
+		if got := encodePathValue(tt.v, tt.allowReserved); got != tt.want {
+			t.Errorf("encodePathValue(%q, %v) = %q, want %q", tt.v, tt.allowReserved, got, tt.want)
+		}
+	}
+	// Without a % the encoding is url.PathEscape's
+	for _, v := range []string{":@!$&'()*+,;=/?#[] é~", "a b/c"} {
+		if got, want := encodePathValue(v, false), url.PathEscape(v); got != want {
+			t.Errorf("encodePathValue(%q, false) = %q, url.PathEscape gives %q", v, got, want)
+		}
+	}
+}
+
+const filesSpec = `
+openapi: 3.0.3
+info: {title: files, version: "1"}
+security: [{ApiKeyAuth: []}]
+paths:
+  /files/{file_path}:
+    get:
+      parameters:
+        - {name: file_path, in: path, required: true, schema: {type: string}}
+      responses:
+        "200": {description: OK}
+  /tags/{tag}:
+    get:
+      parameters:
+        - {name: tag, in: path, required: true, schema: {type: string}}
+      responses:
+        "200": {description: OK}
+components:
+  securitySchemes:
+    ApiKeyAuth: {type: apiKey, in: header, name: X-API-Key}
+`
+
+func TestReservedPathParams(t *testing.T) {
+	api := newTestAPI(t)
+	var mu sync.Mutex
+	var paths []string
+	record := func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		paths = append(paths, req.URL.EscapedPath())
+		mu.Unlock()
+		if _, ok := api.user(w, req); ok {
+			writeTestJSON(w, http.StatusForbidden, map[string]string{"error": "forbidden"})
+		}
+	}
+	api.Mux.HandleFunc("GET /files/", record)
+	api.Mux.HandleFunc("GET /tags/", record)
+	r := newTestRunner(api, parseTestSpec(t, filesSpec))
+	r.Config.AllowReservedFields = []string{"file_path"}
+	r.Config.Users[0].Fields = map[string]string{"file_path": "alice/2024/q1%20report.pdf", "tag": "100%"}
+	r.Config.Users[1].Fields = map[string]string{"file_path": "bob/notes.txt", "tag": "a/b"}
+
+	execute(t, r)
+
+	sort.Strings(paths)
+	want := []string{
+		"/files/alice/2024/q1%20report.pdf", "/files/alice/2024/q1%20report.pdf",
+		"/files/bob/notes.txt", "/files/bob/notes.txt",
+		"/tags/100%25", "/tags/100%25",
+		"/tags/a%2Fb", "/tags/a%2Fb",
+	}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("paths sent = %q, want %q", paths, want)
+	}
+}
+
+func TestSubstitutePathParams(t *testing.T) {
+	tests := []struct {
+		path     string
+		fields   map[string]string
+		want     string
+		used     map[string]string
+		missing  []string
+		reserved map[string]bool
+	}{
+		{"/tenants/{tenant}/users/{user_id}", map[string]string{"tenant": "t1", "user_id": "7"},
+			"/tenants/t1/users/7", map[string]string{"tenant": "t1", "user_id": "7"}, nil, nil},
+		{"/tenants/{tenant}/users/{user_id}", map[string]string{"user_id": "7"},
+			"/tenants/{tenant}/users/7", map[string]string{"user_id": "7"}, []string{"tenant"}, nil},
+		{"/tenants/{tenant}/users/{user_id}", map[string]string{"tenant": "t1"},
+			"/tenants/t1/users/{user_id}", map[string]string{"tenant": "t1"}, []string{"user_id"}, nil},
+		{"/a/{x}/b/{y}/c/{z}", map[string]string{"y": "2"},
+			"/a/{x}/b/2/c/{z}", map[string]string{"y": "2"}, []string{"x", "z"}, nil},
+		{"/notes", map[string]string{"note_id": "1"}, "/notes", map[string]string{}, nil, nil},
+		// Values with braces are encoded and never rescanned as placeholders
+		{"/groups/{group}/items/{item}", map[string]string{"group": "{item}", "item": "}{"},
+			"/groups/%7Bitem%7D/items/%7D%7B", map[string]string{"group": "{item}", "item": "}{"}, nil, nil},
+		{"/files/{file_path}/versions/{v}", map[string]string{"file_path": "a/b.txt", "v": "1/2"},
+			"/files/a/b.txt/versions/1%2F2", map[string]string{"file_path": "a/b.txt", "v": "1/2"}, nil, map[string]bool{"file_path": true}},
+	}
+	for _, tt := range tests {
+		got, used, missing := substitutePathParams(tt.path, tt.fields, tt.reserved)
+		if got != tt.want || !reflect.DeepEqual(used, tt.used) || !reflect.DeepEqual(missing, tt.missing) {
+			t.Errorf("substitutePathParams(%q, %v) = %q, %v, %q; want %q, %v, %q", tt.path, tt.fields, got, used, missing, tt.want, tt.used, tt.missing)
+		}
+	}
+}
+
+const tenantUsersSpec = `
+openapi: 3.0.3
+info: {title: tenants, version: "1"}
+security: [{ApiKeyAuth: []}]
+paths:
+  /tenants/{tenant}/users/{user_id}:
+    get:
+      parameters:
+        - {name: tenant, in: path, required: true, schema: {type: string}}
+        - {name: user_id, in: path, required: true, schema: {type: string}}
+      responses:
+        "200": {description: OK}
+components:
+  securitySchemes:
+    ApiKeyAuth: {type: apiKey, in: header, name: X-API-Key}
+`
+
+func TestMissingPathParamsSkipReason(t *testing.T) {
+	api := newTestAPI(t)
+	r := newTestRunner(api, parseTestSpec(t, tenantUsersSpec))
+	// Both users have the second placeholder's value but not the first
+	r.Config.Users[0].Fields = map[string]string{"user_id": "alice"}
+	r.Config.Users[1].Fields = map[string]string{"user_id": "bob"}
+
+	results := execute(t, r)
+
+	if len(api.Requests()) != 0 {
+		t.Errorf("sent %q with a placeholder unresolved", api.Requests())
+	}
+	for _, res := range results {
+		if res.Endpoint == "-" {
+			continue
+		}
+		if res.Result != ResultSkipped || !strings.HasSuffix(res.SkippedReason, "missing tenant") {
+			t.Errorf("result %s skipped for %q, want a skip naming tenant", res.Result, res.SkippedReason)
+		}
+	}
+}
+
+// undeclaredParamsSpec declares no parameter objects; its placeholders are only in the paths.
+const undeclaredParamsSpec = `
+openapi: 3.0.3
+info: {title: undeclared, version: "1"}
+security: [{ApiKeyAuth: []}]
+paths:
+  /notes/{note_id}:
+    get:
+      responses:
+        "200": {description: OK}
+  /tenants/{tenant}/notes/{note_id}:
+    get:
+      responses:
+        "200": {description: OK}
+components:
+  securitySchemes:
+    ApiKeyAuth: {type: apiKey, in: header, name: X-API-Key}
+`
+
+func TestUndeclaredPathPlaceholders(t *testing.T) {
+	api := newTestAPI(t)
+	r := newTestRunner(api, parseTestSpec(t, undeclaredParamsSpec))
+
+	results := execute(t, r)
+
+	want := map[string]map[string]int{
+		"GET /notes/{note_id}":                  {ResultIDORFound: 2},
+		"GET /tenants/{tenant}/notes/{note_id}": {ResultSkipped: 1},
+	}
+	if got := verdicts(results); !reflect.DeepEqual(got, want) {
+		t.Fatalf("verdicts = %v, want %v", got, want)
+	}
+	res := resultFor(results, "/tenants/{tenant}/notes/{note_id}")
+	if !strings.HasSuffix(res.SkippedReason, "missing tenant") {
+		t.Errorf("skipped for %q, want a skip naming tenant", res.SkippedReason)
+	}
+	for _, req := range api.Requests() {
+		if strings.Contains(req, "/tenants/") {
+			t.Errorf("sent %q with a placeholder unresolved", req)
+		}
+	}
+}
+
+// pathLevelParamsSpec declares note_id and a required tenant query parameter on the path
+// item; the operation only makes tenant optional.
+const pathLevelParamsSpec = `
+openapi: 3.0.3
+info: {title: path-level, version: "1"}
+security: [{ApiKeyAuth: []}]
+paths:
+  /notes/{note_id}:
+    parameters:
+      - {name: note_id, in: path, required: true, schema: {type: integer}}
+      - {name: tenant, in: query, required: true, schema: {type: string}}
+    get:
+      parameters:
+        - {name: tenant, in: query, required: false, schema: {type: string}}
+      responses:
+        "200": {description: OK}
+components:
+  securitySchemes:
+    ApiKeyAuth: {type: apiKey, in: header, name: X-API-Key}
+`
+
+func TestMergeParamsOperationOverrides(t *testing.T) {
+	doc := parseTestSpec(t, pathLevelParamsSpec)
+	item := doc.Paths.Value("/notes/{note_id}")
+	var got []string
+	for _, p := range mergeParams(item.Parameters, item.Get.Parameters) {
+		got = append(got, fmt.Sprintf("%s %s required=%v", p.Value.In, p.Value.Name, p.Value.Required))
+	}
+	want := []string{"path note_id required=true", "query tenant required=false"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("merged params = %q, want %q", got, want)
+	}
+}
+
+func TestPathLevelParams(t *testing.T) {
+	api := newTestAPI(t)
+	// Neither user has a tenant, which the path item requires and the operation does not
+	r := newTestRunner(api, parseTestSpec(t, pathLevelParamsSpec))
+
+	results := execute(t, r)
+
+	want := map[string]map[string]int{"GET /notes/{note_id}": {ResultIDORFound: 2}}
+	if got := verdicts(results); !reflect.DeepEqual(got, want) {
+		t.Errorf("verdicts = %v, want %v", got, want)
+	}
+	if _, ok := r.collectAllFieldNames()["note_id"]; !ok {
+		t.Error("path-level note_id missing from the spec's field names")
+	}
+}
+
+const workspaceSpec = `
+openapi: 3.0.3
+info: {title: workspaces, version: "1"}
+paths:
+  /notes/{note_id}/workspace:
+    get:
+      security: [{Session: []}]
+      parameters:
+        - {name: note_id, in: path, required: true, schema: {type: integer}}
+        - {name: workspace, in: cookie, required: true, schema: {type: string}}
+      responses:
+        "200": {description: OK}
+components:
+  securitySchemes:
+    Session: {type: apiKey, in: cookie, name: session}
+`
+
+func TestRequiredCookieParam(t *testing.T) {
+	api := newTestAPI(t)
+	var mu sync.Mutex
+	var cookies []string
+	api.Mux.HandleFunc("GET /notes/{note_id}/workspace", func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		cookies = append(cookies, req.Header.Get("Cookie"))
+		mu.Unlock()
+		session, err := req.Cookie("session")
+		if err != nil {
+			writeTestJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		req.Header.Set("X-API-Key", session.Value)
+		user, ok := api.user(w, req)
+		if !ok {
+			return
+		}
+		if ws, err := req.Cookie("workspace"); err != nil || ws.Value == "" {
+			writeTestJSON(w, http.StatusBadRequest, map[string]string{"error": "workspace cookie required"})
+			return
+		}
+		note, ok := api.note(w, req)
+		if !ok {
+			return
+		}
+		if note.Owner != user {
+			writeTestJSON(w, http.StatusForbidden, map[string]string{"error": "forbidden"})
+			return
+		}
+		writeTestJSON(w, http.StatusOK, note)
+	})
+	r := newTestRunner(api, parseTestSpec(t, workspaceSpec))
+	r.Config.Users[0].Auth = testconfig.Auth{Type: "cookie", Value: "session=KEY_ALICE"}
+	r.Config.Users[1].Auth = testconfig.Auth{Type: "cookie", Value: "session=KEY_BOB"}
+	r.Config.Users[0].Fields["workspace"] = "ws-a"
+	r.Config.Users[1].Fields["workspace"] = "ws b"
+
+	results := execute(t, r)
+
+	want := map[string]map[string]int{"GET /notes/{note_id}/workspace": {ResultSecure: 2}}
+	if got := verdicts(results); !reflect.DeepEqual(got, want) {
+		t.Errorf("verdicts = %v, want %v", got, want)
+	}
+	sort.Strings(cookies)
+	// The auth cookie is kept and the object owner's workspace added to it
+	wantCookies := []string{
+		`session=KEY_ALICE; workspace="ws b"`, "session=KEY_ALICE; workspace=ws-a",
+		`session=KEY_BOB; workspace="ws b"`, "session=KEY_BOB; workspace=ws-a",
+	}
+	if !reflect.DeepEqual(cookies, wantCookies) {
+		t.Errorf("Cookie headers = %q, want %q", cookies, wantCookies)
+	}
+}
+
+func TestBodiesLikelyEqualContentTypes(t *testing.T) {
+	resp := func(ct, body string) ResponseDetails {
+		return ResponseDetails{Headers: map[string]string{"Content-Type": ct}, Body: body}
+	}
+	const json1, json2 = `{"id": 1, "name": "a"}`, `{"name":"a","id":1}`
+	tests := []struct {
+		name string
+		a, b ResponseDetails
+		want bool
+	}{
+		{"json reordered", resp("application/json", json1), resp("application/json; charset=utf-8", json2), true},
+		{"vendor json", resp("application/vnd.api+json", json1), resp("application/problem+json", json2), true},
+		{"json vs html of the same text", resp("application/json", json1), resp("text/html", json2), false},
+		{"html login page vs json", resp("text/html", "<html>Sign in</html>"), resp("application/json", json1), false},
+		{"html identical but for whitespace", resp("text/html", " <p>hi</p>\n"), resp("text/html", "<p>hi</p>"), true},
+		{"json-looking text", resp("text/plain", json1), resp("text/plain", json2), false},
+		{"missing content type", resp("", json1), resp("", json2), false},
+		{"invalid json declared as json", resp("application/json", "{oops"), resp("application/json", "{oops "), true},
+		{"xml", resp("application/xml", "<a>1</a>"), resp("application/xml", "<a>2</a>"), false},
+	}
+	for _, tt := range tests {
+		if got := bodiesLikelyEqual(tt.a, tt.b, nil); got != tt.want {
+			t.Errorf("%s: bodiesLikelyEqual = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLoginPageIsNotAMatch(t *testing.T) {
+	api := newTestAPI(t)
+	// Other users are sent a login page with status 200, like a session-expired redirect target
+	api.Mux.HandleFunc("GET /notes/{note_id}/page", func(w http.ResponseWriter, req *http.Request) {
+		user, ok := api.user(w, req)
+		if !ok {
+			return
+		}
+		note, ok := api.note(w, req)
+		if !ok {
+			return
+		}
+		if note.Owner != user {
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, "<html><body>Please sign in</body></html>")
+			return
+		}
+		writeTestJSON(w, http.StatusOK, note)
+	})
+	spec := parseTestSpec(t, `
+openapi: 3.0.3
+info: {title: page, version: "1"}
+security: [{ApiKeyAuth: []}]
+paths:
+`+noteOperation("page")+`
+components:
+  securitySchemes:
+    ApiKeyAuth: {type: apiKey, in: header, name: X-API-Key}
+`)
+	r := newTestRunner(api, spec)
+
+	results := execute(t, r)
+
+	want := map[string]map[string]int{"GET /notes/{note_id}/page": {ResultSecure: 2}}
+	if got := verdicts(results); !reflect.DeepEqual(got, want) {
+		t.Errorf("verdicts = %v, want %v", got, want)
+	}
+}
+
+// expiredBobRunner tests getNote with alice's note only, bob attacking with an expired
+// key and an auth.refresh request that hands out his valid one.
+func expiredBobRunner(t *testing.T, refreshAfter int) *Runner {
+	t.Helper()
+	api := newTestAPI(t)
+	api.Mux.HandleFunc("POST /auth/refresh", func(w http.ResponseWriter, req *http.Request) {
+		writeTestJSON(w, http.StatusOK, map[string]string{"token": "KEY_BOB"})
+	})
+	r := newTestRunner(api, loadTestSpec(t, "notes_api.json"))
+	r.OnlyOperations = []string{"getNote"}
+	r.RefreshAfter = refreshAfter
+	r.Config.Users[1].Auth.Value = "EXPIRED_BOB"
+	r.Config.Users[1].Auth.Refresh = &testconfig.AuthRefresh{Method: "POST", URL: "/auth/refresh", Token: "token"}
+	r.Config.Users[1].Fields = map[string]string{"user_id": "bob"}
+	return r
+}
+
+func TestAuthRefreshRetriesTest(t *testing.T) {
+	r := expiredBobRunner(t, 1)
+	results := execute(t, r)
+
+	want := map[string]map[string]int{"GET /notes/{note_id}": {ResultIDORFound: 1}}
+	if got := verdicts(results); !reflect.DeepEqual(got, want) {
+		t.Fatalf("verdicts = %v, want %v", got, want)
+	}
+	if r.AuthRefreshes["bob"] != 1 {
+		t.Errorf("AuthRefreshes = %v, want bob refreshed once", r.AuthRefreshes)
+	}
+	if !containsNote(results[0].Notes, "test retried after refreshing auth for bob") {
+		t.Errorf("notes = %q, want the retry noted", results[0].Notes)
+	}
+	if got := results[0].Test.Request.Headers["X-API-Key"]; got != "KEY_BOB" {
+		t.Errorf("test sent with X-API-Key %q, want the refreshed key", got)
+	}
+}
+
+func TestAuthRefreshNotDueMarksTestPotential(t *testing.T) {
+	r := expiredBobRunner(t, 2)
+	results := execute(t, r)
+
+	want := map[string]map[string]int{"GET /notes/{note_id}": {ResultPotential: 1}}
+	if got := verdicts(results); !reflect.DeepEqual(got, want) {
+		t.Fatalf("verdicts = %v, want %v", got, want)
+	}
+	if len(r.AuthRefreshes) != 0 {
+		t.Errorf("AuthRefreshes = %v, want none", r.AuthRefreshes)
+	}
+	if !containsNote(results[0].Notes, "credential may have expired") {
+		t.Errorf("notes = %q, want the possible expiry noted", results[0].Notes)
+	}
+}
+
+// TestDisallowedHostNotConfirmed checks that a write to a host --allow-host leaves out is
+// refused before the operator is asked to confirm it.
+func TestDisallowedHostNotConfirmed(t *testing.T) {
+	api := newTestAPI(t)
+	doc := parseTestSpec(t, `
+openapi: 3.0.3
+info: {title: notes, version: "1"}
+paths:
+  /notes/{note_id}:
+    delete:
+      parameters:
+        - {name: note_id, in: path, required: true, schema: {type: integer}}
+      responses:
+        "204": {description: deleted}
+`)
+	r := newTestRunner(api, doc)
+	r.ConfirmWrites = true
+	r.AllowedHosts = []string{"api.example.com"}
+	item := doc.Paths.Find("/notes/{note_id}")
+	users := r.Config.Users
+	_, _, err := r.sendOne(context.Background(), api.Client(), "DELETE", "/notes/{note_id}", item.Delete, item, users[0], users[1], nil)
+	// With no one listening for events, asking for confirmation would decline the write
+	if err == nil || !strings.Contains(err.Error(), "host is not allowed") {
+		t.Fatalf("sendOne error = %v, want the host refused", err)
+	}
+	if got := api.Requests(); len(got) != 0 {
+		t.Errorf("requests = %q, want none", got)
+	}
+}
+
+// TestAuthHeaderPrecedence checks where header credentials go: a user's header_name wins
+// over the default header name, which --auth-header replaces for the run.
+func TestAuthHeaderPrecedence(t *testing.T) {
+	api := newTestAPI(t)
+	api.Mux.HandleFunc("GET /notes/{note_id}/either", func(w http.ResponseWriter, req *http.Request) {
+		writeTestJSON(w, http.StatusOK, testNotes[1])
+	})
+	spec := parseTestSpec(t, `
+openapi: 3.0.3
+info: {title: either, version: "1"}
+security: [{ApiKeyAuth: []}, {AltKeyAuth: []}]
+paths:
+`+noteOperation("either")+`
+components:
+  securitySchemes:
+    ApiKeyAuth: {type: apiKey, in: header, name: X-API-Key}
+    AltKeyAuth: {type: apiKey, in: header, name: X-Alt-Key}
+`)
+	tests := []struct {
+		name          string
+		defaultHeader string
+		aliceHeader   string
+		want          map[string]string // user -> header carrying the credential
+	}{
+		{"default for everyone", "X-API-Key", "", map[string]string{"alice": "X-API-Key", "bob": "X-API-Key"}},
+		{"user header wins", "X-API-Key", "X-Alt-Key", map[string]string{"alice": "X-Alt-Key", "bob": "X-API-Key"}},
+		{"user header over a changed default", "X-Alt-Key", "X-API-Key", map[string]string{"alice": "X-API-Key", "bob": "X-Alt-Key"}},
+	}
+	for _, tt := range tests {
+		r := newTestRunner(api, spec)
+		r.Config.DefaultAuthHeaderName = tt.defaultHeader
+		r.Config.Users[0].Auth.HeaderName = tt.aliceHeader
+		got := map[string]string{}
+		for _, res := range execute(t, r) {
+			for _, ex := range []Exchange{res.Control, res.Test} {
+				for k, v := range ex.Request.Headers {
+					if strings.HasPrefix(v, "KEY_") {
+						got[ex.Request.AuthUser] = k
+					}
+				}
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: credential headers = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// downNoteRunner tests GET /notes/{note_id}/down, which fails for every user the way fail
+// does, with or without SkipIdenticalErrors.
+func downNoteRunner(t *testing.T, skipIdentical bool, fail http.HandlerFunc) *Runner {
+	t.Helper()
+	api := newTestAPI(t)
+	api.Mux.HandleFunc("GET /notes/{note_id}/down", fail)
+	r := newTestRunner(api, parseTestSpec(t, `
+openapi: 3.0.3
+info: {title: down, version: "1"}
+security: [{ApiKeyAuth: []}]
+paths:
+`+noteOperation("down")+`
+components:
+  securitySchemes:
+    ApiKeyAuth: {type: apiKey, in: header, name: X-API-Key}
+`))
+	r.SkipIdenticalErrors = skipIdentical
+	return r
+}
+
+func TestSkipIdenticalErrors(t *testing.T) {
+	unavailable := func(w http.ResponseWriter, req *http.Request) {
+		writeTestJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "maintenance"})
+	}
+	hangUp := func(w http.ResponseWriter, req *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}
+	tests := []struct {
+		name          string
+		fail          http.HandlerFunc
+		skipIdentical bool
+		want          string
+	}{
+		{"same status and body", unavailable, true, ResultSkipped},
+		{"same status and body, option off", unavailable, false, ResultControlFailed},
+		{"same transport error", hangUp, true, ResultSkipped},
+		{"same transport error, option off", hangUp, false, ResultControlFailed},
+	}
+	for _, tt := range tests {
+		results := execute(t, downNoteRunner(t, tt.skipIdentical, tt.fail))
+		want := map[string]map[string]int{"GET /notes/{note_id}/down": {tt.want: 2}}
+		if got := verdicts(results); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: verdicts = %v, want %v", tt.name, got, want)
+			continue
+		}
+		for _, res := range results {
+			if res.Endpoint != "-" && tt.want == ResultSkipped && res.SkippedReason != SkipReasonIdenticalErrors {
+				t.Errorf("%s: skipped reason = %q, want %q", tt.name, res.SkippedReason, SkipReasonIdenticalErrors)
+			}
+		}
+	}
+}
+
+func TestSkipIdenticalErrorsKeepsDifferentFailures(t *testing.T) {
+	// alice's own reads fail with a 500 while bob is told 503: not the same failure
+	r := downNoteRunner(t, true, func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("X-API-Key") == "KEY_ALICE" {
+			writeTestJSON(w, http.StatusInternalServerError, map[string]string{"error": "boom"})
+			return
+		}
+		writeTestJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "maintenance"})
+	})
+	r.Config.Users[1].Fields = map[string]string{"user_id": "bob"}
+
+	results := execute(t, r)
+
+	want := map[string]map[string]int{"GET /notes/{note_id}/down": {ResultControlFailed: 1}}
+	if got := verdicts(results); !reflect.DeepEqual(got, want) {
+		t.Errorf("verdicts = %v, want %v", got, want)
+	}
+}
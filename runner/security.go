@@ -0,0 +1,131 @@
+package runner
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/yansol0/aperture/testconfig"
+)
+
+// securitySchemeFor returns the security requirement of op that user's credentials satisfy,
+// as its scheme names joined with "+". ok is false only when op declares requirements whose
+// schemes are all known and none of them fits the credential; operations without security,
+// with an anonymous alternative or with undeclared schemes are not filtered.
+func (r *Runner) securitySchemeFor(op *openapi3.Operation, user testconfig.User) (scheme string, ok bool) {
+	reqs := r.Spec.Security
+	if op.Security != nil {
+		reqs = *op.Security
+	}
+	if len(reqs) == 0 {
+		return "", true
+	}
+	for _, req := range reqs {
+		if len(req) == 0 {
+			return "", true
+		}
+		names := make([]string, 0, len(req))
+		for name := range req {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		satisfied := true
+		for _, name := range names {
+			s := r.securityScheme(name)
+			if s == nil {
+				return "", true
+			}
+			if !r.credentialSatisfies(s, user) {
+				satisfied = false
+				break
+			}
+		}
+		if satisfied {
+			return strings.Join(names, "+"), true
+		}
+	}
+	return "", false
+}
+
+func (r *Runner) securityScheme(name string) *openapi3.SecurityScheme {
+	if r.Spec.Components == nil {
+		return nil
+	}
+	ref := r.Spec.Components.SecuritySchemes[name]
+	if ref == nil {
+		return nil
+	}
+	return ref.Value
+}
+
+// credentialSatisfies reports whether user's configured credential is sent the way s expects.
+func (r *Runner) credentialSatisfies(s *openapi3.SecurityScheme, user testconfig.User) bool {
+	headerName := user.Auth.HeaderName
+	if headerName == "" {
+		headerName = r.Config.DefaultAuthHeaderName
+	}
+	switch s.Type {
+	case "apiKey":
+		switch s.In {
+		case "header":
+			return user.Auth.Type == "header" && strings.EqualFold(headerName, s.Name)
+		case "cookie":
+			return user.Auth.Type == "cookie" && cookieHasName(user.Auth.Value, s.Name)
+		case "query":
+			return user.Auth.Type == testconfig.AuthSignedQuery && user.Auth.Signing != nil && user.Auth.Signing.Sets(s.Name)
+		default:
+			return false
+		}
+	case "http", "oauth2", "openIdConnect":
+		return user.Auth.Type == "header" && strings.EqualFold(headerName, "Authorization")
+	}
+	return true
+}
+
+func cookieHasName(cookieHeader, name string) bool {
+	for _, part := range strings.Split(cookieHeader, ";") {
+		if k, _, _ := strings.Cut(strings.TrimSpace(part), "="); k == name {
+			return true
+		}
+	}
+	return false
+}
+
+// pairSecurity checks that both users' credentials fit op's security requirements. It
+// returns a skip reason when they do not, or otherwise a note naming the schemes used.
+func (r *Runner) pairSecurity(op *openapi3.Operation, objectUser, credUser testconfig.User) (skipReason, note string) {
+	ctrl, ok := r.securitySchemeFor(op, objectUser)
+	if !ok {
+		return fmt.Sprintf("credentials of %s do not satisfy the operation's security schemes", objectUser.Name), ""
+	}
+	test, ok := r.securitySchemeFor(op, credUser)
+	if !ok {
+		return fmt.Sprintf("credentials of %s do not satisfy the operation's security schemes", credUser.Name), ""
+	}
+	if ctrl == "" && test == "" {
+		return "", ""
+	}
+	return "", fmt.Sprintf("security scheme: control=%s test=%s", orNone(ctrl), orNone(test))
+}
+
+// compatiblePairs counts the users whose credentials can be paired with objectUser on op.
+func (r *Runner) compatiblePairs(op *openapi3.Operation, objectUser testconfig.User) int {
+	n := 0
+	for _, u := range r.Config.Users {
+		if u.Name == objectUser.Name {
+			continue
+		}
+		if reason, _ := r.pairSecurity(op, objectUser, u); reason == "" {
+			n++
+		}
+	}
+	return n
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
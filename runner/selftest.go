@@ -0,0 +1,107 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/yansol0/aperture/testconfig"
+)
+
+// selfTestMaxEndpoints caps how many GET endpoints SelfTest calibrates against.
+const selfTestMaxEndpoints = 20
+
+// SelfTestReport is the outcome of SelfTest.
+type SelfTestReport struct {
+	Endpoints int               `json:"endpoints"`
+	Anomalies []SelfTestAnomaly `json:"anomalies"`
+}
+
+// SelfTestAnomaly is a baseline comparison that did not come out as expected on one endpoint.
+type SelfTestAnomaly struct {
+	Endpoint string `json:"endpoint"` // e.g. "GET /orders/{order_id}"
+	Users    string `json:"users"`
+	Problem  string `json:"problem"`
+}
+
+func (a SelfTestAnomaly) String() string {
+	return fmt.Sprintf("%s (%s): %s", a.Endpoint, a.Users, a.Problem)
+}
+
+// SelfTest calibrates the response comparison before a scan. On up to
+// selfTestMaxEndpoints testable GET endpoints it sends the first two eligible users'
+// requests for their own objects, the first one twice. The repeated request must compare
+// equal, otherwise volatile data missing from ignore_fields hides real findings; the two
+// users' objects must not, otherwise the comparison is too loose (body_match_pct,
+// ignore_fields) and body-equality findings on the endpoint are not trustworthy. These
+// requests are not counted in the scan's progress.
+func (r *Runner) SelfTest(ctx context.Context) SelfTestReport {
+	report := SelfTestReport{Anomalies: []SelfTestAnomaly{}}
+	events := r.Events
+	r.Events = nil
+	defer func() {
+		r.Events = events
+		r.CompletedRequests = 0
+	}()
+	client := r.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: r.HTTPTimeout}
+	}
+	paths := r.Spec.Paths.Map()
+	names := make([]string, 0, len(paths))
+	for p := range paths {
+		names = append(names, p)
+	}
+	sort.Strings(names)
+	for _, path := range names {
+		if report.Endpoints == selfTestMaxEndpoints {
+			break
+		}
+		item := paths[path]
+		op := item.Get
+		if op == nil || !r.operationSelected(op) || r.operationSkipReason(path, "GET", op, item) != "" {
+			continue
+		}
+		required := r.requiredParams(path, op, item)
+		var users []testconfig.User
+		for _, u := range r.eligibleUsers(required) {
+			if objectUserSkipReason(path, op, item, u) == "" {
+				users = append(users, u)
+			}
+		}
+		if len(users) < 2 {
+			continue
+		}
+		a, b := users[0], users[1]
+		if reason, _ := r.pairSecurity(op, a, b); reason != "" {
+			continue
+		}
+		_, first, err1 := r.sendOne(ctx, client, "GET", path, op, item, a, a, required)
+		_, again, err2 := r.sendOne(ctx, client, "GET", path, op, item, a, a, required)
+		_, other, err3 := r.sendOne(ctx, client, "GET", path, op, item, b, b, required)
+		if err1 != nil || err2 != nil || err3 != nil || first.Status < 200 || first.Status >= 300 {
+			continue
+		}
+		report.Endpoints++
+		add := func(users, problem string) {
+			report.Anomalies = append(report.Anomalies, SelfTestAnomaly{Endpoint: "GET " + path, Users: users, Problem: problem})
+		}
+		switch match, _ := r.bodiesMatch(first, again); {
+		case again.Status != first.Status:
+			add(a.Name+" twice", fmt.Sprintf("the same request returned %d, then %d", first.Status, again.Status))
+		case !match:
+			add(a.Name+" twice", "the same request returned different bodies; add the volatile fields to ignore_fields")
+		}
+		if other.Status == first.Status && !bodiesLikelyEqual(first, other, nil) {
+			if match, pct := r.bodiesMatch(first, other); match {
+				problem := "different users' objects compare equal once ignore_fields are removed"
+				if pct > 0 {
+					problem = fmt.Sprintf("different users' objects are %.1f%% similar, above --body-match-pct %g", pct, r.BodyMatchPct)
+				}
+				add(a.Name+" vs "+b.Name, problem)
+			}
+		}
+	}
+	return report
+}
@@ -0,0 +1,30 @@
+package runner
+
+import "fmt"
+
+// serverErrorExcerptLen is how much of a 5xx test response body its note quotes.
+const serverErrorExcerptLen = 200
+
+// classifyServerError marks a pair whose control succeeded but whose test got a 5xx as
+// ERROR: the server failed, which usually points at the synthesized request rather than
+// at its authorization, so it is kept apart from POTENTIAL.
+func classifyServerError(res *ResultLog, test ResponseDetails) {
+	res.Result = ResultError
+	excerpt := []rune(normalizeBody(test.FullBody()))
+	if len(excerpt) > serverErrorExcerptLen {
+		excerpt = append(excerpt[:serverErrorExcerptLen], '…')
+	}
+	res.Notes = append(res.Notes, fmt.Sprintf("test returned server error %d: %s", test.Status, string(excerpt)))
+}
+
+// ServerErrors counts ERROR results per "METHOD path", so endpoints whose requests
+// systematically fail stand out.
+func ServerErrors(results []ResultLog) map[string]int {
+	counts := map[string]int{}
+	for _, rl := range results {
+		if rl.Result == ResultError {
+			counts[rl.Method+" "+rl.Endpoint]++
+		}
+	}
+	return counts
+}
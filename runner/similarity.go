@@ -0,0 +1,61 @@
+package runner
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode"
+)
+
+// bodiesMatch is the body comparison of the IDOR check: bodiesLikelyEqual, or, when
+// BodyMatchPct is between 0 and 100, at least that percentage of tokens in common. It
+// returns the similarity percentage when the threshold, not equality, decided the match.
+func (r *Runner) bodiesMatch(a, b ResponseDetails) (bool, float64) {
+	if bodiesLikelyEqual(a, b, r.Config.IgnoreFields) {
+		return true, 0
+	}
+	if r.BodyMatchPct <= 0 || r.BodyMatchPct >= 100 {
+		return false, 0
+	}
+	pct := bodySimilarity(a, b, r.Config.IgnoreFields)
+	return pct >= r.BodyMatchPct, pct
+}
+
+// bodySimilarity returns how alike two bodies are, from 0 to 100, as the Dice coefficient
+// of their word tokens. Bodies are normalized like in bodiesLikelyEqual first, so JSON
+// formatting and ignored fields do not count.
+func bodySimilarity(a, b ResponseDetails, ignore []string) float64 {
+	at := similarityTokens(a, ignore)
+	bt := similarityTokens(b, ignore)
+	if len(at)+len(bt) == 0 {
+		return 100
+	}
+	counts := map[string]int{}
+	for _, t := range at {
+		counts[t]++
+	}
+	common := 0
+	for _, t := range bt {
+		if counts[t] > 0 {
+			counts[t]--
+			common++
+		}
+	}
+	return 200 * float64(common) / float64(len(at)+len(bt))
+}
+
+func similarityTokens(resp ResponseDetails, ignore []string) []string {
+	var body string
+	if isJSONMediaType(resp.Headers["Content-Type"]) {
+		if v, ok := decodeBodyJSON(resp); ok {
+			if b, err := json.Marshal(stripFields(v, ignore)); err == nil {
+				body = string(b)
+			}
+		}
+	}
+	if body == "" {
+		body = strings.TrimSpace(resp.FullBody())
+	}
+	return strings.FieldsFunc(body, func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsDigit(c)
+	})
+}
@@ -0,0 +1,40 @@
+package runner
+
+import (
+	pathpkg "path"
+	"strings"
+)
+
+// SkipReasonInfrastructure is recorded for health, metrics and documentation endpoints.
+const SkipReasonInfrastructure = "infrastructure endpoint"
+
+// defaultSkipPaths are well-known infrastructure paths skipped unless NoDefaultSkips is set.
+// They return the same body to every caller, so testing them only adds noise.
+var defaultSkipPaths = []string{
+	"/health", "/healthz", "/health/*", "/healthcheck", "/ready", "/readyz", "/live", "/livez", "/ping",
+	"/metrics", "/prometheus", "/actuator", "/actuator/*",
+	"/swagger*", "/swagger-ui/*", "/openapi*", "/api-docs", "/api-docs/*", "/docs", "/redoc",
+	"/favicon.ico", "/robots.txt",
+}
+
+// infrastructurePath reports whether path matches a skip pattern. Patterns are matched
+// case-insensitively with "*" wildcards against the whole path and each trailing run of
+// segments, so "/metrics" also matches "/api/v1/metrics".
+func (r *Runner) infrastructurePath(path string) bool {
+	patterns := r.Config.SkipPaths
+	if !r.NoDefaultSkips {
+		patterns = append(append([]string(nil), defaultSkipPaths...), patterns...)
+	}
+	lower := strings.ToLower(strings.TrimRight(path, "/"))
+	for i := 0; i < len(lower); i++ {
+		if lower[i] != '/' {
+			continue
+		}
+		for _, p := range patterns {
+			if ok, err := pathpkg.Match(strings.ToLower(p), lower[i:]); err == nil && ok {
+				return true
+			}
+		}
+	}
+	return false
+}
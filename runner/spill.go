@@ -0,0 +1,377 @@
+package runner
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// BodyRef points at a response body written to disk because it exceeded
+// Runner.SpillThreshold. Files are content-addressed, so equal bodies share one file.
+// Path is empty in results written by WithoutPrunedBodyRefs, whose file is removed.
+type BodyRef struct {
+	SHA256 string `json:"sha256"`
+	Size   int    `json:"size"`
+	Path   string `json:"path,omitempty"`
+}
+
+// Retention modes for PruneSpilledBodies.
+const (
+	KeepBodiesNone     = "none"
+	KeepBodiesFindings = "findings"
+	KeepBodiesAll      = "all"
+)
+
+// FullBody returns the response body, reading it back from disk when it was spilled.
+func (d ResponseDetails) FullBody() string {
+	if d.BodyRef == nil {
+		return d.Body
+	}
+	if d.BodyRef.Path == "" {
+		return "(spilled body removed)"
+	}
+	b, err := os.ReadFile(d.BodyRef.Path)
+	if err != nil {
+		return fmt.Sprintf("(spilled body unavailable: %v)", err)
+	}
+	return string(b)
+}
+
+// Open returns the response body as a stream, read from disk when it was spilled, so
+// checks on a large body need not hold it in memory.
+func (d ResponseDetails) Open() (io.ReadCloser, error) {
+	if d.BodyRef == nil {
+		return io.NopCloser(strings.NewReader(d.Body)), nil
+	}
+	return os.Open(d.BodyRef.Path)
+}
+
+// spillBody moves a response body larger than SpillThreshold to SpillDir as soon as it is
+// received, so that from then on the body is only streamed back from disk.
+func (r *Runner) spillBody(d *ResponseDetails) {
+	if r.SpillDir == "" || d.BodyRef != nil || len(d.Body) <= r.SpillThreshold {
+		return
+	}
+	sum := sha256.Sum256([]byte(d.Body))
+	hash := hex.EncodeToString(sum[:])
+	path := filepath.Join(r.SpillDir, hash[:2], hash)
+	if _, err := os.Stat(path); err != nil {
+		if err := writeSpillFile(path, d.Body); err != nil {
+			// Keeping the body in memory is better than losing it
+			r.logf("[!] Could not spill response body: %v", err)
+			return
+		}
+	}
+	d.BodyRef = &BodyRef{SHA256: hash, Size: len(d.Body), Path: path}
+	d.Body = ""
+}
+
+// writeSpillFile writes body to path through a temporary file, so a reader never sees a
+// partial body under its final name.
+func writeSpillFile(path, body string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".spill-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.WriteString(body)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// PruneSpilledBodies deletes spilled bodies referenced by results according to keep:
+// KeepBodiesNone deletes all of them, KeepBodiesFindings those not referenced by an IDOR
+// FOUND or POTENTIAL result, and KeepBodiesAll none. Files not referenced by results are
+// left alone.
+func PruneSpilledBodies(results []ResultLog, keep string) error {
+	var firstErr error
+	for path := range prunedPaths(results, keep) {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// WithoutPrunedBodyRefs returns a copy of results for outputs written before
+// PruneSpilledBodies runs with the same keep: the body refs of files it will remove keep
+// their hash and size but lose their path, so no written output points at a missing file.
+// results itself is not modified.
+func WithoutPrunedBodyRefs(results []ResultLog, keep string) []ResultLog {
+	pruned := prunedPaths(results, keep)
+	if len(pruned) == 0 {
+		return results
+	}
+	out := make([]ResultLog, len(results))
+	for i, rl := range results {
+		rl.Verification = append([]Exchange(nil), rl.Verification...)
+		rl.Cleanup = append([]Exchange(nil), rl.Cleanup...)
+		if rl.ConditionalProbe != nil {
+			probe := *rl.ConditionalProbe
+			rl.ConditionalProbe = &probe
+		}
+		for _, d := range responsesOf(&rl) {
+			if d.BodyRef != nil && pruned[d.BodyRef.Path] {
+				d.BodyRef = &BodyRef{SHA256: d.BodyRef.SHA256, Size: d.BodyRef.Size}
+			}
+		}
+		out[i] = rl
+	}
+	return out
+}
+
+// prunedPaths returns the spilled files PruneSpilledBodies removes for keep. A file shared
+// by a kept result and a pruned one is kept.
+func prunedPaths(results []ResultLog, keep string) map[string]bool {
+	if keep == KeepBodiesAll {
+		return nil
+	}
+	kept := map[string]bool{}
+	remove := map[string]bool{}
+	for i := range results {
+		finding := results[i].Result == ResultIDORFound || results[i].Result == ResultPotential
+		for _, d := range responsesOf(&results[i]) {
+			if d.BodyRef == nil || d.BodyRef.Path == "" {
+				continue
+			}
+			if keep == KeepBodiesFindings && finding {
+				kept[d.BodyRef.Path] = true
+			} else {
+				remove[d.BodyRef.Path] = true
+			}
+		}
+	}
+	for path := range kept {
+		delete(remove, path)
+	}
+	return remove
+}
+
+// responsesOf returns pointers to every response of a result.
+func responsesOf(rl *ResultLog) []*ResponseDetails {
+	out := []*ResponseDetails{&rl.Control.Response, &rl.Test.Response}
+	for i := range rl.Verification {
+		out = append(out, &rl.Verification[i].Response)
+	}
+	for i := range rl.Cleanup {
+		out = append(out, &rl.Cleanup[i].Response)
+	}
+	if rl.ConditionalProbe != nil {
+		out = append(out, &rl.ConditionalProbe.Response)
+	}
+	return out
+}
+
+// decodeBodyJSON decodes the body as a single JSON value, streaming it from disk when it
+// was spilled. ok is false when the body is not JSON.
+func decodeBodyJSON(d ResponseDetails) (v any, ok bool) {
+	rc, err := d.Open()
+	if err != nil {
+		return nil, false
+	}
+	defer rc.Close()
+	dec := json.NewDecoder(rc)
+	if dec.Decode(&v) != nil {
+		return nil, false
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, false
+	}
+	return v, true
+}
+
+// walkJSONKeys streams a JSON document from r, calling fn, when set, with every object
+// key, and reports whether r holds exactly one valid JSON value.
+func walkJSONKeys(r io.Reader, fn func(key string)) bool {
+	type level struct{ object, wantKey bool }
+	var stack []level
+	dec := json.NewDecoder(r)
+	done := false
+	valueDone := func() {
+		if len(stack) == 0 {
+			done = true
+		} else if top := &stack[len(stack)-1]; top.object {
+			top.wantKey = true
+		}
+	}
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return done
+		}
+		if err != nil || done {
+			return false
+		}
+		switch t := tok.(type) {
+		case json.Delim:
+			switch t {
+			case '{', '[':
+				stack = append(stack, level{object: t == '{', wantKey: t == '{'})
+			default:
+				stack = stack[:len(stack)-1]
+				valueDone()
+			}
+		case string:
+			if top := len(stack) - 1; top >= 0 && stack[top].wantKey {
+				stack[top].wantKey = false
+				if fn != nil {
+					fn(t)
+				}
+				continue
+			}
+			valueDone()
+		default:
+			valueDone()
+		}
+	}
+}
+
+// bodyContainsAny reports whether the body contains one of values, ignoring case. The
+// body is scanned in chunks, streamed from disk when it was spilled.
+func bodyContainsAny(d ResponseDetails, values map[string]string) bool {
+	var needles [][]byte
+	longest := 0
+	for _, v := range values {
+		if v == "" {
+			continue
+		}
+		needle := []byte(strings.ToLower(v))
+		needles = append(needles, needle)
+		longest = max(longest, len(needle))
+	}
+	if len(needles) == 0 {
+		return false
+	}
+	rc, err := d.Open()
+	if err != nil {
+		return false
+	}
+	defer rc.Close()
+	buf := make([]byte, 32<<10)
+	// window is the lowercased tail of what was read before, long enough for a match
+	// that straddles two reads, followed by the newly read text
+	var window, partial []byte
+	for {
+		n, err := rc.Read(buf)
+		chunk := append(partial, buf[:n]...)
+		// Lowercase whole runes only; one cut off by the read waits for its other bytes
+		whole := len(chunk)
+		if err == nil {
+			whole = completeRunes(chunk)
+		}
+		partial = append([]byte(nil), chunk[whole:]...)
+		window = append(window, bytes.ToLower(chunk[:whole])...)
+		for _, needle := range needles {
+			if bytes.Contains(window, needle) {
+				return true
+			}
+		}
+		if err != nil {
+			return false
+		}
+		if keep := longest - 1; len(window) > keep {
+			window = append(window[:0], window[len(window)-keep:]...)
+		}
+	}
+}
+
+// completeRunes returns the length of b without a trailing incomplete UTF-8 sequence.
+func completeRunes(b []byte) int {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				return i
+			}
+			break
+		}
+	}
+	return len(b)
+}
+
+// sameTrimmedBody reports whether two bodies are equal once surrounding whitespace is
+// trimmed, like strings.TrimSpace. Spilled bodies with the same hash are equal without
+// being read; otherwise both are streamed and compared rune by rune.
+func sameTrimmedBody(a, b ResponseDetails) bool {
+	if a.BodyRef != nil && b.BodyRef != nil && a.BodyRef.SHA256 == b.BodyRef.SHA256 {
+		return true
+	}
+	if a.BodyRef == nil && b.BodyRef == nil {
+		return strings.TrimSpace(a.Body) == strings.TrimSpace(b.Body)
+	}
+	ra, err := a.Open()
+	if err != nil {
+		return false
+	}
+	defer ra.Close()
+	rb, err := b.Open()
+	if err != nil {
+		return false
+	}
+	defer rb.Close()
+	ar, br := bufio.NewReader(ra), bufio.NewReader(rb)
+	ac, aerr := skipSpace(ar)
+	bc, berr := skipSpace(br)
+	for aerr == nil && berr == nil && ac == bc {
+		ac, aerr = readRune(ar)
+		bc, berr = readRune(br)
+	}
+	// Past the common prefix, each side may only have trailing whitespace left
+	return onlySpaceLeft(ac, aerr, ar) && onlySpaceLeft(bc, berr, br)
+}
+
+// readRune reads one rune, mapping each invalid byte to its own value above
+// utf8.MaxRune so that different invalid bytes never compare equal.
+func readRune(r *bufio.Reader) (rune, error) {
+	c, size, err := r.ReadRune()
+	if err == nil && c == utf8.RuneError && size == 1 {
+		if err := r.UnreadRune(); err != nil {
+			return 0, err
+		}
+		b, err := r.ReadByte()
+		return utf8.MaxRune + 1 + rune(b), err
+	}
+	return c, err
+}
+
+// skipSpace returns the first rune that is not whitespace.
+func skipSpace(r *bufio.Reader) (rune, error) {
+	for {
+		c, err := readRune(r)
+		if err != nil || !unicode.IsSpace(c) {
+			return c, err
+		}
+	}
+}
+
+// onlySpaceLeft reports whether c, the rune read last with error err, and the rest of r
+// are all whitespace.
+func onlySpaceLeft(c rune, err error, r *bufio.Reader) bool {
+	for {
+		if err == io.EOF {
+			return true
+		}
+		if err != nil || !unicode.IsSpace(c) {
+			return false
+		}
+		c, err = readRune(r)
+	}
+}
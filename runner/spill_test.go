@@ -0,0 +1,154 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// spilled writes body to a spill file under t's temp dir and returns it as a response.
+func spilled(t *testing.T, body string) ResponseDetails {
+	t.Helper()
+	r := &Runner{SpillDir: t.TempDir()}
+	d := ResponseDetails{Status: 200, Headers: map[string]string{"Content-Type": "application/json"}, Body: body}
+	r.spillBody(&d)
+	if d.BodyRef == nil || d.Body != "" {
+		t.Fatalf("body of %d bytes was not spilled", len(body))
+	}
+	return d
+}
+
+func TestSpilledBodiesAreCheckedFromDisk(t *testing.T) {
+	api := newTestAPI(t)
+	run := func(spillDir string) []ResultLog {
+		r := newTestRunner(api, loadTestSpec(t, "notes_api.json"))
+		r.OnlyOperations = []string{"getNote", "previewNote"}
+		r.SpillDir, r.SpillThreshold = spillDir, 10
+		r.Config.SensitiveKeys = []string{"content"}
+		return execute(t, r)
+	}
+	inMemory := run("")
+	results := run(t.TempDir())
+
+	want := map[string]map[string]int{"GET /notes/{note_id}": {ResultIDORFound: 2}, "GET /notes/{note_id}/preview": {ResultIDORFound: 2}}
+	if got := verdicts(results); !reflect.DeepEqual(got, want) {
+		t.Fatalf("verdicts = %v, want %v", got, want)
+	}
+	// Verdicts, confidence and notes do not depend on where the bodies are kept
+	outcome := func(results []ResultLog) map[string]string {
+		out := map[string]string{}
+		for _, res := range results {
+			key := res.Endpoint + " " + res.Test.Request.AuthUser
+			out[key] = fmt.Sprintf("%s %.2f %q %q", res.Result, res.Confidence, res.Notes, res.SensitiveKeys)
+		}
+		return out
+	}
+	if got, want := outcome(results), outcome(inMemory); !reflect.DeepEqual(got, want) {
+		t.Errorf("spilled outcome = %v\nin memory = %v", got, want)
+	}
+	for _, res := range results {
+		if res.Endpoint == "-" {
+			continue
+		}
+		if ref := res.Test.Response.BodyRef; ref == nil || res.Test.Response.Body != "" {
+			t.Fatalf("test body was not spilled: %+v", res.Test.Response)
+		}
+		if res.Endpoint == "/notes/{note_id}" && !reflect.DeepEqual(res.SensitiveKeys, []string{"content"}) {
+			t.Errorf("SensitiveKeys = %v, want the spilled body's content key", res.SensitiveKeys)
+		}
+	}
+}
+
+func TestBodyContainsAnyAcrossReads(t *testing.T) {
+	// The identifier and a multi-byte rune straddle the 32 KiB read boundary
+	body := strings.Repeat("x", 32<<10-3) + "ÉTÉ-42" + strings.Repeat("y", 100)
+	for _, d := range []ResponseDetails{{Body: body}, spilled(t, body)} {
+		if !bodyContainsAny(d, map[string]string{"id": "été-42"}) {
+			t.Errorf("spilled=%v: identifier across the read boundary not found", d.BodyRef != nil)
+		}
+		if bodyContainsAny(d, map[string]string{"id": "été-43", "empty": ""}) {
+			t.Errorf("spilled=%v: absent identifier found", d.BodyRef != nil)
+		}
+	}
+}
+
+func TestSameTrimmedBody(t *testing.T) {
+	long := `{"id": 1, "data": "` + strings.Repeat("a", 100) + `"}`
+	tests := []struct {
+		name string
+		a, b ResponseDetails
+		want bool
+	}{
+		{"same hash", spilled(t, long), spilled(t, long), true},
+		{"surrounding whitespace", spilled(t, "\n "+long+"\t\n"), ResponseDetails{Body: long}, true},
+		{"trailing text", spilled(t, long+" x"), ResponseDetails{Body: long}, false},
+		{"different bodies", spilled(t, long), spilled(t, strings.Replace(long, "a", "b", 1)), false},
+		{"different invalid bytes", spilled(t, long+"\xff"), spilled(t, long+"\xfe"), false},
+	}
+	for _, tt := range tests {
+		if got := sameTrimmedBody(tt.a, tt.b); got != tt.want {
+			t.Errorf("%s: sameTrimmedBody = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWalkJSONKeys(t *testing.T) {
+	tests := []struct {
+		doc   string
+		keys  []string
+		valid bool
+	}{
+		{`{"a": {"b": [1, {"c": "a"}]}, "d": null}`, []string{"a", "b", "c", "d"}, true},
+		{` [{"k": []}, "v"] `, []string{"k"}, true},
+		{`"just a string"`, nil, true},
+		{`{"a": 1} trailing`, []string{"a"}, false},
+		{`{"a": 1}{"b": 2}`, []string{"a"}, false},
+		{`{"a": }`, []string{"a"}, false},
+		{``, nil, false},
+	}
+	for _, tt := range tests {
+		var keys []string
+		valid := walkJSONKeys(strings.NewReader(tt.doc), func(k string) { keys = append(keys, k) })
+		if valid != tt.valid || !reflect.DeepEqual(keys, tt.keys) {
+			t.Errorf("walkJSONKeys(%q) = %v, %q; want %v, %q", tt.doc, valid, keys, tt.valid, tt.keys)
+		}
+	}
+}
+
+func TestPrunedBodyRefs(t *testing.T) {
+	finding, secure := spilled(t, strings.Repeat("f", 100)), spilled(t, strings.Repeat("s", 100))
+	results := []ResultLog{
+		{Result: ResultIDORFound, Test: Exchange{Response: finding}},
+		{Result: ResultSecure, Test: Exchange{Response: secure}, Verification: []Exchange{{Response: finding}}},
+	}
+
+	written := WithoutPrunedBodyRefs(results, KeepBodiesFindings)
+
+	if got := written[1].Test.Response.BodyRef; got.Path != "" || got.SHA256 != secure.BodyRef.SHA256 || got.Size != 100 {
+		t.Errorf("pruned ref = %+v, want hash and size without a path", got)
+	}
+	if written[0].Test.Response.BodyRef.Path == "" || written[1].Verification[0].Response.BodyRef.Path == "" {
+		t.Error("refs of a kept body lost their path")
+	}
+	if results[1].Test.Response.BodyRef.Path == "" {
+		t.Error("WithoutPrunedBodyRefs modified its input")
+	}
+	if err := PruneSpilledBodies(results, KeepBodiesFindings); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(secure.BodyRef.Path); !os.IsNotExist(err) {
+		t.Errorf("pruned body still exists: %v", err)
+	}
+	if _, err := os.Stat(finding.BodyRef.Path); err != nil {
+		t.Errorf("body shared with a finding was removed: %v", err)
+	}
+	if got := written[1].Test.Response.FullBody(); got != "(spilled body removed)" {
+		t.Errorf("FullBody of a pruned ref = %q", got)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(filepath.Dir(finding.BodyRef.Path), ".spill-*")); len(matches) > 0 {
+		t.Errorf("temporary spill files left behind: %v", matches)
+	}
+}
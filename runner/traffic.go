@@ -0,0 +1,87 @@
+package runner
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// Traffic is the number of bytes exchanged with the target, counted as they cross the
+// wire: request line, headers and body sent; status line, headers and body received.
+type Traffic struct {
+	Requests         int   `json:"requests"`
+	BytesSent        int64 `json:"bytes_sent"`
+	BytesReceived    int64 `json:"bytes_received"`
+	AvgResponseBytes int64 `json:"avg_response_bytes"`
+}
+
+func (t *Traffic) add(sent, received int64) {
+	t.Requests++
+	t.BytesSent += sent
+	t.BytesReceived += received
+	t.AvgResponseBytes = t.BytesReceived / int64(t.Requests)
+}
+
+// TrafficStats is a run's traffic in total, per "METHOD path" and per user whose
+// credentials were sent. The pre-scan reachability check has no user.
+type TrafficStats struct {
+	Total     Traffic            `json:"total"`
+	Endpoints map[string]Traffic `json:"endpoints"`
+	Users     map[string]Traffic `json:"users"`
+}
+
+// recordTraffic adds one completed exchange to r.Traffic.
+func (r *Runner) recordTraffic(endpoint, user string, req *http.Request, reqBody int, resp *http.Response, respBody int) {
+	sent, received := requestSize(req, reqBody), responseSize(resp, respBody)
+	r.trafficMu.Lock()
+	defer r.trafficMu.Unlock()
+	t := &r.Traffic
+	t.Total.add(sent, received)
+	if t.Endpoints == nil {
+		t.Endpoints, t.Users = map[string]Traffic{}, map[string]Traffic{}
+	}
+	ep := t.Endpoints[endpoint]
+	ep.add(sent, received)
+	t.Endpoints[endpoint] = ep
+	if user != "" {
+		u := t.Users[user]
+		u.add(sent, received)
+		t.Users[user] = u
+	}
+}
+
+// requestSize is the HTTP/1.1 size of req with a body of n bytes, including the Host,
+// Content-Length and Accept-Encoding headers the transport adds.
+func requestSize(req *http.Request, n int) int64 {
+	var hw byteCounter
+	req.Header.Write(&hw)
+	size := len(req.Method) + 1 + len(req.URL.RequestURI()) + len(" HTTP/1.1\r\n") +
+		len("Host: \r\n") + len(req.URL.Host) + len("\r\n") + n
+	if n > 0 {
+		size += len("Content-Length: \r\n") + len(strconv.Itoa(n))
+	}
+	if req.Header.Get("Accept-Encoding") == "" {
+		size += len("Accept-Encoding: gzip\r\n")
+	}
+	return hw.n + int64(size)
+}
+
+// responseSize is the size of resp as received. read is the number of body bytes read
+// before any decoding; the declared Content-Length is used instead when known, so a
+// body cut short still counts at its true size.
+func responseSize(resp *http.Response, read int) int64 {
+	var hw byteCounter
+	resp.Header.Write(&hw)
+	body := int64(read)
+	if resp.ContentLength >= 0 {
+		body = resp.ContentLength
+	}
+	return hw.n + int64(len(resp.Proto)+1+len(resp.Status)+len("\r\n\r\n")) + body
+}
+
+// byteCounter counts the bytes written to it.
+type byteCounter struct{ n int64 }
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
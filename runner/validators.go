@@ -0,0 +1,84 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/yansol0/aperture/testconfig"
+)
+
+// weightValidatorMatch is added to the confidence when the test response carries the
+// control's caching validators although its status or body differ.
+const weightValidatorMatch = 0.15
+
+// sameETag compares two entity tags with the weak comparison of RFC 9110: a W/ prefix is
+// ignored, so a weak and a strong tag for the same version match.
+func sameETag(a, b string) bool {
+	a = strings.TrimPrefix(strings.TrimSpace(a), "W/")
+	b = strings.TrimPrefix(strings.TrimSpace(b), "W/")
+	return a != "" && a == b
+}
+
+// compareValidators adds a note and confidence when the test response repeats the
+// control's ETag or Last-Modified despite a non-2xx status or a different body: the
+// attacker learned the version of an object they may not read. An ETag match also turns
+// a SECURE result into POTENTIAL, since an ETag identifies the object's exact content.
+func (r *Runner) compareValidators(res *ResultLog, ctrl, test ResponseDetails) {
+	test2xx := test.Status >= 200 && test.Status < 300
+	if test2xx && bodiesLikelyEqual(ctrl, test, r.Config.IgnoreFields) {
+		return
+	}
+	var matched []string
+	if sameETag(ctrl.ETag, test.ETag) {
+		matched = append(matched, fmt.Sprintf("ETag %s", test.ETag))
+	}
+	if ctrl.LastModified != "" && ctrl.LastModified == test.LastModified {
+		matched = append(matched, fmt.Sprintf("Last-Modified %s", test.LastModified))
+	}
+	if len(matched) == 0 {
+		return
+	}
+	res.Notes = append(res.Notes, fmt.Sprintf("test response (status %d) carries the control's %s", test.Status, strings.Join(matched, " and ")))
+	res.Confidence = min(res.Confidence+weightValidatorMatch, 1)
+	if res.Result == ResultSecure && sameETag(ctrl.ETag, test.ETag) {
+		res.Result = ResultPotential
+		r.logf("[?] POTENTIAL: %s %s (test status=%d carries the control's ETag)", res.Method, res.Endpoint, test.Status)
+	}
+}
+
+// probeConditional repeats the test request as a conditional GET with the control's ETag
+// in If-None-Match. A 304 tells the attacker their copy of the owner's object is current,
+// which confirms the object and its version, so it is reported as IDOR FOUND.
+func (r *Runner) probeConditional(
+	ctx context.Context,
+	client *http.Client,
+	res *ResultLog,
+	method, path string,
+	op *openapi3.Operation,
+	item *openapi3.PathItem,
+	objectUser, credUser testconfig.User,
+	required map[string]paramSpec,
+	etag string,
+) {
+	r.TotalRequests++
+	r.pair.extraHeaders = map[string]string{"If-None-Match": etag}
+	probe, resp, err := r.sendOne(ctx, client, method, path, op, item, objectUser, credUser, required)
+	r.pair.extraHeaders = nil
+	if err != nil {
+		res.Notes = append(res.Notes, fmt.Sprintf("conditional probe error: %v", err))
+		return
+	}
+	res.ConditionalProbe = &probe
+	if resp.Status != http.StatusNotModified {
+		return
+	}
+	res.Notes = append(res.Notes, fmt.Sprintf("conditional probe: If-None-Match %s returned 304 to %s", etag, credUser.Name))
+	if res.Result != ResultIDORFound {
+		r.logf("[!] IDOR FOUND: %s %s (conditional probe returned 304 to %s)", method, path, credUser.Name)
+		res.Result = ResultIDORFound
+		res.Confidence = max(res.Confidence, weightStatusMatch+weightValidatorMatch)
+	}
+}
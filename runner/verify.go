@@ -0,0 +1,229 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/yansol0/aperture/testconfig"
+)
+
+// isWriteMethod reports whether method is expected to modify server state.
+func isWriteMethod(method string) bool {
+	switch strings.ToUpper(method) {
+	case "POST", "PUT", "PATCH", "DELETE":
+		return true
+	}
+	return false
+}
+
+// verificationTarget returns the GET operation used to check whether a write took effect:
+// the override's verify_path when set, otherwise a GET on the same path. A nil operation
+// means the write cannot be verified.
+func (r *Runner) verificationTarget(method, path string, item *openapi3.PathItem) (string, *openapi3.Operation, *openapi3.PathItem) {
+	if !r.VerifyWrites || !isWriteMethod(method) {
+		return "", nil, nil
+	}
+	if ov, ok := r.Config.OverrideFor(method, path); ok && ov.VerifyPath != "" {
+		if vi := r.Spec.Paths.Value(ov.VerifyPath); vi != nil && vi.Get != nil {
+			return ov.VerifyPath, vi.Get, vi
+		}
+		return "", nil, nil
+	}
+	if item.Get != nil {
+		return path, item.Get, item
+	}
+	return "", nil, nil
+}
+
+// verifyWrite re-reads the object as its owner after a successful attacker write and
+// compares it to the snapshot taken just before. A change that shows a value only the
+// attacker sent (see perturbBody), or a DELETE after which the object is gone, confirms
+// the IDOR; another change leaves it POTENTIAL at best, since something else may have
+// changed the object. No change downgrades a finding unless the attacker sent the same
+// data as the control did.
+func (r *Runner) verifyWrite(
+	ctx context.Context,
+	client *http.Client,
+	res *ResultLog,
+	snapshot Exchange,
+	verifyPath string,
+	verifyOp *openapi3.Operation,
+	verifyItem *openapi3.PathItem,
+	owner testconfig.User,
+) {
+	after, _, err := r.sendOne(ctx, client, "GET", verifyPath, verifyOp, verifyItem, owner, owner, nil)
+	res.Verification = []Exchange{snapshot, after}
+	if err != nil {
+		res.Notes = append(res.Notes, fmt.Sprintf("verification error: %v", err))
+		return
+	}
+
+	changed := snapshot.Response.Status != after.Response.Status || !bodiesLikelyEqual(snapshot.Response, after.Response, r.Config.IgnoreFields)
+	gone := strings.EqualFold(res.Method, http.MethodDelete) && (after.Response.Status == http.StatusNotFound || after.Response.Status == http.StatusGone)
+	field := ""
+	if changed && !gone {
+		field = attackerValueIn(res.Control.Request.Body, res.Test.Request.Body, after.Response.FullBody())
+	}
+	switch {
+	case gone && changed:
+		res.Result = ResultIDORFound
+		res.WriteVerified = true
+		res.Confidence = min(res.Confidence+weightWriteVerified, 1)
+		res.Notes = append(res.Notes, fmt.Sprintf("verified: object gone (status %d) after the attacker's DELETE", after.Response.Status))
+	case field != "":
+		res.Result = ResultIDORFound
+		res.WriteVerified = true
+		res.Confidence = min(res.Confidence+weightWriteVerified, 1)
+		res.Notes = append(res.Notes, fmt.Sprintf("verified: object changed and now has the attacker's %s", field))
+	case changed:
+		if res.Result != ResultIDORFound {
+			res.Result = ResultPotential
+		}
+		res.Notes = append(res.Notes, "object changed after the attacker's request, but shows none of the data only the attacker sent; the change may have another cause")
+	case !sameJSON(res.Control.Request.Body, res.Test.Request.Body):
+		if res.Result == ResultIDORFound {
+			res.Result = ResultPotential
+		}
+		res.Confidence /= 2
+		res.Notes = append(res.Notes, "verified: object unchanged after the attacker's request")
+	default:
+		res.Notes = append(res.Notes, "verification inconclusive: object unchanged, but the attacker sent the same data as the control")
+	}
+}
+
+// attackerValueIn returns a top-level property of the test body whose value differs from
+// the control body's and appears under the same key anywhere in the JSON body after, or
+// "" when there is none.
+func attackerValueIn(ctrlBody, testBody any, after string) string {
+	test, ok := testBody.(map[string]any)
+	if !ok {
+		return ""
+	}
+	ctrl, _ := ctrlBody.(map[string]any)
+	var doc any
+	if json.Unmarshal([]byte(strings.TrimSpace(after)), &doc) != nil {
+		return ""
+	}
+	names := make([]string, 0, len(test))
+	for name := range test {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if sameJSON(test[name], ctrl[name]) {
+			continue
+		}
+		if jsonHasValue(doc, name, test[name]) {
+			return name
+		}
+	}
+	return ""
+}
+
+// jsonHasValue reports whether an object in doc has key with a value equal to v as JSON.
+func jsonHasValue(doc any, key string, v any) bool {
+	switch n := doc.(type) {
+	case map[string]any:
+		for k, child := range n {
+			if k == key && sameJSON(child, v) {
+				return true
+			}
+			if jsonHasValue(child, key, v) {
+				return true
+			}
+		}
+	case []any:
+		for _, child := range n {
+			if jsonHasValue(child, key, v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// perturbBody returns a copy of a JSON object body with one top-level property changed
+// and that property's name, so the object re-read after the attacker's write shows
+// whether that write changed it. Properties synthesized from the schema are changed before
+// those taken from the object user's fields, and parameters addressing the object never
+// are. Only strings, numbers and booleans the schema accepts another value for qualify;
+// with none, body is returned as it is with an empty name.
+func (r *Runner) perturbBody(body any, schema *openapi3.SchemaRef, fields map[string]string, params openapi3.Parameters) (any, string) {
+	obj, ok := body.(map[string]any)
+	s := r.resolveSchema(schema)
+	if !ok || s == nil {
+		return body, ""
+	}
+	addressing := map[string]bool{}
+	for _, p := range params {
+		if p != nil && p.Value != nil {
+			addressing[p.Value.Name] = true
+		}
+	}
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, fromFields := range []bool{false, true} {
+		for _, name := range names {
+			if _, isField := fields[name]; isField != fromFields || addressing[name] {
+				continue
+			}
+			v, ok := perturbValue(obj[name], r.resolveSchema(s.Properties[name]))
+			if !ok {
+				continue
+			}
+			out := make(map[string]any, len(obj))
+			for k, old := range obj {
+				out[k] = old
+			}
+			out[name] = v
+			return out, name
+		}
+	}
+	return body, ""
+}
+
+// perturbValue returns a value other than v that s still accepts, if it can tell.
+func perturbValue(v any, s *openapi3.Schema) (any, bool) {
+	if s == nil || s.Type == nil || len(s.Enum) > 0 || s.ReadOnly {
+		return nil, false
+	}
+	switch x := v.(type) {
+	case string:
+		if !s.Type.Is("string") || s.Format != "" || s.Pattern != "" {
+			return nil, false
+		}
+		p := x + "-aperture"
+		if s.MaxLength != nil && uint64(len(p)) > *s.MaxLength {
+			return nil, false
+		}
+		return p, true
+	case bool:
+		return !x, true
+	case int:
+		if s.Max != nil && float64(x+1) > *s.Max {
+			return nil, false
+		}
+		return x + 1, true
+	case float64:
+		if s.Max != nil && x+1 > *s.Max {
+			return nil, false
+		}
+		return x + 1, true
+	}
+	return nil, false
+}
+
+func sameJSON(a, b any) bool {
+	aj, errA := json.Marshal(a)
+	bj, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(aj, bj)
+}
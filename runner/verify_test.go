@@ -0,0 +1,177 @@
+package runner
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+const titlesSpec = `
+openapi: 3.0.3
+info: {title: titles, version: "1"}
+security: [{ApiKeyAuth: []}]
+paths:
+  /notes/{note_id}/title:
+    parameters:
+      - {name: note_id, in: path, required: true, schema: {type: integer}}
+    get:
+      responses:
+        "200": {description: OK}
+    put:
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              required: [title, color]
+              properties:
+                title: {type: string}
+                color: {type: string, enum: [red, blue]}
+      responses:
+        "200": {description: OK}
+components:
+  securitySchemes:
+    ApiKeyAuth: {type: apiKey, in: header, name: X-API-Key}
+`
+
+// titleStore serves the titles of notes 1 and 2. mode decides what a PUT by someone other
+// than the owner does: "apply" the write, "ignore" it, or "bump" a revision counter only.
+type titleStore struct {
+	mu       sync.Mutex
+	titles   map[string]string
+	revision int
+}
+
+func newTitleAPI(t *testing.T, mode string) *testAPI {
+	api := newTestAPI(t)
+	store := &titleStore{titles: map[string]string{"1": "alice's", "2": "bob's"}}
+	api.Mux.HandleFunc("GET /notes/{note_id}/title", func(w http.ResponseWriter, req *http.Request) {
+		if _, ok := api.user(w, req); !ok {
+			return
+		}
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		writeTestJSON(w, http.StatusOK, map[string]any{"title": store.titles[req.PathValue("note_id")], "revision": store.revision})
+	})
+	api.Mux.HandleFunc("PUT /notes/{note_id}/title", func(w http.ResponseWriter, req *http.Request) {
+		user, ok := api.user(w, req)
+		if !ok {
+			return
+		}
+		note, ok := api.note(w, req)
+		if !ok {
+			return
+		}
+		var body struct{ Title string }
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			writeTestJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		switch {
+		case note.Owner == user || mode == "apply":
+			store.titles[req.PathValue("note_id")] = body.Title
+		case mode == "bump":
+			store.revision++
+		}
+		writeTestJSON(w, http.StatusOK, map[string]bool{"ok": true})
+	})
+	return api
+}
+
+func runTitles(t *testing.T, mode string) []ResultLog {
+	t.Helper()
+	api := newTitleAPI(t, mode)
+	r := newTestRunner(api, parseTestSpec(t, titlesSpec))
+	r.AllowMutations = true
+	r.VerifyWrites = true
+	var out []ResultLog
+	for _, res := range execute(t, r) {
+		if res.Method == "PUT" {
+			out = append(out, res)
+		}
+	}
+	if len(out) != 2 {
+		t.Fatalf("got %d PUT results, want 2", len(out))
+	}
+	return out
+}
+
+func TestVerifyWriteAttributed(t *testing.T) {
+	for _, res := range runTitles(t, "apply") {
+		ctrl := res.Control.Request.Body.(map[string]any)
+		test := res.Test.Request.Body.(map[string]any)
+		// color has an enum, so title is the property that is changed
+		if test["title"] != ctrl["title"].(string)+"-aperture" || test["color"] != ctrl["color"] {
+			t.Errorf("test body %v, control body %v: want only title changed", test, ctrl)
+		}
+		if !containsNote(res.Test.Request.Notes, "title differs from the control's") {
+			t.Errorf("test request notes = %q", res.Test.Request.Notes)
+		}
+		if res.Result != ResultIDORFound || !res.WriteVerified || !containsNote(res.Notes, "now has the attacker's title") {
+			t.Errorf("result %s verified=%v notes %q", res.Result, res.WriteVerified, res.Notes)
+		}
+		if res.Confidence >= 1 {
+			t.Errorf("confidence = %v, a read-back alone must not force 1", res.Confidence)
+		}
+		if len(res.Verification) != 2 {
+			t.Errorf("verification has %d exchanges, want 2", len(res.Verification))
+		}
+	}
+}
+
+func TestVerifyWriteUnchanged(t *testing.T) {
+	for _, res := range runTitles(t, "ignore") {
+		if res.Result != ResultPotential || res.WriteVerified || !containsNote(res.Notes, "verified: object unchanged") {
+			t.Errorf("result %s verified=%v notes %q", res.Result, res.WriteVerified, res.Notes)
+		}
+	}
+}
+
+func TestVerifyWriteUnattributedChange(t *testing.T) {
+	for _, res := range runTitles(t, "bump") {
+		if res.WriteVerified || !containsNote(res.Notes, "the change may have another cause") {
+			t.Errorf("result %s verified=%v notes %q", res.Result, res.WriteVerified, res.Notes)
+		}
+		if res.Result != ResultPotential && res.Result != ResultIDORFound {
+			t.Errorf("result %s, want POTENTIAL or an earlier IDOR FOUND kept", res.Result)
+		}
+	}
+}
+
+func TestPerturbValue(t *testing.T) {
+	maxLen := uint64(8)
+	maxNum := 5.0
+	str := &openapi3.Schema{Type: &openapi3.Types{"string"}}
+	tests := []struct {
+		v      any
+		s      *openapi3.Schema
+		want   any
+		wantOK bool
+	}{
+		{"abc", str, "abc-aperture", true},
+		{"abc", &openapi3.Schema{Type: &openapi3.Types{"string"}, MaxLength: &maxLen}, nil, false},
+		{"a@b.c", &openapi3.Schema{Type: &openapi3.Types{"string"}, Format: "email"}, nil, false},
+		{"red", &openapi3.Schema{Type: &openapi3.Types{"string"}, Enum: []any{"red", "blue"}}, nil, false},
+		{"1", &openapi3.Schema{Type: &openapi3.Types{"integer"}}, nil, false},
+		{true, &openapi3.Schema{Type: &openapi3.Types{"boolean"}}, false, true},
+		{1, &openapi3.Schema{Type: &openapi3.Types{"integer"}}, 2, true},
+		{5, &openapi3.Schema{Type: &openapi3.Types{"integer"}, Max: &maxNum}, nil, false},
+		{1.5, &openapi3.Schema{Type: &openapi3.Types{"number"}}, 2.5, true},
+		{"abc", &openapi3.Schema{Type: &openapi3.Types{"string"}, ReadOnly: true}, nil, false},
+	}
+	for _, tt := range tests {
+		got, ok := perturbValue(tt.v, tt.s)
+		if ok != tt.wantOK || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("perturbValue(%#v) = %#v, %v; want %#v, %v", tt.v, got, ok, tt.want, tt.wantOK)
+		}
+	}
+	if got, ok := perturbValue("xyz", nil); ok {
+		t.Errorf("nil schema: %v", got)
+	}
+}
@@ -0,0 +1,81 @@
+package runner
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/yansol0/aperture/testconfig"
+)
+
+// stripFields removes the ignored fields from the decoded JSON value v in place and returns
+// it. A bare name removes that key wherever it occurs; a dot-separated path or JSON pointer
+// removes the single location it points at. Missing fields are ignored.
+func stripFields(v any, ignore []string) any {
+	if len(ignore) == 0 {
+		return v
+	}
+	names := map[string]bool{}
+	for _, f := range ignore {
+		if strings.ContainsAny(f, "./") {
+			v = removePointer(v, testconfig.DotPathToPointer(f))
+		} else {
+			names[f] = true
+		}
+	}
+	if len(names) > 0 {
+		v = removeKeys(v, names)
+	}
+	return v
+}
+
+func removeKeys(v any, names map[string]bool) any {
+	switch n := v.(type) {
+	case map[string]any:
+		for k, child := range n {
+			if names[k] {
+				delete(n, k)
+				continue
+			}
+			n[k] = removeKeys(child, names)
+		}
+	case []any:
+		for i := range n {
+			n[i] = removeKeys(n[i], names)
+		}
+	}
+	return v
+}
+
+// removePointer deletes the object key or array element at the RFC 6901 pointer.
+func removePointer(v any, pointer string) any {
+	if pointer == "" || !strings.HasPrefix(pointer, "/") {
+		return v
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return removeAt(v, tokens)
+}
+
+func removeAt(v any, tokens []string) any {
+	last := len(tokens) == 1
+	switch n := v.(type) {
+	case map[string]any:
+		if last {
+			delete(n, tokens[0])
+		} else if child, ok := n[tokens[0]]; ok {
+			n[tokens[0]] = removeAt(child, tokens[1:])
+		}
+	case []any:
+		i, err := strconv.Atoi(tokens[0])
+		if err != nil || i < 0 || i >= len(n) {
+			return v
+		}
+		if last {
+			return append(n[:i:i], n[i+1:]...)
+		}
+		n[i] = removeAt(n[i], tokens[1:])
+	}
+	return v
+}
@@ -0,0 +1,88 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+func TestStripFields(t *testing.T) {
+	const doc = `{"id": 7, "updated_at": "t1", "meta": {"updated_at": "t2", "generated_at": "t3"},
+		"items": [{"ts": "a", "v": 1}, {"ts": "b", "v": 2}]}`
+	tests := []struct {
+		ignore []string
+		want   string
+	}{
+		{nil, doc},
+		{[]string{"updated_at"}, `{"id": 7, "meta": {"generated_at": "t3"}, "items": [{"ts": "a", "v": 1}, {"ts": "b", "v": 2}]}`},
+		{[]string{"meta.generated_at"}, `{"id": 7, "updated_at": "t1", "meta": {"updated_at": "t2"}, "items": [{"ts": "a", "v": 1}, {"ts": "b", "v": 2}]}`},
+		{[]string{"/items/0/ts", "/items/5/ts", "missing.path"}, `{"id": 7, "updated_at": "t1", "meta": {"updated_at": "t2", "generated_at": "t3"}, "items": [{"v": 1}, {"ts": "b", "v": 2}]}`},
+		{[]string{"/items/1"}, `{"id": 7, "updated_at": "t1", "meta": {"updated_at": "t2", "generated_at": "t3"}, "items": [{"ts": "a", "v": 1}]}`},
+	}
+	for _, tt := range tests {
+		var v, want any
+		if err := json.Unmarshal([]byte(doc), &v); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+			t.Fatal(err)
+		}
+		if got := stripFields(v, tt.ignore); !reflect.DeepEqual(got, want) {
+			t.Errorf("stripFields(%q) = %v, want %v", tt.ignore, got, want)
+		}
+	}
+}
+
+// TestIgnoreFieldsMatchVolatileBodies runs an endpoint that returns the note's content with
+// a fresh timestamp and request id: only with those fields ignored do the attacker's and the
+// owner's bodies compare equal.
+func TestIgnoreFieldsMatchVolatileBodies(t *testing.T) {
+	api := newTestAPI(t)
+	var n atomic.Int32
+	api.Mux.HandleFunc("GET /notes/{note_id}/stamp", func(w http.ResponseWriter, req *http.Request) {
+		if _, ok := api.user(w, req); !ok {
+			return
+		}
+		note, ok := api.note(w, req)
+		if !ok {
+			return
+		}
+		// Letters only, so the owner's fields never appear in the body
+		stamp := fmt.Sprintf("t%c", 'a'+n.Add(1))
+		writeTestJSON(w, http.StatusOK, map[string]any{
+			"content":    note.Content,
+			"updated_at": stamp,
+			"meta":       map[string]string{"request_id": "r" + stamp},
+		})
+	})
+	spec := parseTestSpec(t, `
+openapi: 3.0.3
+info: {title: stamp, version: "1"}
+security: [{ApiKeyAuth: []}]
+paths:
+`+noteOperation("stamp")+`
+components:
+  securitySchemes:
+    ApiKeyAuth: {type: apiKey, in: header, name: X-API-Key}
+`)
+	for _, tt := range []struct {
+		ignore []string
+		want   string
+	}{
+		{nil, ResultSecure},
+		{[]string{"updated_at"}, ResultSecure},
+		{[]string{"updated_at", "meta.request_id"}, ResultIDORFound},
+		{[]string{"updated_at", "/meta/request_id"}, ResultIDORFound},
+	} {
+		r := newTestRunner(api, spec)
+		r.Config.Users[1].Fields = map[string]string{"user_id": "bob"}
+		r.Config.IgnoreFields = tt.ignore
+		want := map[string]map[string]int{"GET /notes/{note_id}/stamp": {tt.want: 1}}
+		if got := verdicts(execute(t, r)); !reflect.DeepEqual(got, want) {
+			t.Errorf("ignore_fields %q: verdicts = %v, want %v", tt.ignore, got, want)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package runner
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/yansol0/aperture/testconfig"
+)
+
+// warmUp sends WarmupRequests requests to the endpoint as owner before its first pair and
+// discards them, so a cache in front of the API serves the measured control and test
+// alike. Write methods are never warmed up, since every request would change data. The
+// requests go through sendOne, so they are paced and counted like any other.
+func (r *Runner) warmUp(ctx context.Context, client *http.Client, method, path string, op *openapi3.Operation, item *openapi3.PathItem, owner testconfig.User, required map[string]paramSpec) {
+	if r.WarmupRequests <= 0 || isWriteMethod(method) {
+		return
+	}
+	r.logf("[*] Warming up %s %s with %d request(s) as %s", method, path, r.WarmupRequests, owner.Name)
+	for i := 0; i < r.WarmupRequests; i++ {
+		if _, _, err := r.sendOne(ctx, client, method, path, op, item, owner, owner, required); err != nil {
+			r.logf("[~] Warm-up request for %s %s failed: %v", method, path, err)
+			return
+		}
+	}
+}
@@ -8,6 +8,9 @@ COMPOSE_FILE="$TEST_DIR/docker-compose.yml"
 SPEC_FILE="$TEST_DIR/openapi.json"
 CONFIG_FILE="$TEST_DIR/test_config.yml"
 OUT_FILE="$TEST_DIR/output.jsonl"
+EXPECTED_FILE="$TEST_DIR/expected_results.tsv"
+CALLBACKS_SPEC="$TEST_DIR/openapi_callbacks.yaml"
+CALLBACKS_OUT="$TEST_DIR/output_callbacks.jsonl"
 
 # Determine how to invoke Docker
 DOCKER=docker
@@ -36,24 +39,61 @@ done
 
 echo "[3/4] Running aperture scanner..."
 rm -f "$OUT_FILE"
-GO111MODULE=on go run "$ROOT_DIR/main.go" --spec "$SPEC_FILE" --config "$CONFIG_FILE" --base-url "http://localhost:8080" --out "$OUT_FILE" -v
+GO111MODULE=on go run "$ROOT_DIR/main.go" --spec "$SPEC_FILE" --config "$CONFIG_FILE" --base-url "http://localhost:8080" --out "$OUT_FILE" --jsonl --no-tui -v
 
 # Summarize results without external deps
 echo "[3.5/4] Summarizing results..."
+MISMATCHES=0
 if [[ ! -f "$OUT_FILE" ]]; then
   echo "No output file found at $OUT_FILE" >&2
+  MISMATCHES=1
 else
-  SECURE=$(grep -o '"result":"SECURE"' "$OUT_FILE" | wc -l | tr -d ' ')
-  IDOR=$(grep -o '"result":"IDOR FOUND"' "$OUT_FILE" | wc -l | tr -d ' ')
-  POTENTIAL=$(grep -o '"result":"POTENTIAL"' "$OUT_FILE" | wc -l | tr -d ' ')
-  CTRL_FAILED=$(grep -o '"result":"CONTROL_FAILED"' "$OUT_FILE" | wc -l | tr -d ' ')
-  SKIPPED=$(grep -o '"result":"SKIPPED"' "$OUT_FILE" | wc -l | tr -d ' ')
+  SECURE=$(grep -c '"result":"SECURE"' "$OUT_FILE" || true)
+  IDOR=$(grep -c '"result":"IDOR FOUND"' "$OUT_FILE" || true)
+  POTENTIAL=$(grep -c '"result":"POTENTIAL"' "$OUT_FILE" || true)
+  CTRL_FAILED=$(grep -c '"result":"CONTROL_FAILED"' "$OUT_FILE" || true)
+  SKIPPED=$(grep -c '"result":"SKIPPED"' "$OUT_FILE" || true)
   TESTED=$((SECURE + IDOR + POTENTIAL + CTRL_FAILED))
   FAILED=$((IDOR + POTENTIAL + CTRL_FAILED))
   echo "Results: tested=$TESTED, passed=$SECURE, failed=$FAILED (idor=$IDOR, potential=$POTENTIAL, control_failed=$CTRL_FAILED), skipped=$SKIPPED"
+
+  # Every result must match a line of the expected verdicts, and there must be no others
+  EXPECTED_TOTAL=0
+  while IFS=$'\t' read -r method endpoint result count; do
+    [[ -z "$method" || "$method" == \#* ]] && continue
+    GOT=$(grep -F "\"endpoint\":\"$endpoint\",\"method\":\"$method\"," "$OUT_FILE" | grep -cF "\"result\":\"$result\"" || true)
+    if [[ "$GOT" -ne "$count" ]]; then
+      echo "MISMATCH: $method $endpoint: expected $count $result, got $GOT" >&2
+      MISMATCHES=$((MISMATCHES + 1))
+    fi
+    EXPECTED_TOTAL=$((EXPECTED_TOTAL + count))
+  done < "$EXPECTED_FILE"
+  TOTAL=$(grep -c . "$OUT_FILE" || true)
+  if [[ "$TOTAL" -ne "$EXPECTED_TOTAL" ]]; then
+    echo "MISMATCH: expected $EXPECTED_TOTAL results, got $TOTAL" >&2
+    MISMATCHES=$((MISMATCHES + 1))
+  fi
+fi
+
+# The callback and the webhook must be skipped, and nothing sent to the callback URL expression
+echo "[3.6/4] Scanning the callbacks and webhooks spec..."
+rm -f "$CALLBACKS_OUT"
+GO111MODULE=on go run "$ROOT_DIR/main.go" --spec "$CALLBACKS_SPEC" --config "$CONFIG_FILE" --base-url "http://localhost:8080" --out "$CALLBACKS_OUT" --jsonl --no-tui --quiet
+CALLBACKS=$(grep -c '"skipped_reason":"callback definition"' "$CALLBACKS_OUT" || true)
+if [[ "$CALLBACKS" -ne 2 ]]; then
+  echo "MISMATCH: expected 2 callback definition skips, got $CALLBACKS" >&2
+  MISMATCHES=$((MISMATCHES + 1))
+fi
+if grep -F '$request' "$CALLBACKS_OUT" | grep -qvF '"skipped_reason":"callback definition"'; then
+  echo "MISMATCH: a callback URL expression was tested" >&2
+  MISMATCHES=$((MISMATCHES + 1))
 fi
 
 echo "[4/4] Tearing down containers..."
 $DOCKER compose -f "$COMPOSE_FILE" down -v
 
-echo "Done. Output log: $OUT_FILE" 
\ No newline at end of file
+echo "Done. Output log: $OUT_FILE"
+if [[ $MISMATCHES -gt 0 ]]; then
+  echo "FAILED: $MISMATCHES result(s) differ from $EXPECTED_FILE" >&2
+  exit 1
+fi 
\ No newline at end of file
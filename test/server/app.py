@@ -69,6 +69,25 @@ def get_note(note_id: int):
 	return jsonify({"id": row[0], "owner": row[1], "title": row[2], "content": row[3]}), 200
 
 
+# VULNERABLE: other users get a shortened preview instead of the full note, so the body
+# differs from the owner's, but the preview still exposes the note's id, owner and title
+@app.get("/notes/<int:note_id>/preview")
+def preview_note(note_id: int):
+	user = get_auth_user()
+	if user is None:
+		return jsonify({"error": "unauthorized"}), 401
+	conn = get_db_connection()
+	cur = conn.cursor()
+	cur.execute("SELECT id, owner, title, content FROM notes WHERE id = ?", (note_id,))
+	row = cur.fetchone()
+	conn.close()
+	if not row:
+		return jsonify({"error": "not_found"}), 404
+	if row[1] != user:
+		return jsonify({"id": row[0], "owner": row[1], "title": row[2]}), 200
+	return jsonify({"id": row[0], "owner": row[1], "title": row[2], "content": row[3], "shared": False}), 200
+
+
 # Add a query-param based endpoint for IDOR testing
 # VULNERABLE: allows listing notes for any provided org_id (mapped to owner) without verifying it matches the auth user
 @app.get("/notes/by-org")
@@ -1,32 +1,180 @@
 package testconfig
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
-	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 type Auth struct {
-	Type       string `yaml:"type"` // "header" or "cookie"
+	Type       string `yaml:"type"` // "header", "cookie" or "signed_query"
 	Value      string `yaml:"value"`
 	HeaderName string `yaml:"header_name"` // optional; defaults to Authorization
+	// Signing is the recipe of a signed_query credential; Value is not used.
+	Signing *QuerySigning `yaml:"signing"`
+	// Refresh optionally obtains a new Value when the user's own requests start
+	// failing with 401, e.g. because a token expired during a long run.
+	Refresh *AuthRefresh `yaml:"refresh"`
+}
+
+// AuthRefresh is a request that returns a fresh credential for a user in a JSON response.
+type AuthRefresh struct {
+	Method  string            `yaml:"method"` // optional; defaults to POST
+	URL     string            `yaml:"url"`    // absolute URL, or a path relative to the base URL
+	Headers map[string]string `yaml:"headers"`
+	Body    string            `yaml:"body"` // sent as-is; Content-Type defaults to application/json
+	// Token is a dot-separated key or JSON pointer locating the credential in the response.
+	Token string `yaml:"token"`
+	// Prefix is prepended to the extracted token, e.g. "Bearer ".
+	Prefix string `yaml:"prefix"`
 }
 
 type User struct {
 	Name   string            `yaml:"name"`
 	Auth   Auth              `yaml:"auth"`
 	Fields map[string]string `yaml:"fields"`
+	// Delay is the minimum time between two requests sent with this user's credentials,
+	// e.g. "500ms" for a rate-limited service account.
+	Delay time.Duration `yaml:"delay"`
+	// MaxConcurrent caps the requests in flight with this user's credentials when
+	// operations run concurrently; 0 means no cap.
+	MaxConcurrent int `yaml:"max_concurrent"`
+}
+
+// EndpointOverride adjusts how a single operation is exercised.
+// An empty Method matches every method on Path.
+type EndpointOverride struct {
+	Method string `yaml:"method"`
+	Path   string `yaml:"path"`
+	Accept string `yaml:"accept"` // optional; replaces the Accept header derived from the spec
+	// BodyTemplate is an optional text/template rendering the JSON request body
+	// in place of schema synthesis. It is executed with TemplateData.
+	BodyTemplate string `yaml:"body_template"`
+	// VerifyPath is an optional path whose GET operation is used to check whether
+	// a cross-user write actually changed the object; defaults to a GET on Path.
+	VerifyPath string `yaml:"verify_path"`
+	// Cleanup lists requests sent as the object owner after each pair on this endpoint
+	// whose control succeeded, e.g. to re-create an object a DELETE removed.
+	Cleanup []CleanupRequest `yaml:"cleanup"`
+	// Delay is the minimum time between two requests to this endpoint, by any user. An
+	// override without a method spaces requests to every method on Path together.
+	Delay time.Duration `yaml:"delay"`
+	// MaxConcurrent caps the requests in flight to this endpoint, by any user, when
+	// operations run concurrently; 0 means no cap. Like Delay, an override without a
+	// method counts every method on Path together.
+	MaxConcurrent int `yaml:"max_concurrent"`
+
+	bodyTemplate *template.Template // BodyTemplate parsed by Load
+}
+
+// CleanupRequest is one request of an endpoint's cleanup sequence. Its body comes from
+// the endpoint override for Method and Path if one sets body_template, otherwise it is
+// synthesized from the spec like any other request.
+type CleanupRequest struct {
+	Method string `yaml:"method"`
+	Path   string `yaml:"path"`
+	// Capture maps a user field to a dot-separated key or JSON pointer in the JSON response,
+	// e.g. "note_id: id" or "note_id: /items/0/id"; the captured value replaces the owner's
+	// field for later tests.
+	Capture map[string]string `yaml:"capture"`
+}
+
+// TemplateData is the value a body_template is executed against.
+type TemplateData struct {
+	Object     map[string]string // fields of the object owner
+	Creds      map[string]string // fields of the user whose credentials are sent
+	ObjectUser string
+	CredUser   string
+}
+
+var templateFuncs = template.FuncMap{
+	// json renders v as a JSON literal, e.g. {"id": {{json .Object.user_id}}}
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+func (o EndpointOverride) parseBodyTemplate() (*template.Template, error) {
+	return template.New(o.Method + " " + o.Path).Funcs(templateFuncs).Option("missingkey=error").Parse(o.BodyTemplate)
+}
+
+// RenderBody executes the override's body template. It returns the rendered bytes, which
+// are sent as they are, and their parsed JSON value for logging.
+func (o EndpointOverride) RenderBody(data TemplateData) ([]byte, any, error) {
+	tmpl := o.bodyTemplate
+	if tmpl == nil {
+		var err error
+		if tmpl, err = o.parseBodyTemplate(); err != nil {
+			return nil, nil, err
+		}
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, nil, err
+	}
+	var body any
+	if err := json.Unmarshal(buf.Bytes(), &body); err != nil {
+		return nil, nil, fmt.Errorf("body_template did not produce valid JSON: %w", err)
+	}
+	return buf.Bytes(), body, nil
 }
 
 type Config struct {
-	Users                 []User `yaml:"users"`
-	DefaultAuthHeaderName string `yaml:"default_auth_header_name"`
+	Users                 []User             `yaml:"users"`
+	DefaultAuthHeaderName string             `yaml:"default_auth_header_name"`
+	EndpointOverrides     []EndpointOverride `yaml:"endpoint_overrides"`
+	// DefaultFields are merged into every user's Fields at load; a user's own value wins.
+	DefaultFields map[string]string `yaml:"default_fields"`
+	// SensitiveKeys lists JSON key names (case-insensitive; "*" wildcards allowed, e.g. "password*")
+	// whose presence in an attacker's response is reported regardless of value.
+	SensitiveKeys []string `yaml:"sensitive_keys"`
+	// IgnoreFields lists volatile JSON fields, such as timestamps or request IDs, removed from
+	// both responses before they are compared. A bare name matches the key at any depth; a
+	// dot-separated path ("meta.generated_at") or JSON pointer ("/data/0/ts") one location.
+	IgnoreFields []string `yaml:"ignore_fields"`
+	// JWT, when set, reads JWT credentials: claims can populate fields, and expired tokens
+	// and users sharing a subject are reported before the scan.
+	JWT *JWTOptions `yaml:"jwt"`
+	// AllowReservedFields names path parameters whose values keep reserved characters
+	// such as "/" unencoded, like OpenAPI's allowReserved.
+	AllowReservedFields []string `yaml:"allow_reserved_fields"`
+	// SkipPaths extends the built-in infrastructure path patterns (case-insensitive; "*"
+	// wildcards allowed) with paths that are never tested, e.g. "/internal/*".
+	SkipPaths []string `yaml:"skip_paths"`
+	// ErrorIndicators are dot-separated paths ("errors[0].code" also works) or JSON pointers
+	// whose presence marks a 2xx JSON response as an error envelope, i.e. a denial.
+	ErrorIndicators []string `yaml:"error_indicators"`
+	// FieldsCSV fills user fields from a CSV file; the user's own fields win.
+	FieldsCSV *FieldsCSV `yaml:"fields_csv"`
+	// IncludeProperties names optional request body properties synthesized at any depth
+	// even without a matching user field, for servers that require them despite the spec.
+	IncludeProperties []string `yaml:"include_properties"`
+	// NullProperties names optional request body properties sent as null at any depth. One
+	// whose schema is not nullable is synthesized instead, since null would be invalid.
+	NullProperties []string `yaml:"null_properties"`
+	// Body tunes request body synthesis like the --body-* flags, which win when given.
+	Body *BodySynthesis `yaml:"body"`
+}
+
+// BodySynthesis is the config form of the body synthesis options; see runner.BodyOptions.
+type BodySynthesis struct {
+	MaxDepth        int  `yaml:"max_depth"`
+	ArrayCap        int  `yaml:"array_cap"`
+	IncludeOptional bool `yaml:"include_optional"`
 }
 
 func Load(path string) (Config, error) {
 	var cfg Config
-	b, err := os.ReadFile(path)
+	b, err := ReadInput(path)
 	if err != nil {
 		return cfg, fmt.Errorf("read config: %w", err)
 	}
@@ -36,5 +184,80 @@ func Load(path string) (Config, error) {
 	if cfg.DefaultAuthHeaderName == "" {
 		cfg.DefaultAuthHeaderName = "Authorization"
 	}
+	// Per-user sources take precedence over default_fields; the CSV is explicit data, so
+	// it also wins over claims
+	if err := cfg.applyFieldsCSV(filepath.Dir(path)); err != nil {
+		return cfg, err
+	}
+	cfg.applyClaims()
+	for i := range cfg.Users {
+		if cfg.Users[i].Delay < 0 {
+			return cfg, fmt.Errorf("users[%d] delay must not be negative", i)
+		}
+		if cfg.Users[i].MaxConcurrent < 0 {
+			return cfg, fmt.Errorf("users[%d] max_concurrent must not be negative", i)
+		}
+		if cfg.Users[i].Auth.Type == AuthSignedQuery {
+			if cfg.Users[i].Auth.Signing == nil {
+				return cfg, fmt.Errorf("users[%d] auth: type %s needs signing", i, AuthSignedQuery)
+			}
+			if err := cfg.Users[i].Auth.Signing.validate(); err != nil {
+				return cfg, fmt.Errorf("users[%d] auth.signing: %w", i, err)
+			}
+		}
+		if rf := cfg.Users[i].Auth.Refresh; rf != nil {
+			if rf.URL == "" || rf.Token == "" {
+				return cfg, fmt.Errorf("users[%d] auth.refresh: url and token are required", i)
+			}
+			if rf.Method == "" {
+				rf.Method = "POST"
+			}
+		}
+		if cfg.Users[i].Fields == nil && len(cfg.DefaultFields) > 0 {
+			cfg.Users[i].Fields = map[string]string{}
+		}
+		for k, v := range cfg.DefaultFields {
+			if _, ok := cfg.Users[i].Fields[k]; !ok {
+				cfg.Users[i].Fields[k] = v
+			}
+		}
+	}
+	if b := cfg.Body; b != nil && (b.MaxDepth < 0 || b.ArrayCap < 0) {
+		return cfg, fmt.Errorf("body max_depth and array_cap must not be negative")
+	}
+	for i, o := range cfg.EndpointOverrides {
+		if o.Delay < 0 {
+			return cfg, fmt.Errorf("endpoint_overrides[%d] delay must not be negative", i)
+		}
+		if o.MaxConcurrent < 0 {
+			return cfg, fmt.Errorf("endpoint_overrides[%d] max_concurrent must not be negative", i)
+		}
+		if o.BodyTemplate != "" {
+			tmpl, err := o.parseBodyTemplate()
+			if err != nil {
+				return cfg, fmt.Errorf("endpoint_overrides[%d] body_template: %w", i, err)
+			}
+			cfg.EndpointOverrides[i].bodyTemplate = tmpl
+		}
+		for j, c := range o.Cleanup {
+			if c.Method == "" || c.Path == "" {
+				return cfg, fmt.Errorf("endpoint_overrides[%d] cleanup[%d]: method and path are required", i, j)
+			}
+		}
+	}
 	return cfg, nil
 }
+
+// OverrideFor returns the first endpoint override matching method and path.
+func (c Config) OverrideFor(method, path string) (EndpointOverride, bool) {
+	for _, o := range c.EndpointOverrides {
+		if o.Path != path {
+			continue
+		}
+		if o.Method != "" && !strings.EqualFold(o.Method, method) {
+			continue
+		}
+		return o, true
+	}
+	return EndpointOverride{}, false
+}
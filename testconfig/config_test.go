@@ -0,0 +1,116 @@
+package testconfig
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// loadConfig writes yml to a temporary file and loads it.
+func loadConfig(t *testing.T, yml string) Config {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte(yml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return cfg
+}
+
+func TestRenderBodyVerbatim(t *testing.T) {
+	cfg := loadConfig(t, `
+users:
+  - name: alice
+    auth: {type: header, value: KEY_ALICE}
+    fields: {note_id: "1"}
+endpoint_overrides:
+  - method: PUT
+    path: /notes/{note_id}
+    body_template: '{"title": "t", "amount": 1.50, "id": {{json .Object.note_id}}}'
+`)
+	ov, ok := cfg.OverrideFor("put", "/notes/{note_id}")
+	if !ok {
+		t.Fatal("override not found")
+	}
+	if ov.bodyTemplate == nil {
+		t.Error("Load did not keep the parsed body_template")
+	}
+	raw, value, err := ov.RenderBody(TemplateData{Object: map[string]string{"note_id": "1"}})
+	if err != nil {
+		t.Fatalf("RenderBody: %v", err)
+	}
+	// Key order and number formatting survive, which re-marshalling would lose
+	if want := `{"title": "t", "amount": 1.50, "id": "1"}`; string(raw) != want {
+		t.Errorf("raw = %s, want %s", raw, want)
+	}
+	if want := map[string]any{"title": "t", "amount": 1.5, "id": "1"}; !reflect.DeepEqual(value, want) {
+		t.Errorf("value = %v, want %v", value, want)
+	}
+}
+
+func TestRenderBodyWithoutLoad(t *testing.T) {
+	ov := EndpointOverride{Path: "/x", BodyTemplate: `{"user": {{json .CredUser}}}`}
+	raw, _, err := ov.RenderBody(TemplateData{CredUser: "bob"})
+	if err != nil || string(raw) != `{"user": "bob"}` {
+		t.Errorf("RenderBody = %s, %v", raw, err)
+	}
+	ov.BodyTemplate = `{"user": {{.CredUser}}}`
+	if _, _, err := ov.RenderBody(TemplateData{CredUser: "bob"}); err == nil {
+		t.Error("invalid JSON was accepted")
+	}
+}
+
+func TestLoadBodySynthesis(t *testing.T) {
+	cfg := loadConfig(t, `
+users:
+  - name: alice
+body:
+  max_depth: 4
+  array_cap: 3
+  include_optional: true
+`)
+	if want := (BodySynthesis{MaxDepth: 4, ArrayCap: 3, IncludeOptional: true}); cfg.Body == nil || *cfg.Body != want {
+		t.Errorf("Body = %+v, want %+v", cfg.Body, want)
+	}
+
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte("body: {max_depth: -1}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Error("negative max_depth was accepted")
+	}
+}
+
+func TestDefaultFields(t *testing.T) {
+	cfg := loadConfig(t, `
+default_fields:
+  tenant_id: acme
+  region: eu
+users:
+  - name: alice
+    fields: {user_id: "1"}
+  - name: bob
+    fields: {user_id: "2", tenant_id: globex}
+  - name: carol
+`)
+	want := []map[string]string{
+		{"user_id": "1", "tenant_id": "acme", "region": "eu"},
+		{"user_id": "2", "tenant_id": "globex", "region": "eu"},
+		{"tenant_id": "acme", "region": "eu"},
+	}
+	for i, u := range cfg.Users {
+		if !reflect.DeepEqual(u.Fields, want[i]) {
+			t.Errorf("%s fields = %v, want %v", u.Name, u.Fields, want[i])
+		}
+	}
+	// Each user gets its own map, so a change to one user's fields stays with that user
+	cfg.Users[0].Fields["region"] = "us"
+	if cfg.Users[2].Fields["region"] != "eu" {
+		t.Error("users share the default fields map")
+	}
+}
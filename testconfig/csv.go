@@ -0,0 +1,139 @@
+package testconfig
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// FieldsCSV reads user fields from a CSV file, e.g. exported from the application's
+// database. The header row names the columns; each row is an object owned by the user
+// in UserColumn, and every other non-empty cell becomes a field of that user.
+type FieldsCSV struct {
+	// Path is the CSV file, relative to the config file unless absolute.
+	Path string `yaml:"path"`
+	// UserColumn names the column holding the owning user's name; defaults to "user".
+	UserColumn string `yaml:"user_column"`
+	// Columns renames header columns to field names, e.g. {order_uuid: order_id}.
+	// Unlisted columns keep their header as the field name.
+	Columns map[string]string `yaml:"columns"`
+}
+
+// applyFieldsCSV copies the fields of each user's first CSV row into fields the user
+// does not set. Users appear by name and must be defined in users, since credentials
+// only come from the YAML. Later rows of the same user are ignored: a user has one value
+// per field, so one object per endpoint.
+func (c *Config) applyFieldsCSV(configDir string) error {
+	if c.FieldsCSV == nil {
+		return nil
+	}
+	fc := c.FieldsCSV
+	if fc.Path == "" {
+		return fmt.Errorf("fields_csv: path is required")
+	}
+	path := fc.Path
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(configDir, path)
+	}
+	f, err := OpenInput(path)
+	if err != nil {
+		return fmt.Errorf("fields_csv: %w", err)
+	}
+	defer f.Close()
+	rows, err := ReadFieldsCSV(f, fc.UserColumn, fc.Columns)
+	if err != nil {
+		return fmt.Errorf("fields_csv %s: %w", fc.Path, err)
+	}
+	index := map[string]int{}
+	for i, u := range c.Users {
+		index[u.Name] = i
+	}
+	seen := map[string]bool{}
+	for _, row := range rows {
+		i, ok := index[row.User]
+		if !ok {
+			return fmt.Errorf("fields_csv %s line %d: user %q is not defined in users", fc.Path, row.Line, row.User)
+		}
+		if seen[row.User] {
+			continue
+		}
+		seen[row.User] = true
+		if c.Users[i].Fields == nil {
+			c.Users[i].Fields = map[string]string{}
+		}
+		for k, v := range row.Fields {
+			if _, set := c.Users[i].Fields[k]; !set {
+				c.Users[i].Fields[k] = v
+			}
+		}
+	}
+	return nil
+}
+
+// FieldsRow is one data row of a fields CSV.
+type FieldsRow struct {
+	Line   int // 1-based line number in the file
+	User   string
+	Fields map[string]string
+}
+
+// ReadFieldsCSV parses a CSV with a header row. userColumn ("user" when empty) names the
+// owner column; columns renames the other headers to field names. Empty cells are left
+// out of the row's fields.
+func ReadFieldsCSV(r io.Reader, userColumn string, columns map[string]string) ([]FieldsRow, error) {
+	if userColumn == "" {
+		userColumn = "user"
+	}
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("missing header row")
+	}
+	if err != nil {
+		return nil, err
+	}
+	userIdx := -1
+	names := make([]string, len(header))
+	for i, h := range header {
+		// Spreadsheet exports often start with a UTF-8 byte order mark
+		h = strings.TrimSpace(strings.TrimPrefix(h, "\ufeff"))
+		if h == userColumn {
+			userIdx = i
+		}
+		names[i] = h
+		if to, ok := columns[h]; ok {
+			names[i] = to
+		}
+	}
+	if userIdx < 0 {
+		return nil, fmt.Errorf("header has no %q column", userColumn)
+	}
+	var rows []FieldsRow
+	for {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := cr.FieldPos(0)
+		row := FieldsRow{Line: line, User: strings.TrimSpace(rec[userIdx]), Fields: map[string]string{}}
+		if row.User == "" {
+			return nil, fmt.Errorf("line %d: empty %s", line, userColumn)
+		}
+		for i, v := range rec {
+			if i == userIdx || names[i] == "" {
+				continue
+			}
+			if v = strings.TrimSpace(v); v != "" {
+				row.Fields[names[i]] = v
+			}
+		}
+		rows = append(rows, row)
+	}
+}
@@ -0,0 +1,118 @@
+package testconfig
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadFieldsCSV(t *testing.T) {
+	in := "\ufeffowner, order_uuid ,project_id,note\n" +
+		"alice,7f3c,abc,\n" +
+		"bob , 91d2,def,\"has, comma\"\n"
+	rows, err := ReadFieldsCSV(strings.NewReader(in), "owner", map[string]string{"order_uuid": "order_id"})
+	if err != nil {
+		t.Fatalf("ReadFieldsCSV: %v", err)
+	}
+	want := []FieldsRow{
+		{Line: 2, User: "alice", Fields: map[string]string{"order_id": "7f3c", "project_id": "abc"}},
+		{Line: 3, User: "bob", Fields: map[string]string{"order_id": "91d2", "project_id": "def", "note": "has, comma"}},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %+v\nwant   %+v", rows, want)
+	}
+}
+
+func TestReadFieldsCSVErrors(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"empty file", "", "missing header row"},
+		{"no user column", "owner,order_id\nalice,1\n", `no "user" column`},
+		{"empty user", "user,order_id\nalice,1\n,2\n", "line 3: empty user"},
+		{"ragged row", "user,order_id\nalice,1,extra\n", "wrong number of fields"},
+	}
+	for _, tt := range tests {
+		_, err := ReadFieldsCSV(strings.NewReader(tt.in), "", nil)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+// loadWithCSV writes yml and objects.csv to a temporary directory and loads the config.
+func loadWithCSV(t *testing.T, yml, csv string) (Config, error) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "objects.csv"), []byte(csv), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "config.yml")
+	if err := os.WriteFile(path, []byte(yml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return Load(path)
+}
+
+func TestLoadFieldsCSV(t *testing.T) {
+	cfg, err := loadWithCSV(t, `
+fields_csv: {path: objects.csv}
+default_fields: {tenant_id: acme, region: eu}
+users:
+  - name: alice
+    fields: {note_id: "10"}
+  - name: bob
+`, "user,note_id,tenant_id\nalice,1,initech\nbob,2,\nbob,3,globex\n")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	want := []map[string]string{
+		// alice's own note_id wins over the CSV, the CSV over default_fields
+		{"note_id": "10", "tenant_id": "initech", "region": "eu"},
+		// only bob's first row is used; its empty tenant_id falls back to the default
+		{"note_id": "2", "tenant_id": "acme", "region": "eu"},
+	}
+	for i, u := range cfg.Users {
+		if !reflect.DeepEqual(u.Fields, want[i]) {
+			t.Errorf("%s fields = %v, want %v", u.Name, u.Fields, want[i])
+		}
+	}
+
+	_, err = loadWithCSV(t, `
+fields_csv: {path: objects.csv}
+users:
+  - name: alice
+`, "user,note_id\nalice,1\nmallory,2\n")
+	if err == nil || !strings.Contains(err.Error(), `line 3: user "mallory" is not defined`) {
+		t.Errorf("unknown user: err = %v", err)
+	}
+}
+
+func TestLoadGzipped(t *testing.T) {
+	dir := t.TempDir()
+	gz := func(name, content string) {
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		w.Write([]byte(content))
+		w.Close()
+		if err := os.WriteFile(filepath.Join(dir, name), buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gz("config.yml.gz", "fields_csv: {path: objects.csv.gz}\nusers:\n  - name: alice\n  - name: bob\n")
+	gz("objects.csv.gz", "user,note_id\nalice,1\nbob,2\n")
+
+	cfg, err := Load(filepath.Join(dir, "config.yml.gz"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	for i, want := range []string{"1", "2"} {
+		if got := cfg.Users[i].Fields["note_id"]; got != want {
+			t.Errorf("%s note_id = %q, want %q", cfg.Users[i].Name, got, want)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package testconfig
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"strings"
+)
+
+// OpenInput opens an input file for reading. Files whose name ends in .gz are gunzipped,
+// so inputs may be kept compressed like the output files; closing the returned reader
+// closes the file.
+func OpenInput(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(strings.ToLower(path), ".gz") {
+		return f, nil
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &gzipInput{Reader: gz, file: f}, nil
+}
+
+// ReadInput reads an input file whole, gunzipping it like OpenInput.
+func ReadInput(path string) ([]byte, error) {
+	in, err := OpenInput(path)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	return io.ReadAll(in)
+}
+
+// gzipInput is a gzip stream read from a file it owns.
+type gzipInput struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (g *gzipInput) Close() error {
+	err := g.Reader.Close()
+	if cerr := g.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,80 @@
+package testconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ExtractJSONPointer returns the value at the RFC 6901 JSON pointer in body, e.g.
+// "/data/items/0/id". Strings are returned unquoted, numbers and booleans in their JSON
+// form, null as "", and objects or arrays as compact JSON. The empty pointer selects
+// the whole document.
+func ExtractJSONPointer(body []byte, pointer string) (string, error) {
+	return ExtractJSONPointerFrom(bytes.NewReader(body), pointer)
+}
+
+// ExtractJSONPointerFrom is ExtractJSONPointer for a document read from r, such as a
+// large response body streamed from disk.
+func ExtractJSONPointerFrom(r io.Reader, pointer string) (string, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	var node any
+	if err := dec.Decode(&node); err != nil {
+		return "", fmt.Errorf("body is not JSON: %w", err)
+	}
+	if pointer != "" {
+		if !strings.HasPrefix(pointer, "/") {
+			return "", fmt.Errorf("invalid JSON pointer %q: must be empty or start with /", pointer)
+		}
+		for _, token := range strings.Split(pointer[1:], "/") {
+			token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+			switch n := node.(type) {
+			case map[string]any:
+				v, ok := n[token]
+				if !ok {
+					return "", fmt.Errorf("%s: key %q not found", pointer, token)
+				}
+				node = v
+			case []any:
+				i, err := strconv.Atoi(token)
+				if err != nil || i < 0 || (len(token) > 1 && token[0] == '0') {
+					return "", fmt.Errorf("%s: invalid array index %q", pointer, token)
+				}
+				if i >= len(n) {
+					return "", fmt.Errorf("%s: index %d out of range (length %d)", pointer, i, len(n))
+				}
+				node = n[i]
+			default:
+				return "", fmt.Errorf("%s: cannot descend into scalar at %q", pointer, token)
+			}
+		}
+	}
+	switch v := node.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case json.Number, bool:
+		return fmt.Sprint(v), nil
+	default:
+		b, err := json.Marshal(v)
+		return string(b), err
+	}
+}
+
+// DotPathToPointer converts a dot-separated key such as "data.id" to the JSON pointer
+// "/data/id". Keys that already start with "/" are returned unchanged.
+func DotPathToPointer(key string) string {
+	if key == "" || strings.HasPrefix(key, "/") {
+		return key
+	}
+	parts := strings.Split(key, ".")
+	for i, p := range parts {
+		parts[i] = strings.ReplaceAll(strings.ReplaceAll(p, "~", "~0"), "/", "~1")
+	}
+	return "/" + strings.Join(parts, "/")
+}
@@ -0,0 +1,71 @@
+package testconfig
+
+import "testing"
+
+func TestExtractJSONPointer(t *testing.T) {
+	body := []byte(`{
+		"data": {"items": [{"id": 7, "owner": "alice"}, {"id": 8, "tags": ["a", "b"]}]},
+		"a/b": "slash", "m~n": "tilde", "ok": true, "none": null, "big": 12345678901234567890
+	}`)
+	tests := []struct {
+		pointer string
+		want    string
+	}{
+		{"/data/items/0/id", "7"},
+		{"/data/items/0/owner", "alice"},
+		{"/data/items/1/tags/1", "b"},
+		{"/data/items/1/tags", `["a","b"]`},
+		{"/data/items/0", `{"id":7,"owner":"alice"}`},
+		{"/a~1b", "slash"},
+		{"/m~0n", "tilde"},
+		{"/ok", "true"},
+		{"/none", ""},
+		{"/big", "12345678901234567890"},
+	}
+	for _, tt := range tests {
+		got, err := ExtractJSONPointer(body, tt.pointer)
+		if err != nil || got != tt.want {
+			t.Errorf("ExtractJSONPointer(%q) = %q, %v; want %q", tt.pointer, got, err, tt.want)
+		}
+	}
+	if got, err := ExtractJSONPointer([]byte(`[1,2]`), ""); err != nil || got != "[1,2]" {
+		t.Errorf("empty pointer = %q, %v", got, err)
+	}
+}
+
+func TestExtractJSONPointerErrors(t *testing.T) {
+	body := []byte(`{"data": {"items": [{"id": 7}]}, "name": "x"}`)
+	for _, pointer := range []string{
+		"/data/missing",      // missing key
+		"/data/items/1",      // index out of range
+		"/data/items/-1",     // negative index
+		"/data/items/01",     // leading zero
+		"/data/items/first",  // not an index
+		"/name/0",            // descending into a scalar
+		"data/items",         // no leading slash
+		"/data/items/0/id/x", // past a leaf
+	} {
+		if got, err := ExtractJSONPointer(body, pointer); err == nil {
+			t.Errorf("ExtractJSONPointer(%q) = %q, want an error", pointer, got)
+		}
+	}
+	if _, err := ExtractJSONPointer([]byte("<html>"), "/id"); err == nil {
+		t.Error("non-JSON body accepted")
+	}
+}
+
+func TestDotPathToPointer(t *testing.T) {
+	tests := map[string]string{
+		"data.id":    "/data/id",
+		"id":         "/id",
+		"/items/0":   "/items/0",
+		"":           "",
+		"a/b.c~d":    "/a~1b/c~0d",
+		"items.0.id": "/items/0/id",
+	}
+	for in, want := range tests {
+		if got := DotPathToPointer(in); got != want {
+			t.Errorf("DotPathToPointer(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package testconfig
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// JWTOptions enables reading users' JWT credentials. Tokens are decoded, never verified.
+type JWTOptions struct {
+	// ClaimsToFields maps a claim (a dot-separated path or JSON pointer into the payload,
+	// e.g. "sub" or "org.id") to the user field it populates. Fields set on the user win.
+	ClaimsToFields map[string]string `yaml:"claims_to_fields"`
+}
+
+// JWTPayload returns the decoded payload of the user's credential when it is a JWT: a
+// header value with or without an auth scheme such as "Bearer ", or any cookie value.
+func (u User) JWTPayload() ([]byte, bool) {
+	var candidates []string
+	if u.Auth.Type == "cookie" {
+		for _, part := range strings.Split(u.Auth.Value, ";") {
+			if _, v, ok := strings.Cut(strings.TrimSpace(part), "="); ok {
+				candidates = append(candidates, v)
+			}
+		}
+	} else {
+		v := strings.TrimSpace(u.Auth.Value)
+		if _, rest, ok := strings.Cut(v, " "); ok {
+			v = strings.TrimSpace(rest)
+		}
+		candidates = append(candidates, v)
+	}
+	for _, c := range candidates {
+		if payload, ok := decodeJWT(c); ok {
+			return payload, true
+		}
+	}
+	return nil, false
+}
+
+// decodeJWT returns the payload of a compact JWS whose header names an alg.
+func decodeJWT(token string) ([]byte, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, false
+	}
+	header, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[0], "="))
+	if err != nil {
+		return nil, false
+	}
+	var h struct {
+		Alg string `json:"alg"`
+	}
+	if json.Unmarshal(header, &h) != nil || h.Alg == "" {
+		return nil, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil || !json.Valid(payload) || !bytes.HasPrefix(bytes.TrimSpace(payload), []byte("{")) {
+		return nil, false
+	}
+	return payload, true
+}
+
+// applyClaims copies the mapped JWT claims of every user with a JWT credential into
+// fields the user does not set. Users without a JWT, or without a claim, are left alone.
+func (c *Config) applyClaims() {
+	if c.JWT == nil || len(c.JWT.ClaimsToFields) == 0 {
+		return
+	}
+	claims := make([]string, 0, len(c.JWT.ClaimsToFields))
+	for claim := range c.JWT.ClaimsToFields {
+		claims = append(claims, claim)
+	}
+	sort.Strings(claims)
+	for i := range c.Users {
+		payload, ok := c.Users[i].JWTPayload()
+		if !ok {
+			continue
+		}
+		for _, claim := range claims {
+			field := c.JWT.ClaimsToFields[claim]
+			if _, set := c.Users[i].Fields[field]; set {
+				continue
+			}
+			v, err := ExtractJSONPointer(payload, DotPathToPointer(claim))
+			if err != nil || v == "" {
+				continue
+			}
+			if c.Users[i].Fields == nil {
+				c.Users[i].Fields = map[string]string{}
+			}
+			c.Users[i].Fields[field] = v
+		}
+	}
+}
+
+// JWTWarnings reports, when JWT handling is enabled, users whose token has expired by now
+// and users whose tokens share a subject, which means they are the same identity and
+// every test between them is meaningless.
+func (c Config) JWTWarnings(now time.Time) []string {
+	if c.JWT == nil {
+		return nil
+	}
+	var warnings []string
+	bySubject := map[string][]string{}
+	var subjects []string
+	for _, u := range c.Users {
+		payload, ok := u.JWTPayload()
+		if !ok {
+			continue
+		}
+		var claims struct {
+			Sub any          `json:"sub"`
+			Exp *json.Number `json:"exp"`
+		}
+		dec := json.NewDecoder(bytes.NewReader(payload))
+		dec.UseNumber()
+		if dec.Decode(&claims) != nil {
+			continue
+		}
+		if claims.Exp != nil {
+			if exp, err := claims.Exp.Float64(); err == nil {
+				at := time.Unix(int64(exp), 0)
+				if !at.After(now) {
+					warnings = append(warnings, fmt.Sprintf("user %s: token expired at %s", u.Name, at.UTC().Format(time.RFC3339)))
+				}
+			}
+		}
+		if claims.Sub != nil {
+			sub := fmt.Sprint(claims.Sub)
+			if _, seen := bySubject[sub]; !seen {
+				subjects = append(subjects, sub)
+			}
+			bySubject[sub] = append(bySubject[sub], u.Name)
+		}
+	}
+	for _, sub := range subjects {
+		if names := bySubject[sub]; len(names) > 1 {
+			warnings = append(warnings, fmt.Sprintf("users %s share JWT subject %q; they are the same identity", strings.Join(names, ", "), sub))
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,216 @@
+package testconfig
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/url"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// AuthSignedQuery is the auth type whose credential is a set of query parameters signed
+// anew for every request, as used by signed URLs. See QuerySigning.
+const AuthSignedQuery = "signed_query"
+
+// QuerySigning regenerates a user's signed query parameters just before each request is
+// sent, so short-lived signatures never go stale. It signs with an HMAC recipe (Secret,
+// Message and Signature), or runs Command to produce the parameters.
+type QuerySigning struct {
+	// Params are added to every request as they are, e.g. the key ID.
+	Params map[string]string `yaml:"params"`
+	// Timestamp, when set, names a parameter set to the current Unix time.
+	Timestamp string `yaml:"timestamp_param"`
+	// Expires, when set, names a parameter set to the Unix time TTL from now.
+	Expires string        `yaml:"expires_param"`
+	TTL     time.Duration `yaml:"ttl"` // defaults to 5m
+	// Secret is the HMAC key. Algorithm is sha256 (default), sha1 or sha512.
+	Secret    string `yaml:"secret"`
+	Algorithm string `yaml:"algorithm"`
+	// Message is a text/template of the string to sign, executed with SigningData.
+	// Defaults to "{{.Method}}\n{{.Path}}\n{{.Query}}".
+	Message string `yaml:"message"`
+	// Signature names the parameter carrying the signature, encoded as hex (default),
+	// base64 or base64url per Encoding.
+	Signature string `yaml:"signature_param"`
+	Encoding  string `yaml:"encoding"`
+	// Command, instead of the HMAC recipe, is run for every request with the method and
+	// URL in APERTURE_METHOD and APERTURE_URL; it prints a query string
+	// ("sig=...&expires=...") whose parameters are set on the request.
+	Command []string `yaml:"command"`
+}
+
+// SigningData is the value a QuerySigning message is executed against.
+type SigningData struct {
+	Method string // upper case
+	Host   string
+	Path   string // escaped, as sent
+	// Query is every query parameter but the signature, sorted by name and encoded.
+	Query     string
+	Timestamp int64
+	Expires   int64 // zero without expires_param
+}
+
+const defaultSigningMessage = "{{.Method}}\n{{.Path}}\n{{.Query}}"
+
+// validate checks the recipe when the config is loaded.
+func (s *QuerySigning) validate() error {
+	if len(s.Command) > 0 {
+		if s.Secret != "" {
+			return fmt.Errorf("set either command or secret, not both")
+		}
+		return nil
+	}
+	if s.Secret == "" || s.Signature == "" {
+		return fmt.Errorf("secret and signature_param are required without command")
+	}
+	if _, err := s.hash(); err != nil {
+		return err
+	}
+	switch s.Encoding {
+	case "", "hex", "base64", "base64url":
+	default:
+		return fmt.Errorf("unknown encoding %q: want hex, base64 or base64url", s.Encoding)
+	}
+	_, err := s.parseMessage()
+	return err
+}
+
+func (s *QuerySigning) hash() (func() hash.Hash, error) {
+	switch strings.ToLower(s.Algorithm) {
+	case "", "sha256":
+		return sha256.New, nil
+	case "sha1":
+		return sha1.New, nil
+	case "sha512":
+		return sha512.New, nil
+	}
+	return nil, fmt.Errorf("unknown algorithm %q: want sha256, sha1 or sha512", s.Algorithm)
+}
+
+func (s *QuerySigning) parseMessage() (*template.Template, error) {
+	msg := s.Message
+	if msg == "" {
+		msg = defaultSigningMessage
+	}
+	tmpl, err := template.New("message").Funcs(templateFuncs).Option("missingkey=error").Parse(msg)
+	if err != nil {
+		return nil, fmt.Errorf("message: %w", err)
+	}
+	return tmpl, nil
+}
+
+// Sign sets the signed parameters on u's query for a request with method, replacing
+// any earlier ones, as of now.
+func (s *QuerySigning) Sign(ctx context.Context, method string, u *url.URL, now time.Time) error {
+	q := u.Query()
+	for k, v := range s.Params {
+		q.Set(k, v)
+	}
+	data := SigningData{Method: strings.ToUpper(method), Host: u.Host, Path: u.EscapedPath(), Timestamp: now.Unix()}
+	if s.Timestamp != "" {
+		q.Set(s.Timestamp, strconv.FormatInt(data.Timestamp, 10))
+	}
+	if s.Expires != "" {
+		ttl := s.TTL
+		if ttl <= 0 {
+			ttl = 5 * time.Minute
+		}
+		data.Expires = now.Add(ttl).Unix()
+		q.Set(s.Expires, strconv.FormatInt(data.Expires, 10))
+	}
+	if len(s.Command) > 0 {
+		u.RawQuery = q.Encode()
+		return s.runCommand(ctx, data.Method, u)
+	}
+
+	q.Del(s.Signature)
+	data.Query = q.Encode()
+	tmpl, err := s.parseMessage()
+	if err != nil {
+		return err
+	}
+	var msg bytes.Buffer
+	if err := tmpl.Execute(&msg, data); err != nil {
+		return fmt.Errorf("message: %w", err)
+	}
+	newHash, err := s.hash()
+	if err != nil {
+		return err
+	}
+	mac := hmac.New(newHash, []byte(s.Secret))
+	mac.Write(msg.Bytes())
+	sum := mac.Sum(nil)
+	var sig string
+	switch s.Encoding {
+	case "base64":
+		sig = base64.StdEncoding.EncodeToString(sum)
+	case "base64url":
+		sig = base64.RawURLEncoding.EncodeToString(sum)
+	default:
+		sig = hex.EncodeToString(sum)
+	}
+	q.Set(s.Signature, sig)
+	u.RawQuery = q.Encode()
+	return nil
+}
+
+// runCommand runs Command for the request and sets the parameters it prints on u.
+func (s *QuerySigning) runCommand(ctx context.Context, method string, u *url.URL) error {
+	cmd := exec.CommandContext(ctx, s.Command[0], s.Command[1:]...)
+	cmd.Env = append(os.Environ(), "APERTURE_METHOD="+method, "APERTURE_URL="+u.String())
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("command %s: %w: %s", s.Command[0], err, msg)
+		}
+		return fmt.Errorf("command %s: %w", s.Command[0], err)
+	}
+	params, err := url.ParseQuery(strings.TrimPrefix(strings.TrimSpace(string(out)), "?"))
+	if err != nil {
+		return fmt.Errorf("command %s printed an invalid query string: %w", s.Command[0], err)
+	}
+	q := u.Query()
+	for k, vs := range params {
+		q[k] = vs
+	}
+	u.RawQuery = q.Encode()
+	return nil
+}
+
+// Sets reports whether the recipe sets the query parameter name. A command may set any.
+func (s *QuerySigning) Sets(name string) bool {
+	if len(s.Command) > 0 {
+		return true
+	}
+	_, static := s.Params[name]
+	return static || name == s.Signature || name == s.Expires || name == s.Timestamp
+}
+
+// Credential identifies the signing credential, for telling whether two users share it.
+func (s *QuerySigning) Credential() string {
+	keys := make([]string, 0, len(s.Params))
+	for k := range s.Params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := []string{s.Secret, strings.Join(s.Command, " ")}
+	for _, k := range keys {
+		parts = append(parts, k+"="+s.Params[k])
+	}
+	return strings.Join(parts, "\x00")
+}
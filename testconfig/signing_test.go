@@ -0,0 +1,119 @@
+package testconfig
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+var signingTime = time.Unix(1700000000, 0)
+
+func TestQuerySigningHMAC(t *testing.T) {
+	tests := []struct {
+		name    string
+		signing QuerySigning
+		want    url.Values
+	}{
+		{
+			// message "GET\n/notes/1\nexpires=1700000060&key_id=k1&org_id=alice"
+			name: "default message, hex",
+			signing: QuerySigning{
+				Params:    map[string]string{"key_id": "k1"},
+				Expires:   "expires",
+				TTL:       time.Minute,
+				Secret:    "s3cret",
+				Signature: "sig",
+			},
+			want: url.Values{
+				"org_id":  {"alice"},
+				"key_id":  {"k1"},
+				"expires": {"1700000060"},
+				"sig":     {"43475380de8cff9fe307485ffee30b2af386243af2a162c1d0b40bc09ceade3f"},
+			},
+		},
+		{
+			// message "GET api.example.com 1700000000 key_id=k1&org_id=alice&ts=1700000000"
+			name: "custom message, sha1, base64url",
+			signing: QuerySigning{
+				Params:    map[string]string{"key_id": "k1"},
+				Timestamp: "ts",
+				Secret:    "s3cret",
+				Algorithm: "sha1",
+				Message:   "{{.Method}} {{.Host}} {{.Timestamp}} {{.Query}}",
+				Signature: "sig",
+				Encoding:  "base64url",
+			},
+			want: url.Values{
+				"org_id": {"alice"},
+				"key_id": {"k1"},
+				"ts":     {"1700000000"},
+				"sig":    {"YiHeZvH6Q6ljlaaR_BobAY9pkQM"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		if err := tt.signing.validate(); err != nil {
+			t.Errorf("%s: validate: %v", tt.name, err)
+			continue
+		}
+		// A signature left from an earlier request is replaced, not signed over
+		u, _ := url.Parse("https://api.example.com/notes/1?org_id=alice&sig=stale")
+		if err := tt.signing.Sign(context.Background(), "get", u, signingTime); err != nil {
+			t.Errorf("%s: Sign: %v", tt.name, err)
+			continue
+		}
+		if got := u.Query(); got.Encode() != tt.want.Encode() {
+			t.Errorf("%s: query = %s, want %s", tt.name, got.Encode(), tt.want.Encode())
+		}
+	}
+}
+
+func TestQuerySigningDefaultTTL(t *testing.T) {
+	s := QuerySigning{Expires: "expires", Secret: "s3cret", Signature: "sig"}
+	u, _ := url.Parse("https://api.example.com/notes/1")
+	if err := s.Sign(context.Background(), "GET", u, signingTime); err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if got := u.Query().Get("expires"); got != "1700000300" {
+		t.Errorf("expires = %s, want 5 minutes after signing", got)
+	}
+}
+
+func TestQuerySigningCommand(t *testing.T) {
+	s := QuerySigning{
+		Params:  map[string]string{"key_id": "k1"},
+		Command: []string{"sh", "-c", `echo "?sig=$APERTURE_METHOD"`},
+	}
+	if err := s.validate(); err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	u, _ := url.Parse("https://api.example.com/notes/1")
+	if err := s.Sign(context.Background(), "delete", u, signingTime); err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if got, want := u.RawQuery, "key_id=k1&sig=DELETE"; got != want {
+		t.Errorf("query = %s, want %s", got, want)
+	}
+}
+
+func TestQuerySigningValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		signing QuerySigning
+		want    string
+	}{
+		{"no secret", QuerySigning{Signature: "sig"}, "secret and signature_param are required"},
+		{"command and secret", QuerySigning{Command: []string{"sign"}, Secret: "s3cret"}, "either command or secret"},
+		{"algorithm", QuerySigning{Secret: "s3cret", Signature: "sig", Algorithm: "md5"}, `unknown algorithm "md5"`},
+		{"encoding", QuerySigning{Secret: "s3cret", Signature: "sig", Encoding: "base32"}, `unknown encoding "base32"`},
+		{"message", QuerySigning{Secret: "s3cret", Signature: "sig", Message: "{{.Method"}, "message:"},
+	}
+	for _, tt := range tests {
+		err := tt.signing.validate()
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package theme
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+	"github.com/yansol0/aperture/runner"
+)
+
+// Color modes accepted by Configure.
+const (
+	ColorAuto   = "auto"
+	ColorAlways = "always"
+	ColorNever  = "never"
+)
+
+// Theme is the palette used by the TUI and colored console output.
+type Theme struct {
+	Name    string
+	Banner  lipgloss.TerminalColor
+	Accent  lipgloss.TerminalColor
+	Warning lipgloss.TerminalColor
+	Caution lipgloss.TerminalColor
+	Error   lipgloss.TerminalColor
+	Success lipgloss.TerminalColor
+	Muted   lipgloss.TerminalColor
+	Dim     lipgloss.TerminalColor
+}
+
+var themes = map[string]Theme{
+	"default": {
+		Name:    "default",
+		Banner:  lipgloss.Color("69"),
+		Accent:  lipgloss.Color("205"),
+		Warning: lipgloss.Color("208"),
+		Caution: lipgloss.Color("214"),
+		Error:   lipgloss.Color("196"),
+		Success: lipgloss.Color("42"),
+		Muted:   lipgloss.Color("244"),
+		Dim:     lipgloss.Color("240"),
+	},
+	// Darker shades that stay readable on light terminal backgrounds
+	"light": {
+		Name:    "light",
+		Banner:  lipgloss.Color("25"),
+		Accent:  lipgloss.Color("162"),
+		Warning: lipgloss.Color("166"),
+		Caution: lipgloss.Color("130"),
+		Error:   lipgloss.Color("160"),
+		Success: lipgloss.Color("28"),
+		Muted:   lipgloss.Color("240"),
+		Dim:     lipgloss.Color("245"),
+	},
+	// No foreground colors at all; bold and faint text still apply
+	"mono": {
+		Name:    "mono",
+		Banner:  lipgloss.NoColor{},
+		Accent:  lipgloss.NoColor{},
+		Warning: lipgloss.NoColor{},
+		Caution: lipgloss.NoColor{},
+		Error:   lipgloss.NoColor{},
+		Success: lipgloss.NoColor{},
+		Muted:   lipgloss.NoColor{},
+		Dim:     lipgloss.NoColor{},
+	},
+}
+
+var current = themes["default"]
+
+// Configure selects the color mode (auto, always or never) and theme (default, light or
+// mono) for all styled output. In auto mode color is used only when stdout is a terminal
+// and the NO_COLOR environment variable is not set.
+func Configure(mode, name string) error {
+	t, ok := themes[name]
+	if !ok {
+		return fmt.Errorf("unknown theme %q: want default, light or mono", name)
+	}
+	switch mode {
+	case ColorAuto:
+		if os.Getenv("NO_COLOR") != "" {
+			lipgloss.SetColorProfile(termenv.Ascii)
+		}
+	case ColorAlways:
+		lipgloss.SetColorProfile(termenv.ANSI256)
+	case ColorNever:
+		lipgloss.SetColorProfile(termenv.Ascii)
+	default:
+		return fmt.Errorf("invalid color mode %q: want auto, always or never", mode)
+	}
+	current = t
+	return nil
+}
+
+// Current returns the theme selected by Configure.
+func Current() Theme {
+	return current
+}
+
+// Verdict returns the color for a result verdict.
+func (t Theme) Verdict(result string) lipgloss.TerminalColor {
+	switch result {
+	case runner.ResultIDORFound:
+		return t.Error
+	case runner.ResultPotential:
+		return t.Caution
+	case runner.ResultSecure:
+		return t.Success
+	case runner.ResultControlFailed, runner.ResultError:
+		return t.Muted
+	case runner.ResultSkipped:
+		return t.Dim
+	}
+	return lipgloss.NoColor{}
+}
+
+// Status returns the color for an HTTP response status code.
+func (t Theme) Status(code int) lipgloss.TerminalColor {
+	switch {
+	case code >= 500:
+		return t.Error
+	case code >= 400:
+		return t.Caution
+	case code >= 300:
+		return t.Muted
+	}
+	return t.Success
+}
@@ -0,0 +1,93 @@
+package theme
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+	"github.com/yansol0/aperture/runner"
+)
+
+// restore puts back the color profile and theme a test changes.
+func restore(t *testing.T) {
+	profile, th := lipgloss.ColorProfile(), current
+	t.Cleanup(func() {
+		lipgloss.SetColorProfile(profile)
+		current = th
+	})
+}
+
+func TestConfigure(t *testing.T) {
+	restore(t)
+	red := func() string { return lipgloss.NewStyle().Foreground(Current().Error).Render("x") }
+
+	if err := Configure(ColorAlways, "light"); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	if Current().Name != "light" || lipgloss.ColorProfile() != termenv.ANSI256 {
+		t.Errorf("always/light: theme %q, profile %v", Current().Name, lipgloss.ColorProfile())
+	}
+	if got := red(); !strings.Contains(got, "\x1b[") {
+		t.Errorf("always: %q has no escape sequence", got)
+	}
+	if err := Configure(ColorNever, "default"); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	if got := red(); got != "x" {
+		t.Errorf("never: %q, want plain text", got)
+	}
+
+	lipgloss.SetColorProfile(termenv.ANSI256)
+	t.Setenv("NO_COLOR", "1")
+	if err := Configure(ColorAuto, "default"); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	if got := red(); got != "x" {
+		t.Errorf("auto with NO_COLOR: %q, want plain text", got)
+	}
+}
+
+func TestConfigureErrors(t *testing.T) {
+	restore(t)
+	if err := Configure(ColorAuto, "solarized"); err == nil || !strings.Contains(err.Error(), `unknown theme "solarized"`) {
+		t.Errorf("unknown theme: err = %v", err)
+	}
+	if err := Configure("sometimes", "default"); err == nil || !strings.Contains(err.Error(), `invalid color mode "sometimes"`) {
+		t.Errorf("invalid mode: err = %v", err)
+	}
+	if Current().Name != "default" {
+		t.Errorf("theme = %q after failed Configure calls, want default", Current().Name)
+	}
+}
+
+func TestVerdictAndStatusColors(t *testing.T) {
+	th := themes["default"]
+	for verdict, want := range map[string]lipgloss.TerminalColor{
+		runner.ResultIDORFound:     th.Error,
+		runner.ResultPotential:     th.Caution,
+		runner.ResultSecure:        th.Success,
+		runner.ResultControlFailed: th.Muted,
+		runner.ResultError:         th.Muted,
+		runner.ResultSkipped:       th.Dim,
+		"UNKNOWN":                  lipgloss.NoColor{},
+	} {
+		if got := th.Verdict(verdict); got != want {
+			t.Errorf("Verdict(%q) = %v, want %v", verdict, got, want)
+		}
+	}
+	for code, want := range map[int]lipgloss.TerminalColor{
+		200: th.Success, 302: th.Muted, 404: th.Caution, 503: th.Error,
+	} {
+		if got := th.Status(code); got != want {
+			t.Errorf("Status(%d) = %v, want %v", code, got, want)
+		}
+	}
+	// mono sets no foreground at all
+	mono := themes["mono"]
+	for _, c := range []lipgloss.TerminalColor{mono.Banner, mono.Accent, mono.Warning, mono.Caution, mono.Error, mono.Success, mono.Muted, mono.Dim} {
+		if c != (lipgloss.NoColor{}) {
+			t.Errorf("mono color %v, want none", c)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package tui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mattn/go-runewidth"
+)
+
+// logCapacity bounds how many log lines the pane keeps; older lines are dropped.
+const logCapacity = 500
+
+// logPaneHeight is how many lines the log pane shows at once.
+const logPaneHeight = 12
+
+// logRing is a fixed-size ring buffer of log lines.
+type logRing struct {
+	lines []string
+	next  int
+	full  bool
+}
+
+func (l *logRing) add(line string) {
+	if l.lines == nil {
+		l.lines = make([]string, logCapacity)
+	}
+	l.lines[l.next] = line
+	l.next = (l.next + 1) % logCapacity
+	if l.next == 0 {
+		l.full = true
+	}
+}
+
+// all returns the buffered lines, oldest first.
+func (l logRing) all() []string {
+	if !l.full {
+		return l.lines[:l.next]
+	}
+	return append(append([]string(nil), l.lines[l.next:]...), l.lines[:l.next]...)
+}
+
+// scrollLog moves the log view by delta lines; positive values scroll back in time.
+func (m *model) scrollLog(delta int) {
+	n := len(m.log.all())
+	m.logScroll = min(max(0, m.logScroll+delta), max(0, n-logPaneHeight))
+}
+
+// logView renders the visible part of the log, truncating lines to the terminal width.
+func (m model) logView() string {
+	lines := m.log.all()
+	end := len(lines) - m.logScroll
+	start := max(0, end-logPaneHeight)
+	width := m.width - 2
+	if width <= 0 {
+		width = 80
+	}
+	var b strings.Builder
+	for _, line := range lines[start:end] {
+		b.WriteString(runewidth.Truncate(line, width, "…") + "\n")
+	}
+	if len(lines) == 0 {
+		b.WriteString("(no log lines yet)\n")
+	}
+	title := "Log (l hide, PgUp/PgDn scroll)"
+	if m.logScroll > 0 {
+		title += "  - scrolled back"
+	}
+	return lipgloss.JoinVertical(lipgloss.Left,
+		lipgloss.NewStyle().Faint(true).Render(title),
+		strings.TrimRight(b.String(), "\n"),
+	)
+}
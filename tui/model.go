@@ -3,13 +3,18 @@ package tui
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/charmbracelet/bubbles/progress"
 	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/mattn/go-runewidth"
 	"github.com/yansol0/aperture/runner"
+	"github.com/yansol0/aperture/theme"
 )
 
 type ModelInit struct {
@@ -17,6 +22,8 @@ type ModelInit struct {
 	ConfigPath string
 	BaseURL    string
 	Events     <-chan runner.Event
+	// Commands, when set, receives pause, resume and skip requests for the runner.
+	Commands chan<- runner.Command
 }
 
 type UI struct {
@@ -28,13 +35,15 @@ type UI struct {
 }
 
 func NewModel(init ModelInit) *UI {
-	return &UI{mdl: newModel(init)}
+	u := &UI{mdl: newModel(init)}
+	// Create the program up front so Done can always reach it, even when the run
+	// finishes before Run is called
+	u.program = tea.NewProgram(u.mdl, tea.WithoutSignalHandler())
+	return u
 }
 
 func (u *UI) Run() error {
-	p := tea.NewProgram(u.mdl, tea.WithoutSignalHandler())
-	u.program = p
-	m, err := p.Run()
+	m, err := u.program.Run()
 	if mm, ok := m.(model); ok {
 		u.mdl = mm
 	}
@@ -64,10 +73,45 @@ type model struct {
 	completed int
 	total     int
 
-	pathsCount      int
-	currentMethod   string
-	currentEndpoint string
-	lastBodyJSON    string
+	pathsCount         int
+	endpointsCompleted int
+	endpointsTotal     int
+	currentMethod      string
+	currentEndpoint    string
+	lastBodyJSON       string
+
+	// confirm is the pending EventConfirmWrite awaiting the operator's decision.
+	confirm *runner.Event
+
+	// lastStatus and lastMs describe the latest response; doneTimes holds recent
+	// completion times for the throughput and ETA estimate.
+	lastStatus int
+	lastMs     int64
+	doneTimes  []time.Time
+	// concurrency is each host's request limit, from EventConcurrency.
+	concurrency map[string]int
+
+	// bodyExpanded shows the whole request body instead of a preview that fits the screen.
+	bodyExpanded bool
+
+	// log holds EventLog messages for the toggleable log pane.
+	log       logRing
+	showLog   bool
+	logScroll int
+
+	// verdicts and recent are tallied from EventResult for the live panel.
+	verdicts map[string]int
+	recent   []runner.ResultLog
+
+	// started and pausedFor track elapsed time, excluding time spent paused.
+	started   time.Time
+	pausedAt  time.Time
+	pausedFor time.Duration
+	paused    bool
+
+	// done switches to the results browser once the run has finished.
+	done    bool
+	results resultsState
 
 	width    int
 	height   int
@@ -88,12 +132,13 @@ type doneMsg struct {
 func newModel(init ModelInit) model {
 	sp := spinner.New()
 	sp.Spinner = spinner.Dot
-	sp.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
+	sp.Style = lipgloss.NewStyle().Foreground(theme.Current().Accent)
 	pg := progress.New(progress.WithDefaultGradient())
 	return model{
-		init: init,
-		spin: sp,
-		prog: pg,
+		init:    init,
+		spin:    sp,
+		prog:    pg,
+		started: time.Now(),
 	}
 }
 
@@ -104,6 +149,82 @@ func (m model) Init() tea.Cmd {
 	)
 }
 
+// bodyReservedLines is the screen height taken by everything above the request body.
+const bodyReservedLines = 24
+
+// rateWindow is how many recent completions the req/s and ETA estimate is based on.
+const rateWindow = 20
+
+// rate returns requests per second over the recent completion window.
+func (m model) rate() float64 {
+	if len(m.doneTimes) < 2 {
+		return 0
+	}
+	span := m.doneTimes[len(m.doneTimes)-1].Sub(m.doneTimes[0]).Seconds()
+	if span <= 0 {
+		return 0
+	}
+	return float64(len(m.doneTimes)-1) / span
+}
+
+// statusLine renders the latest response status, latency, throughput and ETA.
+func (m model) statusLine() string {
+	if m.lastStatus == 0 {
+		return lipgloss.NewStyle().Faint(true).Render("last: -")
+	}
+	status := lipgloss.NewStyle().Foreground(theme.Current().Status(m.lastStatus)).Render(fmt.Sprintf("%d", m.lastStatus))
+	line := fmt.Sprintf("last: %s in %dms", status, m.lastMs)
+	if rate := m.rate(); rate > 0 {
+		line += fmt.Sprintf("  |  %.1f req/s", rate)
+		if remaining := m.total - m.completed; remaining > 0 {
+			eta := time.Duration(float64(remaining) / rate * float64(time.Second)).Truncate(time.Second)
+			line += fmt.Sprintf("  |  ETA %s", eta)
+		}
+	}
+	if c := m.concurrencyText(); c != "" {
+		line += "  |  " + c
+	}
+	return line
+}
+
+// concurrencyText renders the request limit of an adaptive run: the limit alone for a
+// single host, each host's otherwise.
+func (m model) concurrencyText() string {
+	if len(m.concurrency) == 0 {
+		return ""
+	}
+	hosts := make([]string, 0, len(m.concurrency))
+	for h := range m.concurrency {
+		hosts = append(hosts, h)
+	}
+	if len(hosts) == 1 {
+		return fmt.Sprintf("concurrency %d", m.concurrency[hosts[0]])
+	}
+	sort.Strings(hosts)
+	parts := make([]string, 0, len(hosts))
+	for _, h := range hosts {
+		parts = append(parts, fmt.Sprintf("%s %d", h, m.concurrency[h]))
+	}
+	return "concurrency " + strings.Join(parts, ", ")
+}
+
+// sendCommand passes cmd to the runner without blocking the UI.
+func (m model) sendCommand(cmd runner.Command) {
+	select {
+	case m.init.Commands <- cmd:
+	default:
+	}
+}
+
+// elapsed is the run time so far, excluding time spent paused.
+func (m model) elapsed() time.Duration {
+	d := time.Since(m.started) - m.pausedFor
+	if m.paused {
+		d -= time.Since(m.pausedAt)
+	}
+	return d.Truncate(time.Second)
+}
+
 func waitForEvent(ch <-chan runner.Event) tea.Cmd {
 	return func() tea.Msg {
 		ev, ok := <-ch
@@ -126,10 +247,71 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, cmd
 	case tea.KeyMsg:
 		switch msg.Type {
-		case tea.KeyCtrlC, tea.KeyEsc:
+		case tea.KeyCtrlC:
+			m.quitting = true
+			return m, tea.Quit
+		}
+		if m.done {
+			return m.updateResults(msg)
+		}
+		if msg.Type == tea.KeyEsc {
 			m.quitting = true
 			return m, tea.Quit
 		}
+		if m.confirm == nil {
+			switch msg.String() {
+			case "b":
+				m.bodyExpanded = !m.bodyExpanded
+				return m, nil
+			case "l":
+				m.showLog = !m.showLog
+				m.logScroll = 0
+				return m, nil
+			case "pgup":
+				if m.showLog {
+					m.scrollLog(logPaneHeight)
+				}
+				return m, nil
+			case "pgdown":
+				if m.showLog {
+					m.scrollLog(-logPaneHeight)
+				}
+				return m, nil
+			}
+		}
+		if m.confirm == nil && m.init.Commands != nil {
+			switch msg.String() {
+			case "p":
+				if !m.paused {
+					m.paused, m.pausedAt = true, time.Now()
+					m.sendCommand(runner.CommandPause)
+				}
+				return m, nil
+			case "r":
+				if m.paused {
+					m.paused = false
+					m.pausedFor += time.Since(m.pausedAt)
+					m.sendCommand(runner.CommandResume)
+				}
+				return m, nil
+			case "s":
+				m.sendCommand(runner.CommandSkipEndpoint)
+				return m, nil
+			}
+		}
+		if m.confirm != nil {
+			decision, ok := map[string]runner.ConfirmDecision{
+				"y": runner.ConfirmApprove,
+				"n": runner.ConfirmSkip,
+				"a": runner.ConfirmApproveAll,
+			}[msg.String()]
+			if !ok {
+				return m, nil
+			}
+			m.confirm.Reply <- decision
+			m.confirm = nil
+			return m, waitForEvent(m.init.Events)
+		}
 		return m, nil
 	case evMsg:
 		e := msg.ev
@@ -138,6 +320,10 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.pathsCount = e.PathsCount
 		case runner.EventTotalRequests:
 			m.total = e.Total
+			if e.EndpointsTotal > 0 {
+				// Zero when only the request total grew, e.g. for reclassification retries
+				m.endpointsTotal = e.EndpointsTotal
+			}
 			m.percent = percent(m.completed, m.total)
 			return m, tea.Batch(m.prog.SetPercent(m.percent), waitForEvent(m.init.Events))
 		case runner.EventEndpointStarting:
@@ -151,7 +337,40 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.percent = percent(m.completed, m.total)
 			m.lastBodyJSON = marshalPretty(e.Request.Body)
 			return m, tea.Batch(m.prog.SetPercent(m.percent), waitForEvent(m.init.Events))
+		case runner.EventLog:
+			m.log.add(e.Message)
+			if m.logScroll > 0 {
+				// Keep the scrolled-back view steady while new lines arrive
+				m.scrollLog(1)
+			}
+		case runner.EventConcurrency:
+			if m.concurrency == nil {
+				m.concurrency = map[string]int{}
+			}
+			m.concurrency[e.Host] = e.Concurrency
+			if e.Message != "" {
+				m.log.add(e.Message)
+				if m.logScroll > 0 {
+					m.scrollLog(1)
+				}
+			}
+		case runner.EventResult:
+			if e.Result != nil {
+				m.recordResult(*e.Result, e.Replaces)
+			}
+		case runner.EventConfirmWrite:
+			// The runner blocks until it gets a reply; resume reading events after the operator decides
+			m.confirm = &e
+			return m, nil
+		case runner.EventEndpointCompleted:
+			m.endpointsCompleted = e.EndpointsCompleted
+			m.endpointsTotal = e.EndpointsTotal
 		case runner.EventRequestCompleted:
+			m.lastStatus, m.lastMs = e.Status, e.DurationMs
+			m.doneTimes = append(m.doneTimes, time.Now())
+			if len(m.doneTimes) > rateWindow {
+				m.doneTimes = m.doneTimes[len(m.doneTimes)-rateWindow:]
+			}
 			m.completed = e.Completed
 			m.total = e.Total
 			m.percent = percent(m.completed, m.total)
@@ -163,8 +382,13 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 	case doneMsg:
 		m.err = msg.err
-		m.quitting = true
-		return m, tea.Quit
+		if msg.err != nil || len(msg.results) == 0 {
+			m.quitting = true
+			return m, tea.Quit
+		}
+		m.done = true
+		m.results = newResultsState(msg.results)
+		return m, nil
 	default:
 		return m, nil
 	}
@@ -174,6 +398,9 @@ func (m model) View() string {
 	if m.quitting {
 		return ""
 	}
+	if m.done {
+		return m.viewResults()
+	}
 	bannerString := `
  █████╗ ██████╗ ███████╗██████╗ ████████╗██╗   ██╗██████╗ ███████╗
 ██╔══██╗██╔══██╗██╔════╝██╔══██╗╚══██╔══╝██║   ██║██╔══██╗██╔════╝
@@ -182,31 +409,150 @@ func (m model) View() string {
 ██║  ██║██║     ███████╗██║  ██║   ██║   ╚██████╔╝██║  ██║███████╗
 ╚═╝  ╚═╝╚═╝     ╚══════╝╚═╝  ╚═╝   ╚═╝    ╚═════╝ ╚═╝  ╚═╝╚══════╝
 	`
-	banner := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("69")).Render(bannerString)
-	meta := lipgloss.NewStyle().Faint(true).Render(fmt.Sprintf("Spec: %s  |  Config: %s  |  Base: %s", m.init.SpecPath, m.init.ConfigPath, m.init.BaseURL))
+	if m.compact() {
+		bannerString = "APERTURE - IDOR tester"
+	}
+	banner := lipgloss.NewStyle().Bold(true).Foreground(theme.Current().Banner).Render(bannerString)
+	meta := lipgloss.NewStyle().Faint(true).Render(truncate(fmt.Sprintf("Spec: %s  |  Config: %s  |  Base: %s", m.init.SpecPath, m.init.ConfigPath, m.init.BaseURL), m.width))
 	paths := fmt.Sprintf("Parsed endpoints: %d", m.pathsCount)
 	title := lipgloss.NewStyle().Bold(true).Render("Testing endpoints ") + m.spin.View()
-	current := fmt.Sprintf("%s %s", m.currentMethod, m.currentEndpoint)
+	current := truncate(fmt.Sprintf("%s %s", m.currentMethod, m.currentEndpoint), m.mainWidth())
 	bodyTitle := lipgloss.NewStyle().Faint(true).Render("Current request body:")
-	body := m.lastBodyJSON
-	if body == "" {
-		body = "(none)"
+	body := m.bodyPreview()
+	if m.confirm != nil {
+		return lipgloss.JoinVertical(lipgloss.Left,
+			banner,
+			meta,
+			"",
+			lipgloss.NewStyle().Bold(true).Foreground(theme.Current().Warning).Render("Confirm mutating request"),
+			confirmView(m.confirm.Request),
+			"",
+			lipgloss.NewStyle().Bold(true).Render("[y] approve  [n] skip  [a] approve all"),
+		)
+	}
+	progressLine := fmt.Sprintf("%d/%d requests  |  %d/%d endpoints  |  %s elapsed", m.completed, m.total, m.endpointsCompleted, m.endpointsTotal, m.elapsed())
+	if m.paused {
+		title = lipgloss.NewStyle().Bold(true).Foreground(theme.Current().Caution).Render("PAUSED - press r to resume")
+	} else if m.init.Commands != nil {
+		title += lipgloss.NewStyle().Faint(true).Render("  (p pause, s skip endpoint, l log, b body)")
+	} else {
+		title += lipgloss.NewStyle().Faint(true).Render("  (l log, b body)")
 	}
-	progressLine := fmt.Sprintf("%d/%d", m.completed, m.total)
-	return lipgloss.JoinVertical(lipgloss.Left,
+	details := lipgloss.JoinVertical(lipgloss.Left, bodyTitle, body)
+	if m.showLog {
+		details = m.logView()
+	}
+	if m.width < panelMinWidth {
+		// MaxWidth cuts the lines that cannot be shortened, such as the key hints; zero
+		// (size not known yet) leaves them as they are
+		return lipgloss.NewStyle().MaxWidth(m.width).Render(lipgloss.JoinVertical(lipgloss.Left,
+			banner,
+			meta,
+			paths,
+			"",
+			title,
+			m.prog.ViewAs(m.percent),
+			progressLine,
+			m.statusLine(),
+			m.countersLine(),
+			"",
+			current,
+			"",
+			details,
+		))
+	}
+	mainParts := []string{title, m.prog.ViewAs(m.percent), progressLine, m.statusLine(), "", current}
+	if !m.showLog {
+		mainParts = append(mainParts, "", details)
+	}
+	main := lipgloss.JoinVertical(lipgloss.Left, mainParts...)
+	view := lipgloss.JoinVertical(lipgloss.Left,
 		banner,
 		meta,
 		paths,
 		"",
-		title,
-		m.prog.ViewAs(m.percent),
-		progressLine,
-		"",
-		current,
-		"",
-		bodyTitle,
-		body,
+		lipgloss.JoinHorizontal(lipgloss.Top, lipgloss.NewStyle().Width(m.mainWidth()).Render(main), m.panelView()),
 	)
+	if m.showLog {
+		// The log spans the full width below the panel
+		view = lipgloss.JoinVertical(lipgloss.Left, view, "", details)
+	}
+	return view
+}
+
+// bannerMinWidth is the narrowest terminal the ASCII-art banner fits on without wrapping.
+const bannerMinWidth = 68
+
+// compact reports whether the terminal is too narrow for the ASCII-art banner, in which
+// case a single-line title is shown instead.
+func (m model) compact() bool {
+	return m.width > 0 && m.width < bannerMinWidth
+}
+
+// truncate cuts s to width terminal columns with an ellipsis; width <= 0 (size not known yet)
+// leaves it unchanged.
+func truncate(s string, width int) string {
+	if width <= 0 {
+		return s
+	}
+	return runewidth.Truncate(s, width, "…")
+}
+
+// mainWidth is the width available to the progress column, which shares the screen
+// with the verdict panel on wide terminals.
+func (m model) mainWidth() int {
+	if m.width <= 0 {
+		return 80
+	}
+	if m.width < panelMinWidth {
+		return m.width
+	}
+	return m.width - max(36, m.width/3) - 4
+}
+
+// bodyPreview renders the current request body with lines cut to the column width and,
+// unless expanded, limited to what fits below the progress section.
+func (m model) bodyPreview() string {
+	if m.lastBodyJSON == "" {
+		return "(none)"
+	}
+	lines := strings.Split(m.lastBodyJSON, "\n")
+	width := m.mainWidth()
+	var more int
+	if !m.bodyExpanded {
+		limit := 10
+		if m.height > 0 {
+			limit = max(3, m.height-bodyReservedLines)
+		}
+		if len(lines) > limit {
+			more = len(lines) - limit
+			lines = lines[:limit]
+		}
+	}
+	for i, l := range lines {
+		lines[i] = runewidth.Truncate(l, width, "…")
+	}
+	if more > 0 {
+		lines = append(lines, lipgloss.NewStyle().Faint(true).Render(fmt.Sprintf("… (+%d more lines, b to expand)", more)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func confirmView(req runner.RequestDetails) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s\n", req.Method, req.URL)
+	fmt.Fprintf(&b, "creds: %s\n", req.AuthUser)
+	names := make([]string, 0, len(req.Headers))
+	for k := range req.Headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		fmt.Fprintf(&b, "%s: %s\n", k, req.Headers[k])
+	}
+	b.WriteString("\n")
+	b.WriteString(marshalPretty(req.Body))
+	return b.String()
 }
 
 func marshalPretty(v any) string {
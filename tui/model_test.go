@@ -0,0 +1,97 @@
+package tui
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/yansol0/aperture/runner"
+	"github.com/yansol0/aperture/theme"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// sizedModel returns a model mid-run at the given terminal size, with a long spec path,
+// endpoint and request body.
+func sizedModel(width, height int) model {
+	m := newModel(ModelInit{
+		SpecPath:   "/home/tester/projects/" + strings.Repeat("nested/", 10) + "openapi.yaml",
+		ConfigPath: "aperture.yml",
+		BaseURL:    "https://api.example.com",
+	})
+	next, _ := m.Update(tea.WindowSizeMsg{Width: width, Height: height})
+	m = next.(model)
+	next, _ = m.Update(evMsg{runner.Event{
+		Kind:     runner.EventRequestPrepared,
+		Method:   "GET",
+		Total:    10,
+		Endpoint: "/organizations/{organization_id}/projects/{project_id}/environments/{environment_id}/secrets",
+		Request:  runner.RequestDetails{Body: map[string]any{"description": strings.Repeat("x", 200)}},
+	}})
+	return next.(model)
+}
+
+func TestViewFitsWidth(t *testing.T) {
+	for _, width := range []int{40, 60, 80, 120, 200} {
+		view := sizedModel(width, 50).View()
+		for i, line := range strings.Split(view, "\n") {
+			if w := lipgloss.Width(line); w > width {
+				t.Errorf("width %d: line %d is %d columns: %q", width, i+1, w, line)
+			}
+		}
+	}
+}
+
+func TestCompactBanner(t *testing.T) {
+	if view := sizedModel(60, 50).View(); !strings.Contains(view, "APERTURE - IDOR tester") {
+		t.Errorf("narrow view has no one-line title:\n%s", view)
+	}
+	if view := sizedModel(120, 50).View(); strings.Contains(view, "APERTURE - IDOR tester") {
+		t.Errorf("wide view uses the one-line title:\n%s", view)
+	}
+}
+
+func TestViewBeforeSizeKnown(t *testing.T) {
+	m := newModel(ModelInit{SpecPath: "openapi.yaml"})
+	if view := m.View(); !strings.Contains(view, "Spec: openapi.yaml") || !strings.Contains(view, "SKIPPED 0") {
+		t.Errorf("view before the first window size is cut:\n%s", view)
+	}
+}
+
+// elapsedRE matches the elapsed time in the progress line, the one part of the view that
+// depends on the clock.
+var elapsedRE = regexp.MustCompile(`\d+s elapsed`)
+
+// TestViewNoColor compares the view with color disabled against testdata/view_nocolor.golden,
+// rewriting the file with -update.
+func TestViewNoColor(t *testing.T) {
+	if err := theme.Configure(theme.ColorNever, "default"); err != nil {
+		t.Fatal(err)
+	}
+	view := sizedModel(80, 30).View()
+	if strings.Contains(view, "\x1b[") {
+		t.Errorf("view has escape sequences:\n%q", view)
+	}
+	got := []byte(elapsedRE.ReplaceAllString(view, "0s elapsed"))
+	path := filepath.Join("testdata", "view_nocolor.golden")
+	if *update {
+		if err := os.MkdirAll("testdata", 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden file (run with -update to create it): %v", err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("view differs from %s (run with -update to accept it):\n%s", path, got)
+	}
+}
@@ -0,0 +1,91 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/yansol0/aperture/runner"
+	"github.com/yansol0/aperture/theme"
+)
+
+// panelMinWidth is the terminal width below which the verdict panel collapses to one line.
+const panelMinWidth = 110
+
+// recentFindingsMax is how many of the latest findings the panel lists.
+const recentFindingsMax = 5
+
+var verdictOrder = []string{
+	runner.ResultIDORFound,
+	runner.ResultPotential,
+	runner.ResultSecure,
+	runner.ResultControlFailed,
+	runner.ResultError,
+	runner.ResultSkipped,
+}
+
+// recordResult updates the live verdict counts and recent findings from an EventResult.
+// replaces is the verdict of an earlier result this one supersedes, or "".
+func (m *model) recordResult(rl runner.ResultLog, replaces string) {
+	if m.verdicts == nil {
+		m.verdicts = map[string]int{}
+	}
+	if replaces != "" && m.verdicts[replaces] > 0 {
+		m.verdicts[replaces]--
+	}
+	m.verdicts[rl.Result]++
+	if rl.Result == runner.ResultIDORFound {
+		m.recent = append(m.recent, rl)
+		if len(m.recent) > recentFindingsMax {
+			m.recent = m.recent[len(m.recent)-recentFindingsMax:]
+		}
+	}
+}
+
+func verdictStyle(v string) lipgloss.Style {
+	return lipgloss.NewStyle().Foreground(theme.Current().Verdict(v))
+}
+
+// countersLine renders the verdict counts for narrow terminals, wrapped to the terminal width.
+func (m model) countersLine() string {
+	var lines []string
+	line, lineWidth := "", 0
+	for _, v := range verdictOrder {
+		part := fmt.Sprintf("%s %d", v, m.verdicts[v])
+		// Wrap before a count that would run past the terminal's edge
+		if lineWidth > 0 && m.width > 0 && lineWidth+2+len(part) > m.width {
+			lines = append(lines, line)
+			line, lineWidth = "", 0
+		}
+		if lineWidth > 0 {
+			line += "  "
+			lineWidth += 2
+		}
+		line += verdictStyle(v).Render(part)
+		lineWidth += len(part)
+	}
+	return strings.Join(append(lines, line), "\n")
+}
+
+// panelView renders the verdict counts and the latest findings as a side panel.
+func (m model) panelView() string {
+	var b strings.Builder
+	b.WriteString(lipgloss.NewStyle().Bold(true).Render("Verdicts") + "\n")
+	for _, v := range verdictOrder {
+		b.WriteString(verdictStyle(v).Render(fmt.Sprintf("%-15s %5d", v, m.verdicts[v])) + "\n")
+	}
+	b.WriteString("\n" + lipgloss.NewStyle().Bold(true).Render("Recent findings") + "\n")
+	if len(m.recent) == 0 {
+		b.WriteString(lipgloss.NewStyle().Faint(true).Render("(none yet)"))
+	}
+	for i := len(m.recent) - 1; i >= 0; i-- {
+		rl := m.recent[i]
+		fmt.Fprintf(&b, "%s %s\n", rl.Method, rl.Endpoint)
+		b.WriteString(lipgloss.NewStyle().Faint(true).Render(fmt.Sprintf("  creds=%s object=%s", rl.Test.Request.AuthUser, rl.Control.Request.AuthUser)) + "\n")
+	}
+	return lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		Padding(0, 1).
+		Width(max(36, m.width/3)).
+		Render(strings.TrimRight(b.String(), "\n"))
+}
@@ -0,0 +1,67 @@
+package tui
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/yansol0/aperture/runner"
+)
+
+// plainInterval is how often progress lines are printed when output is not a terminal.
+const plainInterval = 10 * time.Second
+
+// RunPlain reports progress from events as plain text until the channel is closed. With
+// live set, a single line is rewritten in place; otherwise a line is printed periodically,
+// which suits CI logs and cron mail. Adaptive concurrency changes are printed as they
+// happen. Write confirmations cannot be answered and are declined.
+func RunPlain(w io.Writer, events <-chan runner.Event, live bool) {
+	interval := plainInterval
+	if live {
+		interval = 200 * time.Millisecond
+	}
+	var (
+		started   = time.Now()
+		lastPrint time.Time
+		completed int
+		total     int
+		current   string
+	)
+	print := func(final bool) {
+		line := fmt.Sprintf("%d/%d requests  |  %s elapsed  |  %s", completed, total, time.Since(started).Truncate(time.Second), current)
+		switch {
+		case live && final:
+			fmt.Fprintf(w, "\r\033[K%s\n", line)
+		case live:
+			fmt.Fprintf(w, "\r\033[K%s", line)
+		default:
+			fmt.Fprintln(w, line)
+		}
+		lastPrint = time.Now()
+	}
+	for e := range events {
+		switch e.Kind {
+		case runner.EventTotalRequests:
+			total = e.Total
+		case runner.EventEndpointStarting:
+			current = e.Method + " " + e.Endpoint
+		case runner.EventRequestPrepared, runner.EventRequestCompleted:
+			completed, total = e.Completed, e.Total
+		case runner.EventConfirmWrite:
+			e.Reply <- runner.ConfirmSkip
+		case runner.EventConcurrency:
+			// Adjustments are rare and explain a change of pace, so each gets its own line
+			if e.Message != "" {
+				if live {
+					fmt.Fprint(w, "\r\033[K")
+				}
+				fmt.Fprintln(w, e.Message)
+			}
+		}
+		if total > 0 && time.Since(lastPrint) >= interval {
+			print(false)
+		}
+	}
+	current = "done"
+	print(true)
+}
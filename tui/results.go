@@ -0,0 +1,263 @@
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/yansol0/aperture/runner"
+	"github.com/yansol0/aperture/theme"
+)
+
+// verdictKeys maps the number keys of the results screen to the verdict they filter on.
+var verdictKeys = map[string]string{
+	"1": runner.ResultIDORFound,
+	"2": runner.ResultPotential,
+	"3": runner.ResultSecure,
+	"4": runner.ResultControlFailed,
+	"5": runner.ResultSkipped,
+	"6": runner.ResultError,
+}
+
+// resultsState is the results browser shown after the run completes.
+type resultsState struct {
+	all     []runner.ResultLog
+	visible []runner.ResultLog
+
+	filter   string // verdict to show, or "" for all
+	byPath   bool   // sort by endpoint instead of run order
+	cursor   int
+	offset   int
+	detail   bool
+	detailAt int // first line of the detail pane shown
+}
+
+func newResultsState(results []runner.ResultLog) resultsState {
+	rs := resultsState{all: results}
+	rs.refresh()
+	return rs
+}
+
+// refresh recomputes the visible rows after the filter or sort order changed.
+func (rs *resultsState) refresh() {
+	rs.visible = rs.visible[:0]
+	for _, rl := range rs.all {
+		if rs.filter == "" || rl.Result == rs.filter {
+			rs.visible = append(rs.visible, rl)
+		}
+	}
+	if rs.byPath {
+		sort.SliceStable(rs.visible, func(i, j int) bool {
+			if rs.visible[i].Endpoint != rs.visible[j].Endpoint {
+				return rs.visible[i].Endpoint < rs.visible[j].Endpoint
+			}
+			return rs.visible[i].Method < rs.visible[j].Method
+		})
+	}
+	rs.cursor, rs.offset = 0, 0
+}
+
+func (m model) updateResults(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	rs := &m.results
+	key := msg.String()
+	if rs.detail {
+		switch key {
+		case "q":
+			m.quitting = true
+			return m, tea.Quit
+		case "esc", "backspace", "enter":
+			rs.detail = false
+		case "up", "k":
+			if rs.detailAt > 0 {
+				rs.detailAt--
+			}
+		case "down", "j":
+			rs.detailAt++
+		case "pgup":
+			rs.detailAt = max(0, rs.detailAt-m.resultRows())
+		case "pgdown", " ":
+			rs.detailAt += m.resultRows()
+		case "home", "g":
+			rs.detailAt = 0
+		}
+		return m, nil
+	}
+	switch key {
+	case "q", "esc":
+		m.quitting = true
+		return m, tea.Quit
+	case "up", "k":
+		if rs.cursor > 0 {
+			rs.cursor--
+		}
+	case "down", "j":
+		if rs.cursor < len(rs.visible)-1 {
+			rs.cursor++
+		}
+	case "pgup":
+		rs.cursor = max(0, rs.cursor-m.resultRows())
+	case "pgdown", " ":
+		rs.cursor = max(0, min(len(rs.visible)-1, rs.cursor+m.resultRows()))
+	case "home", "g":
+		rs.cursor = 0
+	case "end", "G":
+		rs.cursor = max(0, len(rs.visible)-1)
+	case "s":
+		rs.byPath = !rs.byPath
+		rs.refresh()
+	case "0":
+		rs.filter = ""
+		rs.refresh()
+	case "enter":
+		if len(rs.visible) > 0 {
+			rs.detail = true
+			rs.detailAt = 0
+		}
+	default:
+		if v, ok := verdictKeys[key]; ok {
+			if rs.filter == v {
+				rs.filter = ""
+			} else {
+				rs.filter = v
+			}
+			rs.refresh()
+		}
+	}
+	// Keep the cursor within the rows that fit on screen
+	rows := m.resultRows()
+	if rs.cursor < rs.offset {
+		rs.offset = rs.cursor
+	} else if rs.cursor >= rs.offset+rows {
+		rs.offset = rs.cursor - rows + 1
+	}
+	return m, nil
+}
+
+// resultRows is the number of table rows that fit on screen.
+func (m model) resultRows() int {
+	if m.height <= 0 {
+		return 20
+	}
+	return max(3, m.height-6)
+}
+
+func (m model) viewResults() string {
+	rs := m.results
+	if rs.detail && rs.cursor < len(rs.visible) {
+		return m.viewResultDetail(rs.visible[rs.cursor])
+	}
+
+	counts := map[string]int{}
+	for _, rl := range rs.all {
+		counts[rl.Result]++
+	}
+	var tabs []string
+	for _, k := range []string{"1", "2", "3", "4", "5", "6"} {
+		v := verdictKeys[k]
+		tab := fmt.Sprintf("[%s] %s (%d)", k, v, counts[v])
+		if rs.filter == v {
+			tab = lipgloss.NewStyle().Bold(true).Underline(true).Render(tab)
+		}
+		tabs = append(tabs, tab)
+	}
+	order := "run order"
+	if rs.byPath {
+		order = "endpoint"
+	}
+	header := lipgloss.NewStyle().Bold(true).Render(fmt.Sprintf("Results: %d of %d shown, sorted by %s", len(rs.visible), len(rs.all), order))
+
+	var lines []string
+	end := min(len(rs.visible), rs.offset+m.resultRows())
+	for i := rs.offset; i < end; i++ {
+		rl := rs.visible[i]
+		verdict := lipgloss.NewStyle().Foreground(theme.Current().Verdict(rl.Result)).Render(fmt.Sprintf("%-14s", rl.Result))
+		line := fmt.Sprintf("%s %-7s %s  creds=%s object=%s", verdict, rl.Method, rl.Endpoint, rl.Test.Request.AuthUser, rl.Control.Request.AuthUser)
+		if i == rs.cursor {
+			line = lipgloss.NewStyle().Reverse(true).Render(">") + " " + line
+		} else {
+			line = "  " + line
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "  (no results)")
+	}
+	help := lipgloss.NewStyle().Faint(true).Render("↑/↓ move  pgup/pgdn page  g/G first/last  enter details  1-6 filter  0 all  s sort  q quit and write results")
+	return lipgloss.JoinVertical(lipgloss.Left,
+		header,
+		strings.Join(tabs, "  "),
+		"",
+		strings.Join(lines, "\n"),
+		"",
+		help,
+	)
+}
+
+func (m model) viewResultDetail(rl runner.ResultLog) string {
+	colWidth := 60
+	if m.width > 0 {
+		colWidth = max(30, (m.width-4)/2)
+	}
+	col := lipgloss.NewStyle().Width(colWidth).MarginRight(2)
+	title := lipgloss.NewStyle().Bold(true).Foreground(theme.Current().Verdict(rl.Result)).Render(fmt.Sprintf("%s %s  %s", rl.Method, rl.Endpoint, rl.Result))
+	exchanges := lipgloss.JoinHorizontal(lipgloss.Top,
+		col.Render(exchangeView("Control", rl.Control)),
+		col.Render(exchangeView("Test", rl.Test)),
+	)
+	var notes []string
+	if rl.SkippedReason != "" {
+		notes = append(notes, "skipped: "+rl.SkippedReason)
+	}
+	for _, n := range rl.Notes {
+		notes = append(notes, "- "+n)
+	}
+	if len(notes) == 0 {
+		notes = append(notes, "(no notes)")
+	}
+	body := lipgloss.JoinVertical(lipgloss.Left,
+		title,
+		fmt.Sprintf("confidence %.2f", rl.Confidence),
+		"",
+		exchanges,
+		"",
+		lipgloss.NewStyle().Bold(true).Render("Notes"),
+		strings.Join(notes, "\n"),
+	)
+
+	// Scroll the pane when it is taller than the terminal
+	all := strings.Split(body, "\n")
+	start := min(m.results.detailAt, max(0, len(all)-1))
+	if m.height > 0 && len(all)-start > m.height-2 {
+		all = all[start : start+m.height-2]
+	} else {
+		all = all[start:]
+	}
+	help := lipgloss.NewStyle().Faint(true).Render("↑/↓ scroll  pgup/pgdn page  esc back  q quit and write results")
+	return strings.Join(all, "\n") + "\n" + help
+}
+
+func exchangeView(label string, ex runner.Exchange) string {
+	var b strings.Builder
+	b.WriteString(lipgloss.NewStyle().Bold(true).Render(label) + "\n")
+	if ex.Request.Method == "" {
+		b.WriteString("(not sent)\n")
+		return b.String()
+	}
+	fmt.Fprintf(&b, "%s %s\n", ex.Request.Method, ex.Request.URL)
+	fmt.Fprintf(&b, "creds: %s\n", ex.Request.AuthUser)
+	if ex.Request.Body != nil {
+		b.WriteString(marshalPretty(ex.Request.Body) + "\n")
+	}
+	fmt.Fprintf(&b, "\nstatus %d (%d ms)\n", ex.Response.Status, ex.Response.DurationMs)
+	b.WriteString(ex.Response.FullBody())
+	return b.String()
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}